@@ -0,0 +1,387 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "context"
+
+// An Action represents an action to be performed, such as following
+// a link or launching an application. See PDF 32000-1:2008, §12.6.
+// The methods interpret an action dictionary stored in V.
+type Action struct {
+	V Value
+}
+
+// Type returns the action type (the S entry), such as "GoTo", "GoToR",
+// "Launch", or "URI".
+func (a Action) Type() (string, error) {
+	s, err := a.V.Key("S")
+	if err != nil {
+		return "", err
+	}
+	return s.Name(), nil
+}
+
+// File returns the path of the file targeted by a GoToR, GoToE, or Launch
+// action, or the URL or file of a SubmitForm or ImportData action,
+// taken from its file specification. For Launch actions that use
+// platform-specific parameters instead of F, the Windows, Unix, and Mac
+// entries are consulted in that order.
+// File returns the empty string for other action types.
+func (a Action) File() (string, error) {
+	f, err := a.V.Key("F")
+	if err != nil {
+		return "", err
+	}
+	if !f.IsNull() {
+		return fileSpecName(f)
+	}
+	typ, err := a.Type()
+	if err != nil || typ != "Launch" {
+		return "", err
+	}
+	for _, key := range []string{"Win", "Unix", "Mac"} {
+		p, err := a.V.Key(key)
+		if err != nil {
+			return "", err
+		}
+		switch p.Kind() {
+		case Dict:
+			// The Windows launch parameters name the file in F.
+			f, err := p.Key("F")
+			if err != nil {
+				return "", err
+			}
+			if name := f.Text(); name != "" {
+				return name, nil
+			}
+		case String:
+			if name := p.Text(); name != "" {
+				return name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Dest returns the destination of a GoTo or GoToR action (the D entry).
+// For GoToR actions the destination refers to a page in the remote file,
+// so page references are page numbers rather than page objects.
+// The destination is either an explicit destination array or a name
+// or string naming a destination.
+func (a Action) Dest() (Value, error) {
+	return a.V.Key("D")
+}
+
+// URI returns the uniform resource identifier of a URI action (the URI
+// entry), or the empty string for other action types.
+func (a Action) URI() (string, error) {
+	uri, err := a.V.Key("URI")
+	if err != nil {
+		return "", err
+	}
+	// URIs are 7-bit ASCII.
+	return uri.RawString(), nil
+}
+
+// JavaScript returns the script of a JavaScript action (the JS entry),
+// which is stored as a text string or a stream, or the empty string
+// for other action types.
+func (a Action) JavaScript() (string, error) {
+	js, err := a.V.Key("JS")
+	if err != nil {
+		return "", err
+	}
+	if js.Kind() == Stream {
+		data, err := streamData(js)
+		if err != nil {
+			return "", err
+		}
+		js = NewString(string(data))
+	}
+	return js.Text(), nil
+}
+
+// NewWindow reports whether a GoToR or Launch action asks for
+// the target to be opened in a new window.
+func (a Action) NewWindow() (bool, error) {
+	nw, err := a.V.Key("NewWindow")
+	if err != nil {
+		return false, err
+	}
+	return nw.Bool(), nil
+}
+
+// Next returns the actions to be performed after this one.
+func (a Action) Next() ([]Action, error) {
+	next, err := a.V.Key("Next")
+	if err != nil {
+		return nil, err
+	}
+	switch next.Kind() {
+	case Dict:
+		return []Action{{next}}, nil
+	case Array:
+		var out []Action
+		for i := 0; i < next.Len(); i++ {
+			x, err := next.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			if x.Kind() == Dict {
+				out = append(out, Action{x})
+			}
+		}
+		return out, nil
+	}
+	return nil, nil
+}
+
+// An ActionUse is an action found in a document by Reader.Actions,
+// with where it is found and the event that performs it.
+type ActionUse struct {
+	Action Action
+	In     string // what holds the action: "Document", "Page", "Annotation", "Field", or "Outline"
+	Page   int    // for a page or annotation, the page number, starting at 1; otherwise 0
+	Name   string // the name of a document-level script, the fully qualified name of a field, or the title of an outline item
+
+	// Trigger is the event that performs the action: "Open" for the
+	// document's OpenAction, "Script" for a document-level script, run
+	// when the document is opened, "Activate" for the A entry of an
+	// annotation or outline item, and otherwise the key of the
+	// additional-actions (AA) dictionary holding the action, such as
+	// "WC" (the document will close), "O" (the page is opened),
+	// "E" (the pointer enters the annotation), or "K" (a keystroke
+	// changes the field).
+	Trigger string
+}
+
+// Actions returns the actions in the document, for uses such as
+// scanning documents for scripts and actions that launch applications,
+// open URIs, or submit forms: the document's OpenAction, its
+// document-level JavaScript, the additional actions of the document,
+// its pages, annotations, and form fields, and the actions of
+// annotations and outline items. The actions that follow an action
+// (see Action.Next) are returned after it, with the same location.
+// Each action is returned once.
+func (r *Reader) Actions(ctx context.Context) ([]ActionUse, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, err
+	}
+	var out []ActionUse
+	seen := make(map[objptr]bool)
+	// add adds the action a, if it is one, and those following it.
+	var add func(a Value, use ActionUse) error
+	add = func(a Value, use ActionUse) error {
+		if a.Kind() != Dict {
+			return nil
+		}
+		if isIndirect(a) {
+			if seen[a.ptr] {
+				return nil
+			}
+			seen[a.ptr] = true
+		}
+		use.Action = Action{a}
+		out = append(out, use)
+		next, err := a.Key("Next")
+		if err != nil {
+			return err
+		}
+		if next.Kind() == Dict {
+			return add(next, use)
+		}
+		for i := 0; i < next.Len(); i++ {
+			x, err := next.Index(i)
+			if err != nil {
+				return err
+			}
+			if err := add(x, use); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// addAA adds the additional actions of v.
+	addAA := func(v Value, use ActionUse) error {
+		aa, err := v.Key("AA")
+		if err != nil {
+			return err
+		}
+		for _, key := range aa.Keys() {
+			a, err := aa.Key(key)
+			if err != nil {
+				return err
+			}
+			use.Trigger = key
+			if err := add(a, use); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// An OpenAction may instead be a destination array.
+	open, err := root.Key("OpenAction")
+	if err != nil {
+		return nil, err
+	}
+	if err := add(open, ActionUse{In: "Document", Trigger: "Open"}); err != nil {
+		return nil, err
+	}
+	names, err := root.Key("Names")
+	if err != nil {
+		return nil, err
+	}
+	scripts, err := names.Key("JavaScript")
+	if err != nil {
+		return nil, err
+	}
+	err = walkNameTree(scripts, func(name string, a Value) error {
+		return add(a, ActionUse{In: "Document", Name: name, Trigger: "Script"})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := addAA(root, ActionUse{In: "Document"}); err != nil {
+		return nil, err
+	}
+
+	annots := make(map[objptr]bool)
+	err = r.walkPages(ctx, func(num int, p Page) error {
+		if err := addAA(p.V, ActionUse{In: "Page", Page: num}); err != nil {
+			return err
+		}
+		list, err := p.V.Key("Annots")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < list.Len(); i++ {
+			v, err := list.Index(i)
+			if err != nil {
+				return err
+			}
+			if v.Kind() != Dict {
+				continue
+			}
+			if isIndirect(v) {
+				annots[v.ptr] = true
+			}
+			use := ActionUse{In: "Annotation", Page: num}
+			a, err := v.Key("A")
+			if err != nil {
+				return err
+			}
+			use.Trigger = "Activate"
+			if err := add(a, use); err != nil {
+				return err
+			}
+			if err := addAA(v, use); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields merged with the widget annotations on pages have been
+	// visited as annotations.
+	form, err := root.Key("AcroForm")
+	if err != nil {
+		return nil, err
+	}
+	fields, err := form.Key("Fields")
+	if err != nil {
+		return nil, err
+	}
+	visited := make(map[objptr]bool)
+	var walkFields func(fields Value) error
+	walkFields = func(fields Value) error {
+		for i := 0; i < fields.Len(); i++ {
+			f, err := fields.Index(i)
+			if err != nil {
+				return err
+			}
+			if f.Kind() != Dict {
+				continue
+			}
+			indirect := isIndirect(f)
+			if indirect {
+				if visited[f.ptr] {
+					continue
+				}
+				visited[f.ptr] = true
+			}
+			if !indirect || !annots[f.ptr] {
+				name, err := Field{f}.Name()
+				if err != nil {
+					return err
+				}
+				if err := addAA(f, ActionUse{In: "Field", Name: name}); err != nil {
+					return err
+				}
+			}
+			kids, err := f.Key("Kids")
+			if err != nil {
+				return err
+			}
+			if err := walkFields(kids); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walkFields(fields); err != nil {
+		return nil, err
+	}
+
+	outlines, err := root.Key("Outlines")
+	if err != nil {
+		return nil, err
+	}
+	item, err := outlines.Key("First")
+	if err != nil {
+		return nil, err
+	}
+	var walkOutline func(item Value) error
+	walkOutline = func(item Value) error {
+		for item.Kind() == Dict {
+			if isIndirect(item) {
+				if visited[item.ptr] {
+					break
+				}
+				visited[item.ptr] = true
+			}
+			title, err := item.Key("Title")
+			if err != nil {
+				return err
+			}
+			a, err := item.Key("A")
+			if err != nil {
+				return err
+			}
+			if err := add(a, ActionUse{In: "Outline", Name: title.Text(), Trigger: "Activate"}); err != nil {
+				return err
+			}
+			first, err := item.Key("First")
+			if err != nil {
+				return err
+			}
+			if err := walkOutline(first); err != nil {
+				return err
+			}
+			if item, err = item.Key("Next"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walkOutline(item); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
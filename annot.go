@@ -0,0 +1,466 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// An Annotation is an annotation on a page, such as a link, a note,
+// a highlight, or a form field's widget.
+// The fields hold the entries common to all annotations; the methods
+// interpret the entries of particular types of annotations.
+// See PDF 32000-1:2008, §12.5.
+type Annotation struct {
+	V               Value
+	Subtype         string // Link, Text, Highlight, Widget, FreeText, Stamp, and so on
+	Rect            Rect   // location on the page, in default user space, or displayed space if TextOptions.Displayed is set
+	Contents        string // text displayed for the annotation, or its alternate description
+	Name            string // name uniquely identifying the annotation on its page (NM)
+	Modified        string // date and time last modified (M), as written
+	Flags           AnnotationFlags
+	Color           []float64 // color components (C): none for transparent, or 1, 3, or 4 for gray, RGB, or CMYK
+	AppearanceState string    // the annotation's state (AS), choosing among its appearances
+
+	// Markup annotations, such as notes and highlights, also have:
+	Author  string // the author, usually the user who added the annotation (T)
+	Subject string // the subject (Subj)
+
+	disp Matrix // the transformation to the space of Rect, if not Identity
+}
+
+// AnnotationFlags are the flags of an annotation (its F entry).
+// See PDF 32000-1:2008, §12.5.3.
+type AnnotationFlags uint32
+
+const (
+	AnnotInvisible      AnnotationFlags = 1 << 0 // do not show the annotation if its type is unknown
+	AnnotHidden         AnnotationFlags = 1 << 1 // neither show nor print the annotation
+	AnnotPrint          AnnotationFlags = 1 << 2 // print the annotation
+	AnnotNoZoom         AnnotationFlags = 1 << 3 // do not scale the appearance with the page
+	AnnotNoRotate       AnnotationFlags = 1 << 4 // do not rotate the appearance with the page
+	AnnotNoView         AnnotationFlags = 1 << 5 // do not show the annotation on screen
+	AnnotReadOnly       AnnotationFlags = 1 << 6 // do not let the user interact with the annotation
+	AnnotLocked         AnnotationFlags = 1 << 7 // do not let the user delete or change the annotation's properties
+	AnnotToggleNoView   AnnotationFlags = 1 << 8 // invert AnnotNoView on certain events
+	AnnotLockedContents AnnotationFlags = 1 << 9 // do not let the user change the annotation's contents
+)
+
+// Annotations returns the annotations of the page, in the order
+// of its Annots array. If the Reader's TextOptions.Displayed is set,
+// their positions (Rect, QuadPoints, and the Rect of their Popup) are
+// in the page's displayed space (see Page.Transform), the space of the
+// text returned by Content, rather than in default user space.
+func (p Page) Annotations() ([]Annotation, error) {
+	annots, err := p.V.Key("Annots")
+	if err != nil {
+		return nil, err
+	}
+	disp := Identity
+	if p.textOptions().Displayed {
+		if disp, err = p.Transform(); err != nil {
+			return nil, err
+		}
+	}
+	var out []Annotation
+	for i := 0; i < annots.Len(); i++ {
+		v, err := annots.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		if v.Kind() != Dict {
+			continue
+		}
+		a, err := newAnnotation(v, disp)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// newAnnotation returns the annotation v, with its positions
+// transformed by disp.
+func newAnnotation(v Value, disp Matrix) (Annotation, error) {
+	a := Annotation{V: v, disp: disp}
+	for _, x := range []struct {
+		key string
+		dst *string
+	}{
+		{"Contents", &a.Contents},
+		{"NM", &a.Name},
+		{"M", &a.Modified},
+		{"T", &a.Author},
+		{"Subj", &a.Subject},
+	} {
+		s, err := v.Key(x.key)
+		if err != nil {
+			return Annotation{}, err
+		}
+		*x.dst = s.Text()
+	}
+	subtype, err := v.Key("Subtype")
+	if err != nil {
+		return Annotation{}, err
+	}
+	a.Subtype = subtype.Name()
+	if a.Subtype == "Widget" {
+		// In a widget merged with its field, T is the field name.
+		a.Author = ""
+	}
+	rect, err := v.Key("Rect")
+	if err != nil {
+		return Annotation{}, err
+	}
+	if a.Rect, err = rectFromArray(rect); err != nil {
+		return Annotation{}, err
+	}
+	a.Rect = disp.ApplyRect(a.Rect)
+	f, err := v.Key("F")
+	if err != nil {
+		return Annotation{}, err
+	}
+	a.Flags = AnnotationFlags(uint32(f.Int64()))
+	as, err := v.Key("AS")
+	if err != nil {
+		return Annotation{}, err
+	}
+	a.AppearanceState = as.Name()
+	c, err := v.Key("C")
+	if err != nil {
+		return Annotation{}, err
+	}
+	if a.Color, err = numberArray(c); err != nil {
+		return Annotation{}, err
+	}
+	return a, nil
+}
+
+// numberArray returns the numbers in the array v.
+func numberArray(v Value) ([]float64, error) {
+	var out []float64
+	for i := 0; i < v.Len(); i++ {
+		x, err := v.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, x.Float64())
+	}
+	return out, nil
+}
+
+// Appearance returns the appearance stream used to draw the annotation:
+// its normal appearance for which "N", its rollover appearance for "R",
+// or its down appearance for "D". If the appearance has subdictionaries
+// for different states, Appearance returns the one for the annotation's
+// AppearanceState. It returns a null Value if there is no such appearance.
+// See PDF 32000-1:2008, §12.5.5.
+func (a Annotation) Appearance(which string) (Value, error) {
+	ap, err := a.V.Key("AP")
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := ap.Key(which)
+	if err != nil {
+		return Value{}, err
+	}
+	if v.Kind() == Dict {
+		if a.AppearanceState == "" {
+			return Value{}, nil
+		}
+		if v, err = v.Key(a.AppearanceState); err != nil {
+			return Value{}, err
+		}
+	}
+	if v.Kind() != Stream {
+		return Value{}, nil
+	}
+	return v, nil
+}
+
+// Action returns the action performed when a Link, Widget, or Screen
+// annotation is activated (the A entry). If there is none, the Action's
+// V is null.
+func (a Annotation) Action() (Action, error) {
+	v, err := a.V.Key("A")
+	if err != nil {
+		return Action{}, err
+	}
+	return Action{v}, nil
+}
+
+// Dest returns the destination of a Link annotation (the Dest entry):
+// an explicit destination array or a name or string naming one.
+// Links that perform an action have no Dest; see Action.
+func (a Annotation) Dest() (Value, error) {
+	return a.V.Key("Dest")
+}
+
+// URI returns the URI of a Link annotation whose action opens one,
+// or the empty string.
+func (a Annotation) URI() (string, error) {
+	action, err := a.Action()
+	if err != nil {
+		return "", err
+	}
+	typ, err := action.Type()
+	if err != nil || typ != "URI" {
+		return "", err
+	}
+	uri, err := action.V.Key("URI")
+	if err != nil {
+		return "", err
+	}
+	return uri.RawString(), nil
+}
+
+// A Quad is a quadrilateral: four points, in the order given by
+// a QuadPoints array.
+type Quad [4]Point
+
+// QuadPoints returns the quadrilaterals covered by a Highlight, Underline,
+// Squiggly, StrikeOut, or Link annotation (its QuadPoints entry).
+// Each usually encloses a word or a run of text, with its first two
+// points along the top edge and the other two along the bottom.
+// The points are in the same space as the annotation's Rect.
+func (a Annotation) QuadPoints() ([]Quad, error) {
+	qp, err := a.V.Key("QuadPoints")
+	if err != nil {
+		return nil, err
+	}
+	nums, err := numberArray(qp)
+	if err != nil {
+		return nil, err
+	}
+	var out []Quad
+	for ; len(nums) >= 8; nums = nums[8:] {
+		var q Quad
+		for i := range q {
+			q[i] = a.transform().Apply(Point{nums[2*i], nums[2*i+1]})
+		}
+		out = append(out, q)
+	}
+	return out, nil
+}
+
+// Icon returns the name of the icon of a Text or Stamp annotation
+// (its Name entry), such as "Comment" or "Approved".
+func (a Annotation) Icon() (string, error) {
+	name, err := a.V.Key("Name")
+	if err != nil {
+		return "", err
+	}
+	return name.Name(), nil
+}
+
+// Popup returns the Popup annotation in which a markup annotation's
+// text is shown and edited. If there is none, the Annotation's V is null.
+func (a Annotation) Popup() (Annotation, error) {
+	v, err := a.V.Key("Popup")
+	if err != nil || v.Kind() != Dict {
+		return Annotation{}, err
+	}
+	return newAnnotation(v, a.transform())
+}
+
+// transform returns the transformation from default user space
+// to the space of the annotation's Rect.
+func (a Annotation) transform() Matrix {
+	if a.disp == (Matrix{}) {
+		return Identity
+	}
+	return a.disp
+}
+
+// Field returns the form field of a Widget annotation: the widget
+// itself if it is merged with its field, or its parent otherwise.
+func (a Annotation) Field() (Field, error) {
+	t, err := a.V.Key("T")
+	if err != nil {
+		return Field{}, err
+	}
+	ft, err := a.V.Key("FT")
+	if err != nil {
+		return Field{}, err
+	}
+	if !t.IsNull() || !ft.IsNull() {
+		return Field{a.V}, nil
+	}
+	parent, err := a.V.Key("Parent")
+	if err != nil {
+		return Field{}, err
+	}
+	return Field{parent}, nil
+}
+
+// A NewAnnotation describes an annotation for AddAnnotations to add.
+type NewAnnotation struct {
+	// Subtype is the kind of annotation: "Link" for a link to URI,
+	// "Highlight" for a highlight over Quads, or "Text" for a note
+	// (a "sticky note") showing Contents.
+	Subtype string
+
+	// Rect is the location of the annotation on the page, in default
+	// user space, regardless of TextOptions.Displayed. It may be
+	// left zero for a Highlight, to enclose its Quads. For a Text
+	// annotation, only Rect.Min is needed: it places the note's icon.
+	Rect Rect
+
+	URI      string    // for a Link, the URI to open
+	Quads    []Quad    // for a Highlight, the text to highlight, as for Annotation.QuadPoints
+	Contents string    // the text of a note or comment on a highlight
+	Author   string    // the author of a Highlight or Text annotation
+	Color    []float64 // the color, as RGB; if nil, the highlight or note is yellow
+	Open     bool      // for a Text annotation, show its text initially
+}
+
+// AddAnnotations adds annotations to the page p, read from the Reader
+// being updated by w (see Reader.Update), and writes the updated page.
+// Highlights and notes are given appearance streams; links are given
+// no border and no appearance, as is usual.
+// Since the page is written once, all the annotations to add to a page
+// must be added in one call.
+func (w *Writer) AddAnnotations(p Page, annots ...NewAnnotation) error {
+	if w.update == nil || p.V.r != w.update || !isIndirect(p.V) {
+		return errors.New("pdf: AddAnnotations requires a page of the Reader being updated")
+	}
+	old, err := p.V.Key("Annots")
+	if err != nil {
+		return err
+	}
+	var refs []Value
+	for i := 0; i < old.Len(); i++ {
+		// Keep the references to the existing annotations.
+		refs = append(refs, Value{old.r, old.ptr, old.data.(array)[i]})
+	}
+	for _, a := range annots {
+		v, err := newAnnotationDict(p, a)
+		if err != nil {
+			return err
+		}
+		ref, err := w.Add(v)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+	}
+	entries, err := dictEntries(p.V)
+	if err != nil {
+		return err
+	}
+	entries["Annots"] = NewArray(refs...)
+	return w.Write(p.V, NewDict(entries))
+}
+
+// newAnnotationDict returns the annotation dictionary described by a,
+// for the page p.
+func newAnnotationDict(p Page, a NewAnnotation) (Value, error) {
+	color := a.Color
+	if color == nil {
+		color = []float64{1, 1, 0}
+		if a.Subtype == "Text" {
+			color = []float64{1, 0.82, 0}
+		}
+	}
+	if len(color) != 3 {
+		return Value{}, fmt.Errorf("pdf: annotation color has %d components, not 3", len(color))
+	}
+	entries := map[string]Value{
+		"Type":    NewName("Annot"),
+		"Subtype": NewName(a.Subtype),
+		"P":       p.V,
+		"F":       NewInt(int64(AnnotPrint)),
+	}
+	if a.Contents != "" {
+		entries["Contents"] = NewText(a.Contents)
+	}
+	if a.Author != "" {
+		entries["T"] = NewText(a.Author)
+	}
+	rect := a.Rect
+	var ap bytes.Buffer
+	var resources Value
+	switch a.Subtype {
+	default:
+		return Value{}, fmt.Errorf("pdf: cannot create %s annotation", a.Subtype)
+
+	case "Link":
+		entries["A"] = NewDict(map[string]Value{
+			"S":   NewName("URI"),
+			"URI": NewString(a.URI),
+		})
+		entries["Border"] = NewArray(NewInt(0), NewInt(0), NewInt(0))
+
+	case "Highlight":
+		if len(a.Quads) == 0 {
+			return Value{}, errors.New("pdf: Highlight annotation without Quads")
+		}
+		var nums []Value
+		bounds := Rect{a.Quads[0][0], a.Quads[0][0]}
+		for _, q := range a.Quads {
+			for _, pt := range q {
+				nums = append(nums, NewReal(pt.X), NewReal(pt.Y))
+				bounds = unionRect(bounds, Rect{pt, pt})
+			}
+		}
+		entries["QuadPoints"] = NewArray(nums...)
+		if rect == (Rect{}) {
+			rect = bounds
+		}
+		// Fill each quadrilateral, blending so that the text shows through.
+		fmt.Fprintf(&ap, "/GS0 gs %g %g %g rg\n", float32(color[0]), float32(color[1]), float32(color[2]))
+		for _, q := range a.Quads {
+			// The points run along the top edge, then the bottom edge.
+			for i, j := range []int{0, 1, 3, 2} {
+				op := "l"
+				if i == 0 {
+					op = "m"
+				}
+				fmt.Fprintf(&ap, "%g %g %s ", float32(q[j].X), float32(q[j].Y), op)
+			}
+			ap.WriteString("h f\n")
+		}
+		resources = NewDict(map[string]Value{
+			"ExtGState": NewDict(map[string]Value{
+				"GS0": NewDict(map[string]Value{
+					"Type": NewName("ExtGState"),
+					"BM":   NewName("Multiply"),
+				}),
+			}),
+		})
+
+	case "Text":
+		rect = Rect{rect.Min, Point{rect.Min.X + 20, rect.Min.Y + 20}}
+		entries["Name"] = NewName("Note")
+		entries["Open"] = NewBool(a.Open)
+		entries["F"] = NewInt(int64(AnnotPrint | AnnotNoZoom | AnnotNoRotate))
+		// A sheet of paper with lines of text.
+		x, y := rect.Min.X, rect.Min.Y
+		fmt.Fprintf(&ap, "%g %g %g rg 0 G 0.5 w\n", float32(color[0]), float32(color[1]), float32(color[2]))
+		fmt.Fprintf(&ap, "%g %g 19 19 re B\n", float32(x+0.5), float32(y+0.5))
+		for i := 0; i < 4; i++ {
+			ly := y + 15 - 3.5*float64(i)
+			fmt.Fprintf(&ap, "%g %g m %g %g l S\n", float32(x+4), float32(ly), float32(x+16), float32(ly))
+		}
+	}
+	if a.Subtype != "Link" {
+		entries["C"] = NewArray(NewReal(color[0]), NewReal(color[1]), NewReal(color[2]))
+	}
+	entries["Rect"] = NewArray(NewReal(rect.Min.X), NewReal(rect.Min.Y), NewReal(rect.Max.X), NewReal(rect.Max.Y))
+	if ap.Len() > 0 {
+		// The appearance is drawn in page space, with its BBox the Rect.
+		hdr := map[string]Value{
+			"Type":      NewName("XObject"),
+			"Subtype":   NewName("Form"),
+			"BBox":      entries["Rect"],
+			"Resources": resources,
+		}
+		entries["AP"] = NewDict(map[string]Value{"N": NewStream(NewDict(hdr), ap.Bytes())})
+	}
+	return NewDict(entries), nil
+}
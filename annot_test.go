@@ -0,0 +1,80 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAnnotationDisplayed(t *testing.T) {
+	ctx := context.Background()
+
+	// A link over the text of a page rotated by 90 degrees.
+	objs := pageTree(1)
+	objs[2] = strings.Replace(objs[2], "/Type /Page", "/Type /Page /Rotate 90 /Annots [5 0 R]", 1)
+	objs[2] = strings.Replace(objs[2], "[5 0 R]", "[5 0 R 6 0 R]", 1)
+	objs = append(objs,
+		"<< /Type /Annot /Subtype /Link /Rect [70 715 120 735] >>",
+		"<< /Type /Annot /Subtype /Highlight /Rect [70 715 120 735] /QuadPoints [70 735 120 735 70 715 120 715] /Popup 7 0 R >>",
+		"<< /Type /Annot /Subtype /Popup /Rect [200 600 300 700] >>")
+
+	for _, displayed := range []bool{false, true} {
+		r := openPDF(t, buildPDF(objs...), nil)
+		r.TextOptions.Displayed = displayed
+		p, err := r.Page(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := p.Content(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		annots, err := p.Annotations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Text) == 0 || len(annots) != 2 {
+			t.Fatalf("displayed=%v: got %d texts and %d annotations, want some and 2", displayed, len(c.Text), len(annots))
+		}
+		want := Rect{Point{70, 715}, Point{120, 735}}
+		if displayed {
+			want = Rect{Point{715, 492}, Point{735, 542}}
+		}
+		rect := annots[0].Rect
+		if rect != want {
+			t.Errorf("displayed=%v: Rect = %v, want %v", displayed, rect, want)
+		}
+		text := c.Text[0]
+		if text.X < rect.Min.X || text.X > rect.Max.X || text.Y < rect.Min.Y || text.Y > rect.Max.Y {
+			t.Errorf("displayed=%v: text at (%g, %g) is outside the link's Rect %v", displayed, text.X, text.Y, rect)
+		}
+
+		// The highlight's points and popup are in the same space.
+		quads, err := annots[1].QuadPoints()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantQuad := Quad{{70, 735}, {120, 735}, {70, 715}, {120, 715}}
+		if displayed {
+			wantQuad = Quad{{735, 542}, {735, 492}, {715, 542}, {715, 492}}
+		}
+		if len(quads) != 1 || quads[0] != wantQuad {
+			t.Errorf("displayed=%v: QuadPoints = %v, want [%v]", displayed, quads, wantQuad)
+		}
+		popup, err := annots[1].Popup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantPopup := Rect{Point{200, 600}, Point{300, 700}}
+		if displayed {
+			wantPopup = Rect{Point{600, 312}, Point{700, 412}}
+		}
+		if popup.Rect != wantPopup {
+			t.Errorf("displayed=%v: popup Rect = %v, want %v", displayed, popup.Rect, wantPopup)
+		}
+	}
+}
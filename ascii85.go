@@ -1,53 +1,112 @@
-// file with help function for ascii85 decoder
-// later if new decoders is going to add it reasonable to rename file and add them here
-// also create interfaces to switch between them (like in unidoc)
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
 
 package pdf
 
 import (
+	"bufio"
+	"encoding/ascii85"
+	"fmt"
 	"io"
 )
 
-type alphaReader struct {
-	reader io.Reader
-}
+// The ASCII filters, ASCIIHexDecode and ASCII85Decode.
+// See PDF 32000-1:2008, §7.4.2 and §7.4.3.
 
-func newAlphaReader(reader io.Reader) *alphaReader {
-	return &alphaReader{reader: reader}
+// An eodReader returns the bytes read from r, without white space,
+// up to the end-of-data marker eod.
+type eodReader struct {
+	r    *bufio.Reader
+	eod  byte
+	done bool
 }
 
-func checkASCII85(r byte) byte {
-	if r >= '!' && r <= 'u' { // 33 <= ascii85 <=117
-		return r
+func (e *eodReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && !e.done {
+		c, err := e.r.ReadByte()
+		if err == io.EOF {
+			// A missing end-of-data marker ends the data anyway.
+			e.done = true
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		switch {
+		case c == e.eod:
+			e.done = true
+		case isSpace(c):
+		default:
+			p[n] = c
+			n++
+		}
 	}
-	if r == '~' {
-		return 1 // for marking possible end of data
+	if n == 0 && e.done {
+		return 0, io.EOF
 	}
-	return 0 // if non-ascii85
+	return n, nil
 }
 
-func (a *alphaReader) Read(p []byte) (int, error) {
-	n, err := a.reader.Read(p)
-	if err == io.EOF {
-	}
-	if err != nil {
-		return n, err
-	}
-	buf := make([]byte, n)
-	tilda := false
-	for i := 0; i < n; i++ {
-		char := checkASCII85(p[i])
-		if char == '>' && tilda { // end of data
+// newASCII85Reader returns a reader decoding the ASCII base-85 data read from rd.
+func newASCII85Reader(rd io.Reader) io.Reader {
+	br := bufio.NewReader(rd)
+	// Some producers include the <~ that begins ASCII85 data in PostScript.
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
 			break
 		}
-		if char > 1 {
-			buf[i] = char
+		if isSpace(c) {
+			continue
 		}
-		if char == 1 {
-			tilda = true // possible end of data
+		if c == '<' {
+			if next, err := br.Peek(1); err == nil && next[0] == '~' {
+				br.ReadByte()
+				break
+			}
 		}
+		br.UnreadByte()
+		break
 	}
+	// The data ends with ~>; a ~ cannot appear elsewhere.
+	return ascii85.NewDecoder(&eodReader{r: br, eod: '~'})
+}
 
-	copy(p, buf)
+// An asciiHexReader decodes ASCIIHexDecode data.
+type asciiHexReader struct {
+	r *eodReader
+}
+
+func newASCIIHexReader(rd io.Reader) *asciiHexReader {
+	return &asciiHexReader{&eodReader{r: bufio.NewReader(rd), eod: '>'}}
+}
+
+func (h *asciiHexReader) Read(p []byte) (int, error) {
+	var buf [2]byte
+	n := 0
+	for n < len(p) {
+		m, err := io.ReadFull(h.r, buf[:])
+		if m == 0 {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, io.EOF
+		}
+		if m == 1 {
+			// An odd number of digits: the last is followed by an implied 0.
+			buf[1] = '0'
+		}
+		hi, lo := unhex(buf[0]), unhex(buf[1])
+		if hi < 0 || lo < 0 {
+			return n, fmt.Errorf("ASCIIHexDecode: invalid hex digit in %q", buf[:m])
+		}
+		p[n] = byte(hi<<4 | lo)
+		n++
+		if err != nil {
+			break
+		}
+	}
 	return n, nil
 }
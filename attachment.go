@@ -0,0 +1,401 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/md5"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// An AssociatedFile is a file associated with the document or one of
+// its pages through an AF array, as used by PDF/A-3 and PDF 2.0.
+// E-invoicing formats such as ZUGFeRD and Factur-X carry their
+// invoice XML this way, with a Relationship of "Data" or "Alternative".
+type AssociatedFile struct {
+	Page         int    // page number the file is associated with, or 0 for the document
+	Relationship string // AFRelationship: Source, Data, Alternative, Supplement, EncryptedPayload, FormData, Schema, or Unspecified
+	Name         string // file name
+	Description  string // description of the file (Desc)
+	MIME         string // MIME type of the embedded file, if known
+	Data         []byte // decoded file contents; nil if the file is not embedded
+}
+
+// AssociatedFiles returns the files associated with the document
+// through the AF array of its catalog, followed by those associated
+// with each page through the page's AF array.
+func (r *Reader) AssociatedFiles(ctx context.Context) ([]AssociatedFile, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, err
+	}
+	out, err := associatedFiles(root, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = r.walkPages(ctx, func(num int, p Page) error {
+		files, err := associatedFiles(p.V, num)
+		if err != nil {
+			return err
+		}
+		out = append(out, files...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func associatedFiles(v Value, page int) ([]AssociatedFile, error) {
+	af, err := v.Key("AF")
+	if err != nil {
+		return nil, err
+	}
+	var out []AssociatedFile
+	for i := 0; i < af.Len(); i++ {
+		spec, err := af.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Kind() != Dict {
+			continue
+		}
+		m, err := fileSpecMedia(spec)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := spec.Key("AFRelationship")
+		if err != nil {
+			return nil, err
+		}
+		desc, err := spec.Key("Desc")
+		if err != nil {
+			return nil, err
+		}
+		f := AssociatedFile{
+			Page:         page,
+			Relationship: rel.Name(),
+			Name:         m.Name,
+			Description:  desc.Text(),
+			MIME:         m.MIME,
+			Data:         m.Data,
+		}
+		if f.Relationship == "" {
+			f.Relationship = "Unspecified"
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// An Attachment is a file embedded in the document, either listed in
+// the EmbeddedFiles name tree or attached to a page by a FileAttachment
+// annotation. See PDF 32000-1:2008, §7.11.4 and §12.5.6.15.
+type Attachment struct {
+	Spec Value  // the file specification dictionary
+	Key  string // the key of the file in the EmbeddedFiles name tree; empty for annotations
+	Page int    // the page number of the FileAttachment annotation; 0 for the name tree
+
+	Name         string // file name
+	Description  string // description of the file (Desc)
+	Relationship string // AFRelationship, if given, as for AssociatedFile
+	MIME         string // MIME type (the Subtype of the embedded file stream), if known
+	Size         int64  // size of the file, in bytes, from its parameters; -1 if unknown
+	CreationDate time.Time
+	ModDate      time.Time
+}
+
+// Attachments returns the embedded files listed in the document's
+// EmbeddedFiles name tree, followed by those attached by FileAttachment
+// annotations on each page. File specifications that do not embed
+// their file are skipped.
+func (r *Reader) Attachments(ctx context.Context) ([]Attachment, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, err
+	}
+	names, err := root.Key("Names")
+	if err != nil {
+		return nil, err
+	}
+	tree, err := names.Key("EmbeddedFiles")
+	if err != nil {
+		return nil, err
+	}
+	var out []Attachment
+	add := func(spec Value, key string, page int) error {
+		a, ok, err := newAttachment(spec)
+		if err != nil || !ok {
+			return err
+		}
+		a.Key, a.Page = key, page
+		out = append(out, a)
+		return nil
+	}
+	err = walkNameTree(tree, func(key string, spec Value) error {
+		return add(spec, key, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = r.walkPages(ctx, func(num int, p Page) error {
+		annots, err := p.V.Key("Annots")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < annots.Len(); i++ {
+			annot, err := annots.Index(i)
+			if err != nil {
+				return err
+			}
+			subtype, err := annot.Key("Subtype")
+			if err != nil {
+				return err
+			}
+			if subtype.Name() != "FileAttachment" {
+				continue
+			}
+			spec, err := annot.Key("FS")
+			if err != nil {
+				return err
+			}
+			if err := add(spec, "", num); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// newAttachment returns the Attachment for the file specification spec.
+// It returns ok == false if spec does not embed its file.
+func newAttachment(spec Value) (a Attachment, ok bool, err error) {
+	if spec.Kind() != Dict {
+		return Attachment{}, false, nil
+	}
+	ef, err := embeddedFile(spec)
+	if err != nil || ef.Kind() != Stream {
+		return Attachment{}, false, err
+	}
+	a = Attachment{Spec: spec, Size: -1}
+	if a.Name, err = fileSpecName(spec); err != nil {
+		return Attachment{}, false, err
+	}
+	for _, x := range []struct {
+		v   Value
+		key string
+		dst *string
+	}{
+		{spec, "Desc", &a.Description},
+		{spec, "AFRelationship", &a.Relationship},
+		{ef, "Subtype", &a.MIME},
+	} {
+		v, err := x.v.Key(x.key)
+		if err != nil {
+			return Attachment{}, false, err
+		}
+		if v.Kind() == Name {
+			*x.dst = v.Name()
+		} else {
+			*x.dst = v.Text()
+		}
+	}
+	params, err := ef.Key("Params")
+	if err != nil {
+		return Attachment{}, false, err
+	}
+	size, err := params.Key("Size")
+	if err != nil {
+		return Attachment{}, false, err
+	}
+	if size.Kind() == Integer {
+		a.Size = size.Int64()
+	}
+	for _, x := range []struct {
+		key string
+		dst *time.Time
+	}{
+		{"CreationDate", &a.CreationDate},
+		{"ModDate", &a.ModDate},
+	} {
+		v, err := params.Key(x.key)
+		if err != nil {
+			return Attachment{}, false, err
+		}
+		*x.dst, _ = ParseDate(v.Text())
+	}
+	return a, true, nil
+}
+
+// Open returns a reader for the decoded contents of the attached file.
+// The caller should close it when done.
+func (a Attachment) Open() (io.ReadCloser, error) {
+	ef, err := embeddedFile(a.Spec)
+	if err != nil {
+		return nil, err
+	}
+	if ef.Kind() != Stream {
+		return nil, errors.New("pdf: attachment is not embedded")
+	}
+	return ef.Reader()
+}
+
+// A NewAttachment describes a file to embed in a document
+// with WriteAttachment or AddAttachments.
+type NewAttachment struct {
+	Name        string    // file name
+	Description string    // description of the file
+	MIME        string    // MIME type, such as "text/xml"; optional
+	Data        []byte    // contents of the file
+	ModDate     time.Time // time the file was last modified; optional
+
+	// Relationship, if set, is the file's relationship to the document
+	// (its AFRelationship), such as "Data" or "Source", as PDF/A-3 and
+	// formats such as ZUGFeRD require. AddAttachments also associates
+	// files with a Relationship with the document, in its AF array.
+	Relationship string
+}
+
+// WriteAttachment writes a file specification embedding the file a,
+// with its contents compressed, and returns a reference to it.
+func (w *Writer) WriteAttachment(a NewAttachment) (Value, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(a.Data)
+	if err := zw.Close(); err != nil {
+		return Value{}, err
+	}
+	sum := md5.Sum(a.Data)
+	params := map[string]Value{
+		"Size":     NewInt(int64(len(a.Data))),
+		"CheckSum": NewString(string(sum[:])),
+	}
+	if !a.ModDate.IsZero() {
+		params["ModDate"] = NewString(formatDate(a.ModDate))
+	}
+	hdr := map[string]Value{
+		"Type":   NewName("EmbeddedFile"),
+		"Filter": NewName("FlateDecode"),
+		"Params": NewDict(params),
+	}
+	if a.MIME != "" {
+		hdr["Subtype"] = NewName(a.MIME)
+	}
+	ef, err := w.Add(NewStream(NewDict(hdr), buf.Bytes()))
+	if err != nil {
+		return Value{}, err
+	}
+	spec := map[string]Value{
+		"Type": NewName("Filespec"),
+		"F":    NewText(a.Name),
+		"UF":   NewText(a.Name),
+		"EF":   NewDict(map[string]Value{"F": ef, "UF": ef}),
+	}
+	if a.Description != "" {
+		spec["Desc"] = NewText(a.Description)
+	}
+	if a.Relationship != "" {
+		spec["AFRelationship"] = NewName(a.Relationship)
+	}
+	return w.Add(NewDict(spec))
+}
+
+// AddAttachments embeds files in the document being updated by w
+// (see Reader.Update), adding them to its EmbeddedFiles name tree,
+// and writes the updated document catalog. A file with the same name
+// as one already in the tree replaces it there.
+func (w *Writer) AddAttachments(files ...NewAttachment) error {
+	r := w.update
+	if r == nil {
+		return errors.New("pdf: AddAttachments requires an incremental update")
+	}
+	catalog, err := r.Trailer().Key("Root")
+	if err != nil {
+		return err
+	}
+	names, err := catalog.Key("Names")
+	if err != nil {
+		return err
+	}
+	tree, err := names.Key("EmbeddedFiles")
+	if err != nil {
+		return err
+	}
+	af, err := catalog.Key("AF")
+	if err != nil {
+		return err
+	}
+
+	// Rewrite the tree as a single node holding all the files,
+	// sorted by key.
+	type entry struct{ key, spec Value }
+	var entries []entry
+	err = walkTree(tree, "Names", make(map[objptr]bool), func(key, spec Value) error {
+		entries = append(entries, entry{key, spec})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var assoc []Value
+	for i := 0; i < af.Len(); i++ {
+		v, err := af.Index(i)
+		if err != nil {
+			return err
+		}
+		assoc = append(assoc, v)
+	}
+	for _, a := range files {
+		spec, err := w.WriteAttachment(a)
+		if err != nil {
+			return err
+		}
+		key := NewText(a.Name)
+		replaced := false
+		for i := range entries {
+			if entries[i].key.RawString() == key.RawString() {
+				entries[i].spec, replaced = spec, true
+			}
+		}
+		if !replaced {
+			entries = append(entries, entry{key, spec})
+		}
+		if a.Relationship != "" {
+			assoc = append(assoc, spec)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].key.RawString() < entries[j].key.RawString()
+	})
+	var pairs []Value
+	for _, e := range entries {
+		pairs = append(pairs, e.key, e.spec)
+	}
+
+	namesEntries, err := dictEntries(names)
+	if err != nil {
+		return err
+	}
+	namesEntries["EmbeddedFiles"] = NewDict(map[string]Value{"Names": NewArray(pairs...)})
+	catalogEntries, err := dictEntries(catalog)
+	if err != nil {
+		return err
+	}
+	catalogEntries["Names"] = NewDict(namesEntries)
+	if len(assoc) > 0 {
+		catalogEntries["AF"] = NewArray(assoc...)
+	}
+	return w.Write(catalog, NewDict(catalogEntries))
+}
@@ -0,0 +1,422 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// CCITT fax decoding, as specified by ITU-T T.4 (Group 3, one- and
+// two-dimensional) and T.6 (Group 4). See PDF 32000-1:2008, §7.4.6.
+
+// ccittParams are the CCITTFaxDecode filter parameters.
+type ccittParams struct {
+	k          int  // <0: Group 4; 0: Group 3 1-D; >0: Group 3 mixed 1-D and 2-D
+	columns    int  // pixels per row
+	rows       int  // number of rows, or 0 if unknown
+	byteAlign  bool // EncodedByteAlign
+	endOfBlock bool // data ends with an EOFB or RTC
+	blackIs1   bool
+}
+
+func newCCITTParams(param Value) (ccittParams, error) {
+	p := ccittParams{columns: 1728, endOfBlock: true}
+	for _, k := range param.Keys() {
+		v, err := param.Key(k)
+		if err != nil {
+			return p, err
+		}
+		switch k {
+		case "K":
+			p.k = int(v.Int64())
+		case "Columns":
+			p.columns = int(v.Int64())
+		case "Rows":
+			p.rows = int(v.Int64())
+		case "EncodedByteAlign":
+			p.byteAlign = v.Bool()
+		case "EndOfBlock":
+			p.endOfBlock = v.Bool()
+		case "BlackIs1":
+			p.blackIs1 = v.Bool()
+		}
+	}
+	if p.columns <= 0 || p.columns > 1<<20 {
+		return p, errors.New("CCITTFaxDecode: invalid Columns")
+	}
+	return p, nil
+}
+
+// ccittDecode decodes the CCITT fax data read from rd into rows of
+// packed pixels, with 0 for black unless BlackIs1 is set.
+// Decoding stops at the end of the data, after Rows rows, or at
+// an end-of-block marker.
+func ccittDecode(rd io.Reader, param Value) (io.Reader, error) {
+	p, err := newCCITTParams(param)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	d := ccittDecoder{ccittParams: p, data: data}
+	out, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(out), nil
+}
+
+type ccittDecoder struct {
+	ccittParams
+	data []byte
+	pos  int // bit position in data
+}
+
+// peek returns the next n bits without consuming them,
+// reading zeros past the end of the data.
+func (d *ccittDecoder) peek(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		bit := d.pos + i
+		v <<= 1
+		if bit/8 < len(d.data) {
+			v |= uint32(d.data[bit/8]>>(7-uint(bit%8))) & 1
+		}
+	}
+	return v
+}
+
+func (d *ccittDecoder) eof() bool {
+	return d.pos >= 8*len(d.data)
+}
+
+func (d *ccittDecoder) align() {
+	d.pos = (d.pos + 7) &^ 7
+}
+
+// eol consumes an end-of-line code (000000000001), preceded by any
+// fill bits, and reports whether there was one.
+func (d *ccittDecoder) eol() bool {
+	zeros := 0
+	for d.peek(zeros+1) == 0 && d.pos+zeros < 8*len(d.data) {
+		zeros++
+	}
+	// No valid code has more than 7 leading zeros.
+	if zeros < 11 || d.pos+zeros >= 8*len(d.data) {
+		return false
+	}
+	d.pos += zeros + 1
+	return true
+}
+
+func (d *ccittDecoder) decode() ([]byte, error) {
+	stride := (d.columns + 7) / 8
+	var out []byte
+	ref := []int{d.columns, d.columns} // the imaginary all-white row above the first
+	for row := 0; d.rows <= 0 || row < d.rows; row++ {
+		twoD := d.k < 0
+		if d.k < 0 {
+			if d.byteAlign {
+				d.align()
+			}
+			if d.eol() {
+				break // EOFB
+			}
+		} else {
+			sawEOL := d.eol()
+			if sawEOL && d.eol() {
+				break // RTC
+			}
+			if d.byteAlign {
+				d.align()
+			}
+			if d.k > 0 && sawEOL {
+				twoD = d.peek(1) == 0
+				d.pos++
+			}
+		}
+		if d.eof() {
+			break
+		}
+		var cur []int
+		var err error
+		if twoD {
+			cur, err = d.decode2D(ref)
+		} else {
+			cur, err = d.decode1D()
+		}
+		if err != nil {
+			if len(out) > 0 && d.rows <= 0 {
+				// Trailing garbage after the last row.
+				break
+			}
+			return nil, err
+		}
+		out = append(out, d.pack(cur, stride)...)
+		ref = append(cur, d.columns, d.columns)
+	}
+	return out, nil
+}
+
+// pack returns the row with the given changing elements as packed pixels.
+func (d *ccittDecoder) pack(changes []int, stride int) []byte {
+	row := make([]byte, stride)
+	white := byte(0xff)
+	if d.blackIs1 {
+		white = 0
+	}
+	for i := range row {
+		row[i] = white
+	}
+	// Runs of black are between changes[2i] and changes[2i+1].
+	for i := 0; i < len(changes); i += 2 {
+		end := d.columns
+		if i+1 < len(changes) {
+			end = changes[i+1]
+		}
+		for x := changes[i]; x < end && x < d.columns; x++ {
+			row[x/8] ^= 0x80 >> uint(x%8)
+		}
+	}
+	return row
+}
+
+// decode1D decodes a row of alternating white and black runs,
+// returning the positions at which the color changes.
+func (d *ccittDecoder) decode1D() ([]int, error) {
+	var changes []int
+	a0, black := 0, false
+	for a0 < d.columns {
+		run, err := d.run(black)
+		if err != nil {
+			return nil, err
+		}
+		a0 += run
+		changes = append(changes, a0)
+		black = !black
+	}
+	return changes, nil
+}
+
+// decode2D decodes a row coded relative to the reference row, whose
+// changing elements are ref, followed by two copies of Columns.
+func (d *ccittDecoder) decode2D(ref []int) ([]int, error) {
+	var changes []int
+	a0, black := -1, false
+	for a0 < d.columns {
+		// b1 is the first changing element on the reference row to the
+		// right of a0 whose color is the opposite of a0's: changes to
+		// black are at even indexes.
+		i := 0
+		if black {
+			i = 1
+		}
+		for i < len(ref)-2 && ref[i] <= a0 {
+			i += 2
+		}
+		// Past the end of the reference row, b1 and b2 are at Columns.
+		b1, b2 := d.columns, d.columns
+		if i < len(ref) {
+			b1 = ref[i]
+		}
+		if i+1 < len(ref) {
+			b2 = ref[i+1]
+		}
+
+		mode, err := d.mode()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case mode == modePass:
+			a0 = b2
+		case mode == modeHorizontal:
+			if a0 < 0 {
+				a0 = 0
+			}
+			r1, err := d.run(black)
+			if err != nil {
+				return nil, err
+			}
+			r2, err := d.run(!black)
+			if err != nil {
+				return nil, err
+			}
+			a1 := a0 + r1
+			a0 = a1 + r2
+			changes = append(changes, a1, a0)
+		default: // vertical
+			a1 := b1 + mode
+			if a1 < 0 || a1 < a0 || a1 > d.columns {
+				return nil, errors.New("CCITTFaxDecode: invalid vertical code")
+			}
+			changes = append(changes, a1)
+			a0 = a1
+			black = !black
+		}
+	}
+	return changes, nil
+}
+
+// Coding modes of two-dimensional rows. Vertical modes are
+// represented by the offset of a1 from b1, from -3 to 3.
+const (
+	modePass       = 100
+	modeHorizontal = 101
+)
+
+var ccittModes = []struct {
+	mode int
+	code string
+}{
+	{0, "1"}, {1, "011"}, {-1, "010"}, {modeHorizontal, "001"}, {modePass, "0001"},
+	{2, "000011"}, {-2, "000010"}, {3, "0000011"}, {-3, "0000010"},
+}
+
+// mode reads a two-dimensional coding mode.
+func (d *ccittDecoder) mode() (int, error) {
+	for _, m := range ccittModes {
+		n := len(m.code)
+		if d.peek(n) == bitsValue(m.code) {
+			d.pos += n
+			return m.mode, nil
+		}
+	}
+	return 0, errors.New("CCITTFaxDecode: invalid or unsupported coding mode")
+}
+
+// run reads a white or black run length:
+// any make-up codes followed by a terminating code.
+func (d *ccittDecoder) run(black bool) (int, error) {
+	ccittTablesOnce.Do(buildCCITTTables)
+	table := ccittWhite
+	if black {
+		table = ccittBlack
+	}
+	total := 0
+	for {
+		if d.eof() {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n, found := 0, false
+		for n = 2; n <= 13; n++ {
+			if run, ok := table[uint32(n)<<16|d.peek(n)]; ok {
+				d.pos += n
+				total += run
+				found = true
+				if run < 64 {
+					return total, nil
+				}
+				break
+			}
+		}
+		if !found {
+			return 0, errors.New("CCITTFaxDecode: invalid run code")
+		}
+	}
+}
+
+// bitsValue returns the value of a string of binary digits.
+func bitsValue(s string) uint32 {
+	var v uint32
+	for i := 0; i < len(s); i++ {
+		v = v<<1 | uint32(s[i]-'0')
+	}
+	return v
+}
+
+// The run-length code tables map a code's length<<16 | its bits
+// to the run length.
+var (
+	ccittTablesOnce sync.Once
+	ccittWhite      map[uint32]int
+	ccittBlack      map[uint32]int
+)
+
+func buildCCITTTables() {
+	build := func(codes []ccittCode) map[uint32]int {
+		m := make(map[uint32]int, len(codes))
+		for _, c := range codes {
+			m[uint32(len(c.code))<<16|bitsValue(c.code)] = c.run
+		}
+		return m
+	}
+	ccittWhite = build(ccittWhiteCodes)
+	ccittBlack = build(ccittBlackCodes)
+}
+
+// A ccittCode is the code for a run length.
+type ccittCode struct {
+	run  int
+	code string
+}
+
+// Run-length codes, from ITU-T T.4 tables 2 and 3:
+// terminating codes for runs of 0 to 63, make-up codes for multiples
+// of 64 up to 1728, and the extended make-up codes up to 2560,
+// which are shared by white and black runs.
+var ccittWhiteCodes = []ccittCode{
+	{0, "00110101"}, {1, "000111"}, {2, "0111"}, {3, "1000"},
+	{4, "1011"}, {5, "1100"}, {6, "1110"}, {7, "1111"},
+	{8, "10011"}, {9, "10100"}, {10, "00111"}, {11, "01000"},
+	{12, "001000"}, {13, "000011"}, {14, "110100"}, {15, "110101"},
+	{16, "101010"}, {17, "101011"}, {18, "0100111"}, {19, "0001100"},
+	{20, "0001000"}, {21, "0010111"}, {22, "0000011"}, {23, "0000100"},
+	{24, "0101000"}, {25, "0101011"}, {26, "0010011"}, {27, "0100100"},
+	{28, "0011000"}, {29, "00000010"}, {30, "00000011"}, {31, "00011010"},
+	{32, "00011011"}, {33, "00010010"}, {34, "00010011"}, {35, "00010100"},
+	{36, "00010101"}, {37, "00010110"}, {38, "00010111"}, {39, "00101000"},
+	{40, "00101001"}, {41, "00101010"}, {42, "00101011"}, {43, "00101100"},
+	{44, "00101101"}, {45, "00000100"}, {46, "00000101"}, {47, "00001010"},
+	{48, "00001011"}, {49, "01010010"}, {50, "01010011"}, {51, "01010100"},
+	{52, "01010101"}, {53, "00100100"}, {54, "00100101"}, {55, "01011000"},
+	{56, "01011001"}, {57, "01011010"}, {58, "01011011"}, {59, "01001010"},
+	{60, "01001011"}, {61, "00110010"}, {62, "00110011"}, {63, "00110100"},
+	{64, "11011"}, {128, "10010"}, {192, "010111"}, {256, "0110111"},
+	{320, "00110110"}, {384, "00110111"}, {448, "01100100"}, {512, "01100101"},
+	{576, "01101000"}, {640, "01100111"}, {704, "011001100"}, {768, "011001101"},
+	{832, "011010010"}, {896, "011010011"}, {960, "011010100"}, {1024, "011010101"},
+	{1088, "011010110"}, {1152, "011010111"}, {1216, "011011000"}, {1280, "011011001"},
+	{1344, "011011010"}, {1408, "011011011"}, {1472, "010011000"}, {1536, "010011001"},
+	{1600, "010011010"}, {1664, "011000"}, {1728, "010011011"}, {1792, "00000001000"},
+	{1856, "00000001100"}, {1920, "00000001101"}, {1984, "000000010010"}, {2048, "000000010011"},
+	{2112, "000000010100"}, {2176, "000000010101"}, {2240, "000000010110"}, {2304, "000000010111"},
+	{2368, "000000011100"}, {2432, "000000011101"}, {2496, "000000011110"}, {2560, "000000011111"},
+}
+
+var ccittBlackCodes = []ccittCode{
+	{0, "0000110111"}, {1, "010"}, {2, "11"}, {3, "10"},
+	{4, "011"}, {5, "0011"}, {6, "0010"}, {7, "00011"},
+	{8, "000101"}, {9, "000100"}, {10, "0000100"}, {11, "0000101"},
+	{12, "0000111"}, {13, "00000100"}, {14, "00000111"}, {15, "000011000"},
+	{16, "0000010111"}, {17, "0000011000"}, {18, "0000001000"}, {19, "00001100111"},
+	{20, "00001101000"}, {21, "00001101100"}, {22, "00000110111"}, {23, "00000101000"},
+	{24, "00000010111"}, {25, "00000011000"}, {26, "000011001010"}, {27, "000011001011"},
+	{28, "000011001100"}, {29, "000011001101"}, {30, "000001101000"}, {31, "000001101001"},
+	{32, "000001101010"}, {33, "000001101011"}, {34, "000011010010"}, {35, "000011010011"},
+	{36, "000011010100"}, {37, "000011010101"}, {38, "000011010110"}, {39, "000011010111"},
+	{40, "000001101100"}, {41, "000001101101"}, {42, "000011011010"}, {43, "000011011011"},
+	{44, "000001010100"}, {45, "000001010101"}, {46, "000001010110"}, {47, "000001010111"},
+	{48, "000001100100"}, {49, "000001100101"}, {50, "000001010010"}, {51, "000001010011"},
+	{52, "000000100100"}, {53, "000000110111"}, {54, "000000111000"}, {55, "000000100111"},
+	{56, "000000101000"}, {57, "000001011000"}, {58, "000001011001"}, {59, "000000101011"},
+	{60, "000000101100"}, {61, "000001011010"}, {62, "000001100110"}, {63, "000001100111"},
+	{64, "0000001111"}, {128, "000011001000"}, {192, "000011001001"}, {256, "000001011011"},
+	{320, "000000110011"}, {384, "000000110100"}, {448, "000000110101"}, {512, "0000001101100"},
+	{576, "0000001101101"}, {640, "0000001001010"}, {704, "0000001001011"}, {768, "0000001001100"},
+	{832, "0000001001101"}, {896, "0000001110010"}, {960, "0000001110011"}, {1024, "0000001110100"},
+	{1088, "0000001110101"}, {1152, "0000001110110"}, {1216, "0000001110111"}, {1280, "0000001010010"},
+	{1344, "0000001010011"}, {1408, "0000001010100"}, {1472, "0000001010101"}, {1536, "0000001011010"},
+	{1600, "0000001011011"}, {1664, "0000001100100"}, {1728, "0000001100101"}, {1792, "00000001000"},
+	{1856, "00000001100"}, {1920, "00000001101"}, {1984, "000000010010"}, {2048, "000000010011"},
+	{2112, "000000010100"}, {2176, "000000010101"}, {2240, "000000010110"}, {2304, "000000010111"},
+	{2368, "000000011100"}, {2432, "000000011101"}, {2496, "000000011110"}, {2560, "000000011111"},
+}
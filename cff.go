@@ -0,0 +1,709 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Glyph outlines of CFF (Compact Font Format) font programs,
+// for Page.Render. See Adobe Technical Notes #5176 and #5177.
+
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// A cffFont is a parsed CFF font program: the first font in a FontSet.
+type cffFont struct {
+	charStrings [][]byte
+	gsubrs      [][]byte
+	subrs       [][]byte   // local subroutines, for a name-keyed font
+	fdSubrs     [][][]byte // local subroutines of each Font DICT, for a CID-keyed font
+	fdSelect    []byte     // the Font DICT of each glyph, for a CID-keyed font
+	charset     []int      // the SID, or for a CID-keyed font the CID, of each glyph
+	encoding    map[int]int
+	strings     [][]byte
+	matrix      Matrix
+	cid         bool
+}
+
+var errCFF = errors.New("malformed CFF font")
+
+// cffIndex reads the INDEX at data[off:], returning its elements
+// and the offset after it.
+func cffIndex(data []byte, off int) ([][]byte, int, error) {
+	if off < 0 || off+2 > len(data) {
+		return nil, 0, errCFF
+	}
+	count := int(binary.BigEndian.Uint16(data[off:]))
+	if count == 0 {
+		return nil, off + 2, nil
+	}
+	if off+3 > len(data) {
+		return nil, 0, errCFF
+	}
+	size := int(data[off+2])
+	if size < 1 || size > 4 {
+		return nil, 0, errCFF
+	}
+	offs := off + 3
+	base := offs + (count+1)*size - 1
+	if base >= len(data) {
+		return nil, 0, errCFF
+	}
+	offset := func(i int) int {
+		x := 0
+		for _, b := range data[offs+i*size : offs+(i+1)*size] {
+			x = x<<8 | int(b)
+		}
+		return base + x
+	}
+	elems := make([][]byte, count)
+	for i := range elems {
+		start, end := offset(i), offset(i+1)
+		if start > end || end > len(data) {
+			return nil, 0, errCFF
+		}
+		elems[i] = data[start:end]
+	}
+	return elems, offset(count), nil
+}
+
+// cffDict parses a Top, Font, or Private DICT, returning the operands
+// of each operator. Two-byte operators are numbered 1200 and up.
+func cffDict(data []byte) map[int][]float64 {
+	d := make(map[int][]float64)
+	var ops []float64
+	for i := 0; i < len(data); {
+		b := int(data[i])
+		switch {
+		case b <= 21:
+			op := b
+			i++
+			if b == 12 && i < len(data) {
+				op = 1200 + int(data[i])
+				i++
+			}
+			d[op] = ops
+			ops = nil
+			continue
+		case b == 30:
+			// A real number, in packed decimal nibbles.
+			var s []byte
+			i++
+		Real:
+			for ; i < len(data); i++ {
+				for _, n := range []byte{data[i] >> 4, data[i] & 15} {
+					switch {
+					case n <= 9:
+						s = append(s, '0'+n)
+					case n == 0xa:
+						s = append(s, '.')
+					case n == 0xb:
+						s = append(s, 'E')
+					case n == 0xc:
+						s = append(s, 'E', '-')
+					case n == 0xe:
+						s = append(s, '-')
+					case n == 0xf:
+						i++
+						break Real
+					}
+				}
+			}
+			ops = append(ops, parseRealBytes(s))
+			continue
+		}
+		v, n := cffNumber(data[i:])
+		if n == 0 {
+			break
+		}
+		ops = append(ops, v)
+		i += n
+	}
+	return d
+}
+
+// parseRealBytes parses the decimal number s, returning 0 if it is invalid.
+func parseRealBytes(s []byte) float64 {
+	x, err := strconv.ParseFloat(string(s), 64)
+	if err != nil {
+		return 0
+	}
+	return x
+}
+
+// cffNumber decodes the integer operand at the start of data,
+// returning it and its length, or a length of 0 if there is none.
+// It decodes the operand encodings shared by DICTs and charstrings.
+func cffNumber(data []byte) (float64, int) {
+	b := int(data[0])
+	switch {
+	case b >= 32 && b <= 246:
+		return float64(b - 139), 1
+	case b >= 247 && b <= 250 && len(data) >= 2:
+		return float64((b-247)*256 + int(data[1]) + 108), 2
+	case b >= 251 && b <= 254 && len(data) >= 2:
+		return float64(-(b-251)*256 - int(data[1]) - 108), 2
+	case b == 28 && len(data) >= 3:
+		return float64(int16(binary.BigEndian.Uint16(data[1:]))), 3
+	case b == 29 && len(data) >= 5:
+		return float64(int32(binary.BigEndian.Uint32(data[1:]))), 5
+	}
+	return 0, 0
+}
+
+// parseCFF parses the CFF font program data.
+func parseCFF(data []byte) (_ *cffFont, err error) {
+	defer catchPanic(&err)
+	if len(data) < 4 {
+		return nil, errCFF
+	}
+	_, off, err := cffIndex(data, int(data[2])) // Name INDEX
+	if err != nil {
+		return nil, err
+	}
+	tops, off, err := cffIndex(data, off)
+	if err != nil || len(tops) == 0 {
+		return nil, errCFF
+	}
+	f := &cffFont{matrix: Matrix{0.001, 0, 0, 0.001, 0, 0}}
+	if f.strings, off, err = cffIndex(data, off); err != nil {
+		return nil, err
+	}
+	if f.gsubrs, _, err = cffIndex(data, off); err != nil {
+		return nil, err
+	}
+	top := cffDict(tops[0])
+	if m := top[1207]; len(m) == 6 {
+		f.matrix = Matrix{m[0], m[1], m[2], m[3], m[4], m[5]}
+	}
+	cs := top[17]
+	if len(cs) != 1 {
+		return nil, errCFF
+	}
+	if f.charStrings, _, err = cffIndex(data, int(cs[0])); err != nil {
+		return nil, err
+	}
+	n := len(f.charStrings)
+
+	_, f.cid = top[1230]
+	if f.cid {
+		fds := top[1236]
+		if len(fds) != 1 {
+			return nil, errCFF
+		}
+		dicts, _, err := cffIndex(data, int(fds[0]))
+		if err != nil {
+			return nil, err
+		}
+		for _, fd := range dicts {
+			subrs, err := cffPrivateSubrs(data, cffDict(fd))
+			if err != nil {
+				return nil, err
+			}
+			f.fdSubrs = append(f.fdSubrs, subrs)
+		}
+		if sel := top[1237]; len(sel) == 1 {
+			f.fdSelect = cffFDSelect(data, int(sel[0]), n)
+		}
+	} else if f.subrs, err = cffPrivateSubrs(data, top); err != nil {
+		return nil, err
+	}
+
+	// The charset gives the name (SID) or CID of each glyph.
+	f.charset = make([]int, n)
+	switch off := 0; {
+	case len(top[15]) == 1:
+		off = int(top[15][0])
+		if off <= 2 {
+			// A predefined charset; only ISOAdobe is the identity.
+			for i := range f.charset {
+				f.charset[i] = i
+			}
+			break
+		}
+		cffCharset(data, off, f.charset)
+	default:
+		for i := range f.charset {
+			f.charset[i] = i
+		}
+	}
+
+	if enc := top[16]; len(enc) == 1 && enc[0] > 1 && !f.cid {
+		f.encoding = cffEncoding(data, int(enc[0]), f.charset)
+	}
+	return f, nil
+}
+
+// cffPrivateSubrs returns the local subroutines in the Private DICT
+// of the Top or Font DICT d.
+func cffPrivateSubrs(data []byte, d map[int][]float64) ([][]byte, error) {
+	priv := d[18]
+	if len(priv) != 2 {
+		return nil, nil
+	}
+	size, off := int(priv[0]), int(priv[1])
+	if off < 0 || size < 0 || off+size > len(data) {
+		return nil, errCFF
+	}
+	p := cffDict(data[off : off+size])
+	if s := p[19]; len(s) == 1 {
+		subrs, _, err := cffIndex(data, off+int(s[0]))
+		return subrs, err
+	}
+	return nil, nil
+}
+
+// cffCharset reads the charset at data[off:] into charset.
+func cffCharset(data []byte, off int, charset []int) {
+	u16 := func(off int) int {
+		if off < 0 || off+2 > len(data) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(data[off:]))
+	}
+	if off >= len(data) {
+		return
+	}
+	format := data[off]
+	off++
+	for gid := 1; gid < len(charset); {
+		switch format {
+		case 0:
+			charset[gid] = u16(off)
+			off += 2
+			gid++
+		case 1, 2:
+			first := u16(off)
+			var left int
+			if format == 1 {
+				if off+2 >= len(data) {
+					return
+				}
+				left = int(data[off+2])
+				off += 3
+			} else {
+				left = u16(off + 2)
+				off += 4
+			}
+			for i := 0; i <= left && gid < len(charset); i++ {
+				charset[gid] = first + i
+				gid++
+			}
+		default:
+			return
+		}
+		if off >= len(data) {
+			return
+		}
+	}
+}
+
+// cffEncoding reads the custom encoding at data[off:],
+// returning the glyph for each code.
+func cffEncoding(data []byte, off int, charset []int) map[int]int {
+	if off >= len(data) {
+		return nil
+	}
+	enc := make(map[int]int)
+	format := data[off]
+	off++
+	gid := 1
+	switch format & 0x7f {
+	case 0:
+		if off >= len(data) {
+			return nil
+		}
+		n := int(data[off])
+		off++
+		for i := 0; i < n && off < len(data); i++ {
+			enc[int(data[off])] = gid
+			gid++
+			off++
+		}
+	case 1:
+		if off >= len(data) {
+			return nil
+		}
+		n := int(data[off])
+		off++
+		for i := 0; i < n && off+1 < len(data); i++ {
+			first, left := int(data[off]), int(data[off+1])
+			for c := first; c <= first+left; c++ {
+				enc[c] = gid
+				gid++
+			}
+			off += 2
+		}
+	}
+	if format&0x80 != 0 && off < len(data) {
+		// Supplements give more codes for glyphs named by SID.
+		n := int(data[off])
+		off++
+		for i := 0; i < n && off+2 < len(data); i++ {
+			code, sid := int(data[off]), int(binary.BigEndian.Uint16(data[off+1:]))
+			for g, s := range charset {
+				if s == sid {
+					enc[code] = g
+					break
+				}
+			}
+			off += 3
+		}
+	}
+	return enc
+}
+
+// cffFDSelect reads the FDSelect at data[off:] for n glyphs.
+func cffFDSelect(data []byte, off, n int) []byte {
+	if off >= len(data) {
+		return nil
+	}
+	sel := make([]byte, n)
+	switch data[off] {
+	case 0:
+		copy(sel, data[off+1:])
+	case 3:
+		if off+3 > len(data) {
+			return nil
+		}
+		ranges := int(binary.BigEndian.Uint16(data[off+1:]))
+		r := off + 3
+		for i := 0; i < ranges && r+5 <= len(data); i++ {
+			first := int(binary.BigEndian.Uint16(data[r:]))
+			fd := data[r+2]
+			next := int(binary.BigEndian.Uint16(data[r+3:]))
+			for g := first; g < next && g < n; g++ {
+				sel[g] = fd
+			}
+			r += 3
+		}
+	}
+	return sel
+}
+
+// glyphName returns the name of glyph gid of a name-keyed font.
+func (f *cffFont) glyphName(gid int) string {
+	if gid < 0 || gid >= len(f.charset) || f.cid {
+		return ""
+	}
+	sid := f.charset[gid]
+	if sid < len(cffStandardStrings) {
+		return cffStandardStrings[sid]
+	}
+	// Custom names follow the 391 standard strings.
+	if sid -= 391; sid >= 0 && sid < len(f.strings) {
+		return string(f.strings[sid])
+	}
+	return ""
+}
+
+// cidGlyph returns the glyph for the CID cid of a CID-keyed font.
+func (f *cffFont) cidGlyph(cid int) int {
+	if !f.cid {
+		return cid
+	}
+	for gid, c := range f.charset {
+		if c == cid {
+			return gid
+		}
+	}
+	return 0
+}
+
+// glyph returns the outline of glyph gid, in glyph space.
+func (f *cffFont) glyph(gid int) []PathSegment {
+	if gid < 0 || gid >= len(f.charStrings) {
+		return nil
+	}
+	subrs := f.subrs
+	if f.cid {
+		subrs = nil
+		if gid < len(f.fdSelect) && int(f.fdSelect[gid]) < len(f.fdSubrs) {
+			subrs = f.fdSubrs[f.fdSelect[gid]]
+		}
+	}
+	t := &type2{gsubrs: f.gsubrs, subrs: subrs}
+	t.run(f.charStrings[gid], 0)
+	for i := range t.segs {
+		for j, pt := range t.segs[i].Pts {
+			t.segs[i].Pts[j] = f.matrix.Apply(pt)
+		}
+	}
+	return t.segs
+}
+
+// A type2 interprets a Type 2 charstring.
+type type2 struct {
+	gsubrs, subrs [][]byte
+	stack         []float64
+	x, y          float64
+	stems         int
+	width         bool // whether the width has been seen, if any
+	open          bool // whether a subpath is open
+	done          bool
+	segs          []PathSegment
+}
+
+// subrBias returns the bias added to subroutine numbers
+// for a subroutine INDEX of n elements.
+func subrBias(n int) int {
+	switch {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+func (t *type2) moveTo(dx, dy float64) {
+	if t.open {
+		t.segs = append(t.segs, PathSegment{Op: 'h'})
+	}
+	t.x += dx
+	t.y += dy
+	t.segs = append(t.segs, PathSegment{'m', []Point{{t.x, t.y}}})
+	t.open = true
+}
+
+func (t *type2) lineTo(dx, dy float64) {
+	t.x += dx
+	t.y += dy
+	t.segs = append(t.segs, PathSegment{'l', []Point{{t.x, t.y}}})
+}
+
+func (t *type2) curveTo(dx1, dy1, dx2, dy2, dx3, dy3 float64) {
+	p1 := Point{t.x + dx1, t.y + dy1}
+	p2 := Point{p1.X + dx2, p1.Y + dy2}
+	t.x, t.y = p2.X+dx3, p2.Y+dy3
+	t.segs = append(t.segs, PathSegment{'c', []Point{p1, p2, {t.x, t.y}}})
+}
+
+// takeWidth drops the width that may precede the operands of the
+// first stack-clearing operator, which takes want operands
+// (or, if even is set, an even number).
+func (t *type2) takeWidth(want int, even bool) {
+	if t.width {
+		return
+	}
+	t.width = true
+	if even && len(t.stack)%2 == 1 || !even && len(t.stack) > want {
+		t.stack = t.stack[1:]
+	}
+}
+
+// run interprets the charstring code. depth counts the subroutine calls.
+func (t *type2) run(code []byte, depth int) {
+	if depth > 10 {
+		t.done = true
+		return
+	}
+	s := func(i int) float64 {
+		if i < len(t.stack) {
+			return t.stack[i]
+		}
+		return 0
+	}
+	for i := 0; i < len(code) && !t.done; {
+		b := int(code[i])
+		if b >= 32 || b == 28 {
+			if b == 255 {
+				if i+5 > len(code) {
+					t.done = true
+					return
+				}
+				t.stack = append(t.stack, float64(int32(binary.BigEndian.Uint32(code[i+1:])))/65536)
+				i += 5
+				continue
+			}
+			v, n := cffNumber(code[i:])
+			if n == 0 {
+				t.done = true
+				return
+			}
+			if len(t.stack) < 48 {
+				t.stack = append(t.stack, v)
+			}
+			i += n
+			continue
+		}
+		i++
+		switch b {
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			t.takeWidth(0, true)
+			t.stems += len(t.stack) / 2
+		case 19, 20: // hintmask, cntrmask
+			t.takeWidth(0, true)
+			t.stems += len(t.stack) / 2
+			i += (t.stems + 7) / 8
+		case 21: // rmoveto
+			t.takeWidth(2, false)
+			t.moveTo(s(0), s(1))
+		case 22: // hmoveto
+			t.takeWidth(1, false)
+			t.moveTo(s(0), 0)
+		case 4: // vmoveto
+			t.takeWidth(1, false)
+			t.moveTo(0, s(0))
+		case 5: // rlineto
+			for j := 0; j+1 < len(t.stack); j += 2 {
+				t.lineTo(s(j), s(j+1))
+			}
+		case 6, 7: // hlineto, vlineto
+			horiz := b == 6
+			for j := 0; j < len(t.stack); j++ {
+				if horiz {
+					t.lineTo(s(j), 0)
+				} else {
+					t.lineTo(0, s(j))
+				}
+				horiz = !horiz
+			}
+		case 8: // rrcurveto
+			for j := 0; j+5 < len(t.stack); j += 6 {
+				t.curveTo(s(j), s(j+1), s(j+2), s(j+3), s(j+4), s(j+5))
+			}
+		case 24: // rcurveline
+			j := 0
+			for ; j+5 < len(t.stack)-2; j += 6 {
+				t.curveTo(s(j), s(j+1), s(j+2), s(j+3), s(j+4), s(j+5))
+			}
+			t.lineTo(s(j), s(j+1))
+		case 25: // rlinecurve
+			j := 0
+			for ; j+1 < len(t.stack)-6; j += 2 {
+				t.lineTo(s(j), s(j+1))
+			}
+			t.curveTo(s(j), s(j+1), s(j+2), s(j+3), s(j+4), s(j+5))
+		case 26: // vvcurveto
+			j, dx1 := 0, 0.0
+			if len(t.stack)%2 == 1 {
+				dx1, j = s(0), 1
+			}
+			for ; j+3 < len(t.stack); j += 4 {
+				t.curveTo(dx1, s(j), s(j+1), s(j+2), 0, s(j+3))
+				dx1 = 0
+			}
+		case 27: // hhcurveto
+			j, dy1 := 0, 0.0
+			if len(t.stack)%2 == 1 {
+				dy1, j = s(0), 1
+			}
+			for ; j+3 < len(t.stack); j += 4 {
+				t.curveTo(s(j), dy1, s(j+1), s(j+2), s(j+3), 0)
+				dy1 = 0
+			}
+		case 30, 31: // vhcurveto, hvcurveto
+			horiz := b == 31
+			for j := 0; j+3 < len(t.stack); j += 4 {
+				last := 0.0
+				if j+5 == len(t.stack) {
+					last = s(j + 4)
+				}
+				if horiz {
+					t.curveTo(s(j), 0, s(j+1), s(j+2), last, s(j+3))
+				} else {
+					t.curveTo(0, s(j), s(j+1), s(j+2), s(j+3), last)
+				}
+				horiz = !horiz
+			}
+		case 10, 29: // callsubr, callgsubr
+			if len(t.stack) == 0 {
+				t.done = true
+				return
+			}
+			subrs := t.subrs
+			if b == 29 {
+				subrs = t.gsubrs
+			}
+			n := int(t.stack[len(t.stack)-1]) + subrBias(len(subrs))
+			t.stack = t.stack[:len(t.stack)-1]
+			if n < 0 || n >= len(subrs) {
+				t.done = true
+				return
+			}
+			t.run(subrs[n], depth+1)
+			continue
+		case 11: // return
+			return
+		case 14: // endchar
+			t.takeWidth(0, false)
+			if t.open {
+				t.segs = append(t.segs, PathSegment{Op: 'h'})
+			}
+			t.done = true
+			return
+		case 12:
+			if i >= len(code) {
+				t.done = true
+				return
+			}
+			esc := code[i]
+			i++
+			switch esc {
+			case 35: // flex
+				t.curveTo(s(0), s(1), s(2), s(3), s(4), s(5))
+				t.curveTo(s(6), s(7), s(8), s(9), s(10), s(11))
+			case 34: // hflex
+				y := t.y
+				t.curveTo(s(0), 0, s(1), s(2), s(3), 0)
+				t.curveTo(s(4), 0, s(5), y-t.y, s(6), 0)
+			case 36: // hflex1
+				y := t.y
+				t.curveTo(s(0), s(1), s(2), s(3), s(4), 0)
+				t.curveTo(s(5), 0, s(6), s(7), s(8), y-t.y-s(7))
+			case 37: // flex1
+				x0, y0 := t.x, t.y
+				dx, dy := 0.0, 0.0
+				for j := 0; j < 10; j += 2 {
+					dx += s(j)
+					dy += s(j + 1)
+				}
+				t.curveTo(s(0), s(1), s(2), s(3), s(4), s(5))
+				if math.Abs(dx) > math.Abs(dy) {
+					t.curveTo(s(6), s(7), s(8), s(9), s(10), y0-t.y-s(7)-s(9))
+				} else {
+					t.curveTo(s(6), s(7), s(8), s(9), x0-t.x-s(6)-s(8), s(10))
+				}
+			}
+		}
+		t.stack = t.stack[:0]
+	}
+}
+
+// cffStandardStrings are the names of the first standard strings (SIDs)
+// of CFF: those of the ISOAdobe charset.
+var cffStandardStrings = []string{
+	".notdef", "space", "exclam", "quotedbl", "numbersign", "dollar", "percent", "ampersand",
+	"quoteright", "parenleft", "parenright", "asterisk", "plus", "comma", "hyphen", "period",
+	"slash", "zero", "one", "two", "three", "four", "five", "six",
+	"seven", "eight", "nine", "colon", "semicolon", "less", "equal", "greater",
+	"question", "at", "A", "B", "C", "D", "E", "F",
+	"G", "H", "I", "J", "K", "L", "M", "N",
+	"O", "P", "Q", "R", "S", "T", "U", "V",
+	"W", "X", "Y", "Z", "bracketleft", "backslash", "bracketright", "asciicircum",
+	"underscore", "quoteleft", "a", "b", "c", "d", "e", "f",
+	"g", "h", "i", "j", "k", "l", "m", "n",
+	"o", "p", "q", "r", "s", "t", "u", "v",
+	"w", "x", "y", "z", "braceleft", "bar", "braceright", "asciitilde",
+	"exclamdown", "cent", "sterling", "fraction", "yen", "florin", "section", "currency",
+	"quotesingle", "quotedblleft", "guillemotleft", "guilsinglleft", "guilsinglright", "fi", "fl", "endash",
+	"dagger", "daggerdbl", "periodcentered", "paragraph", "bullet", "quotesinglbase", "quotedblbase", "quotedblright",
+	"guillemotright", "ellipsis", "perthousand", "questiondown", "grave", "acute", "circumflex", "tilde",
+	"macron", "breve", "dotaccent", "dieresis", "ring", "cedilla", "hungarumlaut", "ogonek",
+	"caron", "emdash", "AE", "ordfeminine", "Lslash", "Oslash", "OE", "ordmasculine",
+	"ae", "dotlessi", "lslash", "oslash", "oe", "germandbls", "onesuperior", "logicalnot",
+	"mu", "trademark", "Eth", "onehalf", "plusminus", "Thorn", "onequarter", "divide",
+	"brokenbar", "degree", "thorn", "threequarters", "twosuperior", "registered", "minus", "eth",
+	"multiply", "threesuperior", "copyright", "Aacute", "Acircumflex", "Adieresis", "Agrave", "Aring",
+	"Atilde", "Ccedilla", "Eacute", "Ecircumflex", "Edieresis", "Egrave", "Iacute", "Icircumflex",
+	"Idieresis", "Igrave", "Ntilde", "Oacute", "Ocircumflex", "Odieresis", "Ograve", "Otilde",
+	"Scaron", "Uacute", "Ucircumflex", "Udieresis", "Ugrave", "Yacute", "Ydieresis", "Zcaron",
+	"aacute", "acircumflex", "adieresis", "agrave", "aring", "atilde", "ccedilla", "eacute",
+	"ecircumflex", "edieresis", "egrave", "iacute", "icircumflex", "idieresis", "igrave", "ntilde",
+	"oacute", "ocircumflex", "odieresis", "ograve", "otilde", "scaron", "uacute", "ucircumflex",
+	"udieresis", "ugrave", "yacute", "ydieresis", "zcaron",
+}
@@ -0,0 +1,470 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// A cidRange maps the codes lo through hi to consecutive CIDs starting at cid.
+type cidRange struct {
+	lo  string
+	hi  string
+	cid int
+}
+
+// cid returns the CID for the code, or -1 if the CMap does not map it.
+func (m *cmap) cid(code string) int {
+	for _, r := range m.cidrange {
+		if len(r.lo) == len(code) && r.lo <= code && code <= r.hi {
+			return r.cid + codeValue(code) - codeValue(r.lo)
+		}
+	}
+	return -1
+}
+
+// A type0Encoder decodes text shown with a composite (Type0) font.
+// Codes are split and mapped to CIDs by the font's encoding CMap.
+// The text for a code comes from the ToUnicode CMap if it maps the code,
+// then from the code itself if the encoding is a predefined CMap for
+// a known character set, and then from the Unicode mapping of the
+// embedded TrueType font program.
+type type0Encoder struct {
+	enc       *cmap
+	text      func(code string) string // decodes codes of a predefined CMap, or nil
+	toUnicode *cmap                    // or nil
+	cidText   map[int]rune             // from the embedded font program, or nil
+	widths    map[int]float64          // from the CIDFont's W array
+	dw        float64                  // the CIDFont's default width
+}
+
+func (e *type0Encoder) Decode(ctx context.Context, raw string) (string, error) {
+	var b strings.Builder
+	for len(raw) > 0 {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		n, text, err := e.decodeCode(ctx, raw)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text)
+		raw = raw[n:]
+	}
+	return b.String(), nil
+}
+
+// decodeCode decodes the first code in raw, returning its length in
+// bytes and its text.
+func (e *type0Encoder) decodeCode(ctx context.Context, raw string) (n int, text string, err error) {
+	n = e.enc.codeLen(raw)
+	if n == 0 {
+		// Not in the codespace: the PDF specification has the shortest
+		// code length used, but one byte keeps going without losing much.
+		n = 1
+	}
+	code := raw[:n]
+	if e.toUnicode != nil {
+		if text, ok, err := e.toUnicode.find(code); ok || err != nil {
+			return n, text, err
+		}
+	}
+	if e.text != nil {
+		return n, e.text(code), nil
+	}
+	if r, ok := e.cidText[e.enc.cid(code)]; ok {
+		return n, string(r), nil
+	}
+	return n, string(noRune), nil
+}
+
+// width returns the width of the glyph for code,
+// in thousandths of text space units.
+func (e *type0Encoder) width(code string) float64 {
+	if w, ok := e.widths[e.enc.cid(code)]; ok {
+		return w
+	}
+	return e.dw
+}
+
+// type0Encoder returns the encoder for the composite font f.
+// Vertical writing is not supported: glyphs of a font using a vertical
+// CMap (one whose name ends in -V) are advanced horizontally.
+func (f Font) type0Encoder(ctx context.Context) (*type0Encoder, error) {
+	e := &type0Encoder{dw: 1000}
+	enc, err := f.V.Key("Encoding")
+	if err != nil {
+		return nil, err
+	}
+	switch enc.Kind() {
+	case Name:
+		e.enc, e.text = predefinedCMap(enc.Name())
+	case Stream:
+		if e.enc, err = readCmap(ctx, enc); err != nil {
+			return nil, err
+		}
+		if e.enc != nil && e.enc.usecmap != "" {
+			base, text := predefinedCMap(e.enc.usecmap)
+			if base == nil {
+				break
+			}
+			for i := range e.enc.space {
+				e.enc.space[i] = append(e.enc.space[i], base.space[i]...)
+			}
+			e.enc.cidrange = append(e.enc.cidrange, base.cidrange...)
+			e.text = text
+		}
+	}
+	if e.enc == nil {
+		e.enc, _ = predefinedCMap("Identity-H")
+	}
+
+	toUnicode, err := f.V.Key("ToUnicode")
+	if err != nil {
+		return nil, err
+	}
+	if toUnicode.Kind() == Stream {
+		if e.toUnicode, err = readCmap(ctx, toUnicode); err != nil {
+			return nil, err
+		}
+	}
+
+	desc, err := f.V.Key("DescendantFonts")
+	if err != nil {
+		return nil, err
+	}
+	if desc, err = desc.Index(0); err != nil {
+		return nil, err
+	}
+	if e.widths, e.dw, err = cidWidths(desc); err != nil {
+		return nil, err
+	}
+	if e.text == nil {
+		if e.cidText, err = trueTypeCIDText(ctx, desc); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// cidWidths returns the glyph widths given by the CIDFont desc's
+// W array, indexed by CID, and its default width DW.
+func cidWidths(desc Value) (map[int]float64, float64, error) {
+	dw := 1000.0
+	if v, err := desc.Key("DW"); err != nil {
+		return nil, 0, err
+	} else if k := v.Kind(); k == Integer || k == Real {
+		dw = v.Float64()
+	}
+	w, err := desc.Key("W")
+	if err != nil {
+		return nil, 0, err
+	}
+	widths := make(map[int]float64)
+	// W holds entries of the forms c [w1 w2 ... wn] and cfirst clast w.
+	for i := 0; i+1 < w.Len(); {
+		first, err := w.Index(i)
+		if err != nil {
+			return nil, 0, err
+		}
+		next, err := w.Index(i + 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		c := int(first.Int64())
+		if next.Kind() == Array {
+			for j := 0; j < next.Len(); j++ {
+				x, err := next.Index(j)
+				if err != nil {
+					return nil, 0, err
+				}
+				widths[c+j] = x.Float64()
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= w.Len() {
+			break
+		}
+		x, err := w.Index(i + 2)
+		if err != nil {
+			return nil, 0, err
+		}
+		last := int(next.Int64())
+		if last-c > 0xffff {
+			// CIDs are at most 65535; ignore absurd ranges.
+			last = c + 0xffff
+		}
+		for cid := c; cid <= last; cid++ {
+			widths[cid] = x.Float64()
+		}
+		i += 3
+	}
+	return widths, dw, nil
+}
+
+// trueTypeCIDText returns the Unicode characters for the CIDs of the
+// CIDFontType2 font desc, found by mapping each CID to a glyph index
+// with the font's CIDToGIDMap and the glyph index back to a character
+// with the cmap table of the embedded TrueType font program.
+// It returns nil if the font has no embedded TrueType program.
+func trueTypeCIDText(ctx context.Context, desc Value) (map[int]rune, error) {
+	fd, err := desc.Key("FontDescriptor")
+	if err != nil {
+		return nil, err
+	}
+	file, err := fd.Key("FontFile2")
+	if err != nil {
+		return nil, err
+	}
+	if file.Kind() != Stream {
+		return nil, nil
+	}
+	data, err := readAllStream(file)
+	if err != nil {
+		return nil, err
+	}
+	gidText := trueTypeGlyphText(data)
+	if len(gidText) == 0 {
+		return nil, nil
+	}
+
+	m, err := desc.Key("CIDToGIDMap")
+	if err != nil {
+		return nil, err
+	}
+	if m.Kind() != Stream {
+		// Identity: CIDs are glyph indexes.
+		cidText := make(map[int]rune, len(gidText))
+		for gid, r := range gidText {
+			cidText[int(gid)] = r
+		}
+		return cidText, nil
+	}
+	gids, err := readAllStream(m)
+	if err != nil {
+		return nil, err
+	}
+	cidText := make(map[int]rune)
+	for cid := 0; 2*cid+1 < len(gids); cid++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if r, ok := gidText[binary.BigEndian.Uint16(gids[2*cid:])]; ok {
+			cidText[cid] = r
+		}
+	}
+	return cidText, nil
+}
+
+// readAllStream returns the decoded data of the stream v.
+func readAllStream(v Value) ([]byte, error) {
+	rc, err := v.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// trueTypeGlyphText returns the character for each glyph index mapped by
+// the Unicode cmap subtable of the TrueType font program data.
+// When several characters map to a glyph, the lowest is used.
+// It returns nil if the font has no usable Unicode cmap subtable.
+func trueTypeGlyphText(data []byte) map[uint16]rune {
+	u16 := func(off int) int {
+		if off < 0 || off+2 > len(data) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(data[off:]))
+	}
+	u32 := func(off int) int {
+		if off < 0 || off+4 > len(data) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint32(data[off:]))
+	}
+
+	cmapOff := -1
+	for i, n := 0, u16(4); i < n; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			break
+		}
+		if string(data[rec:rec+4]) == "cmap" {
+			cmapOff = u32(rec + 8)
+		}
+	}
+	if cmapOff < 0 {
+		return nil
+	}
+
+	// Prefer the full Unicode subtable (3,10), then the BMP (3,1),
+	// then a Unicode platform subtable.
+	sub, best := -1, 0
+	for i, n := 0, u16(cmapOff+2); i < n; i++ {
+		rec := cmapOff + 4 + 8*i
+		platform, enc := u16(rec), u16(rec+2)
+		rank := 0
+		switch {
+		case platform == 3 && enc == 10:
+			rank = 3
+		case platform == 3 && enc == 1:
+			rank = 2
+		case platform == 0:
+			rank = 1
+		}
+		if rank > best {
+			sub, best = cmapOff+u32(rec+4), rank
+		}
+	}
+	if sub < 0 {
+		return nil
+	}
+
+	text := make(map[uint16]rune)
+	add := func(r rune, gid int) {
+		if gid == 0 || gid > 0xffff || !utf8.ValidRune(r) {
+			return
+		}
+		if old, ok := text[uint16(gid)]; !ok || r < old {
+			text[uint16(gid)] = r
+		}
+	}
+	switch u16(sub) {
+	case 4:
+		segs := u16(sub+6) / 2
+		ends := sub + 14
+		starts := ends + 2*segs + 2
+		deltas := starts + 2*segs
+		rangeOffs := deltas + 2*segs
+		for i := 0; i < segs; i++ {
+			start, end := u16(starts+2*i), u16(ends+2*i)
+			delta, ro := u16(deltas+2*i), u16(rangeOffs+2*i)
+			for c := start; c <= end && c != 0xffff; c++ {
+				gid := 0
+				if ro == 0 {
+					gid = (c + delta) & 0xffff
+				} else if g := u16(rangeOffs + 2*i + ro + 2*(c-start)); g != 0 {
+					gid = (g + delta) & 0xffff
+				}
+				add(rune(c), gid)
+			}
+		}
+	case 12:
+		total := 0
+		for i, n := 0, u32(sub+12); i < n; i++ {
+			g := sub + 16 + 12*i
+			if g+12 > len(data) {
+				break
+			}
+			start, end, gid := u32(g), u32(g+4), u32(g+8)
+			for c := start; c <= end && c <= utf8.MaxRune; c++ {
+				if total++; total > 0x110000 {
+					return text
+				}
+				add(rune(c), gid+c-start)
+			}
+		}
+	}
+	return text
+}
+
+// predefinedCMap returns the codespace and CID mapping of the predefined
+// CMap with the given name, so far as they are known, and, for CMaps
+// whose codes are in a known character set, a function returning the
+// text for a code. Identity CMaps map 2-byte codes to the same CIDs.
+// For an unknown CMap, predefinedCMap returns nil, nil.
+func predefinedCMap(name string) (*cmap, func(code string) string) {
+	base := strings.TrimSuffix(strings.TrimSuffix(name, "-H"), "-V")
+	if base == name {
+		return nil, nil
+	}
+	m := new(cmap)
+	space := func(lo, hi string) {
+		m.space[len(lo)-1] = append(m.space[len(lo)-1], byteRange{lo, hi})
+	}
+	decode := func(enc encoding.Encoding) func(string) string {
+		return func(code string) string {
+			s, err := enc.NewDecoder().String(code)
+			if err != nil {
+				return string(noRune)
+			}
+			return s
+		}
+	}
+
+	switch {
+	case base == "Identity":
+		space("\x00\x00", "\xff\xff")
+		m.cidrange = []cidRange{{"\x00\x00", "\xff\xff", 0}}
+		return m, nil
+	case strings.HasPrefix(base, "Uni"):
+		switch base[strings.LastIndex(base, "-")+1:] {
+		case "UCS2":
+			space("\x00\x00", "\xff\xff")
+			return m, utf16Decode
+		case "UTF16":
+			space("\x00\x00", "\xd7\xff")
+			space("\xd8\x00\xdc\x00", "\xdb\xff\xdf\xff")
+			space("\xe0\x00", "\xff\xff")
+			return m, utf16Decode
+		case "UTF8":
+			space("\x00", "\x7f")
+			space("\xc2\x80", "\xdf\xbf")
+			space("\xe0\x80\x80", "\xef\xbf\xbf")
+			space("\xf0\x80\x80\x80", "\xf4\xbf\xbf\xbf")
+			return m, func(code string) string { return code }
+		case "UTF32":
+			space("\x00\x00\x00\x00", "\x00\x10\xff\xff")
+			return m, func(code string) string {
+				return string(rune(codeValue(code)))
+			}
+		}
+		return nil, nil
+	}
+
+	switch base {
+	case "GB-EUC", "GBpc-EUC", "GBK-EUC", "GBKp-EUC":
+		space("\x00", "\x80")
+		space("\x81\x40", "\xfe\xfe")
+		return m, decode(simplifiedchinese.GBK)
+	case "GBK2K":
+		space("\x00", "\x80")
+		space("\x81\x40", "\xfe\xfe")
+		space("\x81\x30\x81\x30", "\xfe\x39\xfe\x39")
+		return m, decode(simplifiedchinese.GB18030)
+	case "83pv-RKSJ", "90ms-RKSJ", "90msp-RKSJ", "90pv-RKSJ", "Add-RKSJ", "Ext-RKSJ", "RKSJ":
+		space("\x00", "\x80")
+		space("\xa0", "\xdf")
+		space("\x81\x40", "\x9f\xfc")
+		space("\xe0\x40", "\xfc\xfc")
+		return m, decode(japanese.ShiftJIS)
+	case "EUC":
+		space("\x00", "\x80")
+		space("\x8e\xa0", "\x8e\xdf")
+		space("\xa1\xa1", "\xfe\xfe")
+		return m, decode(japanese.EUCJP)
+	case "B5pc", "ETen-B5", "ETenms-B5", "HKscs-B5":
+		space("\x00", "\x80")
+		space("\xa1\x40", "\xfe\xfe")
+		return m, decode(traditionalchinese.Big5)
+	case "KSC-EUC", "KSCpc-EUC", "KSCms-UHC", "KSCms-UHC-HW":
+		space("\x00", "\x80")
+		space("\x81\x41", "\xfe\xfe")
+		return m, decode(korean.EUCKR)
+	}
+	return nil, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+// A Collection describes how a portable collection (a PDF portfolio)
+// should be presented. See PDF 32000-1:2008, §12.3.5.
+type Collection struct {
+	V       Value
+	View    CollectionView   // initial view mode
+	Initial string           // name of the embedded file to show first (D)
+	Sort    []CollectionSort // sort order, most significant field first
+}
+
+// A CollectionView is the initial presentation of a portable collection.
+type CollectionView int
+
+const (
+	ViewDetails CollectionView = iota // files listed in a details table (/D, the default)
+	ViewTile                          // files shown as tiles (/T)
+	ViewHidden                        // file list hidden initially (/H)
+)
+
+// A CollectionSort is one field of a collection's sort order.
+type CollectionSort struct {
+	Field     string // name of a field in the collection schema
+	Ascending bool
+}
+
+// Collection returns the document's portable collection dictionary.
+// If the document is not a portfolio, Collection returns a Collection with c.V.IsNull().
+// View and Sort take their default values when the dictionary omits them:
+// a details view, and ascending order for every sort field.
+func (r *Reader) Collection() (Collection, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return Collection{}, err
+	}
+	coll, err := root.Key("Collection")
+	if err != nil {
+		return Collection{}, err
+	}
+	if coll.Kind() != Dict {
+		return Collection{}, nil
+	}
+	c := Collection{V: coll}
+
+	view, err := coll.Key("View")
+	if err != nil {
+		return Collection{}, err
+	}
+	switch view.Name() {
+	case "T":
+		c.View = ViewTile
+	case "H":
+		c.View = ViewHidden
+	}
+
+	initial, err := coll.Key("D")
+	if err != nil {
+		return Collection{}, err
+	}
+	c.Initial = initial.Text()
+
+	sort, err := coll.Key("Sort")
+	if err != nil {
+		return Collection{}, err
+	}
+	c.Sort, err = collectionSort(sort)
+	if err != nil {
+		return Collection{}, err
+	}
+	return c, nil
+}
+
+func collectionSort(sort Value) ([]CollectionSort, error) {
+	fields, err := sort.Key("S")
+	if err != nil {
+		return nil, err
+	}
+	asc, err := sort.Key("A")
+	if err != nil {
+		return nil, err
+	}
+	var out []CollectionSort
+	switch fields.Kind() {
+	case Name:
+		out = append(out, CollectionSort{fields.Name(), true})
+	case Array:
+		for i := 0; i < fields.Len(); i++ {
+			f, err := fields.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, CollectionSort{f.Name(), true})
+		}
+	}
+
+	// A is either a single boolean applying to the first field
+	// or an array with one boolean per field.
+	switch asc.Kind() {
+	case Bool:
+		if len(out) > 0 {
+			out[0].Ascending = asc.Bool()
+		}
+	case Array:
+		for i := 0; i < asc.Len() && i < len(out); i++ {
+			a, err := asc.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			if a.Kind() == Bool {
+				out[i].Ascending = a.Bool()
+			}
+		}
+	}
+	return out, nil
+}
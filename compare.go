@@ -0,0 +1,194 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// CompareOptions controls CompareStructure.
+type CompareOptions struct {
+	// IgnoreWhitespace treats any run of white space in page text
+	// as a single space and ignores leading and trailing white space.
+	IgnoreWhitespace bool
+}
+
+// A StructureDiff describes the structural differences between two documents.
+// The zero StructureDiff means the documents are structurally equal.
+type StructureDiff struct {
+	NumPages [2]int      // number of pages in each document, if different
+	Pages    []PageDiff  // pages that differ, in page order
+	Fonts    [2][]string // fonts used only by the first or only by the second document
+	Info     []InfoDiff  // document information entries that differ
+}
+
+// Equal reports whether no differences were found.
+func (d StructureDiff) Equal() bool {
+	return d.NumPages == [2]int{} && len(d.Pages) == 0 &&
+		len(d.Fonts[0]) == 0 && len(d.Fonts[1]) == 0 && len(d.Info) == 0
+}
+
+// A PageDiff describes the differences between two documents' versions of a page.
+// Only the fields for properties that differ are set.
+type PageDiff struct {
+	Page   int       // page number, starting at 1
+	Size   [2]Rect   // media boxes
+	Rotate [2]int    // rotations
+	Text   [2]string // plain text
+}
+
+// An InfoDiff describes a document information entry that differs.
+type InfoDiff struct {
+	Key   string
+	Value [2]string // the entry in each document; empty if absent
+}
+
+// CompareStructure compares the structure of documents a and b:
+// their page counts, each page's size, rotation, and text,
+// the set of fonts used, and the document information dictionary.
+// Only pages present in both documents are compared page by page.
+func CompareStructure(ctx context.Context, a, b *Reader, opts CompareOptions) (StructureDiff, error) {
+	var d StructureDiff
+	var sa, sb docSummary
+	var err error
+	if sa, err = summarize(ctx, a, opts); err != nil {
+		return StructureDiff{}, err
+	}
+	if sb, err = summarize(ctx, b, opts); err != nil {
+		return StructureDiff{}, err
+	}
+
+	if len(sa.pages) != len(sb.pages) {
+		d.NumPages = [2]int{len(sa.pages), len(sb.pages)}
+	}
+	for i := 0; i < len(sa.pages) && i < len(sb.pages); i++ {
+		pa, pb := sa.pages[i], sb.pages[i]
+		if pa == pb {
+			continue
+		}
+		pd := PageDiff{Page: i + 1}
+		if pa.size != pb.size {
+			pd.Size = [2]Rect{pa.size, pb.size}
+		}
+		if pa.rotate != pb.rotate {
+			pd.Rotate = [2]int{pa.rotate, pb.rotate}
+		}
+		if pa.text != pb.text {
+			pd.Text = [2]string{pa.text, pb.text}
+		}
+		d.Pages = append(d.Pages, pd)
+	}
+
+	d.Fonts[0] = setDiff(sa.fonts, sb.fonts)
+	d.Fonts[1] = setDiff(sb.fonts, sa.fonts)
+
+	keys := make(map[string]bool)
+	for k := range sa.info {
+		keys[k] = true
+	}
+	for k := range sb.info {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		if sa.info[k] != sb.info[k] {
+			d.Info = append(d.Info, InfoDiff{k, [2]string{sa.info[k], sb.info[k]}})
+		}
+	}
+	return d, nil
+}
+
+type docSummary struct {
+	pages []pageSummary
+	fonts map[string]bool
+	info  map[string]string
+}
+
+type pageSummary struct {
+	size   Rect
+	rotate int
+	text   string
+}
+
+func summarize(ctx context.Context, r *Reader, opts CompareOptions) (docSummary, error) {
+	s := docSummary{fonts: make(map[string]bool), info: make(map[string]string)}
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		var ps pageSummary
+		var err error
+		if ps.size, err = p.MediaBox(); err != nil {
+			return err
+		}
+		if ps.rotate, err = p.Rotate(); err != nil {
+			return err
+		}
+		if ps.text, err = p.GetPlainText(ctx, nil); err != nil {
+			return err
+		}
+		if opts.IgnoreWhitespace {
+			ps.text = strings.Join(strings.Fields(ps.text), " ")
+		}
+		s.pages = append(s.pages, ps)
+
+		names, err := p.Fonts()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			f, err := p.Font(name)
+			if err != nil {
+				return err
+			}
+			base, err := f.BaseFont()
+			if err != nil {
+				return err
+			}
+			// Subset fonts are named with a random tag, as in ABCDEF+Helvetica.
+			if i := strings.Index(base, "+"); i >= 0 {
+				base = base[i+1:]
+			}
+			s.fonts[base] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return docSummary{}, err
+	}
+
+	info, err := r.Trailer().Key("Info")
+	if err != nil {
+		return docSummary{}, err
+	}
+	for _, k := range info.Keys() {
+		v, err := info.Key(k)
+		if err != nil {
+			return docSummary{}, err
+		}
+		if v.Kind() == String {
+			s.info[k] = v.Text()
+		}
+	}
+	return s, nil
+}
+
+// setDiff returns the sorted elements of x that are not in y.
+func setDiff(x, y map[string]bool) []string {
+	d := make(map[string]bool)
+	for k := range x {
+		if !y[k] {
+			d[k] = true
+		}
+	}
+	return sortedKeys(d)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,339 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An Operator is one operation in a content stream:
+// an operator and the operands before it.
+// See PDF 32000-1:2008, §7.8.2 and Annex A.
+type Operator struct {
+	Name string  // the operator, such as "cm", "Tf", "Tj", "TJ", or "Do"
+	Args []Value // the operands, in order
+
+	// For an inline image, whose Name is "BI", Args holds a single
+	// dictionary of the image parameters, with keys as written
+	// (often abbreviated, as W for Width), and Data holds the image
+	// data as written, still encoded by any filters.
+	Data []byte
+}
+
+// String returns the operator and its operands in content stream syntax,
+// without the data of an inline image.
+func (op Operator) String() string {
+	var buf bytes.Buffer
+	var w Writer // for its formatting of objects
+	for _, a := range op.Args {
+		w.format(&buf, nil, a.data)
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(op.Name)
+	return buf.String()
+}
+
+// formatContent writes the operations ops to buf in content stream
+// syntax, one to a line, including the data of inline images.
+func formatContent(buf *bytes.Buffer, ops []Operator) {
+	var w Writer // for its formatting of objects
+	for _, op := range ops {
+		if op.Name != "BI" {
+			buf.WriteString(op.String())
+			buf.WriteByte('\n')
+			continue
+		}
+		buf.WriteString("BI")
+		if len(op.Args) == 1 {
+			params := op.Args[0]
+			for _, k := range params.Keys() {
+				buf.WriteByte(' ')
+				formatName(buf, k)
+				buf.WriteByte(' ')
+				w.format(buf, nil, params.data.(dict)[name(k)])
+			}
+		}
+		buf.WriteString(" ID ")
+		buf.Write(op.Data)
+		buf.WriteString("\nEI\n")
+	}
+}
+
+// ContentData returns the page's content: the data of its content
+// stream, decoded, or of all its content streams, concatenated.
+func (p Page) ContentData() ([]byte, error) {
+	return contentData(p.V)
+}
+
+// contentData returns the content of the page or annotation appearance
+// dictionary v, whose Contents is a stream or an array of streams.
+func contentData(v Value) ([]byte, error) {
+	c, err := v.Key("Contents")
+	if err != nil {
+		return nil, err
+	}
+	if c.Kind() == Stream {
+		return streamData(c)
+	}
+	var data []byte
+	for i := 0; i < c.Len(); i++ {
+		s, err := c.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		if s.Kind() != Stream {
+			continue
+		}
+		b, err := streamData(s)
+		if err != nil {
+			return nil, err
+		}
+		// Streams split the content only between tokens.
+		data = append(append(data, b...), '\n')
+	}
+	return data, nil
+}
+
+// Operators returns the operations in the page's content,
+// as returned by ContentData, in order.
+func (p Page) Operators(ctx context.Context) ([]Operator, error) {
+	data, err := p.ContentData()
+	if err != nil {
+		return nil, err
+	}
+	return ParseContent(ctx, data)
+}
+
+// ParseContent parses the content stream data, such as the decoded
+// data of a page's content or of a form XObject, into its operations.
+// Operands left without an operator at the end of data are ignored.
+func ParseContent(ctx context.Context, data []byte) ([]Operator, error) {
+	var ops []Operator
+	err := walkOperators(ctx, bytes.NewReader(data), func(op Operator) error {
+		ops = append(ops, op)
+		return nil
+	})
+	return ops, err
+}
+
+// walkOperators calls fn for each operation in the content stream
+// read from rd.
+func walkOperators(ctx context.Context, rd io.Reader, fn func(op Operator) error) error {
+	b := newBuffer(rd, 0)
+	b.allowEOF = true
+	b.allowObjptr = false
+	b.allowStream = false
+	var args []Value
+	for n := 0; ; n++ {
+		if n%1024 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tok, err := b.readToken()
+		if err != nil {
+			return fmt.Errorf("malformed content stream: %v", err)
+		}
+		if tok == io.EOF {
+			return nil
+		}
+		kw, ok := tok.(keyword)
+		if !ok || kw == "null" || kw == "[" || kw == "<<" {
+			b.unreadToken(tok)
+			obj, err := b.readObject()
+			if err != nil {
+				return fmt.Errorf("malformed content stream: %w", err)
+			}
+			args = append(args, Value{nil, objptr{}, obj})
+			continue
+		}
+		op := Operator{Name: string(kw), Args: args}
+		if kw == "BI" {
+			if op, err = readInlineImage(b); err != nil {
+				return err
+			}
+		}
+		if err := fn(op); err != nil {
+			return err
+		}
+		args = nil
+	}
+}
+
+// readInlineImage reads an inline image, following the BI operator:
+// its parameters, the ID operator, its data, and the EI operator.
+func readInlineImage(b *buffer) (Operator, error) {
+	params := make(dict)
+	for {
+		tok, err := b.readToken()
+		if err != nil {
+			return Operator{}, fmt.Errorf("malformed content stream: inline image: %v", err)
+		}
+		if tok == keyword("ID") {
+			break
+		}
+		key, ok := tok.(name)
+		if !ok {
+			return Operator{}, fmt.Errorf("malformed content stream: inline image: unexpected %v", objfmt(tok))
+		}
+		val, err := b.readObject()
+		if err != nil {
+			return Operator{}, fmt.Errorf("malformed content stream: inline image: %v", err)
+		}
+		params[key] = val
+	}
+	// A single white-space character separates ID from the data.
+	b.readByte()
+
+	var data []byte
+	length, ok := params["L"].(int64)
+	if !ok {
+		length, ok = params["Length"].(int64)
+	}
+	if ok && length >= 0 {
+		// The length of the data is given: read it and expect EI.
+		for i := int64(0); i < length; i++ {
+			c := b.readByte()
+			if b.eof {
+				return Operator{}, errors.New("malformed content stream: inline image: unexpected EOF")
+			}
+			data = append(data, c)
+		}
+		if tok, err := b.readToken(); err != nil || tok != keyword("EI") {
+			return Operator{}, errors.New("malformed content stream: inline image: missing EI")
+		}
+	} else {
+		// Otherwise the data ends at the first EI surrounded by white space.
+		for {
+			c := b.readByte()
+			if b.eof {
+				return Operator{}, errors.New("malformed content stream: inline image: missing EI")
+			}
+			data = append(data, c)
+			n := len(data)
+			if n >= 3 && isSpace(data[n-3]) && data[n-2] == 'E' && data[n-1] == 'I' {
+				c := b.readByte()
+				b.unreadByte()
+				if isSpace(c) || isDelim(c) {
+					data = data[:n-3]
+					break
+				}
+			}
+		}
+	}
+	return Operator{Name: "BI", Args: []Value{{nil, objptr{}, params}}, Data: data}, nil
+}
+
+// Abbreviations of the keys and values of inline image parameters.
+// See PDF 32000-1:2008, §8.9.7.
+var (
+	inlineImageKeys = map[name]name{
+		"BPC": "BitsPerComponent",
+		"CS":  "ColorSpace",
+		"D":   "Decode",
+		"DP":  "DecodeParms",
+		"F":   "Filter",
+		"H":   "Height",
+		"IM":  "ImageMask",
+		"I":   "Interpolate",
+		"W":   "Width",
+		"L":   "Length",
+	}
+	inlineImageNames = map[name]name{
+		"G":    "DeviceGray",
+		"RGB":  "DeviceRGB",
+		"CMYK": "DeviceCMYK",
+		"I":    "Indexed",
+		"AHx":  "ASCIIHexDecode",
+		"A85":  "ASCII85Decode",
+		"LZW":  "LZWDecode",
+		"Fl":   "FlateDecode",
+		"RL":   "RunLengthDecode",
+		"CCF":  "CCITTFaxDecode",
+		"DCT":  "DCTDecode",
+	}
+)
+
+// inlineImage returns the inline image of the BI operation op, in
+// content using the resources res of a document read by r, as an image
+// whose V is a stream with the image's data and its parameters, with
+// their abbreviations expanded. A color space named in the resources
+// is replaced by its definition.
+func inlineImage(r *Reader, op Operator, res Value) (Image, error) {
+	if len(op.Args) != 1 || op.Args[0].Kind() != Dict {
+		return Image{}, errors.New("malformed inline image")
+	}
+	params := op.Args[0].data.(dict)
+	hdr := make(dict, len(params)+2)
+	hdr["Type"] = name("XObject")
+	hdr["Subtype"] = name("Image")
+	for k, v := range params {
+		if full, ok := inlineImageKeys[k]; ok {
+			k = full
+		}
+		switch k {
+		case "ColorSpace", "Filter":
+			v = expandInlineNames(v)
+		}
+		hdr[k] = v
+	}
+	delete(hdr, "Length")
+	if cs, ok := hdr["ColorSpace"]; ok {
+		// Names other than those of the device spaces, and the bases
+		// of indexed spaces, may name color spaces in the resources.
+		lookup := func(x object) (object, error) {
+			n, ok := x.(name)
+			if !ok {
+				return x, nil
+			}
+			switch n {
+			case "DeviceGray", "DeviceRGB", "DeviceCMYK", "Indexed":
+				return x, nil
+			}
+			spaces, err := res.Key("ColorSpace")
+			if err != nil {
+				return nil, err
+			}
+			def, err := spaces.Key(string(n))
+			if err != nil {
+				return nil, err
+			}
+			return def.data, nil
+		}
+		var err error
+		if a, ok := cs.(array); ok && len(a) > 1 {
+			a = append(array(nil), a...)
+			a[1], err = lookup(a[1])
+			cs = a
+		} else {
+			cs, err = lookup(cs)
+		}
+		if err != nil {
+			return Image{}, err
+		}
+		hdr["ColorSpace"] = cs
+	}
+	return Image{Value{r, objptr{}, stream{hdr: hdr, data: op.Data}}}, nil
+}
+
+// expandInlineNames expands the abbreviated names in the inline image
+// color space or filter v, which may be an array of them.
+func expandInlineNames(v object) object {
+	switch x := v.(type) {
+	case name:
+		if full, ok := inlineImageNames[x]; ok {
+			return full
+		}
+	case array:
+		out := make(array, len(x))
+		for i, e := range x {
+			out[i] = expandInlineNames(e)
+		}
+		return out
+	}
+	return v
+}
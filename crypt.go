@@ -0,0 +1,282 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+)
+
+// initEncryptV5 sets up decryption for the AES-256 standard security
+// handler (V 5, R 5 or 6), trying password as both the user and the
+// owner password. See ISO 32000-2:2017, §7.6.4.3.3 and §7.6.4.4.
+func (r *Reader) initEncryptV5(encrypt dict, password string) error {
+	if !okayV5(encrypt) {
+		return fmt.Errorf("unsupported PDF: encryption version V=5; %v", objfmt(encrypt))
+	}
+	R, _ := encrypt["R"].(int64)
+	if R != 5 && R != 6 {
+		return fmt.Errorf("unsupported PDF: encryption revision R=%d", R)
+	}
+	O, _ := encrypt["O"].(string)
+	U, _ := encrypt["U"].(string)
+	OE, _ := encrypt["OE"].(string)
+	UE, _ := encrypt["UE"].(string)
+	if len(O) < 48 || len(U) < 48 || len(OE) != 32 || len(UE) != 32 {
+		return fmt.Errorf("malformed PDF: missing O=, U=, OE=, or UE= encryption parameters")
+	}
+
+	// Passwords are UTF-8, limited to 127 bytes.
+	// TODO: Passwords should be normalized with SASLprep.
+	pw := []byte(password)
+	if len(pw) > 127 {
+		pw = pw[:127]
+	}
+	hash := hashV5
+	if R == 5 {
+		hash = hashR5
+	}
+
+	// Each of O and U is a 32-byte hash followed by an 8-byte
+	// validation salt and an 8-byte key salt. The owner password
+	// hashes are salted with U as well.
+	var intermediate, wrapped []byte
+	owner := false
+	u := []byte(U[:48])
+	switch {
+	case bytes.Equal(hash(pw, []byte(U[32:40]), nil), u[:32]):
+		intermediate, wrapped = hash(pw, []byte(U[40:48]), nil), []byte(UE)
+	case bytes.Equal(hash(pw, []byte(O[32:40]), u), []byte(O[:32])):
+		intermediate, wrapped = hash(pw, []byte(O[40:48]), u), []byte(OE)
+		owner = true
+	default:
+		return ErrInvalidPassword
+	}
+
+	// The file key is wrapped with the intermediate key,
+	// using AES-256 with no padding and a zero IV.
+	block, err := aes.NewCipher(intermediate)
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 32)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, wrapped)
+
+	r.key = key
+	r.useAES = true
+	p, _ := encrypt["P"].(int64)
+	r.security = Security{
+		Encrypted:   true,
+		Filter:      "Standard",
+		V:           5,
+		R:           int(R),
+		KeyLength:   256,
+		Permissions: Permissions(uint32(p)),
+		Owner:       owner,
+	}
+	return nil
+}
+
+// okayV5 reports whether encrypt uses AESV3 for both strings and streams.
+func okayV5(encrypt dict) bool {
+	cf, ok := encrypt["CF"].(dict)
+	if !ok {
+		return false
+	}
+	stmf, _ := encrypt["StmF"].(name)
+	strf, _ := encrypt["StrF"].(name)
+	if stmf != strf {
+		return false
+	}
+	cfparam, _ := cf[stmf].(dict)
+	if cfparam["AuthEvent"] != nil && cfparam["AuthEvent"] != name("DocOpen") {
+		return false
+	}
+	return cfparam["CFM"] == name("AESV3")
+}
+
+// hashR5 is the password hash of the deprecated revision 5 handler:
+// a single round of SHA-256.
+func hashR5(pw, salt, udata []byte) []byte {
+	h := sha256.New()
+	h.Write(pw)
+	h.Write(salt)
+	h.Write(udata)
+	return h.Sum(nil)
+}
+
+// hashV5 is the revision 6 password hash (ISO 32000-2:2017, algorithm 2.B),
+// which iterates AES and the SHA-2 family of hashes.
+func hashV5(pw, salt, udata []byte) []byte {
+	k := hashR5(pw, salt, udata)
+	var k1, e []byte
+	for round := 0; round < 64 || int(e[len(e)-1]) > round-32; round++ {
+		k1 = k1[:0]
+		for i := 0; i < 64; i++ {
+			k1 = append(k1, pw...)
+			k1 = append(k1, k...)
+			k1 = append(k1, udata...)
+		}
+		block, _ := aes.NewCipher(k[:16])
+		e = make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		// The first 16 bytes of e, as a number modulo 3,
+		// choose the next hash.
+		sum := 0
+		for _, b := range e[:16] {
+			sum += int(b)
+		}
+		var h hash.Hash
+		switch sum % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		case 2:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+	}
+	return k[:32]
+}
+
+// padPassword returns pw truncated or padded to 32 bytes
+// (PDF 32000-1:2008, algorithm 2, step a).
+func padPassword(pw []byte) []byte {
+	out := make([]byte, 32)
+	n := copy(out, pw)
+	copy(out[n:], passwordPad)
+	return out
+}
+
+// legacyFileKey computes the n-byte file key of the RC4 and AES-128
+// standard security handlers (R 2 to 4) from the user password pw
+// (algorithm 2).
+func legacyFileKey(pw []byte, O string, P uint32, ID []byte, R int64, n int, encryptMetadata bool) []byte {
+	h := md5.New()
+	h.Write(padPassword(pw))
+	h.Write([]byte(O))
+	h.Write([]byte{byte(P), byte(P >> 8), byte(P >> 16), byte(P >> 24)})
+	h.Write(ID)
+	if R >= 4 && !encryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := h.Sum(nil)
+	if R < 3 {
+		return key[:40/8]
+	}
+	for i := 0; i < 50; i++ {
+		h.Reset()
+		h.Write(key[:n])
+		key = h.Sum(key[:0])
+	}
+	return key[:n]
+}
+
+// legacyOwnerKey computes the n-byte RC4 key with which the O entry of
+// an R 2 to 4 encryption dictionary encrypts the user password
+// (algorithm 3, steps a to d).
+func legacyOwnerKey(owner []byte, R int64, n int) []byte {
+	sum := md5.Sum(padPassword(owner))
+	key := sum[:]
+	if R < 3 {
+		return key[:40/8]
+	}
+	for i := 0; i < 50; i++ {
+		sum = md5.Sum(key[:n])
+		key = sum[:]
+	}
+	return key[:n]
+}
+
+// legacyUserPassword recovers the padded user password from the O entry
+// of an R 2 to 4 encryption dictionary, given the owner password
+// (algorithm 7).
+func legacyUserPassword(owner []byte, O string, R int64, n int) []byte {
+	key := legacyOwnerKey(owner, R, n)
+	user := []byte(O)
+	if R < 3 {
+		c, _ := rc4.NewCipher(key)
+		c.XORKeyStream(user, user)
+		return user
+	}
+	for i := 19; i >= 0; i-- {
+		rc4Rounds(key, user, i, i)
+	}
+	return user
+}
+
+// legacyUserHash computes the value that the U entry of an R 2 to 4
+// encryption dictionary begins with, given the file key
+// (algorithms 4 and 5).
+func legacyUserHash(key, ID []byte, R int64) []byte {
+	c, _ := rc4.NewCipher(key)
+	if R == 2 {
+		u := make([]byte, 32)
+		c.XORKeyStream(u, passwordPad)
+		return u
+	}
+	h := md5.New()
+	h.Write(passwordPad)
+	h.Write(ID)
+	u := h.Sum(nil)
+	c.XORKeyStream(u, u)
+	rc4Rounds(key, u, 1, 19)
+	return u
+}
+
+// rc4Rounds encrypts data in place with RC4 once for each i from
+// first to last, using key with each byte xor'ed with i.
+func rc4Rounds(key, data []byte, first, last int) {
+	key1 := make([]byte, len(key))
+	for i := first; i <= last; i++ {
+		for j := range key1 {
+			key1[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(key1)
+		c.XORKeyStream(data, data)
+	}
+}
+
+// Security describes how a document is encrypted and what it permits.
+type Security struct {
+	Encrypted bool
+	Filter    string // the security handler: Standard or Adobe.PubSec
+	V         int    // the encryption algorithm version
+	R         int    // the standard security handler's revision
+	KeyLength int    // the length of the file key, in bits
+
+	// Permissions are the operations the document allows a user
+	// who opened it with the user password or a recipient certificate.
+	Permissions Permissions
+
+	// Owner reports whether the document was opened with the owner
+	// password, which permits every operation.
+	Owner bool
+}
+
+// Permissions returns a description of the document's encryption
+// and the permissions it grants.
+// For an unencrypted document, it reports every operation permitted.
+func (r *Reader) Permissions() Security {
+	if !r.security.Encrypted {
+		return Security{Permissions: PermAll, Owner: true}
+	}
+	return r.security
+}
+
+// Allowed reports whether the operations perm are permitted.
+func (s Security) Allowed(perm Permissions) bool {
+	return !s.Encrypted || s.Owner || s.Permissions&perm == perm
+}
@@ -0,0 +1,201 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "context"
+
+// A Destination is a location in a document: a page, and a view of it.
+// See PDF 32000-1:2008, §12.3.2.
+type Destination struct {
+	Page int    // page number, starting at 1; 0 if the page is not in the document
+	Fit  string // how to show the page: XYZ, Fit, FitH, FitV, FitR, FitB, FitBH, or FitBV
+
+	// The coordinates of the view, in default user space.
+	// Those that the Fit does not use, or that are null
+	// to keep the current view, are 0.
+	Left, Bottom, Right, Top float64
+
+	Zoom float64 // for XYZ, the magnification; 0 to keep the current one
+}
+
+// Destination resolves the destination v: an explicit destination array,
+// or a name or string naming a destination in the document's Dests
+// dictionary or name tree. For an unknown named destination, Destination
+// returns a zero Destination. The destinations of GoToR actions, which
+// give page numbers in another file, are returned with those numbers.
+func (r *Reader) Destination(v Value) (Destination, error) {
+	v, err := r.namedDest(v)
+	if err != nil {
+		return Destination{}, err
+	}
+	return r.explicitDest(v)
+}
+
+// NamedDestinations returns the document's named destinations, resolved,
+// by name: those in the Dests dictionary of PDF 1.1 and those in the
+// Dests name tree. A name in both is resolved by the name tree.
+// Links and outline items that use names can be followed by looking
+// the names up here, or by passing them to Destination.
+func (r *Reader) NamedDestinations() (map[string]Destination, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]Destination)
+	add := func(name string, v Value) error {
+		v, err := destValue(v)
+		if err != nil {
+			return err
+		}
+		d, err := r.explicitDest(v)
+		if err != nil {
+			return err
+		}
+		out[name] = d
+		return nil
+	}
+	dests, err := root.Key("Dests")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range dests.Keys() {
+		v, err := dests.Key(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(name, v); err != nil {
+			return nil, err
+		}
+	}
+	names, err := root.Key("Names")
+	if err != nil {
+		return nil, err
+	}
+	tree, err := names.Key("Dests")
+	if err != nil {
+		return nil, err
+	}
+	if err := walkNameTree(tree, add); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// explicitDest returns the destination given by the explicit
+// destination array v, or a zero Destination if v is not an array.
+func (r *Reader) explicitDest(v Value) (Destination, error) {
+	if v.Kind() != Array {
+		return Destination{}, nil
+	}
+	page, err := v.Index(0)
+	if err != nil {
+		return Destination{}, err
+	}
+	fit, err := v.Index(1)
+	if err != nil {
+		return Destination{}, err
+	}
+	d := Destination{Fit: fit.Name()}
+	switch page.Kind() {
+	case Integer:
+		// Remote destinations count pages from 0.
+		d.Page = int(page.Int64()) + 1
+	case Dict:
+		if d.Page, err = r.pageNumber(page); err != nil {
+			return Destination{}, err
+		}
+	}
+	arg := func(i int) float64 {
+		x, _ := v.Index(2 + i)
+		return x.Float64()
+	}
+	switch d.Fit {
+	case "XYZ":
+		d.Left, d.Top, d.Zoom = arg(0), arg(1), arg(2)
+	case "FitH", "FitBH":
+		d.Top = arg(0)
+	case "FitV", "FitBV":
+		d.Left = arg(0)
+	case "FitR":
+		d.Left, d.Bottom, d.Right, d.Top = arg(0), arg(1), arg(2), arg(3)
+	}
+	return d, nil
+}
+
+// namedDest returns the explicit destination named by v, if v is a name
+// or string, or v itself otherwise.
+func (r *Reader) namedDest(v Value) (Value, error) {
+	if v.Kind() != Name && v.Kind() != String {
+		return v, nil
+	}
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return Value{}, err
+	}
+	var dest Value
+	if v.Kind() == Name {
+		// PDF 1.1 named destinations are names in the Dests dictionary.
+		dests, err := root.Key("Dests")
+		if err != nil {
+			return Value{}, err
+		}
+		if dest, err = dests.Key(v.Name()); err != nil {
+			return Value{}, err
+		}
+	} else {
+		names, err := root.Key("Names")
+		if err != nil {
+			return Value{}, err
+		}
+		tree, err := names.Key("Dests")
+		if err != nil {
+			return Value{}, err
+		}
+		want := v.RawString()
+		err = walkNameTree(tree, func(key string, val Value) error {
+			if dest.IsNull() && key == want {
+				dest = val
+			}
+			return nil
+		})
+		if err != nil {
+			return Value{}, err
+		}
+	}
+	return destValue(dest)
+}
+
+// destValue returns the destination dest, the value of a named
+// destination, which may be a dictionary holding it in D.
+func destValue(dest Value) (Value, error) {
+	if dest.Kind() == Dict {
+		return dest.Key("D")
+	}
+	return dest, nil
+}
+
+// pageNumber returns the number of the page whose dictionary is v,
+// or 0 if v is not a page of the document.
+func (r *Reader) pageNumber(v Value) (int, error) {
+	r.mu.Lock()
+	nums := r.pageNums
+	r.mu.Unlock()
+	if nums == nil {
+		nums = make(map[objptr]int)
+		err := r.walkPages(context.Background(), func(num int, p Page) error {
+			if _, ok := nums[p.V.ptr]; !ok {
+				nums[p.V.ptr] = num
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		r.mu.Lock()
+		r.pageNums = nums
+		r.mu.Unlock()
+	}
+	return nums[v.ptr], nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// A DrawingRegion is a cluster of painted paths forming a vector drawing,
+// such as a logo, chart, or diagram.
+type DrawingRegion struct {
+	Box      Rect // bounds of the region
+	Paths    int  // number of painted paths in the region
+	Segments int  // total number of path segments, a rough measure of complexity
+}
+
+// DrawingOptions controls how Drawings clusters paths into regions.
+type DrawingOptions struct {
+	// Gap is the distance, in points, within which two paths
+	// are considered connected and belong to the same region.
+	Gap float64
+
+	// MaxRuleWidth is the thickness, in points, below which a path
+	// with at most 4 segments is treated as a rule (a table border
+	// or separator line) rather than part of a drawing.
+	MaxRuleWidth float64
+
+	// MinSegments is the number of segments a region must have
+	// to be reported. It filters out plain boxes and single shapes.
+	MinSegments int
+}
+
+// DefaultDrawingOptions are reasonable DrawingOptions for typical documents.
+var DefaultDrawingOptions = DrawingOptions{
+	Gap:          2,
+	MaxRuleWidth: 2,
+	MinSegments:  8,
+}
+
+// Drawings returns the regions of the page covered by vector drawings.
+// Painted paths, other than those classified as rules, are clustered into
+// regions of connected paths as directed by opts; regions are returned
+// top to bottom, then left to right.
+// Coordinates are as selected by the Reader's TextOptions.
+// Paths in form XObjects are not considered.
+func (p Page) Drawings(ctx context.Context, opts DrawingOptions) ([]DrawingRegion, error) {
+	var paths []contentPath
+	_, err := p.walkContent(ctx, p.textOptions(), contentHandler{
+		path: func(pa contentPath) error {
+			if !isRule(pa, opts.MaxRuleWidth) {
+				paths = append(paths, pa)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Union the paths whose boxes, grown by Gap, overlap.
+	// Sorting by left edge lets the scan stop at the first path
+	// that starts too far to the right.
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].Box.Min.X < paths[j].Box.Min.X
+	})
+	parent := make([]int, len(paths))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, a := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			b := paths[j]
+			if b.Box.Min.X > a.Box.Max.X+opts.Gap {
+				break
+			}
+			if b.Box.Min.Y <= a.Box.Max.Y+opts.Gap && a.Box.Min.Y <= b.Box.Max.Y+opts.Gap {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	regions := make(map[int]*DrawingRegion)
+	var roots []int
+	for i, pa := range paths {
+		root := find(i)
+		d := regions[root]
+		if d == nil {
+			d = &DrawingRegion{Box: pa.Box}
+			regions[root] = d
+			roots = append(roots, root)
+		}
+		d.Box = unionRect(d.Box, pa.Box)
+		d.Paths++
+		d.Segments += pa.Segments
+	}
+	var out []DrawingRegion
+	for _, root := range roots {
+		if d := regions[root]; d.Segments >= opts.MinSegments {
+			out = append(out, *d)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Box.Max.Y != out[j].Box.Max.Y {
+			return out[i].Box.Max.Y > out[j].Box.Max.Y
+		}
+		return out[i].Box.Min.X < out[j].Box.Min.X
+	})
+	return out, nil
+}
+
+// isRule reports whether pa is a simple horizontal or vertical line
+// or thin rectangle, as used for table borders and separators.
+func isRule(pa contentPath, maxWidth float64) bool {
+	if pa.Segments > 4 {
+		return false
+	}
+	w := pa.Box.Max.X - pa.Box.Min.X
+	h := pa.Box.Max.Y - pa.Box.Min.Y
+	return math.Min(w, h) <= maxWidth
+}
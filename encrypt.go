@@ -0,0 +1,245 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rc4"
+	"errors"
+	"strings"
+)
+
+// Permissions are the operations that an encrypted document allows
+// a user who opens it with the user password: the bits of the P entry
+// of its encryption dictionary. See PDF 32000-1:2008, Table 22.
+type Permissions uint32
+
+const (
+	PermPrint            Permissions = 1 << 2  // print, at low quality unless PermPrintHighQuality is also set
+	PermModify           Permissions = 1 << 3  // modify the contents other than by the operations below
+	PermCopy             Permissions = 1 << 4  // copy or otherwise extract text and graphics
+	PermAnnotate         Permissions = 1 << 5  // add or modify annotations and fill in forms
+	PermFillForms        Permissions = 1 << 8  // fill in existing form fields, even without PermAnnotate
+	PermExtract          Permissions = 1 << 9  // extract text and graphics for accessibility
+	PermAssemble         Permissions = 1 << 10 // insert, rotate, or delete pages and create bookmarks
+	PermPrintHighQuality Permissions = 1 << 11 // print faithfully to the document
+
+	PermAll = PermPrint | PermModify | PermCopy | PermAnnotate | PermFillForms | PermExtract | PermAssemble | PermPrintHighQuality
+)
+
+// p returns the value of the P entry granting perm: the permission
+// bits with all the reserved bits that must be 1 set.
+func (perm Permissions) p() int64 {
+	return int64(int32(uint32(perm&PermAll) | 0xfffff0c0))
+}
+
+// An EncryptMethod is a way to encrypt a document.
+type EncryptMethod int
+
+const (
+	EncryptAES256 EncryptMethod = iota // AES with 256-bit keys (V 5, R 6), as defined by PDF 2.0
+	EncryptAES128                      // AES with 128-bit keys (V 4, R 4)
+	EncryptRC4                         // RC4 with 128-bit keys (V 2, R 3)
+)
+
+// Encryption describes how a Writer encrypts the document it writes,
+// with the standard security handler.
+type Encryption struct {
+	Method EncryptMethod
+
+	// UserPassword is required to open the document, unless empty.
+	// OwnerPassword opens the document with all permissions;
+	// if empty, it is the same as UserPassword.
+	UserPassword  string
+	OwnerPassword string
+
+	// Permissions are the operations allowed to a user
+	// who opens the document with the user password.
+	Permissions Permissions
+}
+
+// writerCrypt holds the state of a Writer encrypting its output.
+type writerCrypt struct {
+	key    []byte
+	useAES bool
+	dict   uint32 // object number of the encryption dictionary, which is not encrypted
+	id     string // the file identifier
+}
+
+// Encrypt arranges for the document written by w to be encrypted
+// as described by e. It must be called before any objects are written.
+// Close adds the encryption dictionary and a file identifier to the trailer.
+// Passwords for EncryptAES128 and EncryptRC4 should be ASCII;
+// those for EncryptAES256 may be any UTF-8 text.
+func (w *Writer) Encrypt(e Encryption) error {
+	if w.update != nil {
+		return errors.New("pdf: cannot encrypt an incremental update")
+	}
+	if len(w.offsets) > 1 {
+		return errors.New("pdf: Encrypt called after objects were written")
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	user, owner := []byte(e.UserPassword), []byte(e.OwnerPassword)
+	if len(owner) == 0 {
+		owner = user
+	}
+	P := e.Permissions.p()
+
+	var enc dict
+	var key []byte
+	switch e.Method {
+	default:
+		return errors.New("pdf: unknown encryption method")
+	case EncryptAES256:
+		if len(user) > 127 {
+			user = user[:127]
+		}
+		if len(owner) > 127 {
+			owner = owner[:127]
+		}
+		key = make([]byte, 32)
+		salts := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		if _, err := rand.Read(salts); err != nil {
+			return err
+		}
+		// U and O are each a hash followed by a validation salt and a
+		// key salt; UE and OE wrap the file key with a key derived from
+		// the key salt (ISO 32000-2:2017, algorithms 8 and 9).
+		U := append(hashV5(user, salts[0:8], nil), salts[0:16]...)
+		O := append(hashV5(owner, salts[16:24], U), salts[16:32]...)
+		wrap := func(k []byte) string {
+			block, _ := aes.NewCipher(k)
+			out := make([]byte, 32)
+			cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, key)
+			return string(out)
+		}
+		// Perms records P, encrypted with the file key (algorithm 10).
+		perms := make([]byte, 16)
+		rand.Read(perms[12:])
+		p := uint64(P)
+		for i := 0; i < 8; i++ {
+			perms[i] = byte(p >> (8 * uint(i)))
+		}
+		copy(perms[8:12], "Tadb")
+		block, _ := aes.NewCipher(key)
+		block.Encrypt(perms, perms)
+		enc = dict{
+			"V":      int64(5),
+			"R":      int64(6),
+			"Length": int64(256),
+			"CF":     dict{"StdCF": dict{"CFM": name("AESV3"), "AuthEvent": name("DocOpen"), "Length": int64(32)}},
+			"StmF":   name("StdCF"),
+			"StrF":   name("StdCF"),
+			"U":      string(U),
+			"O":      string(O),
+			"UE":     wrap(hashV5(user, salts[8:16], nil)),
+			"OE":     wrap(hashV5(owner, salts[24:32], U)),
+			"Perms":  string(perms),
+		}
+	case EncryptAES128, EncryptRC4:
+		R := int64(3)
+		if e.Method == EncryptAES128 {
+			R = 4
+		}
+		O := legacyOwnerHash(owner, user, R, 16)
+		key = legacyFileKey(user, string(O), uint32(P), id, R, 16, true)
+		// The rest of U is arbitrary.
+		U := append(legacyUserHash(key, id, R), make([]byte, 16)...)
+		enc = dict{
+			"V":      int64(2),
+			"R":      R,
+			"Length": int64(128),
+			"U":      string(U),
+			"O":      string(O),
+		}
+		if R == 4 {
+			enc["V"] = int64(4)
+			enc["CF"] = dict{"StdCF": dict{"CFM": name("AESV2"), "AuthEvent": name("DocOpen"), "Length": int64(16)}}
+			enc["StmF"] = name("StdCF")
+			enc["StrF"] = name("StdCF")
+		}
+	}
+	enc["Filter"] = name("Standard")
+	enc["P"] = P
+
+	ref := w.NewRef()
+	w.crypt = &writerCrypt{
+		key:    key,
+		useAES: e.Method != EncryptRC4,
+		dict:   ref.data.(writerRef).id,
+		id:     string(id),
+	}
+	return w.Write(ref, Value{data: enc})
+}
+
+// legacyOwnerHash computes the O entry of an R 3 or 4 encryption
+// dictionary with n-byte keys (PDF 32000-1:2008, algorithm 3).
+func legacyOwnerHash(owner, user []byte, R int64, n int) []byte {
+	key := legacyOwnerKey(owner, R, n)
+	o := padPassword(user)
+	c, _ := rc4.NewCipher(key)
+	c.XORKeyStream(o, o)
+	rc4Rounds(key, o, 1, 19)
+	return o
+}
+
+// encrypt returns data encrypted for object number id.
+func (c *writerCrypt) encrypt(id uint32, gen uint16, data []byte) []byte {
+	key := cryptKey(c.key, c.useAES, objptr{id, gen})
+	if !c.useAES {
+		out := make([]byte, len(data))
+		rc4c, _ := rc4.NewCipher(key)
+		rc4c.XORKeyStream(out, data)
+		return out
+	}
+	// A random IV, then the data with PKCS#5 padding.
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	out := make([]byte, aes.BlockSize+len(data)+pad)
+	rand.Read(out[:aes.BlockSize])
+	copy(out[aes.BlockSize:], data)
+	for i := len(out) - pad; i < len(out); i++ {
+		out[i] = byte(pad)
+	}
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], out[aes.BlockSize:])
+	return out
+}
+
+var permNames = []struct {
+	perm Permissions
+	name string
+}{
+	{PermPrint, "print"},
+	{PermModify, "modify"},
+	{PermCopy, "copy"},
+	{PermAnnotate, "annotate"},
+	{PermFillForms, "fill-forms"},
+	{PermExtract, "extract"},
+	{PermAssemble, "assemble"},
+	{PermPrintHighQuality, "print-high-quality"},
+}
+
+// String returns the names of the permissions granted, separated by commas,
+// or "none".
+func (perm Permissions) String() string {
+	var names []string
+	for _, n := range permNames {
+		if perm&n.perm != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,46 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+// fileSpecName returns the file name in the file specification v,
+// which is either a string or a file specification dictionary.
+// See PDF 32000-1:2008, §7.11.
+func fileSpecName(v Value) (string, error) {
+	if v.Kind() == String {
+		return v.Text(), nil
+	}
+	// UF is the preferred Unicode name; F is the portable name;
+	// Unix, DOS, and Mac are obsolete platform-specific names.
+	for _, key := range []string{"UF", "F", "Unix", "DOS", "Mac"} {
+		x, err := v.Key(key)
+		if err != nil {
+			return "", err
+		}
+		if name := x.Text(); name != "" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// embeddedFile returns the embedded file stream of the file specification
+// dictionary spec, preferring the Unicode (UF) entry of its EF dictionary.
+// If the file is not embedded, embeddedFile returns a null Value.
+func embeddedFile(spec Value) (Value, error) {
+	ef, err := spec.Key("EF")
+	if err != nil {
+		return Value{}, err
+	}
+	for _, key := range []string{"UF", "F", "Unix", "DOS", "Mac"} {
+		f, err := ef.Key(key)
+		if err != nil {
+			return Value{}, err
+		}
+		if f.Kind() == Stream {
+			return f, nil
+		}
+	}
+	return Value{}, nil
+}
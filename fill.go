@@ -0,0 +1,549 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormValues maps the fully qualified names of form fields
+// to the values to give them, as for FillForm.
+type FormValues map[string][]string
+
+// FillOptions controls how FillForm fills a form.
+type FillOptions struct {
+	// NeedAppearances asks viewers to regenerate the appearances of the
+	// fields (the NeedAppearances entry of the form) instead of having
+	// FillForm generate appearances for the text and choice fields it fills.
+	// FillForm sets NeedAppearances anyway for fields whose appearance
+	// it cannot generate, such as list boxes and text in fonts whose
+	// encoding lacks the characters needed.
+	NeedAppearances bool
+}
+
+// FillForm sets the values of the form fields named in values, writing
+// the changed objects with w, which must be an incremental update of r
+// (see Reader.Update). The caller must close w to finish the update.
+//
+// A text field takes one value, its text. A check box takes the name
+// of its on state (see Field.States) or "Off"; any other value other than
+// the empty string also turns a check box on. A radio button field takes
+// the state of the button to turn on, or "Off". A choice field takes the
+// export values of the options to select, which must be among the
+// field's Options unless the field is editable; only fields with
+// FieldFlagMultiSelect take more than one.
+// Push buttons and signature fields cannot be filled.
+func (r *Reader) FillForm(w *Writer, values FormValues, opts FillOptions) error {
+	if w.update != r {
+		return errors.New("pdf: FillForm requires an incremental update of the Reader")
+	}
+	fields, err := r.Fields()
+	if err != nil {
+		return err
+	}
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return err
+	}
+	form, err := root.Key("AcroForm")
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(fields))
+	found := make(map[string]bool)
+	for i, field := range fields {
+		if names[i], err = field.Name(); err != nil {
+			return err
+		}
+		found[names[i]] = true
+	}
+	for name := range values {
+		if !found[name] {
+			return fmt.Errorf("pdf: no form field %q", name)
+		}
+	}
+
+	f := &formFill{w: w, form: form, needAppearances: opts.NeedAppearances, edits: make(map[objptr]*objectEdit)}
+	for i, field := range fields {
+		vals, ok := values[names[i]]
+		if !ok {
+			continue
+		}
+		if err := f.fill(field, names[i], vals); err != nil {
+			return err
+		}
+	}
+
+	na, err := form.Key("NeedAppearances")
+	if err != nil {
+		return err
+	}
+	if f.needAppearances && !na.Bool() {
+		if isIndirect(form) {
+			f.edit(form).set["NeedAppearances"] = NewBool(true)
+		} else {
+			entries, err := dictEntries(form)
+			if err != nil {
+				return err
+			}
+			entries["NeedAppearances"] = NewBool(true)
+			f.edit(root).set["AcroForm"] = NewDict(entries)
+		}
+	}
+	for _, e := range f.order {
+		entries, err := dictEntries(e.v)
+		if err != nil {
+			return err
+		}
+		for k, v := range e.set {
+			entries[k] = v
+		}
+		if err := w.Write(e.v, NewDict(entries)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A formFill holds the state of FillForm.
+type formFill struct {
+	w               *Writer
+	form            Value
+	needAppearances bool
+	edits           map[objptr]*objectEdit
+	order           []*objectEdit
+}
+
+// An objectEdit holds the new entries of a dictionary to be rewritten.
+// Null entries are removed.
+type objectEdit struct {
+	v   Value
+	set map[string]Value
+}
+
+// edit returns the edit of the indirect object v, creating it if needed.
+func (f *formFill) edit(v Value) *objectEdit {
+	e := f.edits[v.ptr]
+	if e == nil {
+		e = &objectEdit{v: v, set: make(map[string]Value)}
+		f.edits[v.ptr] = e
+		f.order = append(f.order, e)
+	}
+	return e
+}
+
+// dictEntries returns the entries of the dictionary v.
+func dictEntries(v Value) (map[string]Value, error) {
+	entries := make(map[string]Value)
+	for _, k := range v.Keys() {
+		x, err := v.Key(k)
+		if err != nil {
+			return nil, err
+		}
+		entries[k] = x
+	}
+	return entries, nil
+}
+
+// fill sets the value of the field called name to vals.
+func (f *formFill) fill(field Field, name string, vals []string) error {
+	if !isIndirect(field.V) {
+		return fmt.Errorf("pdf: form field %q is not an indirect object", name)
+	}
+	kind, err := field.Kind()
+	if err != nil {
+		return err
+	}
+	flags, err := field.Flags()
+	if err != nil {
+		return err
+	}
+	widgets, err := field.widgets()
+	if err != nil {
+		return err
+	}
+	for _, wv := range widgets {
+		if !isIndirect(wv) {
+			return fmt.Errorf("pdf: widget of form field %q is not an indirect object", name)
+		}
+	}
+	if len(vals) > 1 && (kind != FieldChoice || flags&FieldFlagMultiSelect == 0) {
+		return fmt.Errorf("pdf: form field %q takes one value, not %d", name, len(vals))
+	}
+	val := ""
+	if len(vals) > 0 {
+		val = vals[0]
+	}
+	fe := f.edit(field.V)
+
+	switch kind {
+	default:
+		return fmt.Errorf("pdf: cannot fill %v form field %q", kind, name)
+
+	case FieldText:
+		max, err := field.MaxLen()
+		if err != nil {
+			return err
+		}
+		if max > 0 && len([]rune(val)) > max {
+			return fmt.Errorf("pdf: value of form field %q is longer than its MaxLen %d", name, max)
+		}
+		fe.set["V"] = NewText(val)
+		return f.appearances(field, widgets, val)
+
+	case FieldCheckBox, FieldRadio:
+		states, err := field.States()
+		if err != nil {
+			return err
+		}
+		state := "Off"
+		switch {
+		case val == "" || val == "Off":
+		case contains(states, val):
+			state = val
+		case kind == FieldCheckBox && len(states) > 0:
+			state = states[0]
+		default:
+			return fmt.Errorf("pdf: form field %q has no state %q", name, val)
+		}
+		fe.set["V"] = NewName(state)
+		// Each widget shows the state if it has an appearance for it.
+		for _, wv := range widgets {
+			ap, err := wv.Key("AP")
+			if err != nil {
+				return err
+			}
+			n, err := ap.Key("N")
+			if err != nil {
+				return err
+			}
+			as := "Off"
+			if on, err := n.Key(state); err != nil {
+				return err
+			} else if !on.IsNull() {
+				as = state
+			}
+			f.edit(wv).set["AS"] = NewName(as)
+		}
+		return nil
+
+	case FieldChoice:
+		options, err := field.Options()
+		if err != nil {
+			return err
+		}
+		var idx []int
+		display := ""
+		for i, v := range vals {
+			j := -1
+			for k, o := range options {
+				if o.Export == v {
+					j = k
+					break
+				}
+			}
+			if j < 0 {
+				if flags&FieldFlagEdit == 0 {
+					return fmt.Errorf("pdf: form field %q has no option %q", name, v)
+				}
+				if i == 0 {
+					display = v
+				}
+				continue
+			}
+			idx = append(idx, j)
+			if i == 0 {
+				display = options[j].Display
+			}
+		}
+		switch len(vals) {
+		case 0:
+			fe.set["V"] = Value{}
+		case 1:
+			fe.set["V"] = NewText(val)
+		default:
+			var texts []Value
+			for _, v := range vals {
+				texts = append(texts, NewText(v))
+			}
+			fe.set["V"] = NewArray(texts...)
+		}
+		// Multiple-selection list boxes also record the selected indices.
+		fe.set["I"] = Value{}
+		if flags&FieldFlagMultiSelect != 0 && len(idx) > 0 {
+			sort.Ints(idx)
+			var ints []Value
+			for _, i := range idx {
+				ints = append(ints, NewInt(int64(i)))
+			}
+			fe.set["I"] = NewArray(ints...)
+		}
+		if flags&FieldFlagCombo == 0 {
+			// List boxes show all their options; leave them to the viewer.
+			f.needAppearances = true
+			return nil
+		}
+		return f.appearances(field, widgets, display)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// appearances gives each widget of the text or combo box field a normal
+// appearance showing text, unless NeedAppearances is set.
+func (f *formFill) appearances(field Field, widgets []Value, text string) error {
+	if f.needAppearances {
+		return nil
+	}
+	for _, wv := range widgets {
+		ap, ok, err := f.textAppearance(field, wv, text)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			f.needAppearances = true
+			return nil
+		}
+		ref, err := f.w.Add(ap)
+		if err != nil {
+			return err
+		}
+		f.edit(wv).set["AP"] = NewDict(map[string]Value{"N": ref})
+	}
+	return nil
+}
+
+// textAppearance returns a form XObject showing text in the widget wv
+// of field, using the field's default appearance (DA).
+// It returns ok == false if the appearance cannot be generated.
+// See PDF 32000-1:2008, §12.7.3.3.
+func (f *formFill) textAppearance(field Field, wv Value, text string) (ap Value, ok bool, err error) {
+	da, err := wv.Key("DA")
+	if err != nil {
+		return Value{}, false, err
+	}
+	if da.IsNull() {
+		if da, err = field.findInherited("DA"); err != nil {
+			return Value{}, false, err
+		}
+	}
+	if da.IsNull() {
+		if da, err = f.form.Key("DA"); err != nil {
+			return Value{}, false, err
+		}
+	}
+	ops := strings.Fields(da.RawString())
+	tf := -1
+	for i, op := range ops {
+		if op == "Tf" && i >= 2 {
+			tf = i
+		}
+	}
+	if tf < 0 || !strings.HasPrefix(ops[tf-2], "/") {
+		return Value{}, false, nil
+	}
+	fontName := ops[tf-2][1:]
+	size, err := strconv.ParseFloat(ops[tf-1], 64)
+	if err != nil {
+		return Value{}, false, nil
+	}
+	dr, err := f.form.Key("DR")
+	if err != nil {
+		return Value{}, false, err
+	}
+	fonts, err := dr.Key("Font")
+	if err != nil {
+		return Value{}, false, err
+	}
+	fv, err := fonts.Key(fontName)
+	if err != nil {
+		return Value{}, false, err
+	}
+	font := Font{V: fv}
+	switch font.subtype() {
+	case "Type1", "TrueType", "MMType1":
+	default:
+		return Value{}, false, nil
+	}
+
+	flags, err := field.Flags()
+	if err != nil {
+		return Value{}, false, err
+	}
+	if flags&FieldFlagPassword != 0 {
+		text = strings.Repeat("*", len([]rune(text)))
+	}
+	codes, ok, err := encodeSimple(font, text)
+	if err != nil || !ok {
+		return Value{}, ok, err
+	}
+	widths := make([]float64, 256)
+	for c := range widths {
+		if widths[c], err = font.Width(c); err != nil {
+			return Value{}, false, err
+		}
+	}
+	width := func(s string) float64 {
+		w := 0.0
+		for i := 0; i < len(s); i++ {
+			w += widths[s[i]]
+		}
+		return w / 1000
+	}
+
+	rect, err := wv.Key("Rect")
+	if err != nil {
+		return Value{}, false, err
+	}
+	box, err := rectFromArray(rect)
+	if err != nil {
+		return Value{}, false, err
+	}
+	w, h := box.Max.X-box.Min.X, box.Max.Y-box.Min.Y
+	if w < 0 {
+		w = -w
+	}
+	if h < 0 {
+		h = -h
+	}
+	const pad = 2
+	multiline := flags&FieldFlagMultiline != 0
+	if size <= 0 {
+		// Auto size: fit a single line in the height and width.
+		size = 12
+		if !multiline {
+			size = (h - 2*pad) / 1.15
+			if tw := width(codes); tw > 0 && tw*size > w-2*pad {
+				size = (w - 2*pad) / tw
+			}
+			if size > 12 {
+				size = 12
+			}
+		}
+		if size < 4 {
+			size = 4
+		}
+	}
+	var lines []string
+	if multiline {
+		lines = wrapCodes(codes, (w-2*pad)/size, width)
+	} else {
+		lines = []string{strings.ReplaceAll(strings.ReplaceAll(codes, "\r", ""), "\n", " ")}
+	}
+	q, err := field.Quadding()
+	if err != nil {
+		return Value{}, false, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "/Tx BMC\nq\n%g %g %g %g re W n\nBT\n", float32(pad/2), float32(pad/2), float32(w-pad), float32(h-pad))
+	ops[tf-1] = strconv.FormatFloat(size, 'g', 4, 64)
+	buf.WriteString(strings.Join(ops, " "))
+	buf.WriteString("\n")
+	y := (h-size)/2 + 0.22*size
+	if multiline {
+		y = h - pad - size
+	}
+	lastX := 0.0
+	for i, line := range lines {
+		x := float64(pad)
+		switch q {
+		case 1:
+			x = (w - width(line)*size) / 2
+		case 2:
+			x = w - pad - width(line)*size
+		}
+		if i == 0 {
+			fmt.Fprintf(&buf, "%g %g Td\n", float32(x), float32(y))
+		} else {
+			fmt.Fprintf(&buf, "%g %g Td\n", float32(x-lastX), float32(-1.15*size))
+		}
+		lastX = x
+		formatString(&buf, line)
+		buf.WriteString(" Tj\n")
+	}
+	buf.WriteString("ET\nQ\nEMC\n")
+
+	hdr := NewDict(map[string]Value{
+		"Type":    NewName("XObject"),
+		"Subtype": NewName("Form"),
+		"BBox":    NewArray(NewInt(0), NewInt(0), NewReal(w), NewReal(h)),
+		"Resources": NewDict(map[string]Value{
+			"Font": NewDict(map[string]Value{fontName: fv}),
+		}),
+	})
+	return NewStream(hdr, buf.Bytes()), true, nil
+}
+
+// encodeSimple returns text encoded as codes of the simple font.
+// It returns ok == false if the font cannot show some of the text.
+// Line breaks are kept as is.
+func encodeSimple(font Font, text string) (codes string, ok bool, err error) {
+	enc, err := font.Encoder(context.Background())
+	if err != nil {
+		return "", false, err
+	}
+	byRune := make(map[rune]byte)
+	for c := 255; c >= 0; c-- {
+		s, err := enc.Decode(context.Background(), string([]byte{byte(c)}))
+		if err != nil {
+			return "", false, err
+		}
+		if r := []rune(s); len(r) == 1 && r[0] != noRune {
+			byRune[r[0]] = byte(c)
+		}
+	}
+	var buf []byte
+	for _, r := range text {
+		if r == '\n' || r == '\r' {
+			buf = append(buf, byte(r))
+			continue
+		}
+		c, ok := byRune[r]
+		if !ok {
+			return "", false, nil
+		}
+		buf = append(buf, c)
+	}
+	return string(buf), true, nil
+}
+
+// wrapCodes splits codes into lines at line breaks and, where needed,
+// at spaces to fit lines in max text space units.
+func wrapCodes(codes string, max float64, width func(string) float64) []string {
+	var lines []string
+	codes = strings.ReplaceAll(codes, "\r\n", "\n")
+	codes = strings.ReplaceAll(codes, "\r", "\n")
+	for _, para := range strings.Split(codes, "\n") {
+		line := ""
+		for _, word := range strings.Split(para, " ") {
+			switch {
+			case line == "":
+				line = word
+			case width(line+" "+word) <= max:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
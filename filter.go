@@ -0,0 +1,295 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// dctDecode decodes the JPEG data read from rd into its samples:
+// 8 bits per component, with the components of each pixel interleaved,
+// row by row. Images are decoded to one component (gray), three (RGB,
+// converted from YCbCr as directed by the JPEG's markers), or four (CMYK).
+// The ColorTransform parameter is not consulted.
+func dctDecode(rd io.Reader) (io.Reader, error) {
+	m, err := jpeg.Decode(rd)
+	if err != nil {
+		return nil, fmt.Errorf("DCTDecode: %v", err)
+	}
+	b := m.Bounds()
+	switch m := m.(type) {
+	case *image.Gray:
+		return bytes.NewReader(m.Pix), nil
+	case *image.CMYK:
+		return bytes.NewReader(m.Pix), nil
+	}
+	out := make([]byte, 0, 3*b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := m.At(x, y).RGBA()
+			out = append(out, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+	}
+	return bytes.NewReader(out), nil
+}
+
+// A runLengthReader decodes RunLengthDecode data.
+// See PDF 32000-1:2008, §7.4.5.
+type runLengthReader struct {
+	r    *bufio.Reader
+	pend []byte
+	buf  [128]byte
+	err  error
+}
+
+func newRunLengthReader(rd io.Reader) *runLengthReader {
+	return &runLengthReader{r: bufio.NewReader(rd)}
+}
+
+func (z *runLengthReader) Read(p []byte) (int, error) {
+	for len(z.pend) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.run()
+	}
+	n := copy(p, z.pend)
+	z.pend = z.pend[n:]
+	return n, nil
+}
+
+// run decodes one run, setting z.pend to its bytes.
+func (z *runLengthReader) run() error {
+	n, err := z.r.ReadByte()
+	if err != nil {
+		// A missing EOD marker ends the data anyway.
+		return err
+	}
+	switch {
+	case n == 128:
+		return io.EOF
+	case n < 128:
+		// Copy the next n+1 bytes literally.
+		if _, err := io.ReadFull(z.r, z.buf[:int(n)+1]); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		z.pend = z.buf[:int(n)+1]
+	default:
+		// Repeat the next byte 257-n times.
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		z.pend = z.buf[:257-int(n)]
+		for i := range z.pend {
+			z.pend[i] = b
+		}
+	}
+	return nil
+}
+
+// applyPredictor undoes the predictor, if any, described by the
+// FlateDecode or LZWDecode parameters param.
+// See PDF 32000-1:2008, §7.4.4.4.
+func applyPredictor(rd io.Reader, param Value) (io.Reader, error) {
+	get := func(key string, def int64) (int, error) {
+		v, err := param.Key(key)
+		if err != nil {
+			return 0, err
+		}
+		if v.Kind() != Integer {
+			return int(def), nil
+		}
+		return int(v.Int64()), nil
+	}
+	pred, err := get("Predictor", 1)
+	if err != nil {
+		return nil, err
+	}
+	if pred == 1 {
+		return rd, nil
+	}
+	colors, err := get("Colors", 1)
+	if err != nil {
+		return nil, err
+	}
+	bpc, err := get("BitsPerComponent", 8)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := get("Columns", 1)
+	if err != nil {
+		return nil, err
+	}
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("invalid predictor BitsPerComponent %d", bpc)
+	}
+	if colors < 1 || colors > 32 || columns < 1 || columns > 1<<20 {
+		return nil, fmt.Errorf("invalid predictor Colors %d or Columns %d", colors, columns)
+	}
+	z := &predictReader{
+		r:      rd,
+		colors: colors,
+		bpc:    bpc,
+		bpp:    (colors*bpc + 7) / 8,
+	}
+	rowLen := (columns*colors*bpc + 7) / 8
+	switch {
+	case pred == 2:
+	case pred >= 10 && pred <= 15:
+		// Each row begins with its PNG filter type; the Predictor
+		// value itself does not constrain which types are used.
+		z.png = true
+	default:
+		return nil, fmt.Errorf("unknown predictor %d", pred)
+	}
+	z.prev = make([]byte, rowLen)
+	z.cur = make([]byte, rowLen)
+	return z, nil
+}
+
+// A predictReader undoes a TIFF (2) or PNG (10 to 15) predictor.
+type predictReader struct {
+	r      io.Reader
+	png    bool
+	colors int
+	bpc    int
+	bpp    int // bytes per pixel, at least 1
+
+	prev, cur []byte // the previous and current rows
+	tag       [1]byte
+	pend      []byte
+	err       error
+}
+
+func (z *predictReader) Read(p []byte) (int, error) {
+	for len(z.pend) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.row()
+	}
+	n := copy(p, z.pend)
+	z.pend = z.pend[n:]
+	return n, nil
+}
+
+// row decodes the next row, setting z.pend to it.
+// A final partial row is decoded as far as it goes.
+func (z *predictReader) row() error {
+	z.prev, z.cur = z.cur, z.prev
+	if z.png {
+		if _, err := io.ReadFull(z.r, z.tag[:]); err != nil {
+			return err
+		}
+	}
+	n, err := io.ReadFull(z.r, z.cur)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if n == 0 {
+			return io.EOF
+		}
+		err = io.EOF
+	} else if err != nil {
+		return err
+	}
+	cur := z.cur[:n]
+	if z.png {
+		if err := z.unfilterPNG(cur); err != nil {
+			return err
+		}
+	} else {
+		z.unfilterTIFF(cur)
+	}
+	z.pend = cur
+	return err
+}
+
+func (z *predictReader) unfilterPNG(cur []byte) error {
+	prev, bpp := z.prev, z.bpp
+	switch z.tag[0] {
+	case 0: // None
+	case 1: // Sub
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case 2: // Up
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case 3: // Average
+		for i := range cur {
+			var left int
+			if i >= bpp {
+				left = int(cur[i-bpp])
+			}
+			cur[i] += byte((left + int(prev[i])) / 2)
+		}
+	case 4: // Paeth
+		for i := range cur {
+			var a, c int
+			if i >= bpp {
+				a, c = int(cur[i-bpp]), int(prev[i-bpp])
+			}
+			b := int(prev[i])
+			pa, pb, pc := abs(b-c), abs(a-c), abs(a+b-2*c)
+			switch {
+			case pa <= pb && pa <= pc:
+				cur[i] += byte(a)
+			case pb <= pc:
+				cur[i] += byte(b)
+			default:
+				cur[i] += byte(c)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid PNG predictor filter type %d", z.tag[0])
+	}
+	return nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// unfilterTIFF undoes TIFF predictor 2: each sample is stored as its
+// difference from the same component of the pixel to its left.
+func (z *predictReader) unfilterTIFF(cur []byte) {
+	switch z.bpc {
+	case 8:
+		for i := z.colors; i < len(cur); i++ {
+			cur[i] += cur[i-z.colors]
+		}
+	case 16:
+		for i := 2 * z.colors; i+1 < len(cur); i += 2 {
+			v := uint16(cur[i])<<8 | uint16(cur[i+1])
+			v += uint16(cur[i-2*z.colors])<<8 | uint16(cur[i+1-2*z.colors])
+			cur[i], cur[i+1] = byte(v>>8), byte(v)
+		}
+	default:
+		// Samples of 1, 2, or 4 bits, packed into bytes.
+		bpc := uint(z.bpc)
+		mask := byte(1<<bpc - 1)
+		n := len(cur) * 8 / z.bpc
+		sample := func(i int) byte {
+			shift := 8 - bpc - uint(i*z.bpc%8)
+			return cur[i*z.bpc/8] >> shift & mask
+		}
+		for i := z.colors; i < n; i++ {
+			shift := 8 - bpc - uint(i*z.bpc%8)
+			v := (sample(i) + sample(i-z.colors)) & mask
+			cur[i*z.bpc/8] = cur[i*z.bpc/8]&^(mask<<shift) | v<<shift
+		}
+	}
+}
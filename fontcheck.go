@@ -0,0 +1,157 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+)
+
+// A FontWarning reports a font whose text is unlikely to extract correctly,
+// because nothing in the document maps its character codes to Unicode.
+// Pages whose text is drawn with such fonts are candidates for OCR.
+type FontWarning struct {
+	Page     int    // page number, starting at 1; 0 when returned by Page.FontWarnings
+	Font     string // resource name of the font, as in /F1
+	BaseFont string // PostScript name of the font
+	Reason   string
+}
+
+// FontWarnings returns a warning for each font used by the page
+// whose text cannot be reliably converted to Unicode: fonts with no
+// ToUnicode CMap whose encoding is neither a standard encoding nor
+// a Differences array of glyph names from the Adobe Glyph List.
+func (p Page) FontWarnings() ([]FontWarning, error) {
+	names, err := p.Fonts()
+	if err != nil {
+		return nil, err
+	}
+	var out []FontWarning
+	for _, name := range names {
+		f, err := p.Font(name)
+		if err != nil {
+			return nil, err
+		}
+		reason, err := f.extractionProblem()
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" {
+			continue
+		}
+		base, err := f.BaseFont()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, FontWarning{Font: name, BaseFont: base, Reason: reason})
+	}
+	return out, nil
+}
+
+// FontWarnings returns the font warnings for every page of the document.
+func (r *Reader) FontWarnings(ctx context.Context) ([]FontWarning, error) {
+	var out []FontWarning
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		w, err := p.FontWarnings()
+		if err != nil {
+			return err
+		}
+		for i := range w {
+			w[i].Page = num
+		}
+		out = append(out, w...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// extractionProblem returns a description of why text in f
+// cannot be mapped to Unicode, or the empty string if it can.
+func (f Font) extractionProblem() (string, error) {
+	toUnicode, err := f.V.Key("ToUnicode")
+	if err != nil {
+		return "", err
+	}
+	if toUnicode.Kind() == Stream {
+		return "", nil
+	}
+	subtype, err := f.V.Key("Subtype")
+	if err != nil {
+		return "", err
+	}
+	enc, err := f.V.Key("Encoding")
+	if err != nil {
+		return "", err
+	}
+
+	if subtype.Name() == "Type0" {
+		if _, text := predefinedCMap(enc.Name()); text != nil {
+			// Predefined Unicode and legacy character set CMaps
+			// map codes to Unicode directly.
+			return "", nil
+		}
+		desc, err := f.V.Key("DescendantFonts")
+		if err != nil {
+			return "", err
+		}
+		if desc, err = desc.Index(0); err != nil {
+			return "", err
+		}
+		text, err := trueTypeCIDText(context.Background(), desc)
+		if err != nil {
+			return "", err
+		}
+		if len(text) > 0 {
+			// The embedded TrueType program maps glyphs to Unicode.
+			return "", nil
+		}
+		return "composite font with no ToUnicode CMap", nil
+	}
+
+	switch enc.Kind() {
+	case Name:
+		switch enc.Name() {
+		case "WinAnsiEncoding", "MacRomanEncoding", "MacExpertEncoding", "StandardEncoding":
+			return "", nil
+		}
+		return "unknown encoding " + enc.Name() + " and no ToUnicode CMap", nil
+	case Dict:
+		diff, err := enc.Key("Differences")
+		if err != nil {
+			return "", err
+		}
+		for i := 0; i < diff.Len(); i++ {
+			x, err := diff.Index(i)
+			if err != nil {
+				return "", err
+			}
+			if x.Kind() == Name && glyphNameToRune(x.Name()) == 0 {
+				return "glyph name /" + x.Name() + " has no Unicode mapping and no ToUnicode CMap", nil
+			}
+		}
+		return "", nil
+	}
+
+	// No encoding: the font's built-in encoding applies, which is
+	// StandardEncoding for nonsymbolic Latin fonts but arbitrary otherwise.
+	if subtype.Name() == "Type3" {
+		return "Type 3 font with no encoding and no ToUnicode CMap", nil
+	}
+	desc, err := f.V.Key("FontDescriptor")
+	if err != nil {
+		return "", err
+	}
+	flags, err := desc.Key("Flags")
+	if err != nil {
+		return "", err
+	}
+	const symbolic = 1 << 2
+	if flags.Int64()&symbolic != 0 {
+		return "symbolic font with no encoding and no ToUnicode CMap", nil
+	}
+	return "", nil
+}
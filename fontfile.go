@@ -0,0 +1,161 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "context"
+
+// A FontProgram is a font program embedded in a document.
+type FontProgram struct {
+	Font Font   // the font embedding the program; for a composite font, its descendant CIDFont
+	Name string // the font's BaseFont, including any subset prefix such as ABCDEF+
+
+	// Subtype is the format of the program: Type1 (FontFile),
+	// TrueType (FontFile2), or, for FontFile3, the stream's Subtype:
+	// Type1C, CIDFontType0C, or OpenType.
+	Subtype string
+
+	V Value // the font file stream
+}
+
+// Data returns the decoded bytes of the font program.
+func (fp FontProgram) Data() ([]byte, error) {
+	return readAllStream(fp.V)
+}
+
+// Program returns the font program embedded in f.
+// If the font is not embedded, Program returns a FontProgram with V.IsNull().
+func (f Font) Program() (FontProgram, error) {
+	if f.subtype() == "Type0" {
+		desc, err := f.V.Key("DescendantFonts")
+		if err != nil {
+			return FontProgram{}, err
+		}
+		if desc, err = desc.Index(0); err != nil {
+			return FontProgram{}, err
+		}
+		f = Font{V: desc}
+	}
+	fd, err := f.V.Key("FontDescriptor")
+	if err != nil {
+		return FontProgram{}, err
+	}
+	for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+		file, err := fd.Key(key)
+		if err != nil {
+			return FontProgram{}, err
+		}
+		if file.Kind() != Stream {
+			continue
+		}
+		name, err := f.BaseFont()
+		if err != nil {
+			return FontProgram{}, err
+		}
+		fp := FontProgram{Font: f, Name: name, V: file}
+		switch key {
+		case "FontFile":
+			fp.Subtype = "Type1"
+		case "FontFile2":
+			fp.Subtype = "TrueType"
+		default:
+			sub, err := file.Key("Subtype")
+			if err != nil {
+				return FontProgram{}, err
+			}
+			fp.Subtype = sub.Name()
+		}
+		return fp, nil
+	}
+	return FontProgram{}, nil
+}
+
+// FontPrograms returns the font programs embedded in the document,
+// found in the font resources of its pages and of the form XObjects
+// and Type 3 fonts they use. Each program is returned once, in the
+// order first used.
+func (r *Reader) FontPrograms(ctx context.Context) ([]FontProgram, error) {
+	var out []FontProgram
+	seen := make(map[objptr]bool)
+	var walk func(res Value) error
+	walk = func(res Value) error {
+		fonts, err := res.Key("Font")
+		if err != nil {
+			return err
+		}
+		for _, name := range fonts.Keys() {
+			f, err := fonts.Key(name)
+			if err != nil {
+				return err
+			}
+			if f.Kind() != Dict {
+				continue
+			}
+			// A font stored directly in the resources shares their object number.
+			if f.ptr != res.ptr {
+				if seen[f.ptr] {
+					continue
+				}
+				seen[f.ptr] = true
+			}
+			font := Font{V: f}
+			if font.subtype() == "Type3" {
+				fres, err := f.Key("Resources")
+				if err != nil {
+					return err
+				}
+				if err := walk(fres); err != nil {
+					return err
+				}
+				continue
+			}
+			fp, err := font.Program()
+			if err != nil {
+				return err
+			}
+			if !fp.V.IsNull() && !seen[fp.V.ptr] {
+				seen[fp.V.ptr] = true
+				out = append(out, fp)
+			}
+		}
+		xobjs, err := res.Key("XObject")
+		if err != nil {
+			return err
+		}
+		for _, name := range xobjs.Keys() {
+			x, err := xobjs.Key(name)
+			if err != nil {
+				return err
+			}
+			if x.Kind() != Stream || seen[x.ptr] {
+				continue
+			}
+			seen[x.ptr] = true
+			if sub, err := x.Key("Subtype"); err != nil {
+				return err
+			} else if sub.Name() != "Form" {
+				continue
+			}
+			fres, err := x.Key("Resources")
+			if err != nil {
+				return err
+			}
+			if err := walk(fres); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		res, err := p.Resources()
+		if err != nil {
+			return err
+		}
+		return walk(res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,553 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+// A Field is a terminal field of an interactive form (AcroForm).
+// The methods interpret a field dictionary stored in V,
+// resolving attributes inherited from ancestor fields.
+// See PDF 32000-1:2008, §12.7.
+type Field struct {
+	V Value
+}
+
+// Fields returns the terminal fields of the document's interactive form,
+// in the order they appear in the field hierarchy.
+// Non-terminal fields, which only group their children, are not returned.
+func (r *Reader) Fields() ([]Field, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, err
+	}
+	form, err := root.Key("AcroForm")
+	if err != nil {
+		return nil, err
+	}
+	fields, err := form.Key("Fields")
+	if err != nil {
+		return nil, err
+	}
+	var out []Field
+	seen := make(map[objptr]bool)
+	var walk func(v Value) error
+	walk = func(v Value) error {
+		for i := 0; i < v.Len(); i++ {
+			f, err := v.Index(i)
+			if err != nil {
+				return err
+			}
+			if f.Kind() != Dict {
+				continue
+			}
+			// Fields are indirect objects, each with its own object
+			// pointer; refuse to visit one twice, or a field among
+			// its own kids, so that a cyclic hierarchy terminates.
+			if seen[f.ptr] {
+				continue
+			}
+			seen[f.ptr] = true
+			kids, err := f.Key("Kids")
+			if err != nil {
+				return err
+			}
+			terminal, err := isTerminalField(kids)
+			if err != nil {
+				return err
+			}
+			if terminal {
+				out = append(out, Field{f})
+				continue
+			}
+			if err := walk(kids); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(fields); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// isTerminalField reports whether a field with the given Kids is terminal:
+// its kids, if any, are widget annotations rather than fields.
+// Widgets are distinguished from fields by their lack of a partial name (T).
+func isTerminalField(kids Value) (bool, error) {
+	for i := 0; i < kids.Len(); i++ {
+		kid, err := kids.Index(i)
+		if err != nil {
+			return false, err
+		}
+		t, err := kid.Key("T")
+		if err != nil {
+			return false, err
+		}
+		if !t.IsNull() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (f Field) findInherited(key string) (Value, error) {
+	seen := make(map[objptr]bool)
+	for v := f.V; v.Kind() == Dict && !seen[v.ptr]; v = getParent(v) {
+		seen[v.ptr] = true
+		x, err := v.Key(key)
+		if err != nil {
+			return Value{}, err
+		}
+		if !x.IsNull() {
+			return x, nil
+		}
+	}
+	return Value{}, nil
+}
+
+// Name returns the field's fully qualified name: the partial names (T)
+// of the field and its ancestors, separated by periods.
+func (f Field) Name() (string, error) {
+	var name string
+	seen := make(map[objptr]bool)
+	for v := f.V; v.Kind() == Dict && !seen[v.ptr]; v = getParent(v) {
+		seen[v.ptr] = true
+		t, err := v.Key("T")
+		if err != nil {
+			return "", err
+		}
+		if t.IsNull() {
+			continue
+		}
+		if name == "" {
+			name = t.Text()
+		} else {
+			name = t.Text() + "." + name
+		}
+	}
+	return name, nil
+}
+
+// Type returns the field type (FT): "Btn", "Tx", "Ch", or "Sig".
+func (f Field) Type() (string, error) {
+	ft, err := f.findInherited("FT")
+	if err != nil {
+		return "", err
+	}
+	return ft.Name(), nil
+}
+
+// A FieldKind is the kind of an interactive form field,
+// determined by its type (FT) and flags (Ff).
+type FieldKind int
+
+const (
+	FieldUnknown    FieldKind = iota
+	FieldText                 // a text field (Tx)
+	FieldCheckBox             // a check box (Btn)
+	FieldRadio                // a set of radio buttons (Btn with FieldFlagRadio)
+	FieldPushButton           // a push button, which holds no value (Btn with FieldFlagPushButton)
+	FieldChoice               // a list box or combo box (Ch)
+	FieldSignature            // a signature field (Sig)
+)
+
+func (k FieldKind) String() string {
+	switch k {
+	case FieldText:
+		return "Text"
+	case FieldCheckBox:
+		return "CheckBox"
+	case FieldRadio:
+		return "Radio"
+	case FieldPushButton:
+		return "PushButton"
+	case FieldChoice:
+		return "Choice"
+	case FieldSignature:
+		return "Signature"
+	}
+	return "Unknown"
+}
+
+// FieldFlags are the flags of a field (its Ff entry).
+// Bits 13 and up have different meanings for different field types.
+// See PDF 32000-1:2008, Tables 221, 226, 228, and 230.
+type FieldFlags uint32
+
+const (
+	FieldFlagReadOnly FieldFlags = 1 << 0
+	FieldFlagRequired FieldFlags = 1 << 1
+	FieldFlagNoExport FieldFlags = 1 << 2
+
+	// Button fields.
+	FieldFlagNoToggleToOff  FieldFlags = 1 << 14
+	FieldFlagRadio          FieldFlags = 1 << 15
+	FieldFlagPushButton     FieldFlags = 1 << 16
+	FieldFlagRadiosInUnison FieldFlags = 1 << 25
+
+	// Text fields.
+	FieldFlagMultiline       FieldFlags = 1 << 12
+	FieldFlagPassword        FieldFlags = 1 << 13
+	FieldFlagFileSelect      FieldFlags = 1 << 20
+	FieldFlagDoNotSpellCheck FieldFlags = 1 << 22 // also for choice fields
+	FieldFlagDoNotScroll     FieldFlags = 1 << 23
+	FieldFlagComb            FieldFlags = 1 << 24
+	FieldFlagRichText        FieldFlags = 1 << 25
+
+	// Choice fields.
+	FieldFlagCombo             FieldFlags = 1 << 17
+	FieldFlagEdit              FieldFlags = 1 << 18
+	FieldFlagSort              FieldFlags = 1 << 19
+	FieldFlagMultiSelect       FieldFlags = 1 << 21
+	FieldFlagCommitOnSelChange FieldFlags = 1 << 26
+)
+
+// Flags returns the field's flags, which may be inherited.
+func (f Field) Flags() (FieldFlags, error) {
+	ff, err := f.findInherited("Ff")
+	if err != nil {
+		return 0, err
+	}
+	return FieldFlags(uint32(ff.Int64())), nil
+}
+
+// Kind returns the kind of the field.
+func (f Field) Kind() (FieldKind, error) {
+	typ, err := f.Type()
+	if err != nil {
+		return FieldUnknown, err
+	}
+	flags, err := f.Flags()
+	if err != nil {
+		return FieldUnknown, err
+	}
+	switch typ {
+	case "Tx":
+		return FieldText, nil
+	case "Ch":
+		return FieldChoice, nil
+	case "Sig":
+		return FieldSignature, nil
+	case "Btn":
+		switch {
+		case flags&FieldFlagPushButton != 0:
+			return FieldPushButton, nil
+		case flags&FieldFlagRadio != 0:
+			return FieldRadio, nil
+		}
+		return FieldCheckBox, nil
+	}
+	return FieldUnknown, nil
+}
+
+// Value returns the field's value (V), which may be inherited:
+// a text string for a text field, a name for a check box or radio
+// button, a text string or array of text strings for a choice field,
+// and a signature dictionary for a signed signature field.
+// See Text and Values for the value as strings.
+func (f Field) Value() (Value, error) {
+	return f.findInherited("V")
+}
+
+// Default returns the value (DV) to which the field reverts
+// when the form is reset.
+func (f Field) Default() (Value, error) {
+	return f.findInherited("DV")
+}
+
+// Text returns the field's value as a string: the text of a text field,
+// the state of a check box or radio button ("Off" if it has none),
+// or the first selected option of a choice field.
+// Rich text values held in streams are returned as the stream's data.
+// Text returns the empty string for push buttons and signature fields.
+func (f Field) Text() (string, error) {
+	vals, err := f.Values()
+	if err != nil || len(vals) == 0 {
+		return "", err
+	}
+	return vals[0], nil
+}
+
+// Values returns the field's value as a list of strings.
+// It is the same as Text, except that a choice field that allows
+// multiple selections may have any number of values.
+func (f Field) Values() ([]string, error) {
+	kind, err := f.Kind()
+	if err != nil {
+		return nil, err
+	}
+	v, err := f.Value()
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case FieldCheckBox, FieldRadio:
+		if v.Kind() != Name {
+			return []string{"Off"}, nil
+		}
+		return []string{v.Name()}, nil
+	case FieldText, FieldChoice:
+		switch v.Kind() {
+		case String:
+			return []string{v.Text()}, nil
+		case Stream:
+			data, err := streamData(v)
+			if err != nil {
+				return nil, err
+			}
+			return []string{Value{data: string(data)}.Text()}, nil
+		case Array:
+			return textArray(v)
+		}
+	}
+	return nil, nil
+}
+
+// Checked reports whether a check box or radio button is on:
+// whether its value is a state other than Off.
+func (f Field) Checked() (bool, error) {
+	v, err := f.Value()
+	if err != nil {
+		return false, err
+	}
+	return v.Kind() == Name && v.Name() != "Off", nil
+}
+
+// A FieldOption is one of the options of a choice field.
+type FieldOption struct {
+	Export  string // the value exported when the option is selected
+	Display string // the text shown for the option; the same as Export unless set
+}
+
+// Options returns the options of a choice field (Opt).
+// For check boxes and radio buttons, Opt may instead give the export
+// values of the field's widgets, which Options returns as well.
+func (f Field) Options() ([]FieldOption, error) {
+	opt, err := f.findInherited("Opt")
+	if err != nil {
+		return nil, err
+	}
+	var out []FieldOption
+	for i := 0; i < opt.Len(); i++ {
+		x, err := opt.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		switch x.Kind() {
+		case String:
+			out = append(out, FieldOption{x.Text(), x.Text()})
+		case Array:
+			export, err := x.Index(0)
+			if err != nil {
+				return nil, err
+			}
+			display, err := x.Index(1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, FieldOption{export.Text(), display.Text()})
+		}
+	}
+	return out, nil
+}
+
+// States returns the names of the "on" states of a check box or radio
+// button, taken from the normal appearances of its widgets.
+// A check box usually has one, and each button of a radio button
+// field has its own.
+func (f Field) States() ([]string, error) {
+	widgets, err := f.widgets()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, w := range widgets {
+		ap, err := w.Key("AP")
+		if err != nil {
+			return nil, err
+		}
+		n, err := ap.Key("N")
+		if err != nil {
+			return nil, err
+		}
+		if n.Kind() != Dict {
+			continue
+		}
+		for _, state := range n.Keys() {
+			if state != "Off" && !seen[state] {
+				seen[state] = true
+				out = append(out, state)
+			}
+		}
+	}
+	return out, nil
+}
+
+// widgets returns the widget annotations of a terminal field:
+// its kids or, if the field and its only widget are merged, the field itself.
+func (f Field) widgets() ([]Value, error) {
+	kids, err := f.V.Key("Kids")
+	if err != nil {
+		return nil, err
+	}
+	if kids.Len() == 0 {
+		return []Value{f.V}, nil
+	}
+	var out []Value
+	for i := 0; i < kids.Len(); i++ {
+		kid, err := kids.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		if kid.Kind() == Dict {
+			out = append(out, kid)
+		}
+	}
+	return out, nil
+}
+
+// MaxLen returns the maximum length of a text field's value, in characters.
+// It returns 0 if the field sets no maximum.
+func (f Field) MaxLen() (int, error) {
+	n, err := f.findInherited("MaxLen")
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+// Quadding returns the justification of the field's text:
+// 0 for left-justified, 1 for centered, or 2 for right-justified.
+// A field without its own Q inherits it from its ancestors or,
+// failing that, from the document's interactive form dictionary.
+func (f Field) Quadding() (int, error) {
+	q, err := f.findInherited("Q")
+	if err != nil {
+		return 0, err
+	}
+	if q.IsNull() && f.V.r != nil {
+		root, err := f.V.r.Trailer().Key("Root")
+		if err != nil {
+			return 0, err
+		}
+		form, err := root.Key("AcroForm")
+		if err != nil {
+			return 0, err
+		}
+		q, err = form.Key("Q")
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int(q.Int64()), nil
+}
+
+// Tooltip returns the field's alternate name (TU), used in place of the
+// field name in the user interface and by accessibility tools.
+// It returns the empty string if the field has no alternate name.
+func (f Field) Tooltip() (string, error) {
+	tu, err := f.V.Key("TU")
+	if err != nil {
+		return "", err
+	}
+	return tu.Text(), nil
+}
+
+// A SigLock describes the fields that are locked when a signature field is signed.
+// See PDF 32000-1:2008, §12.7.4.5, Table 233.
+type SigLock struct {
+	Action string   // "All", "Include", or "Exclude"; empty if the field has no lock
+	Fields []string // the fields included or excluded, for Include and Exclude
+}
+
+// Lock returns the signature field lock (the Lock entry) of a signature field.
+// If the field has no lock dictionary, Lock returns a zero SigLock.
+func (f Field) Lock() (SigLock, error) {
+	lock, err := f.V.Key("Lock")
+	if err != nil {
+		return SigLock{}, err
+	}
+	action, err := lock.Key("Action")
+	if err != nil {
+		return SigLock{}, err
+	}
+	fields, err := lock.Key("Fields")
+	if err != nil {
+		return SigLock{}, err
+	}
+	l := SigLock{Action: action.Name()}
+	l.Fields, err = textArray(fields)
+	if err != nil {
+		return SigLock{}, err
+	}
+	return l, nil
+}
+
+// A SigSeedValue holds the constraints a document author places on
+// the signature applied to a signature field.
+// See PDF 32000-1:2008, §12.7.4.5, Table 234.
+type SigSeedValue struct {
+	Flags        int      // which of the constraints are required (Ff)
+	Filter       string   // signature handler to use
+	SubFilter    []string // acceptable signature encodings, in order of preference
+	DigestMethod []string // acceptable digest algorithms, such as SHA256
+	Reasons      []string // acceptable reasons for signing
+}
+
+// SeedValue returns the seed value dictionary (the SV entry) of a signature field.
+// If the field has no seed value dictionary, SeedValue returns a zero SigSeedValue.
+func (f Field) SeedValue() (SigSeedValue, error) {
+	sv, err := f.V.Key("SV")
+	if err != nil {
+		return SigSeedValue{}, err
+	}
+	var s SigSeedValue
+	ff, err := sv.Key("Ff")
+	if err != nil {
+		return SigSeedValue{}, err
+	}
+	s.Flags = int(ff.Int64())
+	filter, err := sv.Key("Filter")
+	if err != nil {
+		return SigSeedValue{}, err
+	}
+	s.Filter = filter.Name()
+	for _, x := range []struct {
+		key string
+		dst *[]string
+	}{
+		{"SubFilter", &s.SubFilter},
+		{"DigestMethod", &s.DigestMethod},
+		{"Reasons", &s.Reasons},
+	} {
+		v, err := sv.Key(x.key)
+		if err != nil {
+			return SigSeedValue{}, err
+		}
+		if *x.dst, err = textArray(v); err != nil {
+			return SigSeedValue{}, err
+		}
+	}
+	return s, nil
+}
+
+// textArray returns the elements of the array v as strings,
+// taking names as written and decoding text strings.
+func textArray(v Value) ([]string, error) {
+	var out []string
+	for i := 0; i < v.Len(); i++ {
+		x, err := v.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		switch x.Kind() {
+		case Name:
+			out = append(out, x.Name())
+		case String:
+			out = append(out, x.Text())
+		}
+	}
+	return out, nil
+}
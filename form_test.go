@@ -0,0 +1,33 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "testing"
+
+func TestFieldsCycle(t *testing.T) {
+	// Field a, object 5, lists itself among its kids;
+	// field b, object 6, is an ordinary text field.
+	objs := pageTree(1)
+	objs[0] = "<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [5 0 R 6 0 R] >> >>"
+	objs = append(objs,
+		"<< /T (a) /Kids [5 0 R] >>",
+		"<< /T (b) /FT /Tx /V (hello) >>")
+	r := openPDF(t, buildPDF(objs...), nil)
+	fields, err := r.Fields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range fields {
+		name, err := f.Name()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if len(names) != 1 || names[0] != "b" {
+		t.Errorf("Fields = %q, want [b]", names)
+	}
+}
@@ -0,0 +1,639 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A function is a PDF function, loaded for evaluation, such as the
+// tint transform of a Separation color space. See PDF 32000-1:2008, §7.10.
+type function struct {
+	domain []float64 // for each input, its minimum and maximum
+	rng    []float64 // for each output, its minimum and maximum; nil if unbounded
+
+	typ int
+
+	// Type 0, sampled.
+	size    []int
+	bps     int
+	encode  []float64
+	decode  []float64
+	samples []byte
+
+	// Type 2, exponential interpolation.
+	c0, c1 []float64
+	n      float64
+
+	// Type 3, stitching.
+	funcs  []*function
+	bounds []float64
+
+	// Type 4, PostScript calculator.
+	prog []psItem
+
+	// An array of functions of one output each.
+	parts []*function
+}
+
+// loadFunction loads the function v: a function dictionary or stream,
+// or an array of functions whose outputs are concatenated.
+// depth guards against functions defined in terms of themselves.
+func loadFunction(v Value, depth int) (*function, error) {
+	if depth > 8 {
+		return nil, errors.New("function nested too deeply")
+	}
+	if v.Kind() == Array {
+		f := &function{}
+		for i := 0; i < v.Len(); i++ {
+			x, err := v.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			part, err := loadFunction(x, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			f.parts = append(f.parts, part)
+		}
+		if len(f.parts) == 0 {
+			return nil, errors.New("empty function array")
+		}
+		return f, nil
+	}
+	if v.Kind() != Dict && v.Kind() != Stream {
+		return nil, fmt.Errorf("invalid function %v", v)
+	}
+	// Function streams, whose data must be decoded, are loaded once.
+	r := v.r
+	if v.Kind() == Stream && r != nil {
+		r.mu.Lock()
+		f := r.functions[v.ptr]
+		r.mu.Unlock()
+		if f != nil {
+			return f, nil
+		}
+	}
+	typ, err := v.Key("FunctionType")
+	if err != nil {
+		return nil, err
+	}
+	f := &function{typ: int(typ.Int64())}
+	array := func(key string) ([]float64, error) {
+		a, err := v.Key(key)
+		if err != nil {
+			return nil, err
+		}
+		return numberArray(a)
+	}
+	if f.domain, err = array("Domain"); err != nil {
+		return nil, err
+	}
+	if len(f.domain) == 0 || len(f.domain)%2 != 0 {
+		return nil, fmt.Errorf("invalid function domain %v", f.domain)
+	}
+	if f.rng, err = array("Range"); err != nil {
+		return nil, err
+	}
+	if len(f.rng)%2 != 0 {
+		return nil, fmt.Errorf("invalid function range %v", f.rng)
+	}
+	switch f.typ {
+	case 0:
+		if v.Kind() != Stream || len(f.rng) == 0 {
+			return nil, errors.New("invalid sampled function")
+		}
+		size, err := array("Size")
+		if err != nil {
+			return nil, err
+		}
+		if len(size) != len(f.domain)/2 || len(size) > 16 {
+			return nil, fmt.Errorf("invalid sampled function size %v", size)
+		}
+		total := 1
+		for _, s := range size {
+			if s < 1 || s > 1<<16 || float64(total)*s > 1<<28 {
+				return nil, fmt.Errorf("invalid sampled function size %v", size)
+			}
+			f.size = append(f.size, int(s))
+			total *= int(s)
+		}
+		bps, err := v.Key("BitsPerSample")
+		if err != nil {
+			return nil, err
+		}
+		f.bps = int(bps.Int64())
+		switch f.bps {
+		case 1, 2, 4, 8, 12, 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("invalid BitsPerSample %d", f.bps)
+		}
+		if f.encode, err = array("Encode"); err != nil {
+			return nil, err
+		}
+		if len(f.encode) != len(f.domain) {
+			f.encode = nil
+			for _, s := range f.size {
+				f.encode = append(f.encode, 0, float64(s-1))
+			}
+		}
+		if f.decode, err = array("Decode"); err != nil {
+			return nil, err
+		}
+		if len(f.decode) != len(f.rng) {
+			f.decode = f.rng
+		}
+		if f.samples, err = streamData(v); err != nil {
+			return nil, err
+		}
+		if need := (total*len(f.rng)/2*f.bps + 7) / 8; len(f.samples) < need {
+			return nil, fmt.Errorf("sampled function data too short: %d < %d bytes", len(f.samples), need)
+		}
+	case 2:
+		if f.c0, err = array("C0"); err != nil {
+			return nil, err
+		}
+		if f.c1, err = array("C1"); err != nil {
+			return nil, err
+		}
+		if f.c0 == nil {
+			f.c0 = []float64{0}
+		}
+		if f.c1 == nil {
+			f.c1 = []float64{1}
+		}
+		if len(f.c0) != len(f.c1) {
+			return nil, errors.New("invalid exponential function: C0 and C1 differ in length")
+		}
+		n, err := v.Key("N")
+		if err != nil {
+			return nil, err
+		}
+		f.n = n.Float64()
+	case 3:
+		funcs, err := v.Key("Functions")
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < funcs.Len(); i++ {
+			x, err := funcs.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			sub, err := loadFunction(x, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			f.funcs = append(f.funcs, sub)
+		}
+		if f.bounds, err = array("Bounds"); err != nil {
+			return nil, err
+		}
+		if f.encode, err = array("Encode"); err != nil {
+			return nil, err
+		}
+		if len(f.funcs) == 0 || len(f.bounds) != len(f.funcs)-1 || len(f.encode) != 2*len(f.funcs) {
+			return nil, errors.New("invalid stitching function")
+		}
+	case 4:
+		if v.Kind() != Stream {
+			return nil, errors.New("invalid PostScript calculator function")
+		}
+		data, err := streamData(v)
+		if err != nil {
+			return nil, err
+		}
+		if f.prog, err = parsePSCalculator(string(data)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported function type %d", f.typ)
+	}
+	if v.Kind() == Stream && r != nil {
+		r.mu.Lock()
+		if r.functions == nil {
+			r.functions = make(map[objptr]*function)
+		}
+		r.functions[v.ptr] = f
+		r.mu.Unlock()
+	}
+	return f, nil
+}
+
+// eval evaluates the function at the inputs in.
+func (f *function) eval(in []float64) []float64 {
+	if f.parts != nil {
+		var out []float64
+		for _, p := range f.parts {
+			out = append(out, p.eval(in)...)
+		}
+		return out
+	}
+	x := make([]float64, len(f.domain)/2)
+	for i := range x {
+		if i < len(in) {
+			x[i] = in[i]
+		}
+		x[i] = clamp(x[i], f.domain[2*i], f.domain[2*i+1])
+	}
+	var out []float64
+	switch f.typ {
+	case 0:
+		out = f.sampled(x)
+	case 2:
+		t := math.Pow(x[0], f.n)
+		out = make([]float64, len(f.c0))
+		for i := range out {
+			out[i] = f.c0[i] + t*(f.c1[i]-f.c0[i])
+		}
+	case 3:
+		t := x[0]
+		k := 0
+		for k < len(f.bounds) && t >= f.bounds[k] {
+			k++
+		}
+		lo, hi := f.domain[0], f.domain[1]
+		if k > 0 {
+			lo = f.bounds[k-1]
+		}
+		if k < len(f.bounds) {
+			hi = f.bounds[k]
+		}
+		out = f.funcs[k].eval([]float64{interpolate(t, lo, hi, f.encode[2*k], f.encode[2*k+1])})
+	case 4:
+		out = runPSCalculator(f.prog, x)
+	}
+	for i := 0; 2*i+1 < len(f.rng) && i < len(out); i++ {
+		out[i] = clamp(out[i], f.rng[2*i], f.rng[2*i+1])
+	}
+	return out
+}
+
+// sampled evaluates a sampled function at x, interpolating linearly
+// between the samples surrounding it.
+func (f *function) sampled(x []float64) []float64 {
+	m, n := len(f.size), len(f.rng)/2
+	// The position of x in the sample table, in each dimension,
+	// and the index of the sample below it.
+	pos := make([]float64, m)
+	lo := make([]int, m)
+	for i := range x {
+		e := interpolate(x[i], f.domain[2*i], f.domain[2*i+1], f.encode[2*i], f.encode[2*i+1])
+		pos[i] = clamp(e, 0, float64(f.size[i]-1))
+		lo[i] = int(pos[i])
+		if lo[i] == f.size[i]-1 && lo[i] > 0 {
+			lo[i]--
+		}
+	}
+	max := math.Pow(2, float64(f.bps)) - 1
+	out := make([]float64, n)
+	// Sum the samples at the corners of the cell containing x,
+	// each weighted by its nearness to x.
+	for corner := 0; corner < 1<<uint(m); corner++ {
+		w := 1.0
+		index := 0
+		for i := m - 1; i >= 0; i-- {
+			j := lo[i]
+			frac := pos[i] - float64(lo[i])
+			if corner>>uint(i)&1 != 0 {
+				j++
+				w *= frac
+			} else {
+				w *= 1 - frac
+			}
+			if j >= f.size[i] {
+				j = f.size[i] - 1
+			}
+			index = index*f.size[i] + j
+		}
+		if w == 0 {
+			continue
+		}
+		for k := 0; k < n; k++ {
+			out[k] += w * float64(f.sample((index*n+k)*f.bps))
+		}
+	}
+	for k := range out {
+		out[k] = interpolate(out[k], 0, max, f.decode[2*k], f.decode[2*k+1])
+	}
+	return out
+}
+
+// sample returns the sample starting at the given bit offset.
+func (f *function) sample(bit int) uint64 {
+	var s uint64
+	for n := 0; n < f.bps; {
+		b := f.samples[bit/8]
+		used := bit % 8
+		take := min(8-used, f.bps-n)
+		s = s<<uint(take) | uint64(b>>uint(8-used-take))&(1<<uint(take)-1)
+		bit += take
+		n += take
+	}
+	return s
+}
+
+// interpolate maps x from the interval [xmin, xmax] to [ymin, ymax].
+func interpolate(x, xmin, xmax, ymin, ymax float64) float64 {
+	if xmax == xmin {
+		return ymin
+	}
+	return ymin + (x-xmin)*(ymax-ymin)/(xmax-xmin)
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
+
+// A psItem is an element of a PostScript calculator procedure:
+// a number, a boolean, an operator, or, for if and ifelse, a procedure.
+type psItem struct {
+	num    float64
+	op     string // the operator; "" for a number, "true" or "false" for a boolean
+	proc   []psItem
+	isProc bool
+}
+
+// parsePSCalculator parses the program of a PostScript calculator
+// function, a single procedure in braces.
+func parsePSCalculator(src string) ([]psItem, error) {
+	src = strings.NewReplacer("{", " { ", "}", " } ").Replace(src)
+	toks := strings.Fields(src)
+	if len(toks) == 0 || toks[0] != "{" {
+		return nil, errors.New("malformed PostScript calculator function")
+	}
+	var parse func(i int) ([]psItem, int, error)
+	parse = func(i int) ([]psItem, int, error) {
+		var proc []psItem
+		for i < len(toks) {
+			tok := toks[i]
+			i++
+			switch tok {
+			case "{":
+				sub, j, err := parse(i)
+				if err != nil {
+					return nil, 0, err
+				}
+				proc = append(proc, psItem{proc: sub, isProc: true})
+				i = j
+			case "}":
+				return proc, i, nil
+			default:
+				if x, err := strconv.ParseFloat(tok, 64); err == nil {
+					proc = append(proc, psItem{num: x})
+				} else if psOps[tok] || tok == "true" || tok == "false" {
+					proc = append(proc, psItem{op: tok})
+				} else {
+					return nil, 0, fmt.Errorf("unknown PostScript calculator operator %q", tok)
+				}
+			}
+		}
+		return nil, 0, errors.New("malformed PostScript calculator function: unbalanced braces")
+	}
+	prog, _, err := parse(1)
+	return prog, err
+}
+
+// psOps lists the operators of PostScript calculator functions.
+var psOps = map[string]bool{
+	"abs": true, "add": true, "atan": true, "ceiling": true, "cos": true,
+	"cvi": true, "cvr": true, "div": true, "exp": true, "floor": true,
+	"idiv": true, "ln": true, "log": true, "mod": true, "mul": true,
+	"neg": true, "round": true, "sin": true, "sqrt": true, "sub": true,
+	"truncate": true,
+	"and":      true, "bitshift": true, "eq": true, "ge": true, "gt": true,
+	"le": true, "lt": true, "ne": true, "not": true, "or": true, "xor": true,
+	"copy": true, "dup": true, "exch": true, "index": true, "pop": true,
+	"roll": true, "if": true, "ifelse": true,
+}
+
+// maxPSStack limits the operand stack of PostScript calculator functions.
+const maxPSStack = 100
+
+// A psValue is an operand of a PostScript calculator function:
+// a number, or a boolean, 1 for true and 0 for false.
+type psValue struct {
+	x      float64
+	isBool bool
+}
+
+// runPSCalculator runs the PostScript calculator program prog with the
+// inputs in on the stack and returns the numbers on the stack.
+// Errors, such as stack underflow, stop the program.
+func runPSCalculator(prog []psItem, in []float64) []float64 {
+	var stk []psValue
+	for _, x := range in {
+		stk = append(stk, psValue{x: x})
+	}
+	bad := false
+	pop := func() psValue {
+		if len(stk) == 0 {
+			bad = true
+			return psValue{}
+		}
+		v := stk[len(stk)-1]
+		stk = stk[:len(stk)-1]
+		return v
+	}
+	num := func() float64 { return pop().x }
+	push := func(x float64) {
+		if len(stk) >= maxPSStack {
+			bad = true
+			return
+		}
+		stk = append(stk, psValue{x: x})
+	}
+	pushBool := func(ok bool) {
+		push(0)
+		if !bad {
+			stk[len(stk)-1].isBool = true
+			if ok {
+				stk[len(stk)-1].x = 1
+			}
+		}
+	}
+	var run func(proc []psItem, depth int)
+	run = func(proc []psItem, depth int) {
+		if depth > 100 {
+			bad = true
+		}
+		for i := 0; i < len(proc) && !bad; i++ {
+			it := proc[i]
+			if it.isProc {
+				// A procedure is an operand of the if or ifelse following it.
+				if i+1 < len(proc) && proc[i+1].op == "if" {
+					if num() != 0 {
+						run(it.proc, depth+1)
+					}
+					i++
+				} else if i+2 < len(proc) && proc[i+1].isProc && proc[i+2].op == "ifelse" {
+					if num() != 0 {
+						run(it.proc, depth+1)
+					} else {
+						run(proc[i+1].proc, depth+1)
+					}
+					i += 2
+				} else {
+					bad = true
+				}
+				continue
+			}
+			switch it.op {
+			case "":
+				push(it.num)
+			case "true", "false":
+				pushBool(it.op == "true")
+			case "abs":
+				push(math.Abs(num()))
+			case "add":
+				y, x := num(), num()
+				push(x + y)
+			case "atan":
+				x, y := num(), num()
+				a := math.Atan2(y, x) * 180 / math.Pi
+				if a < 0 {
+					a += 360
+				}
+				push(a)
+			case "ceiling":
+				push(math.Ceil(num()))
+			case "cos":
+				push(math.Cos(num() * math.Pi / 180))
+			case "cvi", "truncate":
+				push(math.Trunc(num()))
+			case "cvr":
+			case "div":
+				y, x := num(), num()
+				if y == 0 {
+					bad = true
+					break
+				}
+				push(x / y)
+			case "exp":
+				e, base := num(), num()
+				push(math.Pow(base, e))
+			case "floor":
+				push(math.Floor(num()))
+			case "idiv", "mod":
+				y, x := int64(num()), int64(num())
+				if y == 0 {
+					bad = true
+					break
+				}
+				if it.op == "idiv" {
+					push(float64(x / y))
+				} else {
+					push(float64(x % y))
+				}
+			case "ln":
+				push(math.Log(num()))
+			case "log":
+				push(math.Log10(num()))
+			case "mul":
+				y, x := num(), num()
+				push(x * y)
+			case "neg":
+				push(-num())
+			case "round":
+				push(math.Floor(num() + 0.5))
+			case "sin":
+				push(math.Sin(num() * math.Pi / 180))
+			case "sqrt":
+				push(math.Sqrt(num()))
+			case "sub":
+				y, x := num(), num()
+				push(x - y)
+			case "and", "or", "xor":
+				// Booleans are 0 and 1, so the bitwise operations serve for both.
+				y, x := pop(), pop()
+				a, b := int64(x.x), int64(y.x)
+				switch it.op {
+				case "and":
+					a &= b
+				case "or":
+					a |= b
+				default:
+					a ^= b
+				}
+				if x.isBool {
+					pushBool(a != 0)
+				} else {
+					push(float64(a))
+				}
+			case "bitshift":
+				n, x := int64(num()), int64(num())
+				if n >= 0 {
+					push(float64(x << uint(min(n, 63))))
+				} else {
+					push(float64(x >> uint(min(-n, 63))))
+				}
+			case "not":
+				if x := pop(); x.isBool {
+					pushBool(x.x == 0)
+				} else {
+					push(float64(^int64(x.x)))
+				}
+			case "eq":
+				pushBool(num() == num())
+			case "ne":
+				pushBool(num() != num())
+			case "ge", "gt", "le", "lt":
+				y, x := num(), num()
+				pushBool(map[string]bool{"ge": x >= y, "gt": x > y, "le": x <= y, "lt": x < y}[it.op])
+			case "copy":
+				n := int(num())
+				if n < 0 || n > len(stk) || len(stk)+n > maxPSStack {
+					bad = true
+					break
+				}
+				stk = append(stk, stk[len(stk)-n:]...)
+			case "dup":
+				x := pop()
+				if !bad {
+					stk = append(stk, x, x)
+				}
+			case "exch":
+				y, x := pop(), pop()
+				if !bad {
+					stk = append(stk, y, x)
+				}
+			case "index":
+				n := int(num())
+				if n < 0 || n >= len(stk) {
+					bad = true
+					break
+				}
+				stk = append(stk, stk[len(stk)-1-n])
+			case "pop":
+				pop()
+			case "roll":
+				j, n := int(num()), int(num())
+				if n < 0 || n > len(stk) {
+					bad = true
+					break
+				}
+				if n > 0 {
+					s := stk[len(stk)-n:]
+					j = ((j % n) + n) % n
+					rolled := append(append([]psValue(nil), s[n-j:]...), s[:n-j]...)
+					copy(s, rolled)
+				}
+			default:
+				// if and ifelse without procedures.
+				bad = true
+			}
+		}
+	}
+	run(prog, 0)
+	out := make([]float64, len(stk))
+	for i, v := range stk {
+		out[i] = v.x
+	}
+	return out
+}
@@ -0,0 +1,726 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+)
+
+// A GraphicsState is the graphics state in effect at a point in the
+// interpretation of a page's content by Page.Walk.
+// See PDF 32000-1:2008, §8.4 and §9.3.
+type GraphicsState struct {
+	CTM  Matrix // the current transformation matrix, from user space to device space
+	Clip Rect   // bounds of the clipping path, in device space
+
+	// The color spaces are names, such as DeviceRGB or Pattern,
+	// or color space arrays from the resources.
+	// The colors hold the components in those spaces.
+	// For the Pattern color space, the pattern is named by
+	// FillPattern or StrokePattern.
+	FillSpace, StrokeSpace     Value
+	FillColor, StrokeColor     []float64
+	FillPattern, StrokePattern string
+	FillAlpha, StrokeAlpha     float64 // constant alpha (ca and CA), from 0 to 1
+
+	LineWidth  float64
+	LineCap    int
+	LineJoin   int
+	MiterLimit float64
+	Dash       []float64 // dash array; nil for solid lines
+	DashPhase  float64
+
+	Font       Font    // the current font
+	FontName   string  // the resource name of the current font
+	FontSize   float64 // the text font size, in text space units
+	CharSpace  float64 // character spacing (Tc)
+	WordSpace  float64 // word spacing (Tw)
+	Scale      float64 // horizontal scaling (Tz), as a fraction: 1 is 100%
+	Leading    float64 // leading (TL)
+	Rise       float64 // text rise (Ts)
+	RenderMode int     // text rendering mode (Tr), from 0 to 7
+
+	TextMatrix Matrix // the text matrix (Tm), valid inside BT and ET
+	LineMatrix Matrix // the text line matrix (Tlm)
+}
+
+// A ContentWalker receives the marks made by a page's content,
+// for Page.Walk. Nil callbacks are skipped. Coordinates are in device
+// space, which is the page's default user space, or its displayed space
+// (see Page.Transform) if Displayed is set. The GraphicsState passed
+// to a callback must not be retained or modified.
+type ContentWalker struct {
+	Displayed bool
+
+	// Glyph is called for each character shown, including invisible
+	// text (RenderMode 3).
+	Glyph func(g Glyph, gs *GraphicsState) error
+
+	// Path is called for each path filled or stroked.
+	Path func(pa Path, gs *GraphicsState) error
+
+	// Image is called for each image XObject and inline image painted
+	// (see Image.IsInline). The image fills the unit square mapped
+	// through gs.CTM.
+	Image func(im Image, gs *GraphicsState) error
+}
+
+// A Path is a path painted by a page's content.
+type Path struct {
+	Segments []PathSegment // the path, in device space
+	Fill     bool          // whether the path is filled
+	EvenOdd  bool          // whether it is filled by the even-odd rule rather than the nonzero winding rule
+	Stroke   bool          // whether the path is stroked
+}
+
+// A PathSegment is one segment of a path.
+type PathSegment struct {
+	Op  byte    // 'm' to begin a subpath, 'l' for a line, 'c' for a Bézier curve, 'h' to close the subpath
+	Pts []Point // the end point, preceded for a curve by its two control points; none for 'h'
+}
+
+// Bounds returns the bounding box of the points of the path,
+// including the control points of curves.
+func (pa Path) Bounds() Rect {
+	var r Rect
+	first := true
+	for _, s := range pa.Segments {
+		for _, pt := range s.Pts {
+			if first {
+				r = Rect{pt, pt}
+				first = false
+				continue
+			}
+			r.Min.X = math.Min(r.Min.X, pt.X)
+			r.Min.Y = math.Min(r.Min.Y, pt.Y)
+			r.Max.X = math.Max(r.Max.X, pt.X)
+			r.Max.Y = math.Max(r.Max.Y, pt.Y)
+		}
+	}
+	return r
+}
+
+// Walk interprets the page's content, tracking the graphics state,
+// and calls w's callbacks for the text, paths, and images it paints.
+// Form XObjects are interpreted in place, with their own resources.
+// Clipping paths are approximated by their bounding boxes, starting
+// from the crop box.
+func (p Page) Walk(ctx context.Context, w ContentWalker) error {
+	data, err := p.ContentData()
+	if err != nil {
+		return err
+	}
+	res, err := p.Resources()
+	if err != nil {
+		return err
+	}
+	box, err := p.CropBox()
+	if err != nil {
+		return err
+	}
+	gs := initialGraphicsState()
+	if w.Displayed {
+		if gs.CTM, err = p.Transform(); err != nil {
+			return err
+		}
+	}
+	gs.Clip = gs.CTM.ApplyRect(box)
+	in := &interp{ctx: ctx, w: w}
+	return in.run(data, res, gs)
+}
+
+// initialGraphicsState returns the graphics state at the start of
+// a page's content, in default user space, without a clipping path.
+func initialGraphicsState() GraphicsState {
+	return GraphicsState{
+		CTM:         Identity,
+		FillSpace:   NewName("DeviceGray"),
+		StrokeSpace: NewName("DeviceGray"),
+		FillColor:   []float64{0},
+		StrokeColor: []float64{0},
+		FillAlpha:   1,
+		StrokeAlpha: 1,
+		LineWidth:   1,
+		MiterLimit:  10,
+		Scale:       1,
+		TextMatrix:  Identity,
+		LineMatrix:  Identity,
+	}
+}
+
+// An interp interprets content for Page.Walk.
+type interp struct {
+	ctx   context.Context
+	w     ContentWalker
+	forms []objptr // the form XObjects being interpreted, to stop cycles
+
+	// If op is set, it is called with each operation before it is
+	// interpreted. If form is set, it is called for each form XObject
+	// painted, with the form's resources and the graphics state inside
+	// it, instead of interpreting the form.
+	op   func(op Operator, gs *GraphicsState) error
+	form func(x, res Value, gs GraphicsState) error
+}
+
+// run interprets the content data, whose resources are res,
+// starting in the graphics state gs.
+func (in *interp) run(data []byte, res Value, gs GraphicsState) error {
+	var (
+		stack    []GraphicsState
+		path     []PathSegment
+		cur      Point // current point, in user space
+		clip     bool  // whether the path is to clip when painted
+		resource = func(category, name string) (Value, error) {
+			all, err := res.Key(category)
+			if err != nil {
+				return Value{}, err
+			}
+			return all.Key(name)
+		}
+	)
+	add := func(op byte, pts ...Point) {
+		dev := make([]Point, len(pts))
+		for i, pt := range pts {
+			dev[i] = gs.CTM.Apply(pt)
+		}
+		path = append(path, PathSegment{op, dev})
+		if len(pts) > 0 {
+			cur = pts[len(pts)-1]
+		}
+	}
+	paint := func(fill, evenOdd, stroke bool) error {
+		pa := Path{Segments: path, Fill: fill, EvenOdd: evenOdd, Stroke: stroke}
+		if (fill || stroke) && len(path) > 0 && in.w.Path != nil {
+			if err := in.w.Path(pa, &gs); err != nil {
+				return err
+			}
+		}
+		if clip {
+			if len(path) == 0 {
+				gs.Clip = Rect{}
+			} else {
+				gs.Clip = gs.Clip.Intersect(pa.Bounds())
+			}
+		}
+		path, clip = nil, false
+		return nil
+	}
+
+	return walkOperators(in.ctx, bytes.NewReader(data), func(op Operator) error {
+		if in.op != nil {
+			if err := in.op(op, &gs); err != nil {
+				return err
+			}
+		}
+		args := op.Args
+		// num returns the i'th operand as a number.
+		// Operators without enough operands are ignored.
+		num := func(i int) float64 {
+			return args[i].Float64()
+		}
+		switch op.Name {
+		case "q":
+			stack = append(stack, gs)
+		case "Q":
+			if n := len(stack) - 1; n >= 0 {
+				gs = stack[n]
+				stack = stack[:n]
+			}
+		case "cm":
+			if len(args) == 6 {
+				gs.CTM = Matrix{num(0), num(1), num(2), num(3), num(4), num(5)}.Mul(gs.CTM)
+			}
+
+		case "w":
+			if len(args) == 1 {
+				gs.LineWidth = num(0)
+			}
+		case "J":
+			if len(args) == 1 {
+				gs.LineCap = int(num(0))
+			}
+		case "j":
+			if len(args) == 1 {
+				gs.LineJoin = int(num(0))
+			}
+		case "M":
+			if len(args) == 1 {
+				gs.MiterLimit = num(0)
+			}
+		case "d":
+			if len(args) == 2 {
+				gs.Dash, gs.DashPhase = dashArray(args[0]), num(1)
+			}
+		case "gs":
+			if len(args) == 1 {
+				ext, err := resource("ExtGState", args[0].Name())
+				if err != nil {
+					return err
+				}
+				if err := in.setExtGState(&gs, ext); err != nil {
+					return err
+				}
+			}
+
+		case "m":
+			if len(args) == 2 {
+				add('m', Point{num(0), num(1)})
+			}
+		case "l":
+			if len(args) == 2 {
+				add('l', Point{num(0), num(1)})
+			}
+		case "c":
+			if len(args) == 6 {
+				add('c', Point{num(0), num(1)}, Point{num(2), num(3)}, Point{num(4), num(5)})
+			}
+		case "v": // the first control point is the current point
+			if len(args) == 4 {
+				add('c', cur, Point{num(0), num(1)}, Point{num(2), num(3)})
+			}
+		case "y": // the second control point is the end point
+			if len(args) == 4 {
+				add('c', Point{num(0), num(1)}, Point{num(2), num(3)}, Point{num(2), num(3)})
+			}
+		case "h":
+			add('h')
+		case "re":
+			if len(args) == 4 {
+				x, y, w, h := num(0), num(1), num(2), num(3)
+				add('m', Point{x, y})
+				add('l', Point{x + w, y})
+				add('l', Point{x + w, y + h})
+				add('l', Point{x, y + h})
+				add('h')
+				cur = Point{x, y}
+			}
+		case "W", "W*":
+			clip = true
+		case "n":
+			return paint(false, false, false)
+		case "S":
+			return paint(false, false, true)
+		case "s":
+			add('h')
+			return paint(false, false, true)
+		case "f", "F":
+			return paint(true, false, false)
+		case "f*":
+			return paint(true, true, false)
+		case "B":
+			return paint(true, false, true)
+		case "B*":
+			return paint(true, true, true)
+		case "b":
+			add('h')
+			return paint(true, false, true)
+		case "b*":
+			add('h')
+			return paint(true, true, true)
+
+		case "g", "rg", "k", "G", "RG", "K":
+			space := map[string]string{"g": "DeviceGray", "rg": "DeviceRGB", "k": "DeviceCMYK"}[strings.ToLower(op.Name)]
+			color := numbers(args)
+			if op.Name == strings.ToLower(op.Name) {
+				gs.FillSpace, gs.FillColor, gs.FillPattern = NewName(space), color, ""
+			} else {
+				gs.StrokeSpace, gs.StrokeColor, gs.StrokePattern = NewName(space), color, ""
+			}
+		case "cs", "CS":
+			if len(args) != 1 {
+				return nil
+			}
+			cs := args[0]
+			switch cs.Name() {
+			case "DeviceGray", "DeviceRGB", "DeviceCMYK", "Pattern":
+			default:
+				var err error
+				if cs, err = resource("ColorSpace", cs.Name()); err != nil {
+					return err
+				}
+			}
+			color, err := initialColor(cs)
+			if err != nil {
+				return err
+			}
+			if op.Name == "cs" {
+				gs.FillSpace, gs.FillColor, gs.FillPattern = cs, color, ""
+			} else {
+				gs.StrokeSpace, gs.StrokeColor, gs.StrokePattern = cs, color, ""
+			}
+		case "sc", "scn", "SC", "SCN":
+			color := numbers(args)
+			pattern := ""
+			if n := len(args); n > 0 && args[n-1].Kind() == Name {
+				pattern = args[n-1].Name()
+			}
+			if op.Name[0] == 's' {
+				gs.FillColor, gs.FillPattern = color, pattern
+			} else {
+				gs.StrokeColor, gs.StrokePattern = color, pattern
+			}
+
+		case "BT":
+			gs.TextMatrix, gs.LineMatrix = Identity, Identity
+		case "Tc":
+			if len(args) == 1 {
+				gs.CharSpace = num(0)
+			}
+		case "Tw":
+			if len(args) == 1 {
+				gs.WordSpace = num(0)
+			}
+		case "Tz":
+			if len(args) == 1 {
+				gs.Scale = num(0) / 100
+			}
+		case "TL":
+			if len(args) == 1 {
+				gs.Leading = num(0)
+			}
+		case "Ts":
+			if len(args) == 1 {
+				gs.Rise = num(0)
+			}
+		case "Tr":
+			if len(args) == 1 {
+				gs.RenderMode = int(num(0))
+			}
+		case "Tf":
+			if len(args) == 2 {
+				font, err := resource("Font", args[0].Name())
+				if err != nil {
+					return err
+				}
+				if err := in.setFont(&gs, args[0].Name(), font, num(1)); err != nil {
+					return err
+				}
+			}
+		case "Td", "TD":
+			if len(args) == 2 {
+				if op.Name == "TD" {
+					gs.Leading = -num(1)
+				}
+				gs.LineMatrix = Matrix{1, 0, 0, 1, num(0), num(1)}.Mul(gs.LineMatrix)
+				gs.TextMatrix = gs.LineMatrix
+			}
+		case "Tm":
+			if len(args) == 6 {
+				gs.LineMatrix = Matrix{num(0), num(1), num(2), num(3), num(4), num(5)}
+				gs.TextMatrix = gs.LineMatrix
+			}
+		case "T*":
+			gs.LineMatrix = Matrix{1, 0, 0, 1, 0, -gs.Leading}.Mul(gs.LineMatrix)
+			gs.TextMatrix = gs.LineMatrix
+		case "Tj":
+			if len(args) == 1 {
+				return in.showText(&gs, args[0].RawString())
+			}
+		case "'", "\"":
+			if op.Name == "\"" {
+				if len(args) != 3 {
+					return nil
+				}
+				gs.WordSpace, gs.CharSpace = num(0), num(1)
+				args = args[2:]
+			}
+			if len(args) != 1 {
+				return nil
+			}
+			gs.LineMatrix = Matrix{1, 0, 0, 1, 0, -gs.Leading}.Mul(gs.LineMatrix)
+			gs.TextMatrix = gs.LineMatrix
+			return in.showText(&gs, args[0].RawString())
+		case "TJ":
+			if len(args) != 1 {
+				return nil
+			}
+			for i := 0; i < args[0].Len(); i++ {
+				x, err := args[0].Index(i)
+				if err != nil {
+					return err
+				}
+				if x.Kind() == String {
+					if err := in.showText(&gs, x.RawString()); err != nil {
+						return err
+					}
+					continue
+				}
+				tx := -x.Float64() / 1000 * gs.FontSize * gs.Scale
+				gs.TextMatrix = Matrix{1, 0, 0, 1, tx, 0}.Mul(gs.TextMatrix)
+			}
+
+		case "Do":
+			if len(args) == 1 {
+				x, err := resource("XObject", args[0].Name())
+				if err != nil {
+					return err
+				}
+				return in.doXObject(gs, res, x)
+			}
+		case "BI":
+			if in.w.Image != nil {
+				im, err := inlineImage(res.r, op, res)
+				if err != nil {
+					return err
+				}
+				return in.w.Image(im, &gs)
+			}
+		}
+		return nil
+	})
+}
+
+// showText shows the string s in the graphics state gs,
+// calling the Glyph callback for each character.
+func (in *interp) showText(gs *GraphicsState, s string) error {
+	enc := gs.Font.enc
+	if enc == nil {
+		enc = &nopEncoder{}
+	}
+	codes, err := decodeCodes(in.ctx, enc, s)
+	if err != nil {
+		return err
+	}
+	name, err := gs.Font.BaseFont()
+	if err != nil {
+		return err
+	}
+	if i := strings.Index(name, "+"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, c := range codes {
+		w0, err := gs.Font.codeWidth(enc, c.raw)
+		if err != nil {
+			return err
+		}
+		tx := w0/1000*gs.FontSize + gs.CharSpace
+		// Word spacing applies only to the single-byte code 32.
+		if c.raw == " " {
+			tx += gs.WordSpace
+		}
+		tx *= gs.Scale
+		if in.w.Glyph != nil {
+			trm := Matrix{gs.FontSize * gs.Scale, 0, 0, gs.FontSize, 0, gs.Rise}.Mul(gs.TextMatrix).Mul(gs.CTM)
+			m := gs.TextMatrix.Mul(gs.CTM)
+			g := Glyph{
+				Font:     name,
+				FontSize: math.Hypot(trm[2], trm[3]),
+				X:        trm[4],
+				Y:        trm[5],
+				W:        w0 / 1000 * math.Hypot(trm[0], trm[1]),
+				DX:       tx * m[0],
+				DY:       tx * m[1],
+				S:        c.text,
+				ClipBox:  gs.Clip,
+				code:     c.raw,
+				mcid:     -1,
+			}
+			if err := in.w.Glyph(g, gs); err != nil {
+				return err
+			}
+		}
+		gs.TextMatrix = Matrix{1, 0, 0, 1, tx, 0}.Mul(gs.TextMatrix)
+	}
+	return nil
+}
+
+// setFont makes the font dictionary font, with resource name name,
+// the current font of gs, at the given size.
+func (in *interp) setFont(gs *GraphicsState, name string, font Value, size float64) error {
+	gs.Font, gs.FontName, gs.FontSize = Font{V: font}, name, size
+	if font.Kind() != Dict {
+		return nil
+	}
+	enc, err := gs.Font.Encoder(in.ctx)
+	if err != nil {
+		return err
+	}
+	gs.Font.enc = enc
+	return nil
+}
+
+// setExtGState sets the parameters of gs given by the graphics state
+// parameter dictionary ext.
+func (in *interp) setExtGState(gs *GraphicsState, ext Value) error {
+	for _, key := range ext.Keys() {
+		v, err := ext.Key(key)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "LW":
+			gs.LineWidth = v.Float64()
+		case "LC":
+			gs.LineCap = int(v.Int64())
+		case "LJ":
+			gs.LineJoin = int(v.Int64())
+		case "ML":
+			gs.MiterLimit = v.Float64()
+		case "D":
+			dash, err := v.Index(0)
+			if err != nil {
+				return err
+			}
+			phase, err := v.Index(1)
+			if err != nil {
+				return err
+			}
+			gs.Dash, gs.DashPhase = dashArray(dash), phase.Float64()
+		case "CA":
+			gs.StrokeAlpha = v.Float64()
+		case "ca":
+			gs.FillAlpha = v.Float64()
+		case "Font":
+			font, err := v.Index(0)
+			if err != nil {
+				return err
+			}
+			size, err := v.Index(1)
+			if err != nil {
+				return err
+			}
+			if err := in.setFont(gs, "", font, size.Float64()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// doXObject paints the XObject x in the graphics state gs:
+// an image, reported to the Image callback, or a form,
+// interpreted with its own resources or, if it has none, res.
+func (in *interp) doXObject(gs GraphicsState, res, x Value) error {
+	sub, err := x.Key("Subtype")
+	if err != nil {
+		return err
+	}
+	switch sub.Name() {
+	case "Image":
+		if in.w.Image != nil {
+			return in.w.Image(Image{x}, &gs)
+		}
+	case "Form":
+		for _, ptr := range in.forms {
+			if ptr == x.ptr {
+				return nil
+			}
+		}
+		in.forms = append(in.forms, x.ptr)
+		defer func() { in.forms = in.forms[:len(in.forms)-1] }()
+
+		m, err := x.Key("Matrix")
+		if err != nil {
+			return err
+		}
+		if nums, err := numberArray(m); err != nil {
+			return err
+		} else if len(nums) == 6 {
+			gs.CTM = Matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}.Mul(gs.CTM)
+		}
+		bbox, err := x.Key("BBox")
+		if err != nil {
+			return err
+		}
+		if bbox.Kind() == Array {
+			box, err := rectFromArray(bbox)
+			if err != nil {
+				return err
+			}
+			gs.Clip = gs.Clip.Intersect(gs.CTM.ApplyRect(box))
+		}
+		formRes, err := x.Key("Resources")
+		if err != nil {
+			return err
+		}
+		if formRes.Kind() == Dict {
+			res = formRes
+		}
+		if in.form != nil {
+			return in.form(x, res, gs)
+		}
+		data, err := streamData(x)
+		if err != nil {
+			return err
+		}
+		return in.run(data, res, gs)
+	}
+	return nil
+}
+
+// numbers returns the numeric operands in args.
+func numbers(args []Value) []float64 {
+	var out []float64
+	for _, a := range args {
+		if k := a.Kind(); k == Integer || k == Real {
+			out = append(out, a.Float64())
+		}
+	}
+	return out
+}
+
+// dashArray returns the dash array v, or nil if it is empty.
+func dashArray(v Value) []float64 {
+	var dash []float64
+	for i := 0; i < v.Len(); i++ {
+		x, _ := v.Index(i)
+		dash = append(dash, x.Float64())
+	}
+	return dash
+}
+
+// initialColor returns the color that the cs and CS operators set
+// for the color space cs: black, or for a Separation or DeviceN space,
+// the full tint. See PDF 32000-1:2008, §8.6.8, Table 74.
+func initialColor(cs Value) ([]float64, error) {
+	family := cs
+	if cs.Kind() == Array {
+		var err error
+		if family, err = cs.Index(0); err != nil {
+			return nil, err
+		}
+	}
+	switch family.Name() {
+	case "DeviceGray", "CalGray", "Indexed":
+		return []float64{0}, nil
+	case "DeviceRGB", "CalRGB", "Lab":
+		return []float64{0, 0, 0}, nil
+	case "DeviceCMYK":
+		return []float64{0, 0, 0, 1}, nil
+	case "Separation":
+		return []float64{1}, nil
+	case "DeviceN":
+		names, err := cs.Index(1)
+		if err != nil {
+			return nil, err
+		}
+		color := make([]float64, names.Len())
+		for i := range color {
+			color[i] = 1
+		}
+		return color, nil
+	case "ICCBased":
+		stream, err := cs.Index(1)
+		if err != nil {
+			return nil, err
+		}
+		n, err := stream.Key("N")
+		if err != nil {
+			return nil, err
+		}
+		if n.Int64() < 1 || n.Int64() > 32 {
+			return nil, nil
+		}
+		color := make([]float64, n.Int64())
+		if len(color) == 4 {
+			color[3] = 1
+		}
+		return color, nil
+	}
+	return nil, nil
+}
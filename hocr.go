@@ -0,0 +1,91 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"math"
+)
+
+// ToHOCR returns the page's text as an hOCR document, with each line
+// and word annotated with its bounding box, so that the text layer can be
+// consumed by tools that expect OCR output.
+//
+// Coordinates are in pixels at 72 DPI, so that one pixel is one point,
+// measured from the top left corner of the displayed page (after cropping
+// and rotation); the page's scan_res property records this resolution.
+// Lines and words are reconstructed from glyph positions: glyphs whose
+// baselines are within half a font size of each other form a line, and
+// within a line, words are separated by white space or by gaps wider
+// than a quarter of the font size. Lines are listed top to bottom.
+//
+// num is the page's number, counting from 1, which identifies the page
+// and its lines and words in the element ids, as in "page_3" and
+// "word_3_1_2", and sets the page's ppageno property.
+func (p Page) ToHOCR(ctx context.Context, num int) ([]byte, error) {
+	glyphs, err := p.displayedGlyphs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w, h, err := p.Size()
+	if err != nil {
+		return nil, err
+	}
+	bbox := func(r Rect) string {
+		// Flip the y axis: hOCR measures from the top.
+		return fmt.Sprintf("bbox %d %d %d %d",
+			int(math.Floor(r.Min.X)), int(math.Floor(h-r.Max.Y)),
+			int(math.Ceil(r.Max.X)), int(math.Ceil(h-r.Min.Y)))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+<head>
+<title></title>
+<meta http-equiv="Content-Type" content="text/html;charset=utf-8"/>
+<meta name="ocr-system" content="rebot-pdf"/>
+<meta name="ocr-capabilities" content="ocr_page ocr_line ocrx_word"/>
+</head>
+<body>
+`)
+	fmt.Fprintf(&buf, "<div class=\"ocr_page\" id=\"page_%d\" title=\"%s; ppageno %d; scan_res 72 72\">\n",
+		num, bbox(Rect{Point{0, 0}, Point{w, h}}), num-1)
+	for i, line := range layoutLines(glyphs) {
+		fmt.Fprintf(&buf, "<span class=\"ocr_line\" id=\"line_%d_%d\" title=\"%s\">", num, i+1, bbox(line.Box))
+		for j, word := range line.Words {
+			if j > 0 {
+				buf.WriteString(" ")
+			}
+			fmt.Fprintf(&buf, "<span class=\"ocrx_word\" id=\"word_%d_%d_%d\" title=\"%s\">%s</span>",
+				num, i+1, j+1, bbox(word.Box), html.EscapeString(word.S))
+		}
+		buf.WriteString("</span>\n")
+	}
+	buf.WriteString("</div>\n</body>\n</html>\n")
+	return buf.Bytes(), nil
+}
+
+// displayedGlyphs returns the page's glyphs in displayed space,
+// regardless of the Reader's TextOptions.
+func (p Page) displayedGlyphs(ctx context.Context) ([]Glyph, error) {
+	opts := p.textOptions()
+	opts.Displayed = true
+	var glyphs []Glyph
+	_, err := p.walkGlyphs(ctx, opts, func(g Glyph) error {
+		if !g.synthetic {
+			glyphs = append(glyphs, g)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return glyphs, nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHOCRPageNumber(t *testing.T) {
+	ctx := context.Background()
+	r := openPDF(t, buildPDF(pageTree(2)...), nil)
+	p, err := r.Page(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.ToHOCR(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`id="page_2"`, "ppageno 1;", `id="line_2_1"`, `id="word_2_1_2"`, ">2</span>"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("ToHOCR output lacks %s:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Reading of files served over HTTP.
+
+package pdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HTTPOptions control how an HTTPFile makes its requests.
+// The zero HTTPOptions use the defaults.
+type HTTPOptions struct {
+	// Client makes the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Header holds headers to send with every request, such as
+	// Authorization.
+	Header http.Header
+
+	// BlockSize is the size of the blocks the file is read and cached
+	// in. If zero, it is 64 kB.
+	BlockSize int
+
+	// CacheBlocks is how many blocks to cache, dropping the least
+	// recently used. If zero, it is 256.
+	CacheBlocks int
+}
+
+// An HTTPFile is an io.ReaderAt for a file served over HTTP, such as a
+// large file in object storage, that reads the file with range
+// requests, in blocks, as they are needed, rather than downloading all
+// of it. Blocks are cached. Opening a file reads its cross-reference
+// table, at its end, and whatever objects are then used; opening a
+// linearized file reads only its first page's. A damaged file whose
+// cross-reference table must be rebuilt (see RepairXref) is read in
+// full.
+//
+// The server must support range requests. If the file changes while it
+// is read, as shown by its ETag or Last-Modified header, reads fail.
+// An HTTPFile is safe for concurrent use.
+type HTTPFile struct {
+	ctx       context.Context
+	url       string
+	client    *http.Client
+	header    http.Header
+	validator string // ETag or Last-Modified, for If-Range
+	size      int64
+	blockSize int64
+	maxBlocks int
+
+	mu    sync.Mutex
+	cache map[int64]*httpBlock // by block number
+	tick  uint64
+}
+
+// An httpBlock is a cached block of an HTTPFile.
+type httpBlock struct {
+	data []byte
+	used uint64 // the HTTPFile's tick when the block was last used
+}
+
+// OpenURL opens the file at url for reading, using range requests
+// made with the default HTTPOptions.
+// ctx is used for every request, as by NewHTTPFile.
+func OpenURL(ctx context.Context, url string, opts *Options) (*HTTPFile, *Reader, error) {
+	f, err := NewHTTPFile(ctx, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := NewReaderContext(ctx, f, f.Size(), opts)
+	return f, r, err
+}
+
+// NewHTTPFile returns an HTTPFile for the file at url. It makes a
+// request for the file's first block, which gives its size.
+// Nil options are the zero HTTPOptions.
+// The requests made for the file, including those by later calls to
+// ReadAt, use ctx: once it is done, reads fail with its error.
+func NewHTTPFile(ctx context.Context, url string, opts *HTTPOptions) (*HTTPFile, error) {
+	if opts == nil {
+		opts = new(HTTPOptions)
+	}
+	f := &HTTPFile{
+		ctx:       ctx,
+		url:       url,
+		client:    opts.Client,
+		header:    opts.Header,
+		blockSize: int64(opts.BlockSize),
+		maxBlocks: opts.CacheBlocks,
+		cache:     make(map[int64]*httpBlock),
+	}
+	if f.client == nil {
+		f.client = http.DefaultClient
+	}
+	if f.blockSize <= 0 {
+		f.blockSize = 64 << 10
+	}
+	if f.maxBlocks <= 0 {
+		f.maxBlocks = 256
+	}
+
+	resp, err := f.request(0, f.blockSize)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _, size, err := contentRange(resp)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: reading %s: %v", url, err)
+	}
+	f.size = size
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		f.validator = etag
+	} else {
+		f.validator = resp.Header.Get("Last-Modified")
+	}
+	n := f.blockSize
+	if n > size {
+		n = size
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("pdf: reading %s: %v", url, err)
+	}
+	f.cache[0] = &httpBlock{data: data}
+	return f, nil
+}
+
+// Size returns the size of the file.
+func (f *HTTPFile) Size() int64 {
+	return f.size
+}
+
+// ReadAt reads len(p) bytes of the file at offset off, making a range
+// request for the blocks they span that are not cached.
+func (f *HTTPFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("pdf: negative offset")
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > f.size {
+		end = f.size
+	}
+	first := off / f.blockSize
+	blocks, err := f.blocks(first, (end-1)/f.blockSize)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i, data := range blocks {
+		start := (first + int64(i)) * f.blockSize
+		if i == 0 {
+			data = data[off-start:]
+		}
+		n += copy(p[n:end-off], data)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// blocks returns the blocks numbered first to last, fetching those not
+// cached with a request for each run of them.
+func (f *HTTPFile) blocks(first, last int64) ([][]byte, error) {
+	out := make([][]byte, last-first+1)
+	f.mu.Lock()
+	for i := range out {
+		if b := f.cache[first+int64(i)]; b != nil {
+			f.tick++
+			b.used = f.tick
+			out[i] = b.data
+		}
+	}
+	f.mu.Unlock()
+
+	for i := 0; i < len(out); {
+		if out[i] != nil {
+			i++
+			continue
+		}
+		j := i
+		for j < len(out) && out[j] == nil {
+			j++
+		}
+		data, err := f.get((first+int64(i))*f.blockSize, (first+int64(j))*f.blockSize)
+		if err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		for k := i; k < j; k++ {
+			lo := int64(k-i) * f.blockSize
+			hi := lo + f.blockSize
+			if hi > int64(len(data)) {
+				hi = int64(len(data))
+			}
+			out[k] = data[lo:hi:hi]
+			f.add(first+int64(k), out[k])
+		}
+		f.mu.Unlock()
+		i = j
+	}
+	return out, nil
+}
+
+// add caches the block numbered n, dropping the least recently used
+// block if the cache is full. f.mu must be held.
+func (f *HTTPFile) add(n int64, data []byte) {
+	if len(f.cache) >= f.maxBlocks {
+		var (
+			lru  int64 = -1
+			used uint64
+		)
+		for k, b := range f.cache {
+			if lru < 0 || b.used < used {
+				lru, used = k, b.used
+			}
+		}
+		delete(f.cache, lru)
+	}
+	f.tick++
+	f.cache[n] = &httpBlock{data: data, used: f.tick}
+}
+
+// get returns the bytes of the file from offset start up to offset
+// end, or the end of the file.
+func (f *HTTPFile) get(start, end int64) ([]byte, error) {
+	if end > f.size {
+		end = f.size
+	}
+	resp, err := f.request(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	first, last, size, err := contentRange(resp)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: reading %s: %v", f.url, err)
+	}
+	if first != start || last != end-1 || size != f.size {
+		return nil, fmt.Errorf("pdf: reading %s: got bytes %d-%d/%d for range %d-%d/%d", f.url, first, last, size, start, end-1, f.size)
+	}
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("pdf: reading %s: %v", f.url, err)
+	}
+	return data, nil
+}
+
+// request makes a request for the bytes of the file from offset start
+// up to offset end. Unless the response is a partial content response,
+// which the caller must close, it returns an error.
+func (f *HTTPFile) request(start, end int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(f.ctx, "GET", f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range f.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if f.validator != "" {
+		req.Header.Set("If-Range", f.validator)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp, nil
+	case http.StatusOK:
+		resp.Body.Close()
+		if f.validator != "" {
+			return nil, fmt.Errorf("pdf: reading %s: file changed", f.url)
+		}
+		return nil, fmt.Errorf("pdf: reading %s: server does not support range requests", f.url)
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("pdf: reading %s: %s", f.url, resp.Status)
+}
+
+// contentRange parses the Content-Range header of a partial content
+// response: the first and last offsets of the bytes it holds, and the
+// size of the file.
+func contentRange(resp *http.Response) (first, last, size int64, err error) {
+	h := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(h, "bytes %d-%d/%d", &first, &last, &size); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	if first < 0 || last < first || size <= last {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	return first, last, size, nil
+}
@@ -0,0 +1,860 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// An Image is an image XObject, or an inline image (see IsInline).
+// The methods interpret an image dictionary stored in V.
+// See PDF 32000-1:2008, §8.9.
+type Image struct {
+	V Value
+}
+
+// Images returns the image XObjects in the page's resources,
+// including those in the resources of form XObjects the page uses.
+// Each image is returned once, even if it is used more than once.
+// Decode returns an image's samples as an image.Image, and Encoded
+// its compressed data.
+func (p Page) Images() ([]Image, error) {
+	res, err := p.Resources()
+	if err != nil {
+		return nil, err
+	}
+	var out []Image
+	seen := make(map[objptr]bool)
+	var walk func(res Value) error
+	walk = func(res Value) error {
+		xobjs, err := res.Key("XObject")
+		if err != nil {
+			return err
+		}
+		for _, name := range xobjs.Keys() {
+			x, err := xobjs.Key(name)
+			if err != nil {
+				return err
+			}
+			if x.Kind() != Stream || seen[x.ptr] {
+				continue
+			}
+			seen[x.ptr] = true
+			sub, err := x.Key("Subtype")
+			if err != nil {
+				return err
+			}
+			switch sub.Name() {
+			case "Image":
+				out = append(out, Image{x})
+			case "Form":
+				fres, err := x.Key("Resources")
+				if err != nil {
+					return err
+				}
+				if err := walk(fres); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(res); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InlineImages returns the inline images painted by the page's content,
+// including that of the form XObjects it paints, in the order they are
+// painted. Each is returned as an image whose V is a stream holding
+// the image's data, with the abbreviated keys and values of its
+// parameters expanded, as in an image XObject, and a color space named
+// in the resources replaced by its definition.
+func (p Page) InlineImages(ctx context.Context) ([]Image, error) {
+	var out []Image
+	err := p.Walk(ctx, ContentWalker{
+		Image: func(im Image, gs *GraphicsState) error {
+			if im.IsInline() {
+				out = append(out, im)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IsInline reports whether the image is an inline image, written in
+// content between BI and EI operators rather than as an XObject,
+// as returned by Page.InlineImages and passed to ContentWalker.Image.
+func (im Image) IsInline() bool {
+	x, ok := im.V.data.(stream)
+	return ok && x.ptr.id == 0 && im.V.r != nil
+}
+
+// Width returns the width of the image in samples.
+func (im Image) Width() (int, error) {
+	w, err := im.V.Key("Width")
+	if err != nil {
+		return 0, err
+	}
+	return int(w.Int64()), nil
+}
+
+// Height returns the height of the image in samples.
+func (im Image) Height() (int, error) {
+	h, err := im.V.Key("Height")
+	if err != nil {
+		return 0, err
+	}
+	return int(h.Int64()), nil
+}
+
+// IsMask reports whether the image is a stencil mask (ImageMask is true).
+func (im Image) IsMask() (bool, error) {
+	m, err := im.V.Key("ImageMask")
+	if err != nil {
+		return false, err
+	}
+	return m.Bool(), nil
+}
+
+// ImageExportOptions controls which images ExtractImages writes.
+type ImageExportOptions struct {
+	MinWidth  int  // skip images narrower than this many samples
+	MinHeight int  // skip images shorter than this many samples
+	SkipMasks bool // skip stencil masks and images used as another image's mask
+}
+
+// ExtractImages writes the image XObjects used by each page, and its
+// inline images, to files in dir, which must already exist, and returns
+// the paths of the files written. Files are named page<N>-obj<M> after
+// the page number and the image's object number, or page<N>-inline<K>
+// for the K'th inline image of the page, with an extension giving the format:
+// JPEG (DCTDecode) data is written as is to a .jpg file, and
+// JPEG 2000, JBIG2, and CCITT fax data is written as the raw codestream
+// to .jp2, .jb2, and .ccitt files; JBIG2 global segments are not included.
+// Other images, and images with a soft mask, explicit mask, or color
+// key mask that DecodeAlpha can decode, are decoded and written as PNG,
+// with the mask as the alpha channel.
+// If an error occurs, ExtractImages returns the paths written so far.
+func (r *Reader) ExtractImages(ctx context.Context, dir string, opts ImageExportOptions) ([]string, error) {
+	var paths []string
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		images, err := p.Images()
+		if err != nil {
+			return err
+		}
+		masks := make(map[objptr]bool)
+		for _, im := range images {
+			for _, key := range []string{"SMask", "Mask"} {
+				m, err := im.V.Key(key)
+				if err != nil {
+					return err
+				}
+				if m.Kind() == Stream {
+					masks[m.ptr] = true
+				}
+			}
+		}
+		for _, im := range images {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			skip, err := opts.skip(im, masks)
+			if err != nil {
+				return err
+			}
+			if skip {
+				continue
+			}
+			path, err := writeImage(im, filepath.Join(dir, fmt.Sprintf("page%d-obj%d", num, im.V.ptr.id)))
+			if err != nil {
+				return fmt.Errorf("page %d: image %v: %v", num, im.V.ptr, err)
+			}
+			paths = append(paths, path)
+		}
+		inline, err := p.InlineImages(ctx)
+		if err != nil {
+			return err
+		}
+		for i, im := range inline {
+			skip, err := opts.skip(im, masks)
+			if err != nil {
+				return err
+			}
+			if skip {
+				continue
+			}
+			path, err := writeImage(im, filepath.Join(dir, fmt.Sprintf("page%d-inline%d", num, i+1)))
+			if err != nil {
+				return fmt.Errorf("page %d: inline image %d: %v", num, i+1, err)
+			}
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func (opts ImageExportOptions) skip(im Image, masks map[objptr]bool) (bool, error) {
+	if opts.SkipMasks {
+		mask, err := im.IsMask()
+		if err != nil {
+			return false, err
+		}
+		if mask || masks[im.V.ptr] {
+			return true, nil
+		}
+	}
+	w, err := im.Width()
+	if err != nil {
+		return false, err
+	}
+	h, err := im.Height()
+	if err != nil {
+		return false, err
+	}
+	return w < opts.MinWidth || h < opts.MinHeight, nil
+}
+
+// writeImage writes im to base plus the extension for its format
+// and returns the path written.
+// An image with a mask that DecodeAlpha can decode is written as PNG
+// with the mask as its alpha channel.
+func writeImage(im Image, base string) (string, error) {
+	var m image.Image
+	masked, err := im.hasMask()
+	if err != nil {
+		return "", err
+	}
+	if masked {
+		if a, err := im.DecodeAlpha(); err == nil {
+			m = a
+		}
+	}
+	if m == nil {
+		data, codec, err := im.Encoded()
+		if err != nil {
+			return "", err
+		}
+		if codec != "" {
+			path := base + imageCodecs[codec]
+			return path, ioutil.WriteFile(path, data, 0666)
+		}
+		if m, err = im.Decode(); err != nil {
+			return "", err
+		}
+	}
+	path := base + ".png"
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := png.Encode(f, m); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// hasMask reports whether the image has a soft mask, an explicit mask,
+// or a color key mask.
+func (im Image) hasMask() (bool, error) {
+	sm, err := im.V.Key("SMask")
+	if err != nil {
+		return false, err
+	}
+	m, err := im.V.Key("Mask")
+	if err != nil {
+		return false, err
+	}
+	return sm.Kind() == Stream || m.Kind() == Stream || m.Kind() == Array, nil
+}
+
+// imageCodecs maps the filters that encode complete images
+// to the file extension of their data.
+var imageCodecs = map[string]string{
+	"DCTDecode":      ".jpg",
+	"JPXDecode":      ".jp2",
+	"JBIG2Decode":    ".jb2",
+	"CCITTFaxDecode": ".ccitt",
+}
+
+// Encoded returns the image data with all filters applied except
+// a final image codec, together with the name of that codec:
+// DCTDecode (JPEG), JPXDecode (JPEG 2000), JBIG2Decode, or CCITTFaxDecode.
+// If the image uses no such codec, the data is the fully decoded
+// samples and the codec is the empty string.
+// Encoded lets callers pass JPEG and other compressed images through
+// without decoding and re-encoding them.
+func (im Image) Encoded() ([]byte, string, error) {
+	rd, codec, err := im.V.reader(func(filter string) bool {
+		return imageCodecs[filter] != ""
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer rd.Close()
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, codec, nil
+}
+
+// Decode decodes the image samples into an image.Image.
+// Images in DeviceGray, DeviceRGB, DeviceCMYK, and the calibrated,
+// ICC-based, and indexed spaces based on them are supported,
+// at any bit depth, as are JPEG (DCTDecode), CCITT fax
+// (CCITTFaxDecode), and JBIG2 (JBIG2Decode) images.
+// Images encoded with the other image codecs are not supported;
+// JPXInfo describes JPEG 2000 images.
+func (im Image) Decode() (image.Image, error) {
+	img, _, err := im.decode(nil)
+	return img, err
+}
+
+// DecodeAlpha decodes the image, as Decode does, together with its
+// mask, into an image whose alpha channel is the mask: the soft mask
+// given by its SMask entry; otherwise, the explicit mask given by a
+// stencil mask image in its Mask entry, or the colors given by an array
+// of color key ranges in it. Masks of another size are scaled to the
+// image's. Colors that were premultiplied by the soft mask, with the
+// Matte color of a gray or RGB image, are restored. An image without a
+// mask is opaque, and a stencil mask is black where it is painted and
+// transparent elsewhere. See PDF 32000-1:2008, §8.9.6 and §11.6.5.3.
+func (im Image) DecodeAlpha() (*image.NRGBA, error) {
+	mask, err := im.IsMask()
+	if err != nil {
+		return nil, err
+	}
+	sm, err := im.V.Key("SMask")
+	if err != nil {
+		return nil, err
+	}
+	m, err := im.V.Key("Mask")
+	if err != nil {
+		return nil, err
+	}
+	var key []int
+	if !mask && sm.Kind() != Stream && m.Kind() == Array {
+		for i := 0; i < m.Len(); i++ {
+			x, err := m.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			key = append(key, int(x.Int64()))
+		}
+	}
+	src, keyAlpha, err := im.decode(key)
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+
+	// alpha returns the opacity, from 0 to 0xff, of the pixel at x, y,
+	// relative to b.
+	alpha := func(x, y int) uint8 { return 0xff }
+	var matte []float64
+	switch {
+	case mask:
+		alpha = func(x, y int) uint8 {
+			// Samples of 0 are painted, unless Decode inverts them.
+			if color.GrayModel.Convert(src.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y >= 0x80 {
+				return 0
+			}
+			return 0xff
+		}
+	case sm.Kind() == Stream:
+		soft, err := Image{sm}.Decode()
+		if err != nil {
+			return nil, err
+		}
+		alpha = func(x, y int) uint8 {
+			return color.GrayModel.Convert(scaledAt(soft, b, x, y)).(color.Gray).Y
+		}
+		mv, err := sm.Key("Matte")
+		if err != nil {
+			return nil, err
+		}
+		if matte, err = numberArray(mv); err != nil {
+			return nil, err
+		}
+	case m.Kind() == Stream:
+		stencil, err := Image{m}.Decode()
+		if err != nil {
+			return nil, err
+		}
+		alpha = func(x, y int) uint8 {
+			if color.GrayModel.Convert(scaledAt(stencil, b, x, y)).(color.Gray).Y >= 0x80 {
+				return 0
+			}
+			return 0xff
+		}
+	case keyAlpha != nil:
+		alpha = func(x, y int) uint8 { return keyAlpha.AlphaAt(x, y).A }
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			a := alpha(x, y)
+			if mask {
+				dst.SetNRGBA(x, y, color.NRGBA{0, 0, 0, a})
+				continue
+			}
+			c := color.NRGBAModel.Convert(src.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			if a > 0 && a < 0xff && (len(matte) == 1 || len(matte) == 3) {
+				// The color was composited with the matte color by
+				// the soft mask: c = m + a(c0 - m).
+				unmatte := func(v uint8, i int) uint8 {
+					m := matte[i%len(matte)]
+					return to8(m + (float64(v)/0xff-m)*0xff/float64(a))
+				}
+				c.R, c.G, c.B = unmatte(c.R, 0), unmatte(c.G, 1), unmatte(c.B, 2)
+			}
+			c.A = a
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return dst, nil
+}
+
+// scaledAt returns the pixel of img at the point x, y relative to the
+// rectangle b, with img scaled to the size of b.
+func scaledAt(img image.Image, b image.Rectangle, x, y int) color.Color {
+	ib := img.Bounds()
+	return img.At(ib.Min.X+x*ib.Dx()/b.Dx(), ib.Min.Y+y*ib.Dy()/b.Dy())
+}
+
+// decode decodes the image samples, as for Decode. If key is not nil,
+// it is the color key mask of the image, the ranges of sample values
+// [min max] of each component of the colors to be masked, and decode
+// also returns the mask: opaque where a sample falls outside them.
+// A key of the wrong length masks nothing.
+func (im Image) decode(key []int) (image.Image, *image.Alpha, error) {
+	w, err := im.Width()
+	if err != nil {
+		return nil, nil, err
+	}
+	h, err := im.Height()
+	if err != nil {
+		return nil, nil, err
+	}
+	if w <= 0 || h <= 0 {
+		return nil, nil, fmt.Errorf("invalid image size %dx%d", w, h)
+	}
+	data, codec, err := im.Encoded()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch codec {
+	case "":
+	case "DCTDecode":
+		// Color keys select exact colors, which lossy JPEG data does
+		// not keep, so they are not applied.
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		var alpha *image.Alpha
+		if key != nil {
+			alpha = opaque(img.Bounds())
+		}
+		return img, alpha, nil
+	case "CCITTFaxDecode", "JBIG2Decode":
+		// Bitonal data decodes to 1-bit samples.
+		rd, err := im.V.Reader()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err = ioutil.ReadAll(rd)
+		rd.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported image filter %s", codec)
+	}
+
+	mask, err := im.IsMask()
+	if err != nil {
+		return nil, nil, err
+	}
+	bpc := 1
+	var cs imageColorSpace
+	if mask {
+		cs = imageColorSpace{n: 1}
+	} else {
+		b, err := im.V.Key("BitsPerComponent")
+		if err != nil {
+			return nil, nil, err
+		}
+		bpc = int(b.Int64())
+		space, err := im.V.Key("ColorSpace")
+		if err != nil {
+			return nil, nil, err
+		}
+		cs, err = parseImageColorSpace(space, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, nil, fmt.Errorf("invalid BitsPerComponent %d", bpc)
+	}
+
+	// Decode maps each sample onto [dmin, dmax] of the color space.
+	decode := make([]float64, 2*cs.n)
+	max := float64(int(1)<<uint(bpc) - 1)
+	for i := 0; i < cs.n; i++ {
+		decode[2*i], decode[2*i+1] = 0, 1
+		switch {
+		case cs.palette != nil:
+			decode[2*i+1] = max
+		case cs.decode != nil:
+			decode[2*i], decode[2*i+1] = cs.decode[2*i], cs.decode[2*i+1]
+		}
+	}
+	d, err := im.V.Key("Decode")
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.Len() == len(decode) {
+		for i := range decode {
+			x, err := d.Index(i)
+			if err != nil {
+				return nil, nil, err
+			}
+			decode[i] = x.Float64()
+		}
+	}
+
+	stride := (w*cs.n*bpc + 7) / 8
+	if len(data) < stride*h {
+		return nil, nil, fmt.Errorf("image data too short: %d < %d bytes", len(data), stride*h)
+	}
+	sample := func(row []byte, i int) float64 {
+		var s int
+		switch bpc {
+		case 8:
+			s = int(row[i])
+		case 16:
+			s = int(row[2*i])<<8 | int(row[2*i+1])
+		default:
+			bit := i * bpc
+			s = int(row[bit/8]>>(8-uint(bpc)-uint(bit%8))) & (1<<uint(bpc) - 1)
+		}
+		return float64(s) / max
+	}
+
+	rect := image.Rect(0, 0, w, h)
+	var gray *image.Gray
+	var rgba *image.RGBA
+	var cmyk *image.CMYK
+	switch {
+	case cs.palette != nil || cs.base == 3:
+		rgba = image.NewRGBA(rect)
+	case cs.base == 4:
+		cmyk = image.NewCMYK(rect)
+	default:
+		gray = image.NewGray(rect)
+	}
+	comp := make([]float64, cs.n)
+	// Colors converted to the device space, such as by the tint
+	// transforms of Separation and DeviceN spaces, are computed once.
+	var converted map[[4]float64][]float64
+	if cs.convert != nil && cs.n <= 4 {
+		converted = make(map[[4]float64][]float64)
+	}
+	var alpha *image.Alpha
+	if key != nil && len(key) == 2*cs.n {
+		alpha = image.NewAlpha(rect)
+	}
+	for y := 0; y < h; y++ {
+		row := data[y*stride : (y+1)*stride]
+		for x := 0; x < w; x++ {
+			keyed := alpha != nil
+			for c := range comp {
+				s := sample(row, x*cs.n+c)
+				comp[c] = decode[2*c] + s*(decode[2*c+1]-decode[2*c])
+				if keyed {
+					raw := int(s*max + 0.5)
+					keyed = key[2*c] <= raw && raw <= key[2*c+1]
+				}
+			}
+			if alpha != nil && !keyed {
+				alpha.Pix[y*alpha.Stride+x] = 0xff
+			}
+			dc := comp
+			if cs.convert != nil {
+				var key [4]float64
+				copy(key[:], comp)
+				var ok bool
+				if dc, ok = converted[key]; !ok {
+					dc = cs.convert(comp)
+					for len(dc) < cs.base {
+						dc = append(dc, 0)
+					}
+					if converted != nil {
+						converted[key] = dc
+					}
+				}
+			}
+			switch {
+			case cs.palette != nil:
+				rgba.Set(x, y, cs.indexed(int(dc[0]+0.5)))
+			case rgba != nil:
+				rgba.Set(x, y, color.RGBA{to8(dc[0]), to8(dc[1]), to8(dc[2]), 0xff})
+			case cmyk != nil:
+				cmyk.Set(x, y, color.CMYK{to8(dc[0]), to8(dc[1]), to8(dc[2]), to8(dc[3])})
+			default:
+				gray.Set(x, y, color.Gray{to8(dc[0])})
+			}
+		}
+	}
+	switch {
+	case rgba != nil:
+		return rgba, alpha, nil
+	case cmyk != nil:
+		return cmyk, alpha, nil
+	}
+	return gray, alpha, nil
+}
+
+// opaque returns a mask that is opaque within r.
+func opaque(r image.Rectangle) *image.Alpha {
+	a := image.NewAlpha(r)
+	for i := range a.Pix {
+		a.Pix[i] = 0xff
+	}
+	return a
+}
+
+// to8 converts a color component in [0, 1] to 8 bits.
+func to8(x float64) uint8 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 0xff
+	}
+	return uint8(x*0xff + 0.5)
+}
+
+// An imageColorSpace describes how image samples map to colors.
+type imageColorSpace struct {
+	n    int // components per sample
+	base int // components of the underlying device space: 1, 3, or 4
+
+	// decode is the range of each component, as a default Decode
+	// array, if it is not [0, 1].
+	decode []float64
+
+	// convert maps the components of a color to the device space,
+	// if they are not in it, as for Lab, Separation, and DeviceN spaces.
+	convert func(comps []float64) []float64
+
+	palette []color.Color // for Indexed spaces, the colors, by index
+}
+
+// indexed returns the color at index i of an Indexed color space.
+func (cs imageColorSpace) indexed(i int) color.Color {
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(cs.palette) {
+		i = len(cs.palette) - 1
+	}
+	return cs.palette[i]
+}
+
+// deviceColor returns the color with the components comps, in the
+// device space of cs, after any conversion.
+func (cs imageColorSpace) deviceColor(comps []float64) color.Color {
+	if cs.convert != nil {
+		comps = cs.convert(comps)
+	}
+	c := func(i int) uint8 {
+		if i < len(comps) {
+			return to8(comps[i])
+		}
+		return 0
+	}
+	switch cs.base {
+	case 3:
+		return color.RGBA{c(0), c(1), c(2), 0xff}
+	case 4:
+		return color.CMYK{c(0), c(1), c(2), c(3)}
+	}
+	return color.Gray{c(0)}
+}
+
+// parseImageColorSpace interprets an image color space.
+// depth guards against color spaces defined in terms of themselves.
+func parseImageColorSpace(v Value, depth int) (imageColorSpace, error) {
+	if depth > 8 {
+		return imageColorSpace{}, fmt.Errorf("color space nested too deeply")
+	}
+	name := v.Name()
+	if v.Kind() == Array {
+		x, err := v.Index(0)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		name = x.Name()
+	}
+	switch name {
+	case "DeviceGray", "CalGray", "G":
+		return imageColorSpace{n: 1, base: 1}, nil
+	case "DeviceRGB", "CalRGB", "RGB":
+		return imageColorSpace{n: 3, base: 3}, nil
+	case "DeviceCMYK", "CMYK":
+		return imageColorSpace{n: 4, base: 4}, nil
+	case "Lab":
+		rng := labRange(v)
+		return imageColorSpace{
+			n:      3,
+			base:   3,
+			decode: append([]float64{0, 100}, rng[:]...),
+			convert: func(c []float64) []float64 {
+				rgb := labToRGB(c[0], c[1], c[2])
+				return rgb[:]
+			},
+		}, nil
+	case "ICCBased":
+		strm, err := v.Index(1)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		alt, err := strm.Key("Alternate")
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		if !alt.IsNull() {
+			return parseImageColorSpace(alt, depth+1)
+		}
+		n, err := strm.Key("N")
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		switch n.Int64() {
+		case 1, 3, 4:
+			return imageColorSpace{n: int(n.Int64()), base: int(n.Int64())}, nil
+		}
+		return imageColorSpace{}, fmt.Errorf("invalid ICCBased component count %d", n.Int64())
+	case "Separation", "DeviceN":
+		// The components are tints, converted to the alternate space
+		// by the tint transform function.
+		n := 1
+		if name == "DeviceN" {
+			names, err := v.Index(1)
+			if err != nil {
+				return imageColorSpace{}, err
+			}
+			if n = names.Len(); n < 1 || n > 32 {
+				return imageColorSpace{}, fmt.Errorf("invalid DeviceN component count %d", n)
+			}
+		}
+		alt, err := v.Index(2)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		acs, err := parseImageColorSpace(alt, depth+1)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		if acs.palette != nil {
+			return imageColorSpace{}, fmt.Errorf("%s color space based on indexed color space", name)
+		}
+		fv, err := v.Index(3)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		fn, err := loadFunction(fv, 0)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		return imageColorSpace{
+			n:    n,
+			base: acs.base,
+			convert: func(c []float64) []float64 {
+				out := fn.eval(c)
+				for len(out) < acs.n {
+					out = append(out, 0)
+				}
+				if acs.convert != nil {
+					out = acs.convert(out)
+				}
+				return out
+			},
+		}, nil
+	case "Indexed", "I":
+		base, err := v.Index(1)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		bcs, err := parseImageColorSpace(base, depth+1)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		if bcs.palette != nil {
+			return imageColorSpace{}, fmt.Errorf("indexed color space based on indexed color space")
+		}
+		hival, err := v.Index(2)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		if hival.Int64() < 0 || hival.Int64() > 255 {
+			return imageColorSpace{}, fmt.Errorf("invalid indexed color space maximum %d", hival.Int64())
+		}
+		table, err := v.Index(3)
+		if err != nil {
+			return imageColorSpace{}, err
+		}
+		var lookup []byte
+		if table.Kind() == Stream {
+			lookup, err = streamData(table)
+			if err != nil {
+				return imageColorSpace{}, err
+			}
+		} else {
+			lookup = []byte(table.RawString())
+		}
+		if len(lookup) < bcs.n {
+			return imageColorSpace{}, fmt.Errorf("empty indexed color table")
+		}
+		// Each entry of the table holds a color in the base space,
+		// with its components scaled from their ranges to bytes.
+		cs := imageColorSpace{n: 1, base: bcs.base}
+		for i := 0; i <= int(hival.Int64()) && (i+1)*bcs.n <= len(lookup); i++ {
+			comps := make([]float64, bcs.n)
+			for j := range comps {
+				x := float64(lookup[i*bcs.n+j]) / 0xff
+				if bcs.decode != nil {
+					x = bcs.decode[2*j] + x*(bcs.decode[2*j+1]-bcs.decode[2*j])
+				}
+				comps[j] = x
+			}
+			cs.palette = append(cs.palette, bcs.deviceColor(comps))
+		}
+		return cs, nil
+	}
+	return imageColorSpace{}, fmt.Errorf("unsupported image color space %v", v)
+}
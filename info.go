@@ -0,0 +1,222 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info holds the entries of the document information dictionary.
+// See PDF 32000-1:2008, §14.3.3.
+type Info struct {
+	V        Value
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string // application that created the original document
+	Producer string // application that converted the document to PDF
+	Trapped  Trapped
+
+	// CreationDate and ModDate are the times the document was created
+	// and last modified, or zero if unset or invalid.
+	CreationDate time.Time
+	ModDate      time.Time
+}
+
+// Trapped reports whether a document has been modified to include trapping information.
+type Trapped int
+
+const (
+	TrappedUnknown Trapped = iota
+	TrappedTrue
+	TrappedFalse
+)
+
+func (t Trapped) String() string {
+	switch t {
+	case TrappedTrue:
+		return "True"
+	case TrappedFalse:
+		return "False"
+	}
+	return "Unknown"
+}
+
+// standardInfoKeys are the entries of the information dictionary defined by the PDF specification.
+var standardInfoKeys = map[string]bool{
+	"Title":        true,
+	"Author":       true,
+	"Subject":      true,
+	"Keywords":     true,
+	"Creator":      true,
+	"Producer":     true,
+	"CreationDate": true,
+	"ModDate":      true,
+	"Trapped":      true,
+}
+
+// Info returns the document information dictionary.
+// If the document has none, Info returns an Info with i.V.IsNull().
+func (r *Reader) Info() (Info, error) {
+	v, err := r.Trailer().Key("Info")
+	if err != nil {
+		return Info{}, err
+	}
+	if v.Kind() != Dict {
+		return Info{}, nil
+	}
+	i := Info{V: v}
+	for _, x := range []struct {
+		key string
+		dst *string
+	}{
+		{"Title", &i.Title},
+		{"Author", &i.Author},
+		{"Subject", &i.Subject},
+		{"Keywords", &i.Keywords},
+		{"Creator", &i.Creator},
+		{"Producer", &i.Producer},
+	} {
+		s, err := v.Key(x.key)
+		if err != nil {
+			return Info{}, err
+		}
+		*x.dst = s.Text()
+	}
+
+	for _, x := range []struct {
+		key string
+		dst *time.Time
+	}{
+		{"CreationDate", &i.CreationDate},
+		{"ModDate", &i.ModDate},
+	} {
+		s, err := v.Key(x.key)
+		if err != nil {
+			return Info{}, err
+		}
+		*x.dst, _ = ParseDate(s.Text())
+	}
+
+	trapped, err := v.Key("Trapped")
+	if err != nil {
+		return Info{}, err
+	}
+	switch {
+	case trapped.Name() == "True", trapped.Kind() == Bool && trapped.Bool():
+		i.Trapped = TrappedTrue
+	case trapped.Name() == "False", trapped.Kind() == Bool && !trapped.Bool():
+		i.Trapped = TrappedFalse
+	}
+	return i, nil
+}
+
+// Custom returns the nonstandard entries of the information dictionary
+// whose values are strings, decoded as text strings.
+// Entries with other kinds of values are skipped.
+func (i Info) Custom() (map[string]string, error) {
+	m := make(map[string]string)
+	for _, k := range i.V.Keys() {
+		if standardInfoKeys[k] {
+			continue
+		}
+		v, err := i.V.Key(k)
+		if err != nil {
+			return nil, err
+		}
+		if v.Kind() == String {
+			m[k] = v.Text()
+		}
+	}
+	return m, nil
+}
+
+// ParseDate parses a PDF date string, of the form D:YYYYMMDDHHmmSSOHH'mm',
+// where O is the relationship of local time to UT: +, -, or Z.
+// All the fields after the year are optional, as are the D: prefix
+// and the apostrophes. A date without a time zone is taken to be in UT.
+// See PDF 32000-1:2008, §7.9.4.
+func ParseDate(s string) (time.Time, error) {
+	orig := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	bad := func() (time.Time, error) {
+		return time.Time{}, fmt.Errorf("invalid PDF date %q", orig)
+	}
+	// num consumes an n-digit field, returning def if it is missing.
+	num := func(n, def int) (int, bool) {
+		if len(s) == 0 || s[0] < '0' || s[0] > '9' {
+			return def, true
+		}
+		if len(s) < n {
+			return 0, false
+		}
+		v, err := strconv.Atoi(s[:n])
+		if err != nil {
+			return 0, false
+		}
+		s = s[n:]
+		return v, true
+	}
+	var f [6]int
+	for i, x := range []struct{ n, def int }{{4, -1}, {2, 1}, {2, 1}, {2, 0}, {2, 0}, {2, 0}} {
+		v, ok := num(x.n, x.def)
+		if !ok || v < 0 {
+			return bad()
+		}
+		f[i] = v
+	}
+	if f[1] < 1 || f[1] > 12 || f[2] < 1 || f[2] > 31 || f[3] > 23 || f[4] > 59 || f[5] > 59 {
+		return bad()
+	}
+	loc := time.UTC
+	if len(s) > 0 {
+		sign := 0
+		switch s[0] {
+		case 'Z':
+		case '+':
+			sign = 1
+		case '-':
+			sign = -1
+		default:
+			return bad()
+		}
+		s = s[1:]
+		hh, ok := num(2, 0)
+		if !ok {
+			return bad()
+		}
+		s = strings.TrimPrefix(s, "'")
+		mm, ok := num(2, 0)
+		if !ok {
+			return bad()
+		}
+		s = strings.TrimPrefix(s, "'")
+		if s != "" || hh > 23 || mm > 59 {
+			return bad()
+		}
+		if sign != 0 {
+			loc = time.FixedZone("", sign*(hh*3600+mm*60))
+		}
+	}
+	return time.Date(f[0], time.Month(f[1]), f[2], f[3], f[4], f[5], 0, loc), nil
+}
+
+// formatDate returns t as a PDF date string.
+func formatDate(t time.Time) string {
+	s := t.Format("D:20060102150405")
+	_, offset := t.Zone()
+	if offset == 0 {
+		return s + "Z"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%s%c%02d'%02d'", s, sign, offset/3600, offset/60%60)
+}
@@ -0,0 +1,493 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// JBIG2 decoding, as specified by ITU-T T.88, for the embedded stream
+// format used by PDF (PDF 32000-1:2008, §7.4.7).
+// Only generic region segments, coded with either the MQ arithmetic
+// coder or MMR, are supported. Symbol dictionaries and the other
+// segments that text, halftone, and refinement regions rely on are
+// skipped, and those regions themselves are reported as unsupported.
+
+// JBIG2 segment types.
+const (
+	jbig2SymbolDict             = 0
+	jbig2ImmediateGenericRegion = 38
+	jbig2ImmediateLosslessGen   = 39
+	jbig2PageInfo               = 48
+	jbig2EndOfPage              = 49
+	jbig2EndOfStripe            = 50
+	jbig2EndOfFile              = 51
+	jbig2Profiles               = 52
+	jbig2Tables                 = 53
+	jbig2Extension              = 62
+)
+
+// jbig2Decode decodes the JBIG2 data read from rd, preceded by the
+// segments of the JBIG2Globals stream in param, if any, into rows of
+// packed pixels with 0 for black.
+func jbig2Decode(rd io.Reader, param Value) (io.Reader, error) {
+	var p jbig2Page
+	globals, err := param.Key("JBIG2Globals")
+	if err != nil {
+		return nil, err
+	}
+	if globals.Kind() == Stream {
+		data, err := readAllStream(globals)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.decodeSegments(data); err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.decodeSegments(data); err != nil {
+		return nil, err
+	}
+	if p.bitmap == nil {
+		return nil, errors.New("JBIG2Decode: missing page information segment")
+	}
+	// JBIG2 uses 1 for black; PDF images use 0.
+	out := p.bitmap.pack()
+	for i := range out {
+		out[i] ^= 0xff
+	}
+	return bytes.NewReader(out), nil
+}
+
+// A jbig2Page is the page being decoded.
+type jbig2Page struct {
+	bitmap    *jbig2Bitmap
+	stretch   bool // height is unknown: grow the page to fit each stripe
+	defaultOp int  // default combination operator
+}
+
+// decodeSegments decodes the segments in data.
+func (p *jbig2Page) decodeSegments(data []byte) error {
+	for len(data) > 0 {
+		seg, rest, err := parseJBIG2Segment(data)
+		if err != nil {
+			return err
+		}
+		data = rest
+		switch seg.typ {
+		case jbig2PageInfo:
+			err = p.pageInfo(seg.data)
+		case jbig2ImmediateGenericRegion, jbig2ImmediateLosslessGen:
+			err = p.genericRegion(seg.data)
+		case jbig2EndOfStripe:
+			if len(seg.data) >= 4 && p.bitmap != nil && p.stretch {
+				p.bitmap.grow(int(binary.BigEndian.Uint32(seg.data)) + 1)
+			}
+		case jbig2EndOfPage, jbig2EndOfFile:
+			return nil
+		case jbig2SymbolDict, jbig2Profiles, jbig2Tables, jbig2Extension:
+			// Nothing to draw.
+		default:
+			return fmt.Errorf("JBIG2Decode: unsupported segment type %d", seg.typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jbig2Segment struct {
+	typ  int
+	data []byte
+}
+
+// parseJBIG2Segment parses the segment at the start of data,
+// returning it and the data following it.
+func parseJBIG2Segment(data []byte) (jbig2Segment, []byte, error) {
+	errShort := errors.New("JBIG2Decode: truncated segment header")
+	if len(data) < 6 {
+		return jbig2Segment{}, nil, errShort
+	}
+	num := binary.BigEndian.Uint32(data)
+	flags := data[4]
+	seg := jbig2Segment{typ: int(flags & 0x3f)}
+	pos := 5
+
+	// Referred-to segments.
+	nref := int(data[pos] >> 5)
+	if nref == 7 {
+		if len(data) < pos+4 {
+			return seg, nil, errShort
+		}
+		nref = int(binary.BigEndian.Uint32(data[pos:]) & 0x1fffffff)
+		pos += 4 + (nref+8)/8
+	} else {
+		pos++
+	}
+	refSize := 1
+	if num > 65536 {
+		refSize = 4
+	} else if num > 256 {
+		refSize = 2
+	}
+	pos += nref * refSize
+
+	// Page association.
+	if flags&0x40 != 0 {
+		pos += 4
+	} else {
+		pos++
+	}
+	if len(data) < pos+4 {
+		return seg, nil, errShort
+	}
+	n := binary.BigEndian.Uint32(data[pos:])
+	pos += 4
+	if n == 0xffffffff {
+		return seg, nil, errors.New("JBIG2Decode: segments of unknown length are not supported")
+	}
+	if uint64(len(data)-pos) < uint64(n) {
+		return seg, nil, errors.New("JBIG2Decode: truncated segment data")
+	}
+	seg.data = data[pos : pos+int(n)]
+	return seg, data[pos+int(n):], nil
+}
+
+// pageInfo handles a page information segment.
+func (p *jbig2Page) pageInfo(data []byte) error {
+	if len(data) < 19 {
+		return errors.New("JBIG2Decode: short page information segment")
+	}
+	w := binary.BigEndian.Uint32(data)
+	h := binary.BigEndian.Uint32(data[4:])
+	flags := data[16]
+	if h == 0xffffffff {
+		p.stretch = true
+		h = 0
+	}
+	if w == 0 || w > 1<<16 || h > 1<<16 {
+		return fmt.Errorf("JBIG2Decode: invalid page size %dx%d", w, h)
+	}
+	p.bitmap = newJBIG2Bitmap(int(w), int(h))
+	if flags&0x04 != 0 {
+		for i := range p.bitmap.pix {
+			p.bitmap.pix[i] = 1
+		}
+	}
+	p.defaultOp = int(flags>>3) & 3
+	return nil
+}
+
+// genericRegion decodes a generic region segment and draws it on the page.
+func (p *jbig2Page) genericRegion(data []byte) error {
+	if p.bitmap == nil {
+		return errors.New("JBIG2Decode: region before page information segment")
+	}
+	if len(data) < 18 {
+		return errors.New("JBIG2Decode: short generic region segment")
+	}
+	w := int(binary.BigEndian.Uint32(data))
+	h := int(binary.BigEndian.Uint32(data[4:]))
+	x := int(binary.BigEndian.Uint32(data[8:]))
+	y := int(binary.BigEndian.Uint32(data[12:]))
+	op := int(data[16] & 7)
+	flags := data[17]
+	data = data[18:]
+	if w <= 0 || h <= 0 || w > 1<<16 || h > 1<<16 || x > 1<<16 || y > 1<<16 {
+		return fmt.Errorf("JBIG2Decode: invalid region %dx%d at (%d, %d)", w, h, x, y)
+	}
+
+	var region *jbig2Bitmap
+	if flags&1 != 0 {
+		// MMR: the region is coded as Group 4 fax data, with 1 for black.
+		d := ccittDecoder{
+			ccittParams: ccittParams{k: -1, columns: w, rows: h, blackIs1: true},
+			data:        data,
+		}
+		rows, err := d.decode()
+		if err != nil {
+			return fmt.Errorf("JBIG2Decode: %v", err)
+		}
+		region = newJBIG2Bitmap(w, h)
+		stride := (w + 7) / 8
+		for i := 0; i < h && (i+1)*stride <= len(rows); i++ {
+			for j := 0; j < w; j++ {
+				region.pix[i*w+j] = rows[i*stride+j/8] >> (7 - uint(j%8)) & 1
+			}
+		}
+	} else {
+		template := int(flags>>1) & 3
+		tpgdon := flags&8 != 0
+		nat := 1
+		if template == 0 {
+			nat = 4
+		}
+		if len(data) < 2*nat {
+			return errors.New("JBIG2Decode: short generic region segment")
+		}
+		at := make([]jbig2Point, nat)
+		for i := range at {
+			at[i] = jbig2Point{int(int8(data[2*i])), int(int8(data[2*i+1]))}
+		}
+		region = decodeGenericRegion(newMQDecoder(data[2*nat:]), w, h, template, tpgdon, at)
+	}
+	if p.stretch && y+h > p.bitmap.h {
+		p.bitmap.grow(y + h)
+	}
+	p.bitmap.combine(region, x, y, op)
+	return nil
+}
+
+type jbig2Point struct{ x, y int }
+
+// The generic region templates, listing the pixels that form the
+// context from its most significant bit to its least; the positions
+// of the adaptive pixels A1 to A4 in each; and the context used to
+// decode the SLTP bit with each.
+var (
+	jbig2Templates = [4][]jbig2Point{
+		{{-2, -2}, {-1, -2}, {0, -2}, {1, -2}, {2, -2}, {-3, -1}, {-2, -1}, {-1, -1}, {0, -1}, {1, -1}, {2, -1}, {3, -1}, {-4, 0}, {-3, 0}, {-2, 0}, {-1, 0}},
+		{{-1, -2}, {0, -2}, {1, -2}, {2, -2}, {-2, -1}, {-1, -1}, {0, -1}, {1, -1}, {2, -1}, {3, -1}, {-3, 0}, {-2, 0}, {-1, 0}},
+		{{-1, -2}, {0, -2}, {1, -2}, {-2, -1}, {-1, -1}, {0, -1}, {1, -1}, {2, -1}, {-2, 0}, {-1, 0}},
+		{{-3, -1}, {-2, -1}, {-1, -1}, {0, -1}, {1, -1}, {2, -1}, {-4, 0}, {-3, 0}, {-2, 0}, {-1, 0}},
+	}
+	jbig2ATSlots      = [4][]int{{11, 5, 4, 0}, {9}, {7}, {5}}
+	jbig2SLTPContexts = [4]int{0x9b25, 0x0795, 0x00e5, 0x0195}
+)
+
+// decodeGenericRegion decodes an arithmetically coded generic region
+// (T.88, §6.2.5).
+func decodeGenericRegion(mq *mqDecoder, w, h, template int, tpgdon bool, at []jbig2Point) *jbig2Bitmap {
+	pts := append([]jbig2Point(nil), jbig2Templates[template]...)
+	for i, slot := range jbig2ATSlots[template] {
+		pts[slot] = at[i]
+	}
+	cx := make([]uint8, 1<<uint(len(pts)))
+	b := newJBIG2Bitmap(w, h)
+	ltp := 0
+	for y := 0; y < h; y++ {
+		if tpgdon {
+			ltp ^= mq.decode(cx, jbig2SLTPContexts[template])
+			if ltp != 0 {
+				// The row is the same as the one above.
+				if y > 0 {
+					copy(b.pix[y*w:(y+1)*w], b.pix[(y-1)*w:y*w])
+				}
+				continue
+			}
+		}
+		for x := 0; x < w; x++ {
+			ctx := 0
+			for _, pt := range pts {
+				ctx = ctx<<1 | int(b.at(x+pt.x, y+pt.y))
+			}
+			b.pix[y*w+x] = uint8(mq.decode(cx, ctx))
+		}
+	}
+	return b
+}
+
+// A jbig2Bitmap is a bitonal image with one byte per pixel, 1 for black.
+type jbig2Bitmap struct {
+	w, h int
+	pix  []uint8
+}
+
+func newJBIG2Bitmap(w, h int) *jbig2Bitmap {
+	return &jbig2Bitmap{w: w, h: h, pix: make([]uint8, w*h)}
+}
+
+// at returns the pixel at (x, y), or 0 if it is outside the bitmap.
+func (b *jbig2Bitmap) at(x, y int) uint8 {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return 0
+	}
+	return b.pix[y*b.w+x]
+}
+
+// grow extends the bitmap to h rows.
+func (b *jbig2Bitmap) grow(h int) {
+	if h <= b.h || h > 1<<16 {
+		return
+	}
+	b.pix = append(b.pix, make([]uint8, (h-b.h)*b.w)...)
+	b.h = h
+}
+
+// combine draws src onto b with its top left corner at (x, y),
+// using the combination operator op: OR, AND, XOR, XNOR, or REPLACE.
+func (b *jbig2Bitmap) combine(src *jbig2Bitmap, x, y, op int) {
+	for sy := 0; sy < src.h; sy++ {
+		dy := y + sy
+		if dy >= b.h {
+			break
+		}
+		for sx := 0; sx < src.w; sx++ {
+			dx := x + sx
+			if dx >= b.w {
+				break
+			}
+			s, d := src.pix[sy*src.w+sx], &b.pix[dy*b.w+dx]
+			switch op {
+			case 0:
+				*d |= s
+			case 1:
+				*d &= s
+			case 2:
+				*d ^= s
+			case 3:
+				*d = 1 ^ *d ^ s
+			default:
+				*d = s
+			}
+		}
+	}
+}
+
+// pack returns the bitmap as rows of packed pixels.
+func (b *jbig2Bitmap) pack() []byte {
+	stride := (b.w + 7) / 8
+	out := make([]byte, stride*b.h)
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if b.pix[y*b.w+x] != 0 {
+				out[y*stride+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+	return out
+}
+
+// An mqDecoder is the MQ arithmetic decoder of T.88, Annex E.
+type mqDecoder struct {
+	data []byte
+	pos  int
+	c    uint32
+	a    uint32
+	ct   int
+}
+
+func newMQDecoder(data []byte) *mqDecoder {
+	d := &mqDecoder{data: data}
+	d.c = uint32(d.byteAt(0)) << 16
+	d.bytein()
+	d.c <<= 7
+	d.ct -= 7
+	d.a = 0x8000
+	return d
+}
+
+// byteAt returns data[i], or 0xFF past the end of the data.
+func (d *mqDecoder) byteAt(i int) byte {
+	if i < len(d.data) {
+		return d.data[i]
+	}
+	return 0xff
+}
+
+func (d *mqDecoder) bytein() {
+	if d.byteAt(d.pos) == 0xff {
+		if d.byteAt(d.pos+1) > 0x8f {
+			d.c += 0xff00
+			d.ct = 8
+		} else {
+			d.pos++
+			d.c += uint32(d.byteAt(d.pos)) << 9
+			d.ct = 7
+		}
+	} else {
+		d.pos++
+		d.c += uint32(d.byteAt(d.pos)) << 8
+		d.ct = 8
+	}
+}
+
+// The probability estimation table: Qe, and the next states
+// after an MPS or LPS, and whether an LPS switches the MPS.
+var mqStates = [47]struct {
+	qe         uint32
+	nmps, nlps uint8
+	swtch      bool
+}{
+	{0x5601, 1, 1, true}, {0x3401, 2, 6, false}, {0x1801, 3, 9, false},
+	{0x0ac1, 4, 12, false}, {0x0521, 5, 29, false}, {0x0221, 38, 33, false},
+	{0x5601, 7, 6, true}, {0x5401, 8, 14, false}, {0x4801, 9, 14, false},
+	{0x3801, 10, 14, false}, {0x3001, 11, 17, false}, {0x2401, 12, 18, false},
+	{0x1c01, 13, 20, false}, {0x1601, 29, 21, false}, {0x5601, 15, 14, true},
+	{0x5401, 16, 14, false}, {0x5101, 17, 15, false}, {0x4801, 18, 16, false},
+	{0x3801, 19, 17, false}, {0x3401, 20, 18, false}, {0x3001, 21, 19, false},
+	{0x2801, 22, 19, false}, {0x2401, 23, 20, false}, {0x2201, 24, 21, false},
+	{0x1c01, 25, 22, false}, {0x1801, 26, 23, false}, {0x1601, 27, 24, false},
+	{0x1401, 28, 25, false}, {0x1201, 29, 26, false}, {0x1101, 30, 27, false},
+	{0x0ac1, 31, 28, false}, {0x09c1, 32, 29, false}, {0x08a1, 33, 30, false},
+	{0x0521, 34, 31, false}, {0x0441, 35, 32, false}, {0x02a1, 36, 33, false},
+	{0x0221, 37, 34, false}, {0x0141, 38, 35, false}, {0x0111, 39, 36, false},
+	{0x0085, 40, 37, false}, {0x0049, 41, 38, false}, {0x0025, 42, 39, false},
+	{0x0015, 43, 40, false}, {0x0009, 44, 41, false}, {0x0005, 45, 42, false},
+	{0x0001, 45, 43, false}, {0x5601, 46, 46, false},
+}
+
+// decode decodes a bit in context cx[i], where each context holds
+// its state index shifted left by one, or'ed with its MPS.
+func (d *mqDecoder) decode(cx []uint8, i int) int {
+	st := &mqStates[cx[i]>>1]
+	mps := int(cx[i] & 1)
+	qe := st.qe
+	var bit int
+	d.a -= qe
+	if d.c>>16 >= qe {
+		d.c -= qe << 16
+		if d.a&0x8000 != 0 {
+			return mps
+		}
+		// MPS exchange.
+		if d.a < qe {
+			bit = 1 - mps
+			if st.swtch {
+				mps = 1 - mps
+			}
+			cx[i] = st.nlps<<1 | uint8(mps)
+		} else {
+			bit = mps
+			cx[i] = st.nmps<<1 | uint8(mps)
+		}
+	} else {
+		// LPS exchange.
+		if d.a < qe {
+			bit = mps
+			cx[i] = st.nmps<<1 | uint8(mps)
+		} else {
+			bit = 1 - mps
+			if st.swtch {
+				mps = 1 - mps
+			}
+			cx[i] = st.nlps<<1 | uint8(mps)
+		}
+		d.a = qe
+	}
+	// Renormalize.
+	for {
+		if d.ct == 0 {
+			d.bytein()
+		}
+		d.a <<= 1
+		d.c <<= 1
+		d.ct--
+		if d.a&0x8000 != 0 {
+			break
+		}
+	}
+	return bit
+}
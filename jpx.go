@@ -0,0 +1,172 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// JPEG 2000 (JPXDecode) images are not decoded, but their headers are
+// parsed so that callers passing the data through to a JPEG 2000 decoder
+// know what they are getting. See PDF 32000-1:2008, §7.4.9, and
+// ITU-T T.800, Annexes A and I.
+
+// JPXInfo describes a JPEG 2000 image, as recorded in its data.
+type JPXInfo struct {
+	Width, Height    int
+	Components       int
+	BitsPerComponent int  // of the first component
+	Signed           bool // whether the first component's samples are signed
+
+	// ColorSpace is the color space given by the JP2 header:
+	// DeviceGray, DeviceRGB, DeviceCMYK, sYCC, or ICCBased, with the
+	// ICC profile in ICC. It is empty for a bare codestream, or if the
+	// header uses a color space that has no PDF equivalent.
+	// The image dictionary's ColorSpace, if any, overrides it.
+	ColorSpace string
+	ICC        []byte
+}
+
+// JPXInfo returns the description recorded in the data of a
+// JPEG 2000 (JPXDecode) image, which Decode cannot decode.
+func (im Image) JPXInfo() (JPXInfo, error) {
+	data, codec, err := im.Encoded()
+	if err != nil {
+		return JPXInfo{}, err
+	}
+	if codec != "JPXDecode" {
+		return JPXInfo{}, errors.New("not a JPXDecode image")
+	}
+	return parseJPX(data)
+}
+
+// parseJPX parses data, either a JP2 file or a bare codestream.
+func parseJPX(data []byte) (JPXInfo, error) {
+	var info JPXInfo
+	if len(data) >= 2 && data[0] == 0xff && data[1] == 0x4f {
+		err := parseJPXCodestream(data, &info)
+		return info, err
+	}
+	sawCodestream := false
+	err := jpxBoxes(data, func(typ string, body []byte) error {
+		switch typ {
+		case "jp2h":
+			return jpxBoxes(body, func(typ string, body []byte) error {
+				switch typ {
+				case "ihdr":
+					if len(body) < 14 {
+						return errors.New("JPXDecode: short ihdr box")
+					}
+					info.Height = int(binary.BigEndian.Uint32(body))
+					info.Width = int(binary.BigEndian.Uint32(body[4:]))
+					info.Components = int(binary.BigEndian.Uint16(body[8:]))
+					if body[10] != 0xff {
+						info.BitsPerComponent = int(body[10]&0x7f) + 1
+						info.Signed = body[10]&0x80 != 0
+					}
+				case "colr":
+					if info.ColorSpace != "" || len(body) < 3 {
+						// Only the first colr box is used.
+						return nil
+					}
+					switch body[0] {
+					case 1:
+						if len(body) < 7 {
+							return errors.New("JPXDecode: short colr box")
+						}
+						switch binary.BigEndian.Uint32(body[3:]) {
+						case 12:
+							info.ColorSpace = "DeviceCMYK"
+						case 16:
+							info.ColorSpace = "DeviceRGB"
+						case 17:
+							info.ColorSpace = "DeviceGray"
+						case 18:
+							info.ColorSpace = "sYCC"
+						}
+					case 2, 3:
+						info.ColorSpace = "ICCBased"
+						info.ICC = body[3:]
+					}
+				}
+				return nil
+			})
+		case "jp2c":
+			sawCodestream = true
+			if info.Width == 0 || info.BitsPerComponent == 0 {
+				// The header leaves the details to the codestream.
+				return parseJPXCodestream(body, &info)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return info, err
+	}
+	if !sawCodestream && info.Width == 0 {
+		return info, errors.New("JPXDecode: not a JPEG 2000 file")
+	}
+	return info, nil
+}
+
+// jpxBoxes calls fn for each box in data with the box type and contents.
+func jpxBoxes(data []byte, fn func(typ string, body []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return errors.New("JPXDecode: truncated box header")
+		}
+		n := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		hdr := uint64(8)
+		switch n {
+		case 0:
+			n = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return errors.New("JPXDecode: truncated box header")
+			}
+			n = binary.BigEndian.Uint64(data[8:])
+			hdr = 16
+		}
+		if n < hdr || n > uint64(len(data)) {
+			return fmt.Errorf("JPXDecode: invalid length for %q box", typ)
+		}
+		if err := fn(typ, data[hdr:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// parseJPXCodestream fills in info from the SIZ marker segment of
+// the codestream in data.
+func parseJPXCodestream(data []byte, info *JPXInfo) error {
+	// SOC is followed immediately by SIZ.
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0x4f || data[2] != 0xff || data[3] != 0x51 {
+		return errors.New("JPXDecode: missing SIZ marker")
+	}
+	siz := data[4:]
+	if len(siz) < 38 {
+		return errors.New("JPXDecode: short SIZ marker")
+	}
+	x := binary.BigEndian.Uint32(siz[4:])
+	y := binary.BigEndian.Uint32(siz[8:])
+	x0 := binary.BigEndian.Uint32(siz[12:])
+	y0 := binary.BigEndian.Uint32(siz[16:])
+	if x0 > x || y0 > y {
+		return errors.New("JPXDecode: invalid image offset")
+	}
+	info.Width = int(x - x0)
+	info.Height = int(y - y0)
+	info.Components = int(binary.BigEndian.Uint16(siz[36:]))
+	if info.Components > 0 && len(siz) >= 39 {
+		info.BitsPerComponent = int(siz[38]&0x7f) + 1
+		info.Signed = siz[38]&0x80 != 0
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A pageLabelRange is an entry of the PageLabels number tree: the labels
+// of the pages from the page with index start, counting from 0, up to
+// the start of the next range.
+type pageLabelRange struct {
+	start  int
+	style  string // D, R, r, A, or a; "" for no number
+	prefix string
+	first  int // the number of the first page of the range
+}
+
+// PageLabel returns the label of the page with the given number,
+// starting at 1, as a viewer displays it, such as "iv", "A-1", or "3".
+// Labels are given by the document's PageLabels number tree; a
+// document without one, or a page that the tree does not cover,
+// is labeled with its page number. See PDF 32000-1:2008, §12.4.2.
+func (r *Reader) PageLabel(num int) (string, error) {
+	n, err := r.NumPage()
+	if err != nil {
+		return "", err
+	}
+	if num < 1 || num > n {
+		return "", fmt.Errorf("pdf: page %d out of range [1, %d]", num, n)
+	}
+	ranges, err := r.pageLabelRanges()
+	if err != nil {
+		return "", err
+	}
+	return pageLabel(ranges, num), nil
+}
+
+// PageByLabel returns the number of the first page, starting at 1,
+// whose label (see PageLabel) is label, or 0 if there is none.
+func (r *Reader) PageByLabel(label string) (int, error) {
+	n, err := r.NumPage()
+	if err != nil {
+		return 0, err
+	}
+	ranges, err := r.pageLabelRanges()
+	if err != nil {
+		return 0, err
+	}
+	for num := 1; num <= n; num++ {
+		if pageLabel(ranges, num) == label {
+			return num, nil
+		}
+	}
+	return 0, nil
+}
+
+// pageLabelRanges returns the ranges of the document's PageLabels
+// number tree, ordered by their first pages.
+func (r *Reader) pageLabelRanges() ([]pageLabelRange, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, err
+	}
+	tree, err := root.Key("PageLabels")
+	if err != nil {
+		return nil, err
+	}
+	var ranges []pageLabelRange
+	err = walkNumberTree(tree, func(key int, val Value) error {
+		style, err := val.Key("S")
+		if err != nil {
+			return err
+		}
+		prefix, err := val.Key("P")
+		if err != nil {
+			return err
+		}
+		first, err := val.Key("St")
+		if err != nil {
+			return err
+		}
+		pr := pageLabelRange{start: key, style: style.Name(), prefix: prefix.Text(), first: 1}
+		if first.Kind() == Integer && first.Int64() >= 1 {
+			pr.first = int(first.Int64())
+		}
+		ranges = append(ranges, pr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	return ranges, nil
+}
+
+// pageLabel returns the label of the page with the given number,
+// starting at 1, in the page label ranges.
+func pageLabel(ranges []pageLabelRange, num int) string {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].start > num-1 }) - 1
+	if i < 0 {
+		return strconv.Itoa(num)
+	}
+	pr := ranges[i]
+	n := pr.first + num - 1 - pr.start
+	switch pr.style {
+	case "D":
+		return pr.prefix + strconv.Itoa(n)
+	case "R":
+		return pr.prefix + roman(n)
+	case "r":
+		return pr.prefix + strings.ToLower(roman(n))
+	case "A":
+		return pr.prefix + letters(n)
+	case "a":
+		return pr.prefix + strings.ToLower(letters(n))
+	}
+	return pr.prefix
+}
+
+// roman returns n, which is positive, in upper-case Roman numerals.
+func roman(n int) string {
+	var b strings.Builder
+	for _, d := range []struct {
+		n int
+		s string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"},
+		{100, "C"}, {90, "XC"}, {50, "L"}, {40, "XL"},
+		{10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	} {
+		for ; n >= d.n; n -= d.n {
+			b.WriteString(d.s)
+		}
+	}
+	return b.String()
+}
+
+// letters returns n, which is positive, in upper-case letters:
+// A to Z, then AA to ZZ, AAA to ZZZ, and so on.
+func letters(n int) string {
+	return strings.Repeat(string(rune('A'+(n-1)%26)), (n-1)/26+1)
+}
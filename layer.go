@@ -0,0 +1,268 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "context"
+
+// A Layer is an optional content group: content that a viewer can
+// show or hide, such as a layer of a drawing or a language of a map.
+// See PDF 32000-1:2008, §8.11.
+type Layer struct {
+	V       Value    // the optional content group dictionary
+	Name    string   // the name shown in a viewer's list of layers
+	Visible bool     // whether the layer is shown, in the document's default configuration
+	Locked  bool     // whether the default configuration keeps the user from changing Visible
+	Intent  []string // the intended uses of the layer, such as View or Design; View if unset
+	Usage   Value    // the Usage dictionary, describing the layer's content; null if none
+}
+
+// A LayerRange is a marked-content sequence of a page's content that
+// belongs to optional content: one begun by a BDC operator with the
+// tag OC.
+type LayerRange struct {
+	// Start and End are the indexes, in the page's Operators, of the
+	// BDC operator beginning the sequence and the EMC operator ending
+	// it. End is the number of operators if the sequence is not ended.
+	Start, End int
+
+	// Layers are the layers the content belongs to. For content that
+	// belongs to an optional content membership dictionary, rather
+	// than to a single group, they are its groups, and Membership is
+	// the dictionary; otherwise Membership is null.
+	Layers     []Layer
+	Membership Value
+}
+
+// Layers returns the document's layers, in the order of the OCGs array
+// of its OCProperties dictionary, with their visibility in the default
+// configuration. Documents without optional content have no layers.
+func (r *Reader) Layers() ([]Layer, error) {
+	oc, err := r.layerConfig()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := oc.props.Key("OCGs")
+	if err != nil {
+		return nil, err
+	}
+	var out []Layer
+	for i := 0; i < groups.Len(); i++ {
+		g, err := groups.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		if g.Kind() != Dict {
+			continue
+		}
+		l, err := oc.layer(g)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// LayerRanges returns the marked-content sequences of the page's content
+// (see Page.Operators) that belong to layers, in the order they begin.
+// Sequences may nest. Optional content in form XObjects and annotations,
+// marked by their OC entries, is not reported.
+func (p Page) LayerRanges(ctx context.Context) ([]LayerRange, error) {
+	ops, err := p.Operators(ctx)
+	if err != nil {
+		return nil, err
+	}
+	oc, err := p.V.r.layerConfig()
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.Resources()
+	if err != nil {
+		return nil, err
+	}
+	props, err := res.Key("Properties")
+	if err != nil {
+		return nil, err
+	}
+	var out []LayerRange
+	// open holds, for each open marked-content sequence, the index
+	// in out of its range, or -1 if it does not belong to a layer.
+	var open []int
+	for i, op := range ops {
+		switch op.Name {
+		case "BMC":
+			open = append(open, -1)
+		case "BDC":
+			if len(op.Args) != 2 || op.Args[0].Name() != "OC" {
+				open = append(open, -1)
+				break
+			}
+			v := op.Args[1]
+			if v.Kind() == Name {
+				if v, err = props.Key(v.Name()); err != nil {
+					return nil, err
+				}
+			}
+			lr := LayerRange{Start: i, End: len(ops)}
+			if lr.Layers, lr.Membership, err = oc.layers(v); err != nil {
+				return nil, err
+			}
+			open = append(open, len(out))
+			out = append(out, lr)
+		case "EMC":
+			if len(open) == 0 {
+				break
+			}
+			if j := open[len(open)-1]; j >= 0 {
+				out[j].End = i
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	return out, nil
+}
+
+// A layerConfig is the document's optional content properties and
+// the states of its groups in the default configuration.
+type layerConfig struct {
+	props  Value
+	off    map[objptr]bool // groups that are hidden
+	on     map[objptr]bool // groups that are shown, if baseOff
+	locked map[objptr]bool
+
+	baseOff bool // groups not listed are hidden
+}
+
+// layerConfig returns the document's optional content configuration.
+func (r *Reader) layerConfig() (*layerConfig, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, err
+	}
+	props, err := root.Key("OCProperties")
+	if err != nil {
+		return nil, err
+	}
+	d, err := props.Key("D")
+	if err != nil {
+		return nil, err
+	}
+	base, err := d.Key("BaseState")
+	if err != nil {
+		return nil, err
+	}
+	oc := &layerConfig{props: props, baseOff: base.Name() == "OFF"}
+	groups := func(key string) (map[objptr]bool, error) {
+		a, err := d.Key(key)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[objptr]bool)
+		for i := 0; i < a.Len(); i++ {
+			g, err := a.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			if g.Kind() == Dict {
+				m[g.ptr] = true
+			}
+		}
+		return m, nil
+	}
+	if oc.on, err = groups("ON"); err != nil {
+		return nil, err
+	}
+	if oc.off, err = groups("OFF"); err != nil {
+		return nil, err
+	}
+	if oc.locked, err = groups("Locked"); err != nil {
+		return nil, err
+	}
+	return oc, nil
+}
+
+// layer returns the layer of the optional content group dictionary g.
+func (oc *layerConfig) layer(g Value) (Layer, error) {
+	name, err := g.Key("Name")
+	if err != nil {
+		return Layer{}, err
+	}
+	usage, err := g.Key("Usage")
+	if err != nil {
+		return Layer{}, err
+	}
+	intent, err := g.Key("Intent")
+	if err != nil {
+		return Layer{}, err
+	}
+	l := Layer{
+		V:       g,
+		Name:    name.Text(),
+		Visible: !oc.off[g.ptr] && (!oc.baseOff || oc.on[g.ptr]),
+		Locked:  oc.locked[g.ptr],
+		Usage:   usage,
+	}
+	switch intent.Kind() {
+	case Name:
+		l.Intent = []string{intent.Name()}
+	case Array:
+		for i := 0; i < intent.Len(); i++ {
+			x, err := intent.Index(i)
+			if err != nil {
+				return Layer{}, err
+			}
+			l.Intent = append(l.Intent, x.Name())
+		}
+	default:
+		l.Intent = []string{"View"}
+	}
+	return l, nil
+}
+
+// layers returns the layers of the optional content group or membership
+// dictionary v, and v itself if it is a membership dictionary.
+func (oc *layerConfig) layers(v Value) ([]Layer, Value, error) {
+	typ, err := v.Key("Type")
+	if err != nil {
+		return nil, Value{}, err
+	}
+	if typ.Name() != "OCMD" {
+		if v.Kind() != Dict {
+			return nil, Value{}, nil
+		}
+		l, err := oc.layer(v)
+		if err != nil {
+			return nil, Value{}, err
+		}
+		return []Layer{l}, Value{}, nil
+	}
+	groups, err := v.Key("OCGs")
+	if err != nil {
+		return nil, Value{}, err
+	}
+	// OCGs is a group or an array of them.
+	var all []Value
+	if groups.Kind() == Dict {
+		all = append(all, groups)
+	}
+	for i := 0; i < groups.Len(); i++ {
+		g, err := groups.Index(i)
+		if err != nil {
+			return nil, Value{}, err
+		}
+		if g.Kind() == Dict {
+			all = append(all, g)
+		}
+	}
+	var out []Layer
+	for _, g := range all {
+		l, err := oc.layer(g)
+		if err != nil {
+			return nil, Value{}, err
+		}
+		out = append(out, l)
+	}
+	return out, v, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A Word is a sequence of glyphs forming a word.
+type Word struct {
+	S   string // the UTF-8 text of the word
+	Box Rect   // the approximate bounds of the word's glyphs
+}
+
+// A Line is a sequence of words sharing a baseline, left to right.
+type Line struct {
+	Words []Word
+	Box   Rect // the union of the words' bounds
+}
+
+// Text returns the words of the line separated by spaces.
+func (l Line) Text() string {
+	s := make([]string, len(l.Words))
+	for i, w := range l.Words {
+		s[i] = w.S
+	}
+	return strings.Join(s, " ")
+}
+
+// glyphRect returns the approximate bounding box of g.
+// Without font metrics the ascent and descent are assumed to be
+// 80% and 20% of the font size, and glyphs with no known width
+// are assumed to be half as wide as they are tall.
+func glyphRect(g Glyph) Rect {
+	size := math.Abs(g.FontSize)
+	w := g.W
+	if w == 0 {
+		w = math.Hypot(g.DX, g.DY)
+	}
+	if w == 0 {
+		w = size / 2
+	}
+	return Rect{Point{g.X, g.Y - 0.2*size}, Point{g.X + w, g.Y + 0.8*size}}
+}
+
+func unionRect(r, s Rect) Rect {
+	if r == (Rect{}) {
+		return s
+	}
+	return Rect{
+		Point{math.Min(r.Min.X, s.Min.X), math.Min(r.Min.Y, s.Min.Y)},
+		Point{math.Max(r.Max.X, s.Max.X), math.Max(r.Max.Y, s.Max.Y)},
+	}
+}
+
+// layoutLines groups glyphs into lines and words.
+// Glyphs whose baselines are within half a font size of each other
+// belong to the same line. Within a line, words are separated by
+// white space glyphs or by gaps wider than a quarter of the font size.
+// Lines are returned top to bottom.
+func layoutLines(glyphs []Glyph) []Line {
+	var gs []Glyph
+	for _, g := range glyphs {
+		if g.S != "" && g.S != "\n" {
+			gs = append(gs, g)
+		}
+	}
+	sort.SliceStable(gs, func(i, j int) bool {
+		return gs[i].Y > gs[j].Y
+	})
+
+	var rows [][]Glyph
+	for _, g := range gs {
+		n := len(rows)
+		if n > 0 {
+			last := rows[n-1][0]
+			tol := math.Max(math.Abs(last.FontSize), math.Abs(g.FontSize)) / 2
+			if math.Abs(last.Y-g.Y) <= tol {
+				rows[n-1] = append(rows[n-1], g)
+				continue
+			}
+		}
+		rows = append(rows, []Glyph{g})
+	}
+
+	var lines []Line
+	for _, row := range rows {
+		sort.SliceStable(row, func(i, j int) bool {
+			return row[i].X < row[j].X
+		})
+		var line Line
+		var word strings.Builder
+		var wordRect Rect
+		flush := func() {
+			if word.Len() > 0 {
+				line.Words = append(line.Words, Word{word.String(), wordRect})
+				line.Box = unionRect(line.Box, wordRect)
+			}
+			word.Reset()
+			wordRect = Rect{}
+		}
+		var prevEnd float64
+		for i, g := range row {
+			if strings.TrimFunc(g.S, unicode.IsSpace) == "" {
+				flush()
+				continue
+			}
+			r := glyphRect(g)
+			if i > 0 && word.Len() > 0 && r.Min.X-prevEnd > math.Abs(g.FontSize)/4 {
+				flush()
+			}
+			word.WriteString(g.S)
+			wordRect = unionRect(wordRect, r)
+			prevEnd = r.Max.X
+		}
+		flush()
+		if len(line.Words) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Lines returns the page's text grouped into words and lines, in visual
+// reading order. Glyphs are grouped as described for layoutLines, using
+// the glyph widths from the fonts' metrics; a line is split where a gap
+// between words is wide enough to be a column gutter. The lines are then
+// ordered by recursively dividing the page at the vertical gaps between
+// columns, reading columns left to right, and otherwise at the widest
+// horizontal gap, reading top to bottom.
+// Coordinates are as selected by the Reader's TextOptions.
+func (p Page) Lines(ctx context.Context) ([]Line, error) {
+	glyphs, err := p.Glyphs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var lines []Line
+	for _, line := range layoutLines(glyphs) {
+		lines = append(lines, splitLine(line)...)
+	}
+	return xyCut(lines, nil), nil
+}
+
+// splitLine splits line where the gap between two words is wider than
+// twice the line's height, as between the columns of a page.
+func splitLine(line Line) []Line {
+	gutter := 2 * (line.Box.Max.Y - line.Box.Min.Y)
+	var out []Line
+	var cur Line
+	for i, w := range line.Words {
+		if i > 0 && w.Box.Min.X-line.Words[i-1].Box.Max.X > gutter {
+			out = append(out, cur)
+			cur = Line{}
+		}
+		cur.Words = append(cur.Words, w)
+		cur.Box = unionRect(cur.Box, w.Box)
+	}
+	return append(out, cur)
+}
+
+// xyCut appends lines to out in reading order.
+// If some vertical gap separates the lines into a left and a right part,
+// the left part comes first; otherwise the lines are divided at the
+// widest horizontal gap, top part first.
+func xyCut(lines []Line, out []Line) []Line {
+	if len(lines) <= 1 {
+		return append(out, lines...)
+	}
+	if left, right, ok := cut(lines, func(r Rect) (float64, float64) { return r.Min.X, r.Max.X }); ok {
+		return xyCut(right, xyCut(left, out))
+	}
+	// Cut on negated Y so that the first part is the top one.
+	if top, bottom, ok := cut(lines, func(r Rect) (float64, float64) { return -r.Max.Y, -r.Min.Y }); ok {
+		return xyCut(bottom, xyCut(top, out))
+	}
+	// Overlapping lines: fall back to top to bottom, left to right.
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].Box.Max.Y != lines[j].Box.Max.Y {
+			return lines[i].Box.Max.Y > lines[j].Box.Max.Y
+		}
+		return lines[i].Box.Min.X < lines[j].Box.Min.X
+	})
+	return append(out, lines...)
+}
+
+// cut divides lines at the widest gap between the intervals
+// returned by span for their boxes. It reports false if there is no gap.
+func cut(lines []Line, span func(Rect) (lo, hi float64)) (before, after []Line, ok bool) {
+	sorted := append([]Line(nil), lines...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		lo1, _ := span(sorted[i].Box)
+		lo2, _ := span(sorted[j].Box)
+		return lo1 < lo2
+	})
+	best, at := 0.0, -1
+	_, end := span(sorted[0].Box)
+	for i := 1; i < len(sorted); i++ {
+		lo, hi := span(sorted[i].Box)
+		if gap := lo - end; gap > best {
+			best, at = gap, i
+		}
+		end = math.Max(end, hi)
+	}
+	if at < 0 {
+		return nil, nil, false
+	}
+	return sorted[:at], sorted[at:], true
+}
@@ -7,6 +7,7 @@
 package pdf
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -43,20 +44,25 @@ type buffer struct {
 	allowObjptr bool
 	allowStream bool
 	eof         bool
+	err         error // the error that ended the input, other than an allowed EOF
 	key         []byte
 	useAES      bool
 	objptr      objptr
+	owner       *Reader // reader to report problems to, if any
+	relative    bool    // offsets are within a decoded stream, not the file
+	depth       int     // nesting of the arrays, dictionaries, and definitions being read
 }
 
 // newBuffer returns a new buffer reading from r at the given offset.
+// Buffers come from bufferPool; one no longer needed may be returned
+// to it with free.
 func newBuffer(r io.Reader, offset int64) *buffer {
-	return &buffer{
-		r:           r,
-		offset:      offset,
-		buf:         make([]byte, 0, 4096),
-		allowObjptr: true,
-		allowStream: true,
-	}
+	b := bufferPool.Get().(*buffer)
+	b.r = r
+	b.offset = offset
+	b.allowObjptr = true
+	b.allowStream = true
+	return b
 }
 
 func (b *buffer) seek(offset int64) {
@@ -88,7 +94,8 @@ func (b *buffer) reload() (bool, error) {
 			b.eof = true
 			return false, nil
 		}
-		return false, fmt.Errorf("malformed PDF: reading at offset %d: %v", b.offset, err)
+		b.err = fmt.Errorf("malformed PDF: reading at offset %d: %v", b.offset, err)
+		return false, b.err
 	}
 	b.offset += int64(n)
 	b.buf = b.buf[:n]
@@ -138,6 +145,9 @@ func (b *buffer) readToken() (token, error) {
 			if b.eof {
 				return io.EOF, nil
 			}
+			if b.err != nil {
+				return nil, b.err
+			}
 			c = b.readByte()
 		} else if c == '%' {
 			for c != '\r' && c != '\n' {
@@ -181,27 +191,37 @@ func (b *buffer) readToken() (token, error) {
 	}
 }
 
+// readHexString reads a hexadecimal string, after its opening <.
+// A final odd digit is followed by an assumed 0, as the spec requires.
+// Other characters than digits and white space are malformed; they are
+// ignored if the reader allows RepairTokens.
 func (b *buffer) readHexString() (token, error) {
 	tmp := b.tmp[:0]
+	odd := false
 	for {
-	Loop:
 		c := b.readByte()
-		if c == '>' {
+		if b.err != nil {
+			return "", b.err
+		}
+		if c == '>' || b.eof {
 			break
 		}
 		if isSpace(c) {
-			goto Loop
-		}
-	Loop2:
-		c2 := b.readByte()
-		if isSpace(c2) {
-			goto Loop2
+			continue
 		}
-		x := unhex(c)<<4 | unhex(c2)
+		x := unhex(c)
 		if x < 0 {
-			return "", fmt.Errorf("malformed hex string %c %c %s", c, c2, b.buf[b.pos:])
+			if err := b.badToken(fmt.Sprintf("malformed hex string: invalid character %#q", rune(c)), "ignored"); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if odd {
+			tmp[len(tmp)-1] |= byte(x)
+		} else {
+			tmp = append(tmp, byte(x<<4))
 		}
-		tmp = append(tmp, byte(x))
+		odd = !odd
 	}
 	b.tmp = tmp
 	return string(tmp), nil
@@ -219,12 +239,22 @@ func unhex(b byte) int {
 	return -1
 }
 
+// readLiteralString reads a literal string, after its opening (.
+// As the spec requires, a backslash before a character that does not
+// form an escape sequence is ignored, and an octal escape greater than
+// \377 keeps its low-order byte.
 func (b *buffer) readLiteralString() (token, error) {
 	tmp := b.tmp[:0]
 	depth := 1
 Loop:
-	for !b.eof {
+	for {
 		c := b.readByte()
+		if b.err != nil {
+			return "", b.err
+		}
+		if b.eof {
+			break
+		}
 		switch c {
 		default:
 			tmp = append(tmp, c)
@@ -239,8 +269,7 @@ Loop:
 		case '\\':
 			switch c = b.readByte(); c {
 			default:
-				return tmp, fmt.Errorf("invalid escape sequence \\%c", c)
-				//tmp = append(tmp, '\\', c)
+				tmp = append(tmp, c)
 			case 'n':
 				tmp = append(tmp, '\n')
 			case 'r':
@@ -251,8 +280,6 @@ Loop:
 				tmp = append(tmp, '\t')
 			case 'f':
 				tmp = append(tmp, '\f')
-			case '(', ')', '\\':
-				tmp = append(tmp, c)
 			case '\r':
 				if b.readByte() != '\n' {
 					b.unreadByte()
@@ -270,9 +297,6 @@ Loop:
 					}
 					x = x*8 + int(c-'0')
 				}
-				if x > 255 {
-					return tmp, fmt.Errorf("invalid octal escape \\%03o", x)
-				}
 				tmp = append(tmp, byte(x))
 			}
 		}
@@ -290,11 +314,27 @@ func (b *buffer) readName() (token, error) {
 			break
 		}
 		if c == '#' {
-			x := unhex(b.readByte())<<4 | unhex(b.readByte())
-			if x < 0 {
-				return "", fmt.Errorf("malformed name")
+			// Before PDF 1.2, # was an ordinary character, and files
+			// from then, or from buggy producers, may use it as one.
+			c1 := b.readByte()
+			if unhex(c1) < 0 {
+				if err := b.badToken("malformed name: # not followed by two hex digits", "# kept"); err != nil {
+					return "", err
+				}
+				b.unreadByte()
+				tmp = append(tmp, c)
+				continue
+			}
+			c2 := b.readByte()
+			if unhex(c2) < 0 {
+				if err := b.badToken("malformed name: # not followed by two hex digits", "# kept"); err != nil {
+					return "", err
+				}
+				b.unreadByte()
+				tmp = append(tmp, c, c1)
+				continue
 			}
-			tmp = append(tmp, byte(x))
+			tmp = append(tmp, byte(unhex(c1)<<4|unhex(c2)))
 			continue
 		}
 		tmp = append(tmp, c)
@@ -395,6 +435,7 @@ type stream struct {
 	hdr    dict
 	ptr    objptr
 	offset int64
+	data   []byte // for a stream created by NewStream, its encoded data
 }
 
 type objptr struct {
@@ -449,6 +490,10 @@ func (b *buffer) readObject() (object, error) {
 			case keyword("R"):
 				return objptr{uint32(t1), uint16(t2)}, nil
 			case keyword("obj"):
+				defer func() { b.depth-- }()
+				if err := b.nest(); err != nil {
+					return nil, err
+				}
 				old := b.objptr
 				b.objptr = objptr{uint32(t1), uint16(t2)}
 				obj, err := b.readObject()
@@ -476,12 +521,19 @@ func (b *buffer) readObject() (object, error) {
 }
 
 func (b *buffer) readArray() (object, error) {
+	defer func() { b.depth-- }()
+	if err := b.nest(); err != nil {
+		return nil, err
+	}
 	var x array
 	for {
 		tok, err := b.readToken()
 		if err != nil {
 			return nil, err
 		}
+		if tok == io.EOF {
+			return nil, errors.New("malformed PDF: unexpected end of data in array")
+		}
 		if tok == nil || tok == keyword("]") {
 			break
 		}
@@ -495,13 +547,58 @@ func (b *buffer) readArray() (object, error) {
 	return x, nil
 }
 
+// duplicateKey handles a key repeated within a dictionary.
+// The first occurrence is kept and later ones are ignored, matching Acrobat.
+// The repetition is recorded as a Problem, or is an error if the
+// reader is in strict mode or does not allow RepairDuplicateKeys.
+func (b *buffer) duplicateKey(n name, off int64) error {
+	if b.owner == nil {
+		return nil
+	}
+	if b.relative {
+		off = -1
+	}
+	if !b.owner.repair(RepairDuplicateKeys) {
+		return fmt.Errorf("duplicate dictionary key /%s", n)
+	}
+	b.owner.problem(off, "duplicate dictionary key /%s; keeping first value", n)
+	return nil
+}
+
+// badToken handles a malformed token, described by msg, that the
+// caller can read anyway, as fix says. It is recorded as a Problem,
+// or is an error if the reader is in strict mode or does not allow
+// RepairTokens. Tokens without an owner, as in content streams,
+// are always read.
+func (b *buffer) badToken(msg, fix string) error {
+	if b.owner == nil {
+		return nil
+	}
+	if !b.owner.repair(RepairTokens) {
+		return errors.New(msg)
+	}
+	off := b.readOffset()
+	if b.relative {
+		off = -1
+	}
+	b.owner.problem(off, "%s; %s", msg, fix)
+	return nil
+}
+
 func (b *buffer) readDict() (object, error) {
+	defer func() { b.depth-- }()
+	if err := b.nest(); err != nil {
+		return nil, err
+	}
 	x := make(dict)
 	for {
 		tok, err := b.readToken()
 		if err != nil {
 			return nil, err
 		}
+		if tok == io.EOF {
+			return nil, errors.New("malformed PDF: unexpected end of data in dictionary")
+		}
 		if tok == nil || tok == keyword(">>") {
 			break
 		}
@@ -509,10 +606,17 @@ func (b *buffer) readDict() (object, error) {
 		if !ok {
 			return nil, fmt.Errorf("unexpected non-name key %T(%v) parsing dictionary", tok, tok)
 		}
+		off := b.readOffset()
 		obj, err := b.readObject()
 		if err != nil {
 			return nil, err
 		}
+		if _, dup := x[n]; dup {
+			if err := b.duplicateKey(n, off); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		x[n] = obj
 	}
 
@@ -540,7 +644,7 @@ func (b *buffer) readDict() (object, error) {
 		return nil, fmt.Errorf("stream keyword not followed by newline")
 	}
 
-	return stream{x, b.objptr, b.readOffset()}, nil
+	return stream{x, b.objptr, b.readOffset(), nil}, nil
 }
 
 func isSpace(b byte) bool {
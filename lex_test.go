@@ -0,0 +1,70 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	ctx := context.Background()
+	objs := pageTree(1)
+	objs[2] = strings.Replace(objs[2], "/MediaBox [0 0 612 792]", "/MediaBox [0 0 612 792] /MediaBox [0 0 100 100]", 1)
+	file := buildPDF(objs...)
+
+	// Leniently, the first value is kept and the repetition is a Problem.
+	r := openPDF(t, file, nil)
+	p, err := r.Page(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := p.MediaBox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Rect{Point{0, 0}, Point{612, 792}}); box != want {
+		t.Errorf("MediaBox = %v, want the first value, %v", box, want)
+	}
+	probs := r.Problems()
+	if len(probs) != 1 || !strings.Contains(probs[0].Msg, "duplicate dictionary key /MediaBox") {
+		t.Errorf("Problems = %v, want one for the duplicate /MediaBox", probs)
+	}
+
+	// Strictly, or without the repair, the page cannot be read.
+	for _, opts := range []*Options{{Strict: true}, {Repairs: AllRepairs &^ RepairDuplicateKeys}, {Repairs: RepairNone}} {
+		r = openPDF(t, file, opts)
+		_, err := r.Page(ctx, 1)
+		if err == nil || !strings.Contains(err.Error(), "duplicate dictionary key /MediaBox") {
+			t.Errorf("%+v: Page(1): got error %v, want duplicate key error", *opts, err)
+		}
+	}
+}
+
+// TestUnterminated reads arrays and dictionaries cut off by the end
+// of their data, which must be errors rather than read forever.
+func TestUnterminated(t *testing.T) {
+	for _, src := range []string{"[1 2", "<< /A 1", "[1 [2"} {
+		b := newBuffer(strings.NewReader(src), 0)
+		b.allowEOF = true
+		_, err := b.readObject()
+		b.free()
+		if err == nil || !strings.Contains(err.Error(), "unexpected end of data") {
+			t.Errorf("readObject(%q): got error %v, want unexpected end of data", src, err)
+		}
+	}
+
+	objs := pageTree(1)
+	objs[3] = streamObj("", "BT /F1 12 Tf 72 720 Td (page 1) Tj ET [1 2")
+	r := openPDF(t, buildPDF(objs...), nil)
+	p, err := r.Page(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetPlainText(context.Background(), nil); err == nil {
+		t.Error("GetPlainText of content ending in an unterminated array succeeded, want error")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"fmt"
+	"io"
+)
+
+// Limits bounds the amount of work a Reader is willing to do on behalf of
+// a single document. They exist to protect services processing untrusted
+// files from documents crafted to exhaust memory or CPU.
+// A zero field means no limit. Exceeding a limit is an error, a *LimitError,
+// not a Problem, even for a lenient Reader.
+type Limits struct {
+	MaxPages        int   // maximum number of pages declared or discovered in the page tree
+	MaxDepth        int   // maximum nesting of arrays, dictionaries, and object definitions
+	MaxStreamSize   int64 // maximum size of a stream's data once decoded
+	MaxObjects      int   // maximum object number, plus one
+	MaxXrefSections int   // maximum length of a chain of cross-reference sections
+}
+
+// DefaultLimits are the Limits installed in a Reader when a file is opened
+// without Options.Limits. They are high enough for any legitimate document.
+// MaxDepth also bounds content streams parsed by ParseContent.
+var DefaultLimits = Limits{
+	MaxPages:        1 << 20,
+	MaxDepth:        256,
+	MaxStreamSize:   1 << 30,
+	MaxObjects:      1<<23 - 1, // the limit of PDF 32000-1:2008, Annex C
+	MaxXrefSections: 4096,
+}
+
+// A LimitError reports that a document exceeded one of the Reader's Limits.
+type LimitError struct {
+	Limit string // name of the Limits field that was exceeded
+	Max   int64  // the configured limit
+	Value int64  // the value that exceeded it
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("pdf: %s limit exceeded: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+// checkPages reports a LimitError if n exceeds r.Limits.MaxPages.
+func (r *Reader) checkPages(n int) error {
+	if r.Limits.MaxPages > 0 && n > r.Limits.MaxPages {
+		return &LimitError{"MaxPages", int64(r.Limits.MaxPages), int64(n)}
+	}
+	return nil
+}
+
+// checkObject reports a LimitError if the object number id is not below
+// r.Limits.MaxObjects.
+func (r *Reader) checkObject(id int64) error {
+	if r.Limits.MaxObjects > 0 && id >= int64(r.Limits.MaxObjects) {
+		return &LimitError{"MaxObjects", int64(r.Limits.MaxObjects), id + 1}
+	}
+	return nil
+}
+
+// checkXrefSections reports a LimitError if n exceeds
+// r.Limits.MaxXrefSections.
+func (r *Reader) checkXrefSections(n int) error {
+	if r.Limits.MaxXrefSections > 0 && n > r.Limits.MaxXrefSections {
+		return &LimitError{"MaxXrefSections", int64(r.Limits.MaxXrefSections), int64(n)}
+	}
+	return nil
+}
+
+// nest notes the start of an array, dictionary, or object definition
+// read by b, reporting a LimitError if it is nested more deeply than
+// the Limits of b's owner, or DefaultLimits, allow. The caller must
+// decrement b.depth when done with it, even on error.
+func (b *buffer) nest() error {
+	b.depth++
+	max := DefaultLimits.MaxDepth
+	if b.owner != nil {
+		max = b.owner.Limits.MaxDepth
+	}
+	if max > 0 && b.depth > max {
+		return &LimitError{"MaxDepth", int64(max), int64(b.depth)}
+	}
+	return nil
+}
+
+// A streamLimitReader reads the decoded data of a stream, returning a
+// LimitError once there is more than max bytes of it.
+type streamLimitReader struct {
+	r   io.Reader
+	max int64
+	n   int64 // bytes read so far
+}
+
+func (l *streamLimitReader) Read(p []byte) (int, error) {
+	if l.n > l.max {
+		return 0, &LimitError{"MaxStreamSize", l.max, l.n}
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n - int(l.n-l.max), &LimitError{"MaxStreamSize", l.max, l.n}
+	}
+	return n, err
+}
@@ -0,0 +1,614 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteLinearized writes the document read by r to w as a new,
+// linearized PDF file: one organized so that a viewer reading it over a
+// network can show the first page before the rest of the file arrives,
+// and fetch the other pages as they are needed. The file begins with a
+// linearization parameter dictionary and the cross-reference section
+// for the objects of the first page, which follow it along with the
+// document catalog and a hint stream locating each page's objects;
+// each other page's objects follow in order, then the objects shared
+// by several pages, then the rest. See PDF 32000-1:2008, Annex F.
+//
+// As with WriteTo, the objects reachable from the trailer's Root and
+// Info are copied and the result is unencrypted. Attributes that pages
+// inherit from the page tree are copied into each page. The file is
+// assembled in memory before it is written.
+func (r *Reader) WriteLinearized(ctx context.Context, w io.Writer) (int64, error) {
+	// Start from a plain copy, whose objects are numbered from 1 with
+	// generation 0, stored uncompressed, and all in use.
+	var plain bytes.Buffer
+	if _, err := r.WriteTo(&plain); err != nil {
+		return 0, err
+	}
+	src, err := NewReader(bytes.NewReader(plain.Bytes()), int64(plain.Len()))
+	if err != nil {
+		return 0, err
+	}
+	l, err := src.linearize(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(l)
+	return int64(n), err
+}
+
+// A linearPage is a page of a document being linearized.
+type linearPage struct {
+	v       Value            // the page object
+	extra   map[string]Value // inherited attributes to copy into it
+	objs    []uint32         // the objects it uses, in the order reached, starting with the page object
+	content uint32           // its first content stream, or 0
+}
+
+// linearize returns the document read by r, which must have been
+// written by Reader.WriteTo, as a linearized file.
+func (r *Reader) linearize(ctx context.Context) ([]byte, error) {
+	rootPtr, ok := r.trailer["Root"].(objptr)
+	if !ok {
+		return nil, errors.New("pdf: trailer has no Root")
+	}
+	var pages []*linearPage
+	pageObj := make(map[uint32]*linearPage)
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		if p.V.ptr.id == 0 || !isIndirect(p.V) {
+			return fmt.Errorf("pdf: page %d is not an indirect object", num)
+		}
+		lp := &linearPage{v: p.V, extra: make(map[string]Value)}
+		for _, key := range []string{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			own, err := p.V.Key(key)
+			if err != nil {
+				return err
+			}
+			if !own.IsNull() {
+				continue
+			}
+			v, err := p.findInherited(key)
+			if err != nil {
+				return err
+			}
+			if !v.IsNull() {
+				lp.extra[key] = v
+			}
+		}
+		pages = append(pages, lp)
+		pageObj[p.V.ptr.id] = lp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("pdf: cannot linearize a document without pages")
+	}
+
+	// Find the objects each page uses: those reachable from it without
+	// passing through the page tree, the catalog, or another page.
+	table, err := r.xrefTable(allObjects)
+	if err != nil {
+		return nil, err
+	}
+	size := len(table)
+	stop := make([]bool, size)
+	stop[rootPtr.id] = true
+	for id := 1; id < size; id++ {
+		v, err := r.resolve(objptr{}, objptr{uint32(id), 0})
+		if err != nil {
+			return nil, err
+		}
+		if v.Kind() == Dict && isPageTreeNode(v) {
+			stop[id] = true
+		}
+	}
+	for i, lp := range pages {
+		if stop[lp.v.ptr.id] {
+			return nil, fmt.Errorf("malformed PDF: page %d is the catalog or a page tree node", i+1)
+		}
+	}
+	users := make([]int, size) // the number of pages using each object
+	for _, lp := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		seen := make(map[uint32]bool)
+		var visit func(id uint32) error
+		visit = func(id uint32) error {
+			if int(id) >= size || seen[id] || stop[id] || pageObj[id] != nil && id != lp.v.ptr.id {
+				return nil
+			}
+			seen[id] = true
+			lp.objs = append(lp.objs, id)
+			users[id]++
+			v, err := r.resolve(objptr{}, objptr{id, 0})
+			if err != nil {
+				return err
+			}
+			x := v.data
+			if id == lp.v.ptr.id {
+				d := make(dict)
+				for k, e := range v.data.(dict) {
+					if k != "Parent" {
+						d[k] = e
+					}
+				}
+				for k, e := range lp.extra {
+					d[name(k)] = e
+				}
+				x = d
+			}
+			var err1 error
+			objectRefs(x, func(ptr objptr) {
+				if err1 == nil {
+					err1 = visit(ptr.id)
+				}
+			})
+			return err1
+		}
+		if err := visit(lp.v.ptr.id); err != nil {
+			return nil, err
+		}
+		contents, err := lp.v.Key("Contents")
+		if err != nil {
+			return nil, err
+		}
+		if contents.Kind() == Array {
+			contents, err = contents.Index(0)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if contents.Kind() == Stream {
+			lp.content = contents.ptr.id
+		}
+	}
+
+	// Order the objects by the part of the file they belong in:
+	// the catalog; the first page's; each other page's own; those
+	// shared by other pages; and the rest.
+	placed := make([]bool, size)
+	var own, shared, rest []uint32
+	placed[rootPtr.id] = true
+	var firstPage []uint32
+	for _, id := range pages[0].objs {
+		firstPage = append(firstPage, id)
+		placed[id] = true
+	}
+	ownStart := make([]int, len(pages)) // index in own of each page's objects
+	for i, lp := range pages[1:] {
+		ownStart[i+1] = len(own)
+		for _, id := range lp.objs {
+			if users[id] == 1 {
+				own = append(own, id)
+				placed[id] = true
+			}
+		}
+	}
+	for _, lp := range pages[1:] {
+		for _, id := range lp.objs {
+			if !placed[id] {
+				shared = append(shared, id)
+				placed[id] = true
+			}
+		}
+	}
+	for id := 1; id < size; id++ {
+		if !placed[id] {
+			rest = append(rest, uint32(id))
+		}
+	}
+
+	// Number the objects: those after the first page from 1, then the
+	// linearization dictionary, catalog, hint stream, and first page's.
+	renum := make(map[copyKey]uint32)
+	next := uint32(1)
+	for _, part := range [][]uint32{own, shared, rest} {
+		for _, id := range part {
+			renum[copyKey{r, objptr{id, 0}}] = next
+			next++
+		}
+	}
+	mainSize := next
+	linID, catalogID, hintID := next, next+1, next+2
+	renum[copyKey{r, rootPtr}] = catalogID
+	next += 3
+	for _, id := range firstPage {
+		renum[copyKey{r, objptr{id, 0}}] = next
+		next++
+	}
+	total := next
+
+	// Format each object with its new number.
+	var out bytes.Buffer
+	fw := &Writer{w: &out, offsets: make([]int64, total), copied: renum}
+	objBytes := make(map[uint32][]byte)
+	format := func(id uint32, x object) error {
+		out.Reset()
+		if err := fw.writeIndirect(renum[copyKey{r, objptr{id, 0}}], r, x); err != nil {
+			return err
+		}
+		if len(fw.pending) > 0 {
+			return errors.New("pdf: linearizing: object not numbered")
+		}
+		objBytes[id] = append([]byte(nil), out.Bytes()...)
+		return nil
+	}
+	for id := 1; id < size; id++ {
+		v, err := r.resolve(objptr{}, objptr{uint32(id), 0})
+		if err != nil {
+			return nil, err
+		}
+		x := v.data
+		if lp := pageObj[uint32(id)]; lp != nil && len(lp.extra) > 0 {
+			d := make(dict)
+			for k, e := range v.data.(dict) {
+				d[k] = e
+			}
+			for k, e := range lp.extra {
+				d[name(k)] = e
+			}
+			x = d
+		}
+		if err := format(uint32(id), x); err != nil {
+			return nil, err
+		}
+	}
+	// The parts before the hint stream have reserved sizes,
+	// so that the offsets can be filled in later.
+	header := "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"
+	const maxOffset = 9999999999
+	linDict := func(l, hint, hintLen, end, mainXref int64) string {
+		return fmt.Sprintf("<</Linearized 1/L %d/H [%d %d]/O %d/E %d/N %d/T %d>>",
+			l, hint, hintLen, renum[copyKey{r, pages[0].v.ptr}], end, len(pages), mainXref)
+	}
+	linReserved := len(linDict(maxOffset, maxOffset, maxOffset, maxOffset, maxOffset))
+	var trailer bytes.Buffer
+	firstTrailer := func(prev int64) ([]byte, error) {
+		d := dict{"Size": int64(total), "Prev": prev}
+		for _, k := range []name{"Root", "Info", "ID"} {
+			if x, ok := r.trailer[k]; ok {
+				d[k] = x
+			}
+		}
+		trailer.Reset()
+		trailer.WriteString("trailer\n")
+		err := fw.format(&trailer, r, d)
+		return trailer.Bytes(), err
+	}
+	tr, err := firstTrailer(maxOffset)
+	if err != nil {
+		return nil, err
+	}
+	trailerReserved := len(tr)
+	firstXref := fmt.Sprintf("xref\n%d %d\n", linID, total-linID)
+	linObj := fmt.Sprintf("%d 0 obj\n", linID)
+	hintPos := int64(len(header)+len(linObj)+linReserved+len("\nendobj\n")+len(firstXref)) +
+		20*int64(total-linID) + int64(trailerReserved+len("\nstartxref\n0\n%%EOF\n")) +
+		int64(len(objBytes[rootPtr.id]))
+
+	// The hint tables give offsets as if the hint stream were absent.
+	offset := make(map[uint32]int64)
+	pos := hintPos
+	for _, part := range [][]uint32{firstPage, own, shared, rest} {
+		for _, id := range part {
+			offset[id] = pos
+			pos += int64(len(objBytes[id]))
+		}
+	}
+	hints, sharedPos := linearHints(pages, firstPage, own, ownStart, shared, users, offset, objBytes, renum[copyKey{r, objptr{shared0(shared), 0}}])
+	hintStrm := deflateStream(map[string]Value{"S": NewInt(int64(sharedPos))}, hints)
+	out.Reset()
+	if err := fw.writeIndirect(hintID, nil, hintStrm); err != nil {
+		return nil, err
+	}
+	hintBytes := append([]byte(nil), out.Bytes()...)
+	hintLen := int64(len(hintBytes))
+
+	// Lay out the file.
+	var file bytes.Buffer
+	xrefOffsets := make(map[uint32]int64) // by new object number
+	file.WriteString(header)
+	xrefOffsets[linID] = int64(file.Len())
+	file.WriteString(linObj)
+	linPos := file.Len()
+	file.WriteString(strings.Repeat(" ", linReserved))
+	file.WriteString("\nendobj\n")
+	firstXrefPos := file.Len()
+	file.WriteString(firstXref)
+	entriesPos := file.Len()
+	file.WriteString(strings.Repeat(" ", 20*int(total-linID)))
+	trailerPos := file.Len()
+	file.WriteString(strings.Repeat(" ", trailerReserved))
+	file.WriteString("\nstartxref\n0\n%%EOF\n")
+	xrefOffsets[catalogID] = int64(file.Len())
+	file.Write(objBytes[rootPtr.id])
+	xrefOffsets[hintID] = int64(file.Len())
+	file.Write(hintBytes)
+	for _, part := range [][]uint32{firstPage, own, shared, rest} {
+		for _, id := range part {
+			xrefOffsets[renum[copyKey{r, objptr{id, 0}}]] = int64(file.Len())
+			file.Write(objBytes[id])
+		}
+	}
+	end := hintPos + hintLen
+	for _, id := range firstPage {
+		end += int64(len(objBytes[id]))
+	}
+	mainXrefPos := int64(file.Len())
+	fmt.Fprintf(&file, "xref\n0 %d", mainSize)
+	mainEntries := int64(file.Len())
+	file.WriteString("\n0000000000 65535 f \n")
+	for id := uint32(1); id < mainSize; id++ {
+		fmt.Fprintf(&file, "%010d 00000 n \n", xrefOffsets[id])
+	}
+	fmt.Fprintf(&file, "trailer\n<</Size %d>>\nstartxref\n%d\n%%%%EOF\n", mainSize, firstXrefPos)
+
+	// Fill in the reserved parts.
+	b := file.Bytes()
+	copy(b[linPos:], linDict(int64(len(b)), hintPos, hintLen, end, mainEntries))
+	var entries bytes.Buffer
+	for id := linID; id < total; id++ {
+		fmt.Fprintf(&entries, "%010d 00000 n \n", xrefOffsets[id])
+	}
+	copy(b[entriesPos:], entries.Bytes())
+	tr, err = firstTrailer(mainXrefPos)
+	if err != nil {
+		return nil, err
+	}
+	copy(b[trailerPos:], tr)
+	return b, nil
+}
+
+// objectRefs calls fn for each reference in the object x, in order,
+// taking the entries of dictionaries in the order of their keys.
+// The Length of a stream does not count.
+func objectRefs(x object, fn func(objptr)) {
+	switch x := x.(type) {
+	case objptr:
+		fn(x)
+	case dict:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			objectRefs(x[name(k)], fn)
+		}
+	case array:
+		for _, v := range x {
+			objectRefs(v, fn)
+		}
+	case stream:
+		hdr := make(dict, len(x.hdr))
+		for k, v := range x.hdr {
+			if k != "Length" {
+				hdr[k] = v
+			}
+		}
+		objectRefs(hdr, fn)
+	case Value:
+		if x.Kind() == Stream || isIndirect(x) {
+			fn(x.ptr)
+			return
+		}
+		objectRefs(x.data, fn)
+	}
+}
+
+// linearHints returns the data of the primary hint stream of a
+// linearized file, with the offset in it of the shared object hint
+// table. The hint tables are the page offset hint table, describing
+// the objects of each page, and the shared object hint table,
+// describing the objects of the first page and those shared by other
+// pages, each in a group of its own. See PDF 32000-1:2008, §F.4.
+//
+// The objects, given by their numbers in the file being linearized,
+// are those of the first page, those of each other page alone, with
+// the index in own of each page's, and those shared by other pages.
+// users counts the pages using each object, offset gives each one's
+// offset in the linearized file without the hint stream, and data
+// its formatted bytes. sharedID is the new number of the first of
+// the shared objects.
+func linearHints(pages []*linearPage, firstPage, own []uint32, ownStart []int, shared []uint32,
+	users []int, offset map[uint32]int64, data map[uint32][]byte, sharedID uint32) ([]byte, int) {
+	// The shared object table's groups: the first page's objects,
+	// then the shared objects.
+	group := make(map[uint32]int)
+	for i, id := range firstPage {
+		group[id] = i
+	}
+	for i, id := range shared {
+		group[id] = len(firstPage) + i
+	}
+
+	type pageHint struct {
+		nobj, length  int64
+		refs          []int // shared groups used
+		content, clen int64 // content stream offset in the page, and length
+	}
+	hints := make([]pageHint, len(pages))
+	for i, lp := range pages {
+		objs := firstPage
+		if i > 0 {
+			end := len(own)
+			if i+1 < len(pages) {
+				end = ownStart[i+1]
+			}
+			objs = own[ownStart[i]:end]
+			for _, id := range lp.objs {
+				if users[id] > 1 {
+					hints[i].refs = append(hints[i].refs, group[id])
+				}
+			}
+		}
+		h := &hints[i]
+		h.nobj = int64(len(objs))
+		for _, id := range objs {
+			h.length += int64(len(data[id]))
+			if id == lp.content {
+				h.content = offset[id] - offset[objs[0]]
+				h.clen = int64(len(data[id]))
+			}
+		}
+	}
+	least := func(f func(h pageHint) int64) (min, bits int) {
+		lo, hi := f(hints[0]), f(hints[0])
+		for _, h := range hints[1:] {
+			lo, hi = minInt64(lo, f(h)), maxInt64(hi, f(h))
+		}
+		return int(lo), bitLen(hi - lo)
+	}
+	minObjs, objBits := least(func(h pageHint) int64 { return h.nobj })
+	minLen, lenBits := least(func(h pageHint) int64 { return h.length })
+	minContent, contentBits := least(func(h pageHint) int64 { return h.content })
+	minCLen, clenBits := least(func(h pageHint) int64 { return h.clen })
+	maxRefs, maxGroup := int64(0), int64(0)
+	for _, h := range hints {
+		maxRefs = maxInt64(maxRefs, int64(len(h.refs)))
+		for _, g := range h.refs {
+			maxGroup = maxInt64(maxGroup, int64(g))
+		}
+	}
+	refBits, groupBits := bitLen(maxRefs), bitLen(maxGroup)
+
+	var w bitWriter
+	w.write(uint64(minObjs), 32)
+	w.write(uint64(offset[firstPage[0]]), 32)
+	w.write(uint64(objBits), 16)
+	w.write(uint64(minLen), 32)
+	w.write(uint64(lenBits), 16)
+	w.write(uint64(minContent), 32)
+	w.write(uint64(contentBits), 16)
+	w.write(uint64(minCLen), 32)
+	w.write(uint64(clenBits), 16)
+	w.write(uint64(refBits), 16)
+	w.write(uint64(groupBits), 16)
+	w.write(0, 16) // no fractional positions of shared references
+	w.write(1, 16)
+	// The entries give each item for every page in turn.
+	items := []struct {
+		bits int
+		f    func(h pageHint) []int64
+	}{
+		{objBits, func(h pageHint) []int64 { return []int64{h.nobj - int64(minObjs)} }},
+		{lenBits, func(h pageHint) []int64 { return []int64{h.length - int64(minLen)} }},
+		{refBits, func(h pageHint) []int64 { return []int64{int64(len(h.refs))} }},
+		{groupBits, func(h pageHint) []int64 {
+			var out []int64
+			for _, g := range h.refs {
+				out = append(out, int64(g))
+			}
+			return out
+		}},
+		{contentBits, func(h pageHint) []int64 { return []int64{h.content - int64(minContent)} }},
+		{clenBits, func(h pageHint) []int64 { return []int64{h.clen - int64(minCLen)} }},
+	}
+	for _, item := range items {
+		for _, h := range hints {
+			for _, x := range item.f(h) {
+				w.write(uint64(x), item.bits)
+			}
+		}
+		w.align()
+	}
+
+	sharedPos := len(w.buf)
+	groups := append(append([]uint32(nil), firstPage...), shared...)
+	minGroup, maxGroupLen := int64(len(data[groups[0]])), int64(0)
+	for _, id := range groups {
+		minGroup = minInt64(minGroup, int64(len(data[id])))
+		maxGroupLen = maxInt64(maxGroupLen, int64(len(data[id])))
+	}
+	groupLenBits := bitLen(maxGroupLen - minGroup)
+	if len(shared) > 0 {
+		w.write(uint64(sharedID), 32)
+		w.write(uint64(offset[shared[0]]), 32)
+	} else {
+		w.write(0, 32)
+		w.write(0, 32)
+	}
+	w.write(uint64(len(firstPage)), 32)
+	w.write(uint64(len(groups)), 32)
+	w.write(0, 16) // each group is one object
+	w.write(uint64(minGroup), 32)
+	w.write(uint64(groupLenBits), 16)
+	for _, id := range groups {
+		w.write(uint64(int64(len(data[id]))-minGroup), groupLenBits)
+	}
+	w.align()
+	for range groups {
+		w.write(0, 1) // no signatures
+	}
+	w.align()
+	return w.buf, sharedPos
+}
+
+// shared0 returns the first of the shared objects, or 0 if there are none.
+func shared0(shared []uint32) uint32 {
+	if len(shared) == 0 {
+		return 0
+	}
+	return shared[0]
+}
+
+// bitLen returns the number of bits needed to represent x, which is
+// not negative.
+func bitLen(x int64) int {
+	n := 0
+	for ; x > 0; x >>= 1 {
+		n++
+	}
+	return n
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// A bitWriter packs values into bits, most significant bit first.
+type bitWriter struct {
+	buf  []byte
+	nbit uint // bits used in the last byte, or 0 if it is full
+}
+
+// write appends the low n bits of x.
+func (w *bitWriter) write(x uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.nbit == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if x>>uint(i)&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> w.nbit
+		}
+		w.nbit = (w.nbit + 1) % 8
+	}
+}
+
+// align pads the bits written to a byte boundary.
+func (w *bitWriter) align() {
+	w.nbit = 0
+}
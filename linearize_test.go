@@ -0,0 +1,115 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// linearizedPDF returns the document built from objs, linearized.
+func linearizedPDF(t testing.TB, objs ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := openPDF(t, buildPDF(objs...), nil).WriteLinearized(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLinearized(t *testing.T) {
+	ctx := context.Background()
+	file := linearizedPDF(t, pageTree(5)...)
+	r := openPDF(t, file, nil)
+	if !r.Linearized() {
+		t.Fatal("Linearized = false, want true")
+	}
+	if n, err := r.NumPage(); n != 5 || err != nil {
+		t.Fatalf("NumPage = %d, %v, want 5, nil", n, err)
+	}
+	// The first-page section ends with its own end-of-file marker.
+	if n := bytes.Count(file, []byte("%%EOF")); n != 2 {
+		t.Errorf("file has %d %%%%EOF markers, want 2", n)
+	}
+	// The objects after the first page are found in the rest of the table.
+	for num := 5; num >= 1; num-- {
+		p, err := r.Page(ctx, num)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := p.GetPlainText(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("page %d", num); !strings.Contains(text, want) {
+			t.Errorf("page %d: text %q does not contain %q", num, text, want)
+		}
+	}
+
+	// An update numbers new objects after all of the file's objects.
+	r = openPDF(t, file, nil)
+	size, err := r.Trailer().Key("Size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := r.Update(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := w.NewRef().data.(writerRef).id; int64(id) < size.Int64() {
+		t.Errorf("new object in update is number %d, want at least %d", id, size.Int64())
+	}
+}
+
+// TestLinearizedSize opens a small linearized file whose trailer
+// claims 400000000 objects, which must be refused rather than used
+// to size the cross-reference table.
+func TestLinearizedSize(t *testing.T) {
+	build := func(size int) []byte {
+		// Write the file until the length it records is its own.
+		var buf bytes.Buffer
+		for l := -1; l != buf.Len(); {
+			l = buf.Len()
+			buf.Reset()
+			buf.WriteString("%PDF-1.7\n")
+			fmt.Fprintf(&buf, "1 0 obj\n<< /Linearized 1 /L %010d /O 2 /N 1 >>\nendobj\n", l)
+			xref := buf.Len()
+			fmt.Fprintf(&buf, "xref\n1 1\n%010d 00000 n \n", 9)
+			fmt.Fprintf(&buf, "trailer\n<< /Size %d /Prev %d /Root 3 0 R >>\nstartxref\n0\n%%%%EOF\n", size, xref)
+		}
+		return buf.Bytes()
+	}
+
+	// The file is read as linearized when its Size is reasonable.
+	r := openPDF(t, build(3), nil)
+	if !r.Linearized() {
+		t.Fatal("Linearized = false, want true")
+	}
+
+	data := build(400000000)
+	_, err := NewReaderOptions(bytes.NewReader(data), int64(len(data)), nil)
+	var limit *LimitError
+	if !errors.As(err, &limit) || limit.Limit != "MaxObjects" || limit.Value != 400000000 {
+		t.Errorf("NewReader: got error %v, want MaxObjects LimitError for 400000000 objects", err)
+	}
+}
+
+// TestLinearizedCatalogPage linearizes a document whose page tree
+// lists the catalog as its page, which has no objects of its own.
+func TestLinearizedCatalogPage(t *testing.T) {
+	r := openPDF(t, buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [1 0 R] /Count 1 >>",
+	), nil)
+	if _, err := r.WriteLinearized(context.Background(), io.Discard); err == nil {
+		t.Error("WriteLinearized succeeded, want error for a page that is the catalog")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// The LZW variant used by PDF (and TIFF) differs from the GIF variant
+// implemented by compress/lzw: with EarlyChange, the default, the code
+// width grows one code earlier than the table requires.
+// See PDF 32000-1:2008, §7.4.4.
+
+const (
+	lzwClear = 256
+	lzwEOD   = 257
+	lzwFirst = 258 // first code added to the table
+	lzwMax   = 4096
+)
+
+// An lzwReader decodes PDF LZW data.
+type lzwReader struct {
+	r     io.ByteReader
+	early int // 1 if EarlyChange, 0 otherwise
+
+	bits  uint32 // pending input bits, right-aligned
+	nbits uint
+	width uint // current code width, 9 to 12
+
+	// The string for code c is the string for prefix[c]
+	// followed by suffix[c]; it is length[c] bytes long.
+	prefix [lzwMax]uint16
+	suffix [lzwMax]byte
+	length [lzwMax]uint16
+	next   int // next code to add to the table
+	prev   int // previous code, or -1 after a clear
+
+	pend []byte // decoded output not yet returned
+	buf  [lzwMax]byte
+	err  error
+}
+
+// newLZWReader returns a reader decoding the LZW data read from rd.
+// earlyChange is the filter's EarlyChange parameter.
+func newLZWReader(rd io.Reader, earlyChange bool) *lzwReader {
+	z := &lzwReader{r: bufio.NewReader(rd)}
+	if earlyChange {
+		z.early = 1
+	}
+	for c := 0; c < 256; c++ {
+		z.suffix[c] = byte(c)
+		z.length[c] = 1
+	}
+	z.reset()
+	return z
+}
+
+func (z *lzwReader) reset() {
+	z.width = 9
+	z.next = lzwFirst
+	z.prev = -1
+}
+
+// readCode returns the next code from the input.
+func (z *lzwReader) readCode() (int, error) {
+	for z.nbits < z.width {
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		z.bits = z.bits<<8 | uint32(b)
+		z.nbits += 8
+	}
+	z.nbits -= z.width
+	return int(z.bits>>z.nbits) & (1<<z.width - 1), nil
+}
+
+// expand returns the string for code, which must be in the table.
+func (z *lzwReader) expand(code int) []byte {
+	n := int(z.length[code])
+	out := z.buf[:n]
+	for i := n - 1; i >= 0; i-- {
+		out[i] = z.suffix[code]
+		code = int(z.prefix[code])
+	}
+	return out
+}
+
+func (z *lzwReader) Read(p []byte) (int, error) {
+	for len(z.pend) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.decode()
+	}
+	n := copy(p, z.pend)
+	z.pend = z.pend[n:]
+	return n, nil
+}
+
+// decode decodes one code, setting z.pend to its string.
+func (z *lzwReader) decode() error {
+	code, err := z.readCode()
+	if err == io.EOF {
+		// Missing EOD: end the data anyway.
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case code == lzwClear:
+		z.reset()
+		return nil
+	case code == lzwEOD:
+		return io.EOF
+	case z.prev < 0:
+		if code >= 256 {
+			return errors.New("LZWDecode: invalid code after clear")
+		}
+	case code < z.next:
+		z.add(z.expand(code)[0])
+	case code == z.next:
+		// The code being defined: the previous string plus its own first byte.
+		z.add(z.expand(z.prev)[0])
+	default:
+		return errors.New("LZWDecode: invalid code")
+	}
+	z.pend = z.expand(code)
+	z.prev = code
+	return nil
+}
+
+// add adds the previous string followed by c to the table.
+func (z *lzwReader) add(c byte) {
+	if z.next >= lzwMax {
+		return
+	}
+	z.prefix[z.next] = uint16(z.prev)
+	z.suffix[z.next] = c
+	z.length[z.next] = z.length[z.prev] + 1
+	z.next++
+	if z.next+z.early >= 1<<z.width && z.width < 12 {
+		z.width++
+	}
+}
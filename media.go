@@ -0,0 +1,208 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "context"
+
+// A Media is a multimedia item (video, audio, or a Flash/3D asset)
+// referenced from an annotation on a page.
+type Media struct {
+	Page int    // page number of the annotation, starting at 1
+	Type string // annotation subtype: RichMedia, Screen, Movie, or Sound
+	Name string // file name of the media, if known
+	MIME string // MIME type of the media, if known
+	Data []byte // decoded media data; nil if the media is stored outside the PDF
+}
+
+// Media returns the multimedia items referenced by RichMedia, Screen,
+// Movie, and Sound annotations in the document.
+// Media stored in external files are reported with a nil Data.
+func (r *Reader) Media(ctx context.Context) ([]Media, error) {
+	var out []Media
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		annots, err := p.V.Key("Annots")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < annots.Len(); i++ {
+			annot, err := annots.Index(i)
+			if err != nil {
+				return err
+			}
+			subtype, err := annot.Key("Subtype")
+			if err != nil {
+				return err
+			}
+			var media []Media
+			switch subtype.Name() {
+			case "RichMedia":
+				media, err = richMedia(annot)
+			case "Screen":
+				media, err = screenMedia(annot)
+			case "Movie":
+				media, err = movieMedia(annot)
+			case "Sound":
+				media, err = soundMedia(annot)
+			}
+			if err != nil {
+				return err
+			}
+			for _, m := range media {
+				m.Page = num
+				m.Type = subtype.Name()
+				out = append(out, m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// richMedia returns the assets of a RichMedia annotation,
+// which are file specifications in the RichMediaContent Assets name tree.
+func richMedia(annot Value) ([]Media, error) {
+	content, err := annot.Key("RichMediaContent")
+	if err != nil {
+		return nil, err
+	}
+	assets, err := content.Key("Assets")
+	if err != nil {
+		return nil, err
+	}
+	var out []Media
+	err = walkNameTree(assets, func(key string, spec Value) error {
+		m, err := fileSpecMedia(spec)
+		if err != nil {
+			return err
+		}
+		if m.Name == "" {
+			m.Name = key
+		}
+		out = append(out, m)
+		return nil
+	})
+	return out, err
+}
+
+// screenMedia returns the media clip played by a Screen annotation's
+// rendition action. See PDF 32000-1:2008, §13.2.
+func screenMedia(annot Value) ([]Media, error) {
+	action, err := annot.Key("A")
+	if err != nil {
+		return nil, err
+	}
+	rendition, err := action.Key("R")
+	if err != nil {
+		return nil, err
+	}
+	clip, err := rendition.Key("C")
+	if err != nil {
+		return nil, err
+	}
+	data, err := clip.Key("D")
+	if err != nil {
+		return nil, err
+	}
+	if data.IsNull() {
+		return nil, nil
+	}
+	var m Media
+	if data.Kind() == Stream {
+		m.Data, err = streamData(data)
+	} else {
+		m, err = fileSpecMedia(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ct, err := clip.Key("CT")
+	if err != nil {
+		return nil, err
+	}
+	if t := ct.Text(); t != "" {
+		m.MIME = t
+	}
+	return []Media{m}, nil
+}
+
+// movieMedia returns the movie file of a Movie annotation.
+func movieMedia(annot Value) ([]Media, error) {
+	movie, err := annot.Key("Movie")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := movie.Key("F")
+	if err != nil {
+		return nil, err
+	}
+	if spec.IsNull() {
+		return nil, nil
+	}
+	m, err := fileSpecMedia(spec)
+	if err != nil {
+		return nil, err
+	}
+	return []Media{m}, nil
+}
+
+// soundMedia returns the sound of a Sound annotation.
+// The sound stream holds raw samples described by its R, C, B, and E entries
+// unless it names an external file in F.
+func soundMedia(annot Value) ([]Media, error) {
+	sound, err := annot.Key("Sound")
+	if err != nil {
+		return nil, err
+	}
+	if sound.Kind() != Stream {
+		return nil, nil
+	}
+	spec, err := sound.Key("F")
+	if err != nil {
+		return nil, err
+	}
+	if !spec.IsNull() {
+		m, err := fileSpecMedia(spec)
+		if err != nil {
+			return nil, err
+		}
+		return []Media{m}, nil
+	}
+	data, err := streamData(sound)
+	if err != nil {
+		return nil, err
+	}
+	return []Media{{Data: data}}, nil
+}
+
+// fileSpecMedia returns the name, MIME type, and embedded data
+// of the file specification spec.
+func fileSpecMedia(spec Value) (Media, error) {
+	var m Media
+	var err error
+	m.Name, err = fileSpecName(spec)
+	if err != nil {
+		return Media{}, err
+	}
+	ef, err := embeddedFile(spec)
+	if err != nil {
+		return Media{}, err
+	}
+	if ef.Kind() != Stream {
+		return m, nil
+	}
+	subtype, err := ef.Key("Subtype")
+	if err != nil {
+		return Media{}, err
+	}
+	m.MIME = subtype.Name()
+	m.Data, err = streamData(ef)
+	if err != nil {
+		return Media{}, err
+	}
+	return m, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+// Metadata returns the decoded XMP metadata stream of the document
+// (the Metadata entry of the document catalog).
+// If the document has no metadata stream, or it cannot be decoded,
+// Metadata returns ok == false; decoding failures are recorded as Problems.
+func (r *Reader) Metadata() (data []byte, ok bool) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return nil, false
+	}
+	return metadataStream(root)
+}
+
+// Metadata returns the decoded XMP metadata stream attached to the page
+// itself. This is distinct from the document metadata returned by
+// Reader.Metadata and is not inherited from the page tree.
+// Most pages have no metadata of their own, in which case
+// Metadata returns ok == false.
+func (p Page) Metadata() (data []byte, ok bool) {
+	return metadataStream(p.V)
+}
+
+// metadataStream returns the decoded Metadata stream of the dictionary v.
+func metadataStream(v Value) ([]byte, bool) {
+	m, err := v.Key("Metadata")
+	if err != nil || m.Kind() != Stream {
+		return nil, false
+	}
+	data, err := streamData(m)
+	if err != nil {
+		x := m.data.(stream)
+		m.r.problem(x.offset, "metadata stream %v: %v", x.ptr, err)
+		return nil, false
+	}
+	return data, true
+}
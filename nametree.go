@@ -0,0 +1,68 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+// walkNameTree calls fn for each entry in the name tree rooted at v,
+// in the order the entries appear in the tree.
+// See PDF 32000-1:2008, §7.9.6.
+func walkNameTree(v Value, fn func(key string, val Value) error) error {
+	return walkTree(v, "Names", make(map[objptr]bool), func(key, val Value) error {
+		return fn(key.Text(), val)
+	})
+}
+
+// walkNumberTree calls fn for each entry in the number tree rooted at v,
+// in the order the entries appear in the tree.
+// See PDF 32000-1:2008, §7.9.7.
+func walkNumberTree(v Value, fn func(key int, val Value) error) error {
+	return walkTree(v, "Nums", make(map[objptr]bool), func(key, val Value) error {
+		return fn(int(key.Int64()), val)
+	})
+}
+
+// walkTree walks a name or number tree, whose leaves hold
+// key-value pairs in the array named by leaf.
+func walkTree(v Value, leaf string, seen map[objptr]bool, fn func(key, val Value) error) error {
+	if v.Kind() != Dict {
+		return nil
+	}
+	if seen[v.ptr] {
+		return nil
+	}
+	seen[v.ptr] = true
+
+	pairs, err := v.Key(leaf)
+	if err != nil {
+		return err
+	}
+	for i := 0; i+1 < pairs.Len(); i += 2 {
+		key, err := pairs.Index(i)
+		if err != nil {
+			return err
+		}
+		val, err := pairs.Index(i + 1)
+		if err != nil {
+			return err
+		}
+		if err := fn(key, val); err != nil {
+			return err
+		}
+	}
+
+	kids, err := v.Key("Kids")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < kids.Len(); i++ {
+		kid, err := kids.Index(i)
+		if err != nil {
+			return err
+		}
+		if err := walkTree(kid, leaf, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestObjStmPageTree reads a document whose catalog, page tree nodes,
+// pages, and resources are all stored in an object stream.
+func TestObjStmPageTree(t *testing.T) {
+	ctx := context.Background()
+
+	// The root has an intermediate node over the first two pages,
+	// and the pages share an indirect resource dictionary and font.
+	objs := pageTree(3)
+	objs[1] = "<< /Type /Pages /Count 3 /Kids [9 0 R 7 0 R] >>"
+	for i := 0; i < 3; i++ {
+		parent := 9
+		if i == 2 {
+			parent = 2
+		}
+		objs[2+2*i] = fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources 10 0 R >>", parent, 4+2*i)
+	}
+	objs = append(objs,
+		"<< /Type /Pages /Parent 2 0 R /Count 2 /Kids [3 0 R 5 0 R] >>",
+		"<< /Font << /F1 11 0 R >> >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	r := openPDF(t, buildObjStmPDF(objs...), nil)
+
+	// The objects are found through the object stream.
+	table, err := r.xrefTable(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint32{1, 2, 3, 9, 10, 11} {
+		if !table[id].inStream {
+			t.Errorf("object %d is not in the object stream", id)
+		}
+	}
+
+	if n, err := r.NumPage(); n != 3 || err != nil {
+		t.Fatalf("NumPage = %d, %v, want 3, nil", n, err)
+	}
+	num := 0
+	for p, err := range r.Pages(ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		num++
+		if want := (objptr{uint32(1 + 2*num), 0}); p.V.ptr != want {
+			t.Errorf("page %d is object %v, want %v", num, p.V.ptr, want)
+		}
+		font, err := p.Font("F1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		base, err := font.BaseFont()
+		if err != nil || font.V.ptr != (objptr{11, 0}) || base != "Helvetica" {
+			t.Errorf("page %d: font F1 is object %v, %q, %v, want 11 0 R, Helvetica", num, font.V.ptr, base, err)
+		}
+		text, err := p.GetPlainText(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("page %d", num); !strings.Contains(text, want) {
+			t.Errorf("page %d: text %q does not contain %q", num, text, want)
+		}
+		c, err := p.Content(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Text) == 0 || c.Text[0].Font != "Helvetica" {
+			t.Errorf("page %d: Content text %v, want text in Helvetica", num, c.Text)
+		}
+		q, err := r.Page(ctx, num)
+		if err != nil || q.V.ptr != p.V.ptr {
+			t.Errorf("Page(%d) = object %v, %v, want %v", num, q.V.ptr, err, p.V.ptr)
+		}
+	}
+	if num != 3 {
+		t.Errorf("Pages yielded %d pages, want 3", num)
+	}
+	if probs := r.Problems(); len(probs) != 0 {
+		t.Errorf("Problems: %v", probs)
+	}
+}
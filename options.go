@@ -0,0 +1,74 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+// Options control how a file is opened and read.
+// The zero Options read the file leniently, using every repair.
+type Options struct {
+	// Strict makes the Reader fail on violations of the PDF
+	// specification that it would otherwise work around, as it does
+	// for files written by common producers with bugs. It sets the
+	// Reader's Strict field.
+	Strict bool
+
+	// Repairs are the repairs the Reader may make when it is not
+	// strict. A repair made is recorded as a Problem; a defect whose
+	// repair is not allowed is an error. Zero means AllRepairs, not
+	// none; to allow no repairs, use RepairNone, or set Strict to fail
+	// on other violations too.
+	Repairs Repair
+
+	// Limits, if not nil, are the Reader's Limits, in place of
+	// DefaultLimits. They apply while the file is opened, as well as
+	// after.
+	Limits *Limits
+
+	// Password, if not nil, is called for passwords to try on an
+	// encrypted file, as by NewReaderEncrypted.
+	Password func() string
+}
+
+// A Repair is a set of heuristics for recovering from defects in a file.
+type Repair uint
+
+const (
+	// RepairHeader allows junk before the %PDF header, which is ignored,
+	// and a header without a valid version.
+	RepairHeader Repair = 1 << iota
+
+	// RepairXref allows rebuilding a cross-reference table that cannot
+	// be read by scanning the file for objects.
+	RepairXref
+
+	// RepairObjectOffsets allows finding, by scanning the file, an object
+	// that is not at the offset its cross-reference entry gives.
+	RepairObjectOffsets
+
+	// RepairDuplicateKeys allows dictionaries with a repeated key,
+	// keeping the first value.
+	RepairDuplicateKeys
+
+	// RepairTruncatedStreams allows a stream whose Length extends past
+	// the end of the file, using the data that is there.
+	RepairTruncatedStreams
+
+	// RepairTokens allows malformed tokens: hexadecimal strings with
+	// characters other than digits and white space, which are ignored,
+	// and names with a # not followed by two hexadecimal digits, which
+	// is kept as a character of the name.
+	RepairTokens
+
+	// RepairNone allows no repair. Unlike zero, which Options.Repairs
+	// takes to mean AllRepairs, it is a set containing none of the others.
+	RepairNone
+
+	// AllRepairs allows every repair.
+	AllRepairs = RepairHeader | RepairXref | RepairObjectOffsets | RepairDuplicateKeys | RepairTruncatedStreams | RepairTokens
+)
+
+// repair reports whether the Reader may make the given repair.
+func (r *Reader) repair(h Repair) bool {
+	return !r.Strict && (r.repairs == 0 || r.repairs&h != 0)
+}
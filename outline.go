@@ -0,0 +1,167 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WriteOutline writes the outline whose top-level entries are the
+// children of root, as returned by Reader.Outline, and returns a
+// reference to it for the Outlines entry of the document catalog.
+// pages holds references to the pages of the document, in order,
+// for the page numbers of the entries' destinations.
+//
+// Each entry goes to its Dest if Dest.Page is set and its Action is
+// unset or a GoTo action, and performs its Action otherwise. Only the
+// Title, Dest, Action, Open, Color, Style, and Child of the entries
+// are used; the Counts are computed from the tree.
+func (w *Writer) WriteOutline(pages []Value, root Outline) (Value, error) {
+	ref := w.NewRef()
+	entries := map[string]Value{"Type": NewName("Outlines")}
+	if len(root.Child) > 0 {
+		first, last, n, err := w.writeOutlineItems(ref, pages, root.Child)
+		if err != nil {
+			return Value{}, err
+		}
+		entries["First"] = first
+		entries["Last"] = last
+		entries["Count"] = NewInt(int64(n))
+	}
+	if err := w.Write(ref, NewDict(entries)); err != nil {
+		return Value{}, err
+	}
+	return ref, nil
+}
+
+// writeOutlineItems writes the outline entries items, children of
+// parent, and returns references to the first and last and the number
+// of entries shown when parent is open: the items and the visible
+// descendants of those that are open.
+func (w *Writer) writeOutlineItems(parent Value, pages []Value, items []Outline) (first, last Value, visible int, err error) {
+	refs := make([]Value, len(items))
+	for i := range refs {
+		refs[i] = w.NewRef()
+	}
+	for i, it := range items {
+		entries := map[string]Value{
+			"Title":  NewText(it.Title),
+			"Parent": parent,
+		}
+		if i > 0 {
+			entries["Prev"] = refs[i-1]
+		}
+		if i+1 < len(items) {
+			entries["Next"] = refs[i+1]
+		}
+		visible++
+		if len(it.Child) > 0 {
+			first, last, n, err := w.writeOutlineItems(refs[i], pages, it.Child)
+			if err != nil {
+				return Value{}, Value{}, 0, err
+			}
+			entries["First"] = first
+			entries["Last"] = last
+			// A negative count marks a closed entry.
+			if it.Open {
+				entries["Count"] = NewInt(int64(n))
+				visible += n
+			} else {
+				entries["Count"] = NewInt(int64(-n))
+			}
+		}
+
+		typ := ""
+		if !it.Action.V.IsNull() {
+			if typ, err = it.Action.Type(); err != nil {
+				return Value{}, Value{}, 0, err
+			}
+		}
+		switch {
+		case it.Dest.Page != 0 && (typ == "" || typ == "GoTo"):
+			d, err := destArray(pages, it.Dest)
+			if err != nil {
+				return Value{}, Value{}, 0, fmt.Errorf("pdf: outline entry %q: %v", it.Title, err)
+			}
+			entries["Dest"] = d
+		case typ != "":
+			entries["A"] = it.Action.V
+		}
+		if it.Color != [3]float64{} {
+			entries["C"] = NewArray(NewReal(it.Color[0]), NewReal(it.Color[1]), NewReal(it.Color[2]))
+		}
+		if it.Style != 0 {
+			entries["F"] = NewInt(int64(it.Style))
+		}
+		if err := w.Write(refs[i], NewDict(entries)); err != nil {
+			return Value{}, Value{}, 0, err
+		}
+	}
+	return refs[0], refs[len(refs)-1], visible, nil
+}
+
+// destArray returns the explicit destination array for d,
+// whose page is one of pages.
+func destArray(pages []Value, d Destination) (Value, error) {
+	if d.Page < 1 || d.Page > len(pages) {
+		return Value{}, fmt.Errorf("page %d out of range [1, %d]", d.Page, len(pages))
+	}
+	// Zero coordinates are written as null, keeping the current view.
+	num := func(x float64) Value {
+		if x == 0 {
+			return Value{}
+		}
+		return NewReal(x)
+	}
+	page := pages[d.Page-1]
+	switch d.Fit {
+	case "", "XYZ":
+		return NewArray(page, NewName("XYZ"), num(d.Left), num(d.Top), num(d.Zoom)), nil
+	case "Fit", "FitB":
+		return NewArray(page, NewName(d.Fit)), nil
+	case "FitH", "FitBH":
+		return NewArray(page, NewName(d.Fit), num(d.Top)), nil
+	case "FitV", "FitBV":
+		return NewArray(page, NewName(d.Fit), num(d.Left)), nil
+	case "FitR":
+		return NewArray(page, NewName(d.Fit), NewReal(d.Left), NewReal(d.Bottom), NewReal(d.Right), NewReal(d.Top)), nil
+	}
+	return Value{}, fmt.Errorf("unknown destination type %q", d.Fit)
+}
+
+// SetOutline replaces the outline of the document being updated by w
+// (see Reader.Update) with root, as described for WriteOutline, and
+// writes the updated document catalog. To edit the outline, modify
+// the Outline returned by Reader.Outline and pass it to SetOutline.
+func (w *Writer) SetOutline(ctx context.Context, root Outline) error {
+	r := w.update
+	if r == nil {
+		return errors.New("pdf: SetOutline requires an incremental update")
+	}
+	var pages []Value
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		pages = append(pages, p.V)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ref, err := w.WriteOutline(pages, root)
+	if err != nil {
+		return err
+	}
+	catalog, err := r.Trailer().Key("Root")
+	if err != nil {
+		return err
+	}
+	entries, err := dictEntries(catalog)
+	if err != nil {
+		return err
+	}
+	entries["Outlines"] = ref
+	return w.Write(catalog, NewDict(entries))
+}
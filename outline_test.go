@@ -0,0 +1,91 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// An outline with open and closed entries: A is open, showing A1,
+// which is closed, hiding A1a; B is closed, hiding B1.
+var mixedOutline = []string{
+	"<< /Type /Outlines /First 6 0 R /Last 9 0 R /Count 3 >>",
+	"<< /Title (A) /Parent 5 0 R /Next 9 0 R /First 7 0 R /Last 7 0 R /Count 1 >>",
+	"<< /Title (A1) /Parent 6 0 R /First 8 0 R /Last 8 0 R /Count -1 >>",
+	"<< /Title (A1a) /Parent 7 0 R >>",
+	"<< /Title (B) /Parent 5 0 R /Prev 6 0 R /First 10 0 R /Last 10 0 R /Count -1 >>",
+	"<< /Title (B1) /Parent 9 0 R >>",
+}
+
+// outlineSummary returns the titles, open states, and counts of the
+// entries of o, in order.
+func outlineSummary(o Outline) []string {
+	var out []string
+	var walk func(o Outline)
+	walk = func(o Outline) {
+		for _, c := range o.Child {
+			state := "closed"
+			if c.Open {
+				state = "open"
+			}
+			out = append(out, c.Title+" "+state+" "+strings.Repeat("+", c.Count))
+			walk(c)
+		}
+	}
+	walk(o)
+	return out
+}
+
+func TestOutlineOpen(t *testing.T) {
+	objs := pageTree(1)
+	objs[0] = "<< /Type /Catalog /Pages 2 0 R /Outlines 5 0 R >>"
+	objs = append(objs, mixedOutline...)
+	r := openPDF(t, buildPDF(objs...), nil)
+	o, err := r.Outline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"A open +",
+		"A1 closed +",
+		"A1a closed ",
+		"B closed +",
+		"B1 closed ",
+	}
+	got := outlineSummary(o)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Outline:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !o.Open || o.Count != 3 {
+		t.Errorf("root: Open = %v, Count = %d, want true, 3", o.Open, o.Count)
+	}
+
+	// Writing the outline back computes the same Counts.
+	var buf bytes.Buffer
+	w, err := r.Update(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetOutline(context.Background(), o); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(Value{}); err != nil {
+		t.Fatal(err)
+	}
+	r = openPDF(t, buf.Bytes(), nil)
+	o, err = r.Outline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := outlineSummary(o); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rewritten Outline:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !o.Open || o.Count != 3 {
+		t.Errorf("rewritten root: Open = %v, Count = %d, want true, 3", o.Open, o.Count)
+	}
+}
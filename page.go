@@ -10,8 +10,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"math"
+	"slices"
 	"sort"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
 )
 
 // A Page represent a single page in a PDF file.
@@ -23,69 +29,208 @@ type Page struct {
 // Page returns the page for the given page number.
 // Page numbers are indexed starting at 1, not 0.
 // If the page is not found, Page returns a Page with p.V.IsNull().
+//
+// Page descends the page tree using the Count entries of its nodes,
+// each checked against the Count entries of the node's kids. Where they
+// disagree, as in malformed files, the pages under the node are counted
+// instead (see pageCount), so a wrong Count does not change the result.
 func (r *Reader) Page(ctx context.Context, num int) (Page, error) {
 	if ctx.Err() != nil {
 		return Page{}, ctx.Err()
 	}
-	num-- // now 0-indexed
-	trailRoot, err := r.Trailer().Key("Root")
-	if err != nil {
+	if num < 1 {
+		return Page{}, nil
+	}
+	if err := r.checkPages(num); err != nil {
 		return Page{}, err
 	}
-	page, err := trailRoot.Key("Pages")
-	if err != nil {
+	if num == 1 && r.linear != nil {
+		// The first page of a linearized file is found without
+		// reading the page tree, which follows it.
+		v, err := r.resolve(objptr{}, r.linear.firstPage)
+		if err != nil {
+			return Page{}, err
+		}
+		if v.Kind() == Dict && !isPageTreeNode(v) {
+			return Page{v}, nil
+		}
+	}
+	node, err := r.pageTreeRoot()
+	if err != nil || node.Kind() != Dict {
 		return Page{}, err
 	}
-Search:
-	for isPagesType(page) {
+	if !isPageTreeNode(node) {
+		if num == 1 {
+			return Page{node}, nil
+		}
+		return Page{}, nil
+	}
+	// The nodes from the root to node are skipped as kids, as
+	// walkPages does, and not counted, so that a cyclic tree terminates.
+	visiting := make(map[objptr]bool)
+	base := 0 // pages before node
+	for {
 		if ctx.Err() != nil {
 			return Page{}, ctx.Err()
 		}
-		pageCount, err := page.Key("Count")
+		visiting[node.ptr] = true
+		kids, err := node.Key("Kids")
 		if err != nil {
 			return Page{}, err
 		}
-		count := int(pageCount.Int64())
-		if count < num {
+		var next Value
+		for i := 0; i < kids.Len(); i++ {
+			kid, err := kids.Index(i)
+			if err != nil {
+				return Page{}, err
+			}
+			if kid.Kind() != Dict || visiting[kid.ptr] {
+				continue
+			}
+			n, err := r.pageCount(kid, visiting)
+			if err != nil {
+				return Page{}, err
+			}
+			if num <= base+n {
+				next = kid
+				break
+			}
+			base += n
+		}
+		if next.IsNull() {
 			return Page{}, nil
 		}
-		kids, err := page.Key("Kids")
+		if !isPageTreeNode(next) {
+			return Page{next}, nil
+		}
+		node = next
+	}
+}
+
+// pageTreeRoot returns the root of the page tree.
+func (r *Reader) pageTreeRoot() (Value, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return Value{}, err
+	}
+	return root.Key("Pages")
+}
+
+// pageCount returns the number of pages under the page tree node v:
+// 1 for a page, or else the node's Count, once checked against the
+// Count entries of its kids. If the node's Count is missing, or does
+// not match, its pages are counted instead and the count is recorded
+// as a Problem. The counts of indirect nodes are cached. visiting
+// holds the nodes being counted, to end a count that cycles.
+func (r *Reader) pageCount(v Value, visiting map[objptr]bool) (int, error) {
+	if !isPageTreeNode(v) {
+		return 1, nil
+	}
+	indirect := isIndirect(v)
+	if indirect {
+		if visiting[v.ptr] {
+			return 0, nil
+		}
+		r.mu.Lock()
+		n, ok := r.pageCounts[v.ptr]
+		r.mu.Unlock()
+		if ok {
+			return n, nil
+		}
+		visiting[v.ptr] = true
+		defer delete(visiting, v.ptr)
+	}
+	kids, err := v.Key("Kids")
+	if err != nil {
+		return 0, err
+	}
+	count, err := v.Key("Count")
+	if err != nil {
+		return 0, err
+	}
+	declared := -1
+	if count.Kind() == Integer && count.Int64() >= 0 {
+		if err := r.checkPages(int(count.Int64())); err != nil {
+			return 0, err
+		}
+		declared = int(count.Int64())
+	}
+	sum := 0
+	for i := 0; i < kids.Len() && declared >= 0; i++ {
+		kid, err := kids.Index(i)
 		if err != nil {
-			return Page{}, err
+			return 0, err
 		}
+		if kid.Kind() != Dict {
+			continue
+		}
+		if !isPageTreeNode(kid) {
+			sum++
+			continue
+		}
+		c, err := kid.Key("Count")
+		if err != nil {
+			return 0, err
+		}
+		if c.Kind() != Integer || c.Int64() < 0 {
+			declared = -1
+			break
+		}
+		sum += int(c.Int64())
+	}
+	n := declared
+	if declared < 0 || sum != declared {
+		n = 0
 		for i := 0; i < kids.Len(); i++ {
 			kid, err := kids.Index(i)
 			if err != nil {
-				return Page{}, err
+				return 0, err
+			}
+			if kid.Kind() != Dict {
+				continue
 			}
-			kidType, err := kid.Key("Type")
+			c, err := r.pageCount(kid, visiting)
 			if err != nil {
-				return Page{}, err
+				return 0, err
 			}
-			if kidType.Name() == "Pages" {
-				kidCount, err := kid.Key("Count")
-				if err != nil {
-					return Page{}, err
-				}
-				c := int(kidCount.Int64())
-				if num < c {
-					page = kid
-					continue Search
-				}
-				num -= c
-				continue
+			n += c
+			if err := r.checkPages(n); err != nil {
+				return 0, err
 			}
-			if kidType.Name() == "Page" {
-				if num == 0 {
-					return Page{kid}, nil
-				}
-				num--
+		}
+		r.problem(-1, "page tree node %v: Count %s does not match its kids; counted %d pages", v.ptr, objfmt(count.data), n)
+	}
+	if indirect {
+		r.mu.Lock()
+		if r.pageCounts == nil {
+			r.pageCounts = make(map[objptr]int)
+		}
+		r.pageCounts[v.ptr] = n
+		r.mu.Unlock()
+	}
+	return n, nil
+}
+
+// Pages returns the document's pages, in order, walking the page tree
+// once as the sequence is consumed. If an error occurs, including ctx
+// being done, the sequence ends with a null Page and the error.
+func (r *Reader) Pages(ctx context.Context) iter.Seq2[Page, error] {
+	return func(yield func(Page, error) bool) {
+		err := r.walkPages(ctx, func(num int, p Page) error {
+			if !yield(p, nil) {
+				return errStopWalk
 			}
+			return nil
+		})
+		if err != nil && err != errStopWalk {
+			yield(Page{}, err)
 		}
 	}
-	return Page{}, nil
 }
 
+// errStopWalk is returned by a walkPages callback to end the walk early.
+var errStopWalk = errors.New("pdf: stop walk")
+
 func isPagesType(page Value) bool {
 	pageType, err := page.Key("Type")
 	if err != nil {
@@ -94,22 +239,108 @@ func isPagesType(page Value) bool {
 	return pageType.Name() == "Pages"
 }
 
+// isPageTreeNode reports whether v is an intermediate node of the page tree.
+// Nodes are identified by their Type; a node missing its Type is
+// taken to be intermediate if it has Kids.
+func isPageTreeNode(v Value) bool {
+	typ, err := v.Key("Type")
+	if err != nil {
+		return false
+	}
+	switch typ.Name() {
+	case "Pages":
+		return true
+	case "Page":
+		return false
+	}
+	kids, err := v.Key("Kids")
+	return err == nil && kids.Kind() == Array
+}
+
 // NumPage returns the number of pages in the PDF file.
+//
+// NumPage uses the Count of the root of the page tree, once checked
+// against the Count entries of its kids; where they disagree, the pages
+// are counted instead (see Page), so a missing or wrong Count, or a
+// Count placed on a page object, does not change the result. For a
+// linearized file (see Reader.Linearized), the page tree is not read,
+// and NumPage returns the count recorded at the start of the file.
+// If the declared or discovered page count exceeds r.Limits.MaxPages,
+// NumPage returns a *LimitError.
 func (r *Reader) NumPage() (int, error) {
-	trailerRoot, err := r.Trailer().Key("Root")
-	if err != nil {
+	if r.linear != nil {
+		if err := r.checkPages(r.linear.pages); err != nil {
+			return 0, err
+		}
+		return r.linear.pages, nil
+	}
+	pages, err := r.pageTreeRoot()
+	if err != nil || pages.Kind() != Dict {
 		return 0, err
 	}
-	trailerPages, err := trailerRoot.Key("Pages")
+	return r.pageCount(pages, make(map[objptr]bool))
+}
+
+// walkPages calls fn for each page in the document, in order,
+// passing the 1-based page number. It walks the page tree once,
+// so it is much cheaper than calling r.Page for every page.
+func (r *Reader) walkPages(ctx context.Context, fn func(num int, p Page) error) error {
+	pages, err := r.pageTreeRoot()
 	if err != nil {
-		return 0, err
+		return err
 	}
-	trailerPagesCount, err := trailerPages.Key("Count")
+	if pages.Kind() != Dict {
+		return nil
+	}
+	count, err := pages.Key("Count")
 	if err != nil {
-		return 0, err
+		return err
+	}
+	if err := r.checkPages(int(count.Int64())); err != nil {
+		return err
 	}
 
-	return int(trailerPagesCount.Int64()), nil
+	num := 0
+	seen := make(map[objptr]bool)
+	var walk func(v Value) error
+	walk = func(v Value) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !isPageTreeNode(v) {
+			num++
+			if err := r.checkPages(num); err != nil {
+				return err
+			}
+			return fn(num, Page{v})
+		}
+		kids, err := v.Key("Kids")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < kids.Len(); i++ {
+			kid, err := kids.Index(i)
+			if err != nil {
+				return err
+			}
+			if kid.Kind() != Dict {
+				continue
+			}
+			// Kids are indirect objects, each with its own object
+			// pointer; refuse to visit one twice, or a node inside
+			// itself, so that a cyclic tree terminates.
+			if seen[kid.ptr] {
+				continue
+			}
+			seen[kid.ptr] = true
+			if err := walk(kid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	seen[pages.ptr] = true
+	return walk(pages)
 }
 
 // GetPlainText returns all the text in the PDF file
@@ -117,40 +348,77 @@ func (r *Reader) GetPlainText(ctx context.Context) (reader io.Reader, err error)
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
-	pages, err := r.NumPage()
-	if err != nil {
-		return nil, err
-	}
 	var buf bytes.Buffer
 	fonts := make(map[string]*Font)
-	for i := 1; i <= pages; i++ {
-		p, err := r.Page(ctx, i)
-		if err != nil {
-			return nil, err
-		}
+	err = r.walkPages(ctx, func(num int, p Page) error {
 		pFonts, err := p.Fonts()
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		for _, name := range pFonts { // cache fonts so we don't continually parse charmap
 			if _, ok := fonts[name]; !ok {
 				f, err := p.Font(name)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				fonts[name] = &f
 			}
 		}
 		text, err := p.GetPlainText(ctx, fonts)
 		if err != nil {
-			return &bytes.Buffer{}, err
+			return err
 		}
 		buf.WriteString(text)
+		return nil
+	})
+	if err != nil {
+		return &bytes.Buffer{}, err
 	}
 	return &buf, nil
 }
 
+// TextPreview returns up to the first maxChars characters of the
+// document's plain text, as GetPlainText would return them.
+// It stops reading the document as soon as it has enough text,
+// which makes it much cheaper than GetPlainText for large documents.
+func (r *Reader) TextPreview(ctx context.Context, maxChars int) (string, error) {
+	if maxChars <= 0 {
+		return "", nil
+	}
+	var buf strings.Builder
+	n := 0
+	fonts := make(map[string]*Font)
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		pFonts, err := p.Fonts()
+		if err != nil {
+			return err
+		}
+		for _, name := range pFonts {
+			if _, ok := fonts[name]; !ok {
+				f, err := p.Font(name)
+				if err != nil {
+					return err
+				}
+				fonts[name] = &f
+			}
+		}
+		text, err := p.plainText(ctx, fonts, maxChars-n)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(p.textOptions().encode(text))
+		if n += utf8.RuneCountInString(text); n >= maxChars {
+			return errStopText
+		}
+		return nil
+	})
+	if err != nil && err != errStopText {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // GetSinglePagePlainText returns all the text in the PDF file for a single page
 func (r *Reader) GetSinglePagePlainText(ctx context.Context, page int) (reader io.Reader, err error) {
 	if ctx.Err() != nil {
@@ -191,7 +459,9 @@ func (r *Reader) GetSinglePagePlainText(ctx context.Context, page int) (reader i
 }
 
 func (p Page) findInherited(key string) (Value, error) {
-	for v := p.V; !v.IsNull(); v = getParent(v) {
+	seen := make(map[objptr]bool)
+	for v := p.V; v.Kind() == Dict && !seen[v.ptr]; v = getParent(v) {
+		seen[v.ptr] = true
 		parentKey, err := v.Key(key)
 		if err != nil {
 			return Value{}, err
@@ -211,15 +481,85 @@ func getParent(v Value) Value {
 	return parent
 }
 
-/*
-func (p Page) MediaBox() Value {
-	return p.findInherited("MediaBox")
+// MediaBox returns the page's media box, the boundaries of the physical medium
+// on which the page is to be displayed or printed.
+// If the page has no media box, MediaBox returns a zero Rect.
+func (p Page) MediaBox() (Rect, error) {
+	box, err := p.findInherited("MediaBox")
+	if err != nil {
+		return Rect{}, err
+	}
+	return rectFromArray(box)
 }
 
-func (p Page) CropBox() Value {
-	return p.findInherited("CropBox")
+// CropBox returns the page's crop box, the region to which the contents
+// of the page are clipped when displayed or printed.
+// If the page has no crop box, CropBox returns the media box.
+func (p Page) CropBox() (Rect, error) {
+	box, err := p.findInherited("CropBox")
+	if err != nil {
+		return Rect{}, err
+	}
+	if box.Kind() != Array {
+		return p.MediaBox()
+	}
+	return rectFromArray(box)
+}
+
+// Rotate returns the number of degrees by which the page is rotated
+// clockwise when displayed or printed: 0, 90, 180, or 270.
+func (p Page) Rotate() (int, error) {
+	rot, err := p.findInherited("Rotate")
+	if err != nil {
+		return 0, err
+	}
+	r := int(rot.Int64()) % 360
+	if r < 0 {
+		r += 360
+	}
+	return r, nil
+}
+
+// Size returns the width and height of the page as displayed:
+// those of its crop box, swapped if the page is rotated by 90 or 270 degrees.
+func (p Page) Size() (width, height float64, err error) {
+	box, err := p.CropBox()
+	if err != nil {
+		return 0, 0, err
+	}
+	rot, err := p.Rotate()
+	if err != nil {
+		return 0, 0, err
+	}
+	width, height = box.Max.X-box.Min.X, box.Max.Y-box.Min.Y
+	if rot == 90 || rot == 270 {
+		width, height = height, width
+	}
+	return width, height, nil
+}
+
+// rectFromArray returns the rectangle described by the array [llx lly urx ury],
+// normalized so that Min is the lower left corner.
+func rectFromArray(v Value) (Rect, error) {
+	if v.Len() != 4 {
+		return Rect{}, nil
+	}
+	var x [4]float64
+	for i := range x {
+		xi, err := v.Index(i)
+		if err != nil {
+			return Rect{}, err
+		}
+		x[i] = xi.Float64()
+	}
+	if x[0] > x[2] {
+		x[0], x[2] = x[2], x[0]
+	}
+	if x[1] > x[3] {
+		x[1], x[3] = x[3], x[1]
+	}
+	return Rect{Point{x[0], x[1]}, Point{x[2], x[3]}}, nil
 }
-*/
 
 // Resources returns the resources dictionary associated with the page.
 func (p Page) Resources() (Value, error) {
@@ -256,6 +596,25 @@ func (p Page) Font(name string) (Font, error) {
 	return Font{fontName, nil}, nil
 }
 
+// colorSpace returns the color space named by cs, as given to the cs or CS
+// operator: a device color space name, which yields a null Value,
+// or the name of an entry in the page's ColorSpace resources.
+func (p Page) colorSpace(cs Value) (Value, error) {
+	switch cs.Name() {
+	case "DeviceGray", "DeviceRGB", "DeviceCMYK", "Pattern":
+		return Value{}, nil
+	}
+	res, err := p.Resources()
+	if err != nil {
+		return Value{}, err
+	}
+	spaces, err := res.Key("ColorSpace")
+	if err != nil {
+		return Value{}, err
+	}
+	return spaces.Key(cs.Name())
+}
+
 // A Font represent a font in a PDF file.
 // The methods interpret a Font dictionary stored in V.
 type Font struct {
@@ -309,7 +668,16 @@ func (f Font) Widths() ([]float64, error) {
 }
 
 // Width returns the width of the given code point.
+// For one of the standard 14 fonts with no Widths array,
+// the width comes from the font's built-in metrics.
 func (f Font) Width(code int) (float64, error) {
+	widths, err := f.V.Key("Widths")
+	if err != nil {
+		return 0, err
+	}
+	if widths.Kind() != Array {
+		return f.standardWidth(code)
+	}
 	first, err := f.FirstChar()
 	if err != nil {
 		return 0, err
@@ -321,15 +689,69 @@ func (f Font) Width(code int) (float64, error) {
 	if code < first || last < code {
 		return 0, nil
 	}
-	widths, err := f.V.Key("Widths")
+	wIdx, err := widths.Index(code - first)
 	if err != nil {
 		return 0, err
 	}
-	wIdx, err := widths.Index(code - first)
+	return wIdx.Float64(), nil
+}
+
+// standardWidth returns the width of the given code point in the
+// standard 14 font f, or 0 if f is not a standard font. Common names
+// for the same fonts, such as Arial and TimesNewRoman, are recognized.
+func (f Font) standardWidth(code int) (float64, error) {
+	if code < 0 || code > 255 {
+		return 0, nil
+	}
+	name, err := f.BaseFont()
 	if err != nil {
 		return 0, err
 	}
-	return wIdx.Float64(), nil
+	if i := strings.Index(name, "+"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ToLower(name)
+	bold := strings.Contains(name, "bold")
+	italic := strings.Contains(name, "italic") || strings.Contains(name, "oblique")
+	var table map[rune]uint16
+	switch {
+	case strings.Contains(name, "courier"):
+		return 600, nil
+	case strings.Contains(name, "dingbats"):
+		return float64(zapfDingbatsWidths[code]), nil
+	case strings.HasPrefix(name, "symbol"):
+		return float64(symbolWidths[code]), nil
+	case strings.Contains(name, "times"):
+		switch {
+		case bold && italic:
+			table = timesBoldItalicWidths
+		case bold:
+			table = timesBoldWidths
+		case italic:
+			table = timesItalicWidths
+		default:
+			table = timesRomanWidths
+		}
+	case strings.Contains(name, "helvetica"), strings.Contains(name, "arial"):
+		table = helveticaWidths
+		if bold {
+			table = helveticaBoldWidths
+		}
+	default:
+		return 0, nil
+	}
+	enc, err := f.baseEncoder(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	s, err := enc.Decode(context.Background(), string([]byte{byte(code)}))
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range s {
+		return float64(table[r]), nil
+	}
+	return 0, nil
 }
 
 // Encoder returns the encoding between font code point sequences and UTF-8.
@@ -347,10 +769,47 @@ func (f Font) Encoder(ctx context.Context) (TextEncoding, error) {
 	return f.enc, nil
 }
 
+// getEncoder returns the font's encoding. For a simple font, a ToUnicode
+// CMap, when present, takes precedence, and codes the CMap does not map
+// are decoded using the font's Encoding. Composite fonts are decoded
+// by a type0Encoder.
 func (f Font) getEncoder(ctx context.Context) (TextEncoding, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
+	if f.subtype() == "Type0" {
+		return f.type0Encoder(ctx)
+	}
+	toUnicode, err := f.V.Key("ToUnicode")
+	if err != nil {
+		return nil, err
+	}
+	if toUnicode.Kind() == Stream {
+		m, err := readCmap(ctx, toUnicode)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			if m.fallback, err = f.baseEncoder(ctx); err != nil {
+				return nil, err
+			}
+			return m, nil
+		}
+	}
+	return f.baseEncoder(ctx)
+}
+
+// subtype returns the font's Subtype, or "" if it cannot be read.
+func (f Font) subtype() string {
+	typ, err := f.V.Key("Subtype")
+	if err != nil {
+		return ""
+	}
+	return typ.Name()
+}
+
+// baseEncoder returns the encoding described by the font's Encoding entry.
+func (f Font) baseEncoder(ctx context.Context) (TextEncoding, error) {
 	enc, err := f.V.Key("Encoding")
 	if err != nil {
 		return nil, err
@@ -363,7 +822,7 @@ func (f Font) getEncoder(ctx context.Context) (TextEncoding, error) {
 		case "MacRomanEncoding":
 			return &byteEncoder{&macRomanEncoding}, nil
 		case "Identity-H":
-			return f.charmapEncoding(ctx)
+			return &byteEncoder{&pdfDocEncoding}, nil
 		default:
 			if DebugOn {
 				println("unknown encoding", enc.Name())
@@ -377,7 +836,7 @@ func (f Font) getEncoder(ctx context.Context) (TextEncoding, error) {
 		}
 		return &dictEncoder{encDiff}, nil
 	case Null:
-		return f.charmapEncoding(ctx)
+		return &byteEncoder{&pdfDocEncoding}, nil
 	default:
 		if DebugOn {
 			println("unexpected encoding", enc.String())
@@ -386,28 +845,6 @@ func (f Font) getEncoder(ctx context.Context) (TextEncoding, error) {
 	}
 }
 
-func (f *Font) charmapEncoding(ctx context.Context) (TextEncoding, error) {
-	if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
-	toUnicode, err := f.V.Key("ToUnicode")
-	if err != nil {
-		return nil, err
-	}
-	if toUnicode.Kind() == Stream {
-		m, err := readCmap(ctx, toUnicode)
-		if err != nil {
-			return nil, err
-		}
-		if m == nil {
-			return &nopEncoder{}, nil
-		}
-		return m, nil
-	}
-
-	return &byteEncoder{&pdfDocEncoding}, nil
-}
-
 type dictEncoder struct {
 	v Value
 }
@@ -434,7 +871,7 @@ func (e *dictEncoder) Decode(ctx context.Context, raw string) (text string, err
 			}
 			if x.Kind() == Name {
 				if int(raw[i]) == n {
-					r := nameToRune[x.Name()]
+					r := glyphNameToRune(x.Name())
 					if r != 0 {
 						ch = r
 						break
@@ -448,6 +885,85 @@ func (e *dictEncoder) Decode(ctx context.Context, raw string) (text string, err
 	return string(r), nil
 }
 
+// A fontCode is a single character code in a string shown with a font,
+// with its text.
+type fontCode struct {
+	raw  string
+	text string
+}
+
+// A codeDecoder is a TextEncoding whose codes may be longer than a byte.
+type codeDecoder interface {
+	// decodeCode decodes the first code in raw, returning its length
+	// in bytes and its text.
+	decodeCode(ctx context.Context, raw string) (n int, text string, err error)
+}
+
+// decodeCodes splits s into character codes and decodes each one.
+// Codes are single bytes unless enc is a codeDecoder.
+func decodeCodes(ctx context.Context, enc TextEncoding, s string) ([]fontCode, error) {
+	var codes []fontCode
+	for len(s) > 0 {
+		n, text := 1, ""
+		var err error
+		if d, ok := enc.(codeDecoder); ok {
+			n, text, err = d.decodeCode(ctx, s)
+		} else {
+			text, err = enc.Decode(ctx, s[:1])
+		}
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, fontCode{s[:n], text})
+		s = s[n:]
+	}
+	return codes, nil
+}
+
+// codeWidth returns the width of the glyph for the character code,
+// decoded with enc, in thousandths of text space units.
+// The widths of a Type 3 font are in its glyph space,
+// which its FontMatrix maps to text space.
+func (f Font) codeWidth(enc TextEncoding, code string) (float64, error) {
+	if e, ok := enc.(*type0Encoder); ok {
+		return e.width(code), nil
+	}
+	if len(code) != 1 {
+		return 0, nil
+	}
+	w, err := f.Width(int(code[0]))
+	if err != nil || f.subtype() != "Type3" {
+		return w, err
+	}
+	m, err := f.fontMatrix()
+	if err != nil {
+		return 0, err
+	}
+	return w * m[0] * 1000, nil
+}
+
+// fontMatrix returns the matrix mapping the font's glyph space to
+// text space: the FontMatrix of a Type 3 font, and otherwise the
+// scaling by 1/1000 that the other kinds of fonts use.
+func (f Font) fontMatrix() (Matrix, error) {
+	m := Matrix{0.001, 0, 0, 0.001, 0, 0}
+	if f.subtype() != "Type3" {
+		return m, nil
+	}
+	v, err := f.V.Key("FontMatrix")
+	if err != nil {
+		return Matrix{}, err
+	}
+	nums, err := numberArray(v)
+	if err != nil {
+		return Matrix{}, err
+	}
+	if len(nums) == 6 {
+		copy(m[:], nums)
+	}
+	return m, nil
+}
+
 // A TextEncoding represents a mapping between
 // font code points and UTF-8 text.
 type TextEncoding interface {
@@ -501,6 +1017,13 @@ type cmap struct {
 	space   [4][]byteRange // codespace range
 	bfrange []bfrange
 	bfchar  []bfchar
+
+	// fallback, if not nil, decodes the codes the CMap does not map.
+	fallback TextEncoding
+
+	// For a CMap mapping codes to CIDs, as used to encode a composite font:
+	cidrange []cidRange
+	usecmap  string // name of a predefined CMap this one extends
 }
 
 func (m *cmap) Decode(ctx context.Context, raw string) (text string, err error) {
@@ -508,69 +1031,111 @@ func (m *cmap) Decode(ctx context.Context, raw string) (text string, err error)
 		return "", ctx.Err()
 	}
 	var r []rune
-Parse:
 	for len(raw) > 0 {
 		if ctx.Err() != nil {
 			return "", ctx.Err()
 		}
-		for n := 1; n <= 4 && n <= len(raw); n++ { // number of digits in character replacement (1-4 possible)
-			for _, space := range m.space[n-1] { // find matching codespace Ranges for number of digits
-				if space.low <= raw[:n] && raw[:n] <= space.high { // see if value is in range
-					text := raw[:n]
-					raw = raw[n:]
-					for _, bfchar := range m.bfchar { // check for matching bfchar
-						if len(bfchar.orig) == n && bfchar.orig == text {
-							r = append(r, []rune(utf16Decode(bfchar.repl))...)
-							continue Parse
-						}
-					}
-					for _, bfrange := range m.bfrange { // check for matching bfrange
-						if len(bfrange.lo) == n && bfrange.lo <= text && text <= bfrange.hi {
-							if bfrange.dst.Kind() == String {
-								s := bfrange.dst.RawString()
-								if bfrange.lo != text { // value isn't at the beginning of the range so scale result
-									b := []byte(s)
-									b[len(b)-1] += text[len(text)-1] - bfrange.lo[len(bfrange.lo)-1] // increment last byte by difference
-									s = string(b)
-								}
-								r = append(r, []rune(utf16Decode(s))...)
-								continue Parse
-							}
-							if bfrange.dst.Kind() == Array {
-								n := text[len(text)-1] - bfrange.lo[len(bfrange.lo)-1]
-								v, err := bfrange.dst.Index(int(n))
-								if err != nil {
-									return "", err
-								}
-								if v.Kind() == String {
-									s := v.RawString()
-									r = append(r, []rune(utf16Decode(s))...)
-									continue Parse
-								}
-								if DebugOn {
-									fmt.Printf("array %v\n", bfrange.dst)
-								}
-							} else {
-								if DebugOn {
-									fmt.Printf("unknown dst %v\n", bfrange.dst)
-								}
-							}
-							r = append(r, noRune)
-							continue Parse
-						}
-					}
-					r = append(r, noRune)
-					continue Parse
-				}
+		n, s, err := m.decodeCode(ctx, raw)
+		if err != nil {
+			return "", err
+		}
+		r = append(r, []rune(s)...)
+		raw = raw[n:]
+	}
+	return string(r), nil
+}
+
+// decodeCode decodes the first code in raw, returning its length in
+// bytes and its text. A code may map to several characters, as for
+// a ligature, or to none.
+func (m *cmap) decodeCode(ctx context.Context, raw string) (n int, text string, err error) {
+	if n := m.codeLen(raw); n > 0 {
+		if text, ok, err := m.find(raw[:n]); ok || err != nil {
+			return n, text, err
+		}
+		text, err := m.unmapped(ctx, raw[:n])
+		return n, text, err
+	}
+	if DebugOn {
+		println("no code space found")
+	}
+	text, err = m.unmapped(ctx, raw[:1])
+	return 1, text, err
+}
+
+// codeLen returns the length of the code at the start of raw,
+// or 0 if raw does not start with a code in the codespace.
+func (m *cmap) codeLen(raw string) int {
+	for n := 1; n <= 4 && n <= len(raw); n++ { // number of bytes in the code (1-4 possible)
+		for _, space := range m.space[n-1] { // find matching codespace ranges for the number of bytes
+			if space.low <= raw[:n] && raw[:n] <= space.high { // see if value is in range
+				return n
 			}
 		}
-		if DebugOn {
-			println("no code space found")
+	}
+	return 0
+}
+
+// find returns the text for the code and whether the CMap maps it.
+func (m *cmap) find(code string) (string, bool, error) {
+	for _, bfchar := range m.bfchar { // check for matching bfchar
+		if bfchar.orig == code {
+			return utf16Decode(bfchar.repl), true, nil
 		}
-		r = append(r, noRune)
-		raw = raw[1:]
 	}
-	return string(r), nil
+	for _, bfrange := range m.bfrange { // check for matching bfrange
+		if len(bfrange.lo) != len(code) || code < bfrange.lo || bfrange.hi < code {
+			continue
+		}
+		off := codeValue(code) - codeValue(bfrange.lo)
+		switch bfrange.dst.Kind() {
+		case String:
+			// The destination is incremented for each code after the first,
+			// carrying into the byte before the last.
+			b := []byte(bfrange.dst.RawString())
+			if len(b) >= 2 {
+				u := (int(b[len(b)-2])<<8 | int(b[len(b)-1])) + off
+				b[len(b)-2], b[len(b)-1] = byte(u>>8), byte(u)
+			} else if len(b) == 1 {
+				b[0] += byte(off)
+			}
+			return utf16Decode(string(b)), true, nil
+		case Array:
+			v, err := bfrange.dst.Index(off)
+			if err != nil {
+				return "", false, err
+			}
+			if v.Kind() == String {
+				return utf16Decode(v.RawString()), true, nil
+			}
+			if DebugOn {
+				fmt.Printf("array %v\n", bfrange.dst)
+			}
+		default:
+			if DebugOn {
+				fmt.Printf("unknown dst %v\n", bfrange.dst)
+			}
+		}
+		return string(noRune), true, nil
+	}
+	return "", false, nil
+}
+
+// unmapped returns the text for a code the CMap does not map.
+func (m *cmap) unmapped(ctx context.Context, code string) (string, error) {
+	if m.fallback != nil && len(code) == 1 {
+		return m.fallback.Decode(ctx, code)
+	}
+	return string(noRune), nil
+}
+
+// codeValue returns the big-endian value of the code's bytes.
+func codeValue(code string) int {
+	v := 0
+	for i := 0; i < len(code); i++ {
+		v = v<<8 | int(code[i])
+	}
+	return v
 }
 
 func readCmap(ctx context.Context, toUnicode Value) (*cmap, error) {
@@ -622,7 +1187,14 @@ func readCmap(ctx context.Context, toUnicode Value) (*cmap, error) {
 				return fmt.Errorf("missing beginbfchar")
 			}
 			for i := 0; i < n; i++ {
-				repl, orig := stk.Pop().RawString(), stk.Pop().RawString()
+				dst, orig := stk.Pop(), stk.Pop().RawString()
+				repl := dst.RawString()
+				if dst.Kind() == Name {
+					// A glyph name rather than a UTF-16 string.
+					if r := glyphNameToRune(dst.Name()); r != 0 {
+						repl = utf16Encode(string(r))
+					}
+				}
 				m.bfchar = append(m.bfchar, bfchar{orig, repl})
 			}
 		case "beginbfrange":
@@ -635,6 +1207,26 @@ func readCmap(ctx context.Context, toUnicode Value) (*cmap, error) {
 				dst, srcHi, srcLo := stk.Pop(), stk.Pop().RawString(), stk.Pop().RawString()
 				m.bfrange = append(m.bfrange, bfrange{srcLo, srcHi, dst})
 			}
+		case "begincidrange", "begincidchar":
+			n = int(stk.Pop().Int64())
+		case "endcidrange":
+			if n < 0 {
+				return fmt.Errorf("missing begincidrange")
+			}
+			for i := 0; i < n; i++ {
+				cid, hi, lo := stk.Pop().Int64(), stk.Pop().RawString(), stk.Pop().RawString()
+				m.cidrange = append(m.cidrange, cidRange{lo, hi, int(cid)})
+			}
+		case "endcidchar":
+			if n < 0 {
+				return fmt.Errorf("missing begincidchar")
+			}
+			for i := 0; i < n; i++ {
+				cid, code := stk.Pop().Int64(), stk.Pop().RawString()
+				m.cidrange = append(m.cidrange, cidRange{code, code, int(cid)})
+			}
+		case "usecmap":
+			m.usecmap = stk.Pop().Name()
 		case "defineresource":
 			stk.Pop().Name() // category
 			value := stk.Pop()
@@ -651,14 +1243,116 @@ func readCmap(ctx context.Context, toUnicode Value) (*cmap, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, nil
+	if !ok {
+		return nil, nil
+	}
+	if len(m.space[0])+len(m.space[1])+len(m.space[2])+len(m.space[3]) == 0 {
+		// Without a codespace range, take the codes to be as long as
+		// the ones the CMap maps.
+		for _, c := range m.bfchar {
+			m.addSpace(len(c.orig))
+		}
+		for _, c := range m.bfrange {
+			m.addSpace(len(c.lo))
+		}
+	}
+	return &m, nil
+}
+
+// addSpace adds a codespace range covering all codes of n bytes.
+func (m *cmap) addSpace(n int) {
+	if n < 1 || n > 4 || len(m.space[n-1]) > 0 {
+		return
+	}
+	m.space[n-1] = []byteRange{{strings.Repeat("\x00", n), strings.Repeat("\xff", n)}}
+}
+
+// A Matrix is an affine transformation [a b c d e f], as in the PDF cm operator.
+// It maps the point (x, y) to (a*x + c*y + e, b*x + d*y + f).
+type Matrix [6]float64
+
+// Identity is the identity transformation.
+var Identity = Matrix{1, 0, 0, 1, 0, 0}
+
+// Mul returns the transformation that applies m and then n.
+func (m Matrix) Mul(n Matrix) Matrix {
+	return m.matrix().mul(n.matrix()).Matrix()
+}
+
+// Apply returns the result of transforming the point pt by m.
+func (m Matrix) Apply(pt Point) Point {
+	return Point{
+		m[0]*pt.X + m[2]*pt.Y + m[4],
+		m[1]*pt.X + m[3]*pt.Y + m[5],
+	}
+}
+
+// ApplyRect returns the smallest rectangle containing r transformed by m.
+func (m Matrix) ApplyRect(r Rect) Rect {
+	pts := [4]Point{
+		m.Apply(r.Min),
+		m.Apply(Point{r.Max.X, r.Min.Y}),
+		m.Apply(r.Max),
+		m.Apply(Point{r.Min.X, r.Max.Y}),
+	}
+	out := Rect{pts[0], pts[0]}
+	for _, pt := range pts[1:] {
+		out.Min.X = math.Min(out.Min.X, pt.X)
+		out.Min.Y = math.Min(out.Min.Y, pt.Y)
+		out.Max.X = math.Max(out.Max.X, pt.X)
+		out.Max.Y = math.Max(out.Max.Y, pt.Y)
+	}
+	return out
+}
+
+// inverse returns the transformation undoing m,
+// or false if m is not invertible.
+func (m Matrix) inverse() (Matrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return Matrix{}, false
 	}
-	return &m, nil
+	return Matrix{m[3] / det, -m[1] / det, -m[2] / det, m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det, (m[1]*m[4] - m[0]*m[5]) / det}, true
+}
+
+func (m Matrix) matrix() matrix {
+	return matrix{{m[0], m[1], 0}, {m[2], m[3], 0}, {m[4], m[5], 1}}
 }
 
 type matrix [3][3]float64
 
+// Matrix returns x as a Matrix.
+func (x matrix) Matrix() Matrix {
+	return Matrix{x[0][0], x[0][1], x[1][0], x[1][1], x[2][0], x[2][1]}
+}
+
+// Transform returns the transformation from the page's default user space
+// to its displayed space: the space in which the page appears after its
+// crop box is moved to the origin and the page is rotated by Rotate degrees.
+// In displayed space, (0, 0) is the lower left corner of the visible page.
+func (p Page) Transform() (Matrix, error) {
+	box, err := p.CropBox()
+	if err != nil {
+		return Matrix{}, err
+	}
+	rot, err := p.Rotate()
+	if err != nil {
+		return Matrix{}, err
+	}
+	w, h := box.Max.X-box.Min.X, box.Max.Y-box.Min.Y
+	m := Matrix{1, 0, 0, 1, -box.Min.X, -box.Min.Y}
+	switch rot {
+	case 90:
+		m = m.Mul(Matrix{0, -1, 1, 0, 0, w})
+	case 180:
+		m = m.Mul(Matrix{-1, 0, 0, -1, w, h})
+	case 270:
+		m = m.Mul(Matrix{0, 1, -1, 0, h, 0})
+	}
+	return m, nil
+}
+
 var ident = matrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
 
 func (x matrix) mul(y matrix) matrix {
@@ -681,6 +1375,56 @@ type Text struct {
 	Y        float64 // the Y coordinate, in points, increasing bottom to top
 	W        float64 // the width of the text, in points
 	S        string  // the actual UTF-8 text
+	ClipBox  Rect    // bounds of the clipping path, if TextOptions.ClipBox is set
+}
+
+// TextOptions controls how text is extracted from a page.
+// The zero value extracts text exactly as it is stored in the content stream.
+type TextOptions struct {
+	// BidiReorder converts right-to-left text (Arabic, Hebrew, and so on)
+	// stored in visual order into logical order, one line at a time.
+	// The conversion is a heuristic based on the bidirectional character
+	// classes, not a full implementation of the Unicode Bidirectional Algorithm.
+	BidiReorder bool
+
+	// MergeRuns makes Content coalesce consecutive Text values that share
+	// a font, size, and fill color, lie on the same baseline, and continue
+	// one another horizontally into a single Text with the combined string
+	// and width.
+	MergeRuns bool
+
+	// Displayed makes Content and Glyphs report positions, and
+	// Page.Annotations report annotation rectangles, in the page's
+	// displayed space, as defined by Page.Transform, instead of in
+	// default user space. This accounts for the crop box and page rotation.
+	Displayed bool
+
+	// ClipBox makes Content and Glyphs report the bounding box of the
+	// clipping path in effect when each piece of text is drawn.
+	// Clipping paths are approximated by their bounding boxes, and the
+	// clip is the intersection of those boxes, starting from the crop box.
+	ClipBox bool
+
+	// TargetEncoding, if not nil, makes the plain text functions
+	// (Page.GetPlainText, Reader.GetPlainText, GetSinglePagePlainText,
+	// and TextPreview) return text in this encoding instead of UTF-8,
+	// for example charmap.Windows1252 or japanese.ShiftJIS from
+	// golang.org/x/text/encoding. Characters the encoding cannot represent
+	// are replaced by the encoding's replacement character.
+	TargetEncoding encoding.Encoding
+
+	// Unmappable, if not nil, is incremented, atomically, by the number
+	// of characters that TargetEncoding could not represent, so that
+	// callers can detect a lossy conversion.
+	Unmappable *int64
+}
+
+// textOptions returns the text extraction options of the page's Reader.
+func (p Page) textOptions() TextOptions {
+	if p.V.r == nil {
+		return TextOptions{}
+	}
+	return p.V.r.TextOptions
 }
 
 // A Rect represents a rectangle.
@@ -688,6 +1432,19 @@ type Rect struct {
 	Min, Max Point
 }
 
+// Intersect returns the largest rectangle contained in both r and s.
+// If the two do not overlap, Intersect returns the zero Rect.
+func (r Rect) Intersect(s Rect) Rect {
+	out := Rect{
+		Point{math.Max(r.Min.X, s.Min.X), math.Max(r.Min.Y, s.Min.Y)},
+		Point{math.Min(r.Max.X, s.Max.X), math.Min(r.Max.Y, s.Max.Y)},
+	}
+	if out.Min.X > out.Max.X || out.Min.Y > out.Max.Y {
+		return Rect{}
+	}
+	return out
+}
+
 // A Point represents an X, Y pair.
 type Point struct {
 	X float64
@@ -713,18 +1470,45 @@ type gstate struct {
 	Tlm   matrix
 	Trm   matrix
 	CTM   matrix
+	Clip  Rect // bounding box of the clipping path, in default user space
+
+	FillSpace   Value // non-stroking color space; null for device spaces
+	StrokeSpace Value // stroking color space; null for device spaces
+	Fill        fill  // non-stroking color
+}
+
+// A fill is a non-stroking color, as set by the g, rg, k, sc, and scn
+// operators: its components and, in a Pattern color space, the name of
+// its pattern.
+type fill struct {
+	color   []float64
+	pattern string
+}
+
+func (f fill) equal(g fill) bool {
+	return slices.Equal(f.color, g.color) && f.pattern == g.pattern
 }
 
 // GetPlainText returns the page's all text without format.
 // fonts can be passed in (to improve parsing performance) or left nil
 func (p Page) GetPlainText(ctx context.Context, fonts map[string]*Font) (result string, err error) {
+	text, err := p.plainText(ctx, fonts, 0)
+	return p.textOptions().encode(text), err
+}
+
+// errStopText is returned by a text callback to end
+// content interpretation once enough text has been collected.
+var errStopText = errors.New("pdf: enough text")
+
+// plainText implements GetPlainText. If max > 0, plainText stops
+// interpreting the page once it has collected max characters.
+func (p Page) plainText(ctx context.Context, fonts map[string]*Font, max int) (result string, err error) {
 	if ctx.Err() != nil {
 		return "", ctx.Err()
 	}
 	defer func() {
 		if r := recover(); r != nil {
-			result = ""
-			err = errors.New(fmt.Sprint(r))
+			result, err = "", panicError(r)
 		}
 	}()
 
@@ -750,6 +1534,7 @@ func (p Page) GetPlainText(ctx context.Context, fonts map[string]*Font) (result
 	}
 
 	var textBuilder bytes.Buffer
+	nchar := 0
 	showText := func(s string) error {
 		decoded, err := enc.Decode(ctx, s)
 		if err != nil {
@@ -760,6 +1545,9 @@ func (p Page) GetPlainText(ctx context.Context, fonts map[string]*Font) (result
 			if err != nil {
 				return err
 			}
+			if nchar++; max > 0 && nchar >= max {
+				return errStopText
+			}
 		}
 
 		return nil
@@ -779,7 +1567,7 @@ func (p Page) GetPlainText(ctx context.Context, fonts map[string]*Font) (result
 		default:
 			return nil
 		case "T*": // move to start of next line
-			showText("\n")
+			return showText("\n")
 		case "Tf": // set text font and size
 			if len(args) != 2 {
 				return fmt.Errorf("bad TL")
@@ -806,7 +1594,7 @@ func (p Page) GetPlainText(ctx context.Context, fonts map[string]*Font) (result
 			if len(args) != 1 {
 				return fmt.Errorf("bad Tj operator")
 			}
-			showText(args[0].RawString())
+			return showText(args[0].RawString())
 		case "TJ": // show text, allowing individual glyph positioning
 			v := args[0]
 			for i := 0; i < v.Len(); i++ {
@@ -815,16 +1603,21 @@ func (p Page) GetPlainText(ctx context.Context, fonts map[string]*Font) (result
 					return err
 				}
 				if x.Kind() == String {
-					showText(x.RawString())
+					if err := showText(x.RawString()); err != nil {
+						return err
+					}
 				}
 			}
 		}
 
 		return nil
 	})
-	if err != nil {
+	if err != nil && err != errStopText {
 		return "", err
 	}
+	if p.textOptions().BidiReorder {
+		return bidiReorderLines(textBuilder.String()), nil
+	}
 	return textBuilder.String(), nil
 }
 
@@ -838,17 +1631,15 @@ type Column struct {
 type Columns []*Column
 
 // GetTextByColumn returns the page's all text grouped by column
-func (p Page) GetTextByColumn(ctx context.Context) (Columns, error) {
+func (p Page) GetTextByColumn(ctx context.Context) (result Columns, err error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
-	result := Columns{}
-	var err error
+	result = Columns{}
 
 	defer func() {
 		if r := recover(); r != nil {
-			result = Columns{}
-			err = errors.New(fmt.Sprint(r))
+			result, err = nil, panicError(r)
 		}
 	}()
 
@@ -869,6 +1660,9 @@ func (p Page) GetTextByColumn(ctx context.Context) (Columns, error) {
 			X: currentX,
 			Y: currentY,
 		}
+		if p.textOptions().BidiReorder {
+			text.S = bidiReorder(text.S)
+		}
 
 		var currentColumn *Column
 		columnFound := false
@@ -918,17 +1712,15 @@ type Row struct {
 type Rows []*Row
 
 // GetTextByRow returns the page's all text grouped by rows
-func (p Page) GetTextByRow(ctx context.Context) (Rows, error) {
+func (p Page) GetTextByRow(ctx context.Context) (result Rows, err error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
-	result := Rows{}
-	var err error
+	result = Rows{}
 
 	defer func() {
 		if r := recover(); r != nil {
-			result = Rows{}
-			err = errors.New(fmt.Sprint(r))
+			result, err = nil, panicError(r)
 		}
 	}()
 
@@ -954,6 +1746,9 @@ func (p Page) GetTextByRow(ctx context.Context) (Rows, error) {
 			X: currentX,
 			Y: currentY,
 		}
+		if p.textOptions().BidiReorder {
+			text.S = bidiReorder(text.S)
+		}
 
 		var currentRow *Row
 		rowFound := false
@@ -1094,37 +1889,267 @@ func (p Page) walkTextBlocks(ctx context.Context, walker func(enc TextEncoding,
 
 // Content returns the page's content.
 func (p Page) Content(ctx context.Context) (Content, error) {
+	var text []Text
+	var fills []fill
+	rect, err := p.walkGlyphs(ctx, p.textOptions(), func(g Glyph) error {
+		text = append(text, Text{g.Font, g.FontSize, g.X, g.Y, g.W, g.S, g.ClipBox})
+		fills = append(fills, g.fill)
+		return nil
+	})
+	if err != nil {
+		return Content{}, err
+	}
+	if p.textOptions().MergeRuns {
+		text = mergeRuns(text, fills)
+	}
+	return Content{text, rect}, nil
+}
+
+// mergeRuns coalesces consecutive runs of text with the same style
+// that continue one another on the same baseline. fills holds the
+// color of each run. Line breaks are never merged.
+func mergeRuns(text []Text, fills []fill) []Text {
+	var out []Text
+	for i, t := range text {
+		if n := len(out); n > 0 && fills[i].equal(fills[i-1]) && continuesRun(out[n-1], t) {
+			out[n-1].S += t.S
+			out[n-1].W = t.X + t.W - out[n-1].X
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func continuesRun(prev, t Text) bool {
+	if prev.Font != t.Font || prev.FontSize != t.FontSize || prev.S == "\n" || t.S == "\n" {
+		return false
+	}
+	// Allow for rounding and small kerning adjustments:
+	// a tenth of the font size either way.
+	tol := t.FontSize / 10
+	if tol < 0 {
+		tol = -tol
+	}
+	return math.Abs(prev.Y-t.Y) <= tol && math.Abs(prev.X+prev.W-t.X) <= tol
+}
+
+// A Glyph is a single character drawn on a page.
+type Glyph struct {
+	Font     string  // the font used
+	FontSize float64 // the font size, in points (1/72 of an inch)
+	X        float64 // the X coordinate of the glyph origin, in points, increasing left to right
+	Y        float64 // the Y coordinate of the glyph origin, in points, increasing bottom to top
+	W        float64 // the width of the glyph, in points
+	DX, DY   float64 // the displacement to the next glyph origin, including character and word spacing
+	S        string  // the UTF-8 text for the glyph
+	ClipBox  Rect    // bounds of the clipping path, if TextOptions.ClipBox is set
+
+	code      string // the character code, for rendering
+	synthetic bool   // line break inserted after TJ, not drawn
+	mcid      int    // innermost enclosing marked-content identifier, or -1
+	artifact  bool   // inside Artifact marked content
+	fill      fill   // the color in which the glyph is filled
+}
+
+// Glyphs returns the individual glyphs drawn on the page, in drawing order.
+// Unlike Content, each glyph carries its advance (DX, DY), so the origin
+// of a glyph plus its advance is the origin of the next glyph in the same
+// text-showing operation.
+func (p Page) Glyphs(ctx context.Context) ([]Glyph, error) {
+	var glyphs []Glyph
+	_, err := p.walkGlyphs(ctx, p.textOptions(), func(g Glyph) error {
+		if !g.synthetic {
+			glyphs = append(glyphs, g)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return glyphs, nil
+}
+
+// walkGlyphs interprets the page's content stream, calling fn for each glyph drawn.
+// Positions are computed as directed by opts.
+// It returns the rectangles appended to paths.
+func (p Page) walkGlyphs(ctx context.Context, opts TextOptions, fn func(g Glyph) error) ([]Rect, error) {
+	return p.walkContent(ctx, opts, contentHandler{glyph: fn})
+}
+
+// A contentHandler receives the marks made by a content stream.
+// Nil callbacks are skipped.
+type contentHandler struct {
+	glyph func(g Glyph) error
+	image func(im contentImage) error
+	path  func(pa contentPath) error
+
+	// paint is called for each glyph, path, or image painted, with its bounds
+	// and the fill and stroke color spaces used to paint it.
+	// A color space is null if it is not used or is a device color space.
+	paint func(box Rect, fill, stroke Value) error
+}
+
+// A contentPath is a path painted by a content stream.
+type contentPath struct {
+	Box      Rect // bounds of the path
+	Segments int  // number of lines, curves, and closing segments; a rectangle counts as 4
+}
+
+// A contentImage is an image XObject painted by a content stream.
+type contentImage struct {
+	V        Value // the image XObject or inline image
+	Box      Rect  // the unit square mapped through the CTM
+	mcid     int   // innermost enclosing marked-content identifier, or -1
+	artifact bool  // inside Artifact marked content
+}
+
+// A markedContent is an open marked-content sequence (BMC or BDC).
+type markedContent struct {
+	mcid     int
+	artifact bool
+}
+
+// walkContent interprets the page's content stream like walkGlyphs,
+// calling h's callbacks for each glyph drawn, each image XObject
+// painted, and each path painted. Inline images are not reported.
+func (p Page) walkContent(ctx context.Context, opts TextOptions, h contentHandler) ([]Rect, error) {
 	if ctx.Err() != nil {
-		return Content{}, ctx.Err()
+		return nil, ctx.Err()
 	}
 	strm, err := p.V.Key("Contents")
 	if err != nil {
-		return Content{}, err
+		return nil, err
 	}
 	var enc TextEncoding = &nopEncoder{}
 
 	var g = gstate{
-		Th:  1,
-		CTM: ident,
+		Th:   1,
+		CTM:  ident,
+		Fill: fill{color: []float64{0}},
 	}
 
-	var text []Text
-	showText := func(s string) error {
-		n := 0
-		decoded, err := enc.Decode(ctx, s)
+	disp := Identity
+	if opts.Displayed {
+		disp, err = p.Transform()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		for _, ch := range decoded {
-			var w0 float64
-			if n < len(s) {
-				width, err := g.Tf.Width(int(s[n]))
-				if err != nil {
+	}
+
+	trackClip := opts.ClipBox
+	if trackClip {
+		g.Clip, err = p.CropBox()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The current path is tracked only by its bounding box,
+	// which is all that is needed to approximate clipping.
+	var path Rect
+	var pathEmpty = true
+	var pendingClip bool
+	var segments int
+	addPoint := func(x, y float64) {
+		pt := g.CTM.Matrix().Apply(Point{x, y})
+		if pathEmpty {
+			path = Rect{pt, pt}
+			pathEmpty = false
+			return
+		}
+		path.Min.X = math.Min(path.Min.X, pt.X)
+		path.Min.Y = math.Min(path.Min.Y, pt.Y)
+		path.Max.X = math.Max(path.Max.X, pt.X)
+		path.Max.Y = math.Max(path.Max.Y, pt.Y)
+	}
+	endPath := func(fill, stroke bool) error {
+		if (fill || stroke) && h.path != nil && !pathEmpty {
+			err := h.path(contentPath{disp.ApplyRect(path), segments})
+			if err != nil {
+				return err
+			}
+		}
+		if (fill || stroke) && h.paint != nil && !pathEmpty {
+			var fs, ss Value
+			if fill {
+				fs = g.FillSpace
+			}
+			if stroke {
+				ss = g.StrokeSpace
+			}
+			if err := h.paint(disp.ApplyRect(path), fs, ss); err != nil {
+				return err
+			}
+		}
+		if pendingClip && trackClip {
+			if pathEmpty {
+				g.Clip = Rect{}
+			} else {
+				g.Clip = g.Clip.Intersect(path)
+			}
+		}
+		pendingClip = false
+		pathEmpty = true
+		segments = 0
+		return nil
+	}
+
+	// marks is the stack of open marked-content sequences.
+	// Each entry carries the MCID and artifact state in effect inside it.
+	var marks []markedContent
+	mark := func() markedContent {
+		if len(marks) == 0 {
+			return markedContent{mcid: -1}
+		}
+		return marks[len(marks)-1]
+	}
+
+	// showImage reports the image x, painted in the unit square.
+	showImage := func(x Value) error {
+		box := disp.ApplyRect(g.CTM.Matrix().ApplyRect(Rect{Point{0, 0}, Point{1, 1}}))
+		if h.paint != nil {
+			// A stencil mask is painted in the fill color;
+			// other images carry their own color space.
+			cs := g.FillSpace
+			if mask, err := x.Key("ImageMask"); err != nil {
+				return err
+			} else if !mask.Bool() {
+				if cs, err = x.Key("ColorSpace"); err != nil {
 					return err
 				}
-				w0 = width
+				if cs.Kind() == Name {
+					if cs, err = p.colorSpace(cs); err != nil {
+						return err
+					}
+				}
+			}
+			if err := h.paint(box, cs, Value{}); err != nil {
+				return err
+			}
+		}
+		if h.image == nil {
+			return nil
+		}
+		m := mark()
+		return h.image(contentImage{x, box, m.mcid, m.artifact})
+	}
+
+	showText := func(s string, synthetic bool) error {
+		codes := []fontCode{{s, s}}
+		if !synthetic {
+			var err error
+			codes, err = decodeCodes(ctx, enc, s)
+			if err != nil {
+				return err
+			}
+		}
+		for _, c := range codes {
+			w0, err := g.Tf.codeWidth(enc, c.raw)
+			if err != nil {
+				return err
 			}
-			n++
+			// Word spacing applies only to the single-byte code 32.
+			space := c.raw == " "
 
 			f, err := g.Tf.BaseFont()
 			if err != nil {
@@ -1135,10 +2160,55 @@ func (p Page) Content(ctx context.Context) (Content, error) {
 			}
 
 			Trm := matrix{{g.Tfs * g.Th, 0, 0}, {0, g.Tfs, 0}, {0, g.Trise, 1}}.mul(g.Tm).mul(g.CTM)
-			text = append(text, Text{f, Trm[0][0], Trm[2][0], Trm[2][1], w0 / 1000 * Trm[0][0], string(ch)})
 
 			tx := w0/1000*g.Tfs + g.Tc
+			if space {
+				tx += g.Tw
+			}
 			tx *= g.Th
+			m := g.Tm.mul(g.CTM)
+			origin := disp.Apply(Point{Trm[2][0], Trm[2][1]})
+			adv := Point{tx * m[0][0], tx * m[0][1]}
+			adv = Point{disp[0]*adv.X + disp[2]*adv.Y, disp[1]*adv.X + disp[3]*adv.Y}
+			glyph := Glyph{
+				Font:      f,
+				FontSize:  Trm[0][0],
+				X:         origin.X,
+				Y:         origin.Y,
+				W:         w0 / 1000 * Trm[0][0],
+				DX:        adv.X,
+				DY:        adv.Y,
+				S:         c.text,
+				synthetic: synthetic,
+				mcid:      mark().mcid,
+				artifact:  mark().artifact,
+				fill:      g.Fill,
+			}
+			if trackClip {
+				glyph.ClipBox = disp.ApplyRect(g.Clip)
+			}
+			if h.glyph != nil {
+				err = h.glyph(glyph)
+				if err != nil {
+					return err
+				}
+			}
+			if h.paint != nil && !synthetic {
+				var fs, ss Value
+				switch g.Tmode {
+				case 0, 2, 4, 6:
+					fs = g.FillSpace
+				}
+				switch g.Tmode {
+				case 1, 2, 5, 6:
+					ss = g.StrokeSpace
+				}
+				if g.Tmode != 3 && g.Tmode != 7 {
+					if err := h.paint(glyphRect(glyph), fs, ss); err != nil {
+						return err
+					}
+				}
+			}
 			g.Tm = matrix{{1, 0, 0}, {0, 1, 0}, {tx, 0, 1}}.mul(g.Tm)
 		}
 
@@ -1180,26 +2250,177 @@ func (p Page) Content(ctx context.Context) (Content, error) {
 			// }
 			//}
 
-		case "f": // fill
-		case "g": // setgray
-		case "l": // lineto
-		case "m": // moveto
+		case "m", "l": // moveto, lineto
+			if len(args) != 2 {
+				return fmt.Errorf("bad %s", op)
+			}
+			addPoint(args[0].Float64(), args[1].Float64())
+			if op == "l" {
+				segments++
+			}
+
+		case "c", "v", "y": // curveto
+			for i := 0; i+1 < len(args); i += 2 {
+				addPoint(args[i].Float64(), args[i+1].Float64())
+			}
+			segments++
+
+		case "h": // closepath
+			segments++
+
+		case "W", "W*": // clip
+			pendingClip = true
+
+		case "n": // end path without painting
+			return endPath(false, false)
+
+		case "f", "F", "f*", "S", "s", "B", "B*", "b", "b*": // paint path
+			if op == "s" || op == "b" || op == "b*" {
+				segments++ // implicit closepath
+			}
+			fill := op != "S" && op != "s"
+			stroke := op != "f" && op != "F" && op != "f*"
+			return endPath(fill, stroke)
+
+		case "cs", "CS": // set color space
+			if len(args) != 1 {
+				return fmt.Errorf("bad %s", op)
+			}
+			cs, err := p.colorSpace(args[0])
+			if err != nil {
+				return err
+			}
+			if op == "cs" {
+				// Setting the color space sets its initial color.
+				space := cs
+				if space.IsNull() {
+					space = args[0]
+				}
+				color, err := initialColor(space)
+				if err != nil {
+					return err
+				}
+				g.FillSpace, g.Fill = cs, fill{color: color}
+			} else {
+				g.StrokeSpace = cs
+			}
+
+		case "g", "rg", "k": // set device color non-stroking
+			g.FillSpace, g.Fill = Value{}, fill{color: numbers(args)}
 
-		case "cs": // set colorspace non-stroking
-		case "scn": // set color non-stroking
+		case "sc", "scn": // set color non-stroking
+			g.Fill = fill{color: numbers(args)}
+			if n := len(args); n > 0 && args[n-1].Kind() == Name {
+				g.Fill.pattern = args[n-1].Name()
+			}
+
+		case "G", "RG", "K": // set device color stroking
+			g.StrokeSpace = Value{}
 
 		case "re": // append rectangle to path
 			if len(args) != 4 {
 				return fmt.Errorf("bad re")
 			}
 			x, y, w, h := args[0].Float64(), args[1].Float64(), args[2].Float64(), args[3].Float64()
-			rect = append(rect, Rect{Point{x, y}, Point{x + w, y + h}})
+			rect = append(rect, disp.ApplyRect(Rect{Point{x, y}, Point{x + w, y + h}}))
+			addPoint(x, y)
+			addPoint(x+w, y+h)
+			addPoint(x, y+h)
+			addPoint(x+w, y)
+			segments += 4
 
 		case "q": // save graphics state
 			gstack = append(gstack, g)
 
+		case "BMC", "BDC": // begin marked content
+			if len(args) < 1 {
+				return fmt.Errorf("bad %s", op)
+			}
+			m := mark()
+			if args[0].Name() == "Artifact" {
+				m.artifact = true
+			}
+			if op == "BDC" && len(args) == 2 {
+				props := args[1]
+				if props.Kind() == Name {
+					res, err := p.Resources()
+					if err != nil {
+						return err
+					}
+					all, err := res.Key("Properties")
+					if err != nil {
+						return err
+					}
+					props, err = all.Key(props.Name())
+					if err != nil {
+						return err
+					}
+				}
+				id, err := props.Key("MCID")
+				if err != nil {
+					return err
+				}
+				if id.Kind() == Integer {
+					m.mcid = int(id.Int64())
+				}
+			}
+			marks = append(marks, m)
+
+		case "EMC": // end marked content
+			if len(marks) > 0 {
+				marks = marks[:len(marks)-1]
+			}
+
+		case "Do": // paint XObject
+			if h.image == nil && h.paint == nil {
+				return nil
+			}
+			if len(args) != 1 {
+				return fmt.Errorf("bad Do")
+			}
+			res, err := p.Resources()
+			if err != nil {
+				return err
+			}
+			xobjs, err := res.Key("XObject")
+			if err != nil {
+				return err
+			}
+			x, err := xobjs.Key(args[0].Name())
+			if err != nil {
+				return err
+			}
+			sub, err := x.Key("Subtype")
+			if err != nil {
+				return err
+			}
+			if sub.Name() != "Image" {
+				return nil
+			}
+			return showImage(x)
+
+		case "BI": // inline image
+			if h.image == nil && h.paint == nil {
+				return nil
+			}
+			if len(args) != 2 {
+				return fmt.Errorf("bad BI")
+			}
+			res, err := p.Resources()
+			if err != nil {
+				return err
+			}
+			im, err := inlineImage(p.V.r, Operator{Name: "BI", Args: args[:1], Data: []byte(args[1].RawString())}, res)
+			if err != nil {
+				return err
+			}
+			return showImage(im.V)
+
 		case "Q": // restore graphics state
 			n := len(gstack) - 1
+			if n < 0 {
+				return nil // unbalanced Q
+			}
 			g = gstack[n]
 			gstack = gstack[:n]
 
@@ -1278,7 +2499,7 @@ func (p Page) Content(ctx context.Context) (Content, error) {
 			if len(args) != 1 {
 				return fmt.Errorf("bad Tj operator")
 			}
-			err = showText(args[0].RawString())
+			err = showText(args[0].RawString(), false)
 			if err != nil {
 				return err
 			}
@@ -1291,7 +2512,7 @@ func (p Page) Content(ctx context.Context) (Content, error) {
 					return err
 				}
 				if x.Kind() == String {
-					err = showText(x.RawString())
+					err = showText(x.RawString(), false)
 					if err != nil {
 						return err
 					}
@@ -1300,7 +2521,7 @@ func (p Page) Content(ctx context.Context) (Content, error) {
 					g.Tm = matrix{{1, 0, 0}, {0, 1, 0}, {tx, 0, 1}}.mul(g.Tm)
 				}
 			}
-			err = showText("\n")
+			err = showText("\n", true)
 			if err != nil {
 				return err
 			}
@@ -1351,9 +2572,9 @@ func (p Page) Content(ctx context.Context) (Content, error) {
 		return nil
 	})
 	if err != nil {
-		return Content{}, err
+		return nil, err
 	}
-	return Content{text, rect}, nil
+	return rect, nil
 }
 
 // TextVertical implements sort.Interface for sorting
@@ -1387,10 +2608,24 @@ func (x TextHorizontal) Less(i, j int) bool {
 // An Outline is a tree describing the outline (also known as the table of contents)
 // of a document.
 type Outline struct {
-	Title string    // title for this element
-	Child []Outline // child elements
+	Title  string       // title for this element
+	Action Action       // action performed when the element is activated; Action.V.IsNull() if none
+	Dest   Destination  // destination of the element or of its GoTo action; zero if none
+	Open   bool         // whether the element's children are initially shown
+	Count  int          // number of descendants shown when the element is open, from the magnitude of Count
+	Color  [3]float64   // color of the title, as RGB
+	Style  OutlineStyle // style of the title
+	Child  []Outline    // child elements
 }
 
+// OutlineStyle is the style of an outline element's title (its F entry).
+type OutlineStyle int
+
+const (
+	OutlineItalic OutlineStyle = 1 << 0
+	OutlineBold   OutlineStyle = 1 << 1
+)
+
 // Outline returns the document outline.
 // The Outline returned is the root of the outline tree and typically has no Title itself.
 // That is, the children of the returned root are the top-level entries in the outline.
@@ -1403,18 +2638,72 @@ func (r *Reader) Outline() (Outline, error) {
 	if err != nil {
 		return Outline{}, err
 	}
-	return buildOutline(outlines)
+	return buildOutline(outlines, make(map[objptr]bool))
 }
 
-func buildOutline(entry Value) (Outline, error) {
+func buildOutline(entry Value, seen map[objptr]bool) (Outline, error) {
 	var x Outline
 	title, err := entry.Key("Title")
 	if err != nil {
 		return Outline{}, err
 	}
 	x.Title = title.Text()
-	for child := getKeyValueUnsafe(entry, "First"); child.Kind() == Dict; child = getKeyValueUnsafe(child, "Next") {
-		childOutline, err := buildOutline(child)
+	action, err := entry.Key("A")
+	if err != nil {
+		return Outline{}, err
+	}
+	if action.Kind() == Dict {
+		x.Action = Action{action}
+	}
+	dest, err := entry.Key("Dest")
+	if err != nil {
+		return Outline{}, err
+	}
+	if dest.IsNull() && action.Kind() == Dict {
+		if typ, err := x.Action.Type(); err != nil {
+			return Outline{}, err
+		} else if typ == "GoTo" {
+			if dest, err = x.Action.Dest(); err != nil {
+				return Outline{}, err
+			}
+		}
+	}
+	if !dest.IsNull() {
+		if x.Dest, err = entry.r.Destination(dest); err != nil {
+			return Outline{}, err
+		}
+	}
+	// A positive Count means the item is open and counts its visible
+	// descendants; a negative Count means it is closed.
+	count, err := entry.Key("Count")
+	if err != nil {
+		return Outline{}, err
+	}
+	if c := int(count.Int64()); c > 0 {
+		x.Open = true
+		x.Count = c
+	} else {
+		x.Count = -c
+	}
+	c, err := entry.Key("C")
+	if err != nil {
+		return Outline{}, err
+	}
+	for i := range x.Color {
+		ci, err := c.Index(i)
+		if err != nil {
+			return Outline{}, err
+		}
+		x.Color[i] = ci.Float64()
+	}
+	f, err := entry.Key("F")
+	if err != nil {
+		return Outline{}, err
+	}
+	x.Style = OutlineStyle(f.Int64())
+	for child := getKeyValueUnsafe(entry, "First"); child.Kind() == Dict && !seen[child.ptr]; child = getKeyValueUnsafe(child, "Next") {
+		seen[child.ptr] = true
+		childOutline, err := buildOutline(child, seen)
 		if err != nil {
 			return Outline{}, err
 		}
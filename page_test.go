@@ -0,0 +1,219 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMaxPages(t *testing.T) {
+	ctx := context.Background()
+	opts := &Options{Limits: &Limits{MaxPages: 3}}
+
+	// Five pages, counted correctly, are more than the limit.
+	r := openPDF(t, buildPDF(pageTree(5)...), opts)
+	var limit *LimitError
+	if _, err := r.NumPage(); !errors.As(err, &limit) || limit.Limit != "MaxPages" {
+		t.Errorf("NumPage: got error %v, want MaxPages LimitError", err)
+	}
+	if _, err := r.Page(ctx, 4); !errors.As(err, &limit) {
+		t.Errorf("Page(4): got error %v, want LimitError", err)
+	}
+	n := 0
+	var err error
+	for _, err = range r.Pages(ctx) {
+		if err != nil {
+			break
+		}
+		n++
+	}
+	if !errors.As(err, &limit) || n != 0 {
+		t.Errorf("Pages: got %d pages and error %v, want none and LimitError", n, err)
+	}
+
+	// A tree inflated by its Count is refused before it is walked.
+	objs := pageTree(1)
+	objs[1] = "<< /Type /Pages /Count 1000000000 /Kids [3 0 R] >>"
+	r = openPDF(t, buildPDF(objs...), nil)
+	if _, err := r.NumPage(); !errors.As(err, &limit) || limit.Value != 1000000000 {
+		t.Errorf("inflated NumPage: got error %v, want LimitError for 1000000000 pages", err)
+	}
+	for _, err := range r.Pages(ctx) {
+		if !errors.As(err, &limit) {
+			t.Errorf("inflated Pages: got error %v, want LimitError", err)
+		}
+	}
+
+	// Within the limit, all pages are read.
+	r = openPDF(t, buildPDF(pageTree(3)...), opts)
+	if n, err := r.NumPage(); n != 3 || err != nil {
+		t.Errorf("NumPage = %d, %v, want 3, nil", n, err)
+	}
+}
+
+func TestPageWrongCount(t *testing.T) {
+	ctx := context.Background()
+
+	// The root's Count is right, but the intermediate node under it
+	// claims five pages where it has two.
+	objs := pageTree(3)
+	objs[1] = "<< /Type /Pages /Count 3 /Kids [9 0 R 7 0 R] >>"
+	objs[2] = "<< /Type /Page /Parent 9 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>"
+	objs[4] = "<< /Type /Page /Parent 9 0 R /MediaBox [0 0 612 792] /Contents 6 0 R >>"
+	objs = append(objs, "<< /Type /Pages /Parent 2 0 R /Count 5 /Kids [3 0 R 5 0 R] >>")
+	r := openPDF(t, buildPDF(objs...), nil)
+
+	if n, err := r.NumPage(); n != 3 || err != nil {
+		t.Fatalf("NumPage = %d, %v, want 3, nil", n, err)
+	}
+	var want []Page
+	for p, err := range r.Pages(ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, p)
+	}
+	if len(want) != 3 {
+		t.Fatalf("Pages yielded %d pages, want 3", len(want))
+	}
+	for i, w := range want {
+		p, err := r.Page(ctx, i+1)
+		if err != nil {
+			t.Fatalf("Page(%d): %v", i+1, err)
+		}
+		if p.V.ptr != w.V.ptr {
+			t.Errorf("Page(%d) = object %v, want %v", i+1, p.V.ptr, w.V.ptr)
+		}
+	}
+	if p, err := r.Page(ctx, 3); err != nil || p.V.ptr != (objptr{7, 0}) {
+		t.Errorf("Page(3) = object %v, %v, want 7 0 R", p.V.ptr, err)
+	}
+	if p, err := r.Page(ctx, 4); err != nil || !p.V.IsNull() {
+		t.Errorf("Page(4) = %v, %v, want null page", p.V, err)
+	}
+	if len(r.Problems()) == 0 {
+		t.Errorf("no Problem recorded for the wrong Count")
+	}
+}
+
+func TestPageTreeCycle(t *testing.T) {
+	ctx := context.Background()
+
+	// The root lists itself among its kids.
+	objs := pageTree(2)
+	objs[1] = "<< /Type /Pages /Count 2 /Kids [2 0 R 3 0 R 5 0 R] >>"
+	r := openPDF(t, buildPDF(objs...), nil)
+	if n, err := r.NumPage(); n != 2 || err != nil {
+		t.Errorf("NumPage = %d, %v, want 2, nil", n, err)
+	}
+	n := 0
+	for p, err := range r.Pages(ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if want := (objptr{uint32(1 + 2*n), 0}); p.V.ptr != want {
+			t.Errorf("page %d is object %v, want %v", n, p.V.ptr, want)
+		}
+	}
+	if n != 2 {
+		t.Errorf("Pages yielded %d pages, want 2", n)
+	}
+	rd, err := r.GetPlainText(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "page 1") || !strings.Contains(string(text), "page 2") {
+		t.Errorf("GetPlainText = %q, want the text of both pages", text)
+	}
+}
+
+func TestPageNumbers(t *testing.T) {
+	ctx := context.Background()
+
+	// Node 9, under the root, lists the root among its kids.
+	objs := pageTree(3)
+	objs[1] = "<< /Type /Pages /Count 3 /Kids [9 0 R 7 0 R] >>"
+	objs = append(objs, "<< /Type /Pages /Parent 2 0 R /Count 2 /Kids [2 0 R 3 0 R 5 0 R] >>")
+	r := openPDF(t, buildPDF(objs...), nil)
+	for _, num := range []int{-1, 0, 4} {
+		if p, err := r.Page(ctx, num); err != nil || !p.V.IsNull() {
+			t.Errorf("Page(%d) = %v, %v, want null page", num, p.V, err)
+		}
+	}
+	num := 0
+	for p, err := range r.Pages(ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		num++
+		q, err := r.Page(ctx, num)
+		if err != nil || q.V.ptr != p.V.ptr {
+			t.Errorf("Page(%d) = object %v, %v, want %v", num, q.V.ptr, err, p.V.ptr)
+		}
+	}
+	if num != 3 {
+		t.Errorf("Pages yielded %d pages, want 3", num)
+	}
+	if n, err := r.NumPage(); n != 3 || err != nil {
+		t.Errorf("NumPage = %d, %v, want 3, nil", n, err)
+	}
+}
+
+func TestPageParentCycle(t *testing.T) {
+	// The page is its own Parent, and has no CropBox, Rotate, or
+	// Resources to be found.
+	objs := pageTree(1)
+	objs[2] = "<< /Type /Page /Parent 3 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>"
+	r := openPDF(t, buildPDF(objs...), nil)
+	p, err := r.Page(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if box, err := p.CropBox(); err != nil || box != (Rect{Point{0, 0}, Point{612, 792}}) {
+		t.Errorf("CropBox = %v, %v, want the media box", box, err)
+	}
+	if rot, err := p.Rotate(); err != nil || rot != 0 {
+		t.Errorf("Rotate = %v, %v, want 0", rot, err)
+	}
+	if res, err := p.Resources(); err != nil || !res.IsNull() {
+		t.Errorf("Resources = %v, %v, want null", res, err)
+	}
+	if w, h, err := p.Size(); err != nil || w != 612 || h != 792 {
+		t.Errorf("Size = %v, %v, %v, want 612, 792", w, h, err)
+	}
+}
+
+func TestMergeRunsColor(t *testing.T) {
+	ctx := context.Background()
+	objs := pageTree(1)
+	// Black, black again, red, red again, and then black.
+	objs[3] = streamObj("", "BT /F1 12 Tf 72 720 Td (ab) Tj /DeviceGray cs 0 sc (cd) Tj 1 0 0 rg (ef) Tj 1 0 0 rg (gh) Tj 0 g (ij) Tj ET")
+	r := openPDF(t, buildPDF(objs...), nil)
+	r.TextOptions.MergeRuns = true
+	p, err := r.Page(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Content(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []string
+	for _, text := range c.Text {
+		runs = append(runs, text.S)
+	}
+	if want := []string{"abcd", "efgh", "ij"}; strings.Join(runs, ",") != strings.Join(want, ",") {
+		t.Errorf("merged runs = %q, want %q", runs, want)
+	}
+}
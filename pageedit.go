@@ -0,0 +1,202 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A PageEdit describes changes to the geometry of a page,
+// for Writer.EditPage.
+type PageEdit struct {
+	// Rotate is added to the page's rotation: a multiple of 90 degrees,
+	// clockwise when positive.
+	Rotate int
+
+	// MediaBox and CropBox, if not zero, replace the page's boxes.
+	// The crop box is clipped to the media box.
+	MediaBox, CropBox Rect
+
+	// Margins, if not zero, shrink the crop box by the given amounts
+	// on each side, after any new boxes are set.
+	Margins Margins
+
+	// NormalizeOrigin moves the lower left corner of the media box to
+	// (0, 0), adjusting the other boxes, the page contents, and the
+	// rectangles and quadrilaterals of the annotations to match, so
+	// that the page looks the same.
+	NormalizeOrigin bool
+}
+
+// Margins are distances from the sides of a rectangle.
+type Margins struct {
+	Left, Bottom, Right, Top float64
+}
+
+// EditPage changes the geometry of the page p, read from the Reader
+// being updated by w (see Reader.Update), and writes the updated page.
+// Boxes and rotation inherited from the page tree are written into
+// the page itself. Since the page is written once, all the changes
+// to a page must be made in one call.
+func (w *Writer) EditPage(p Page, edit PageEdit) error {
+	if w.update == nil || p.V.r != w.update || !isIndirect(p.V) {
+		return errors.New("pdf: EditPage requires a page of the Reader being updated")
+	}
+	if edit.Rotate%90 != 0 {
+		return fmt.Errorf("pdf: page rotation %d is not a multiple of 90", edit.Rotate)
+	}
+	entries, err := dictEntries(p.V)
+	if err != nil {
+		return err
+	}
+	rot, err := p.Rotate()
+	if err != nil {
+		return err
+	}
+	media, err := p.MediaBox()
+	if err != nil {
+		return err
+	}
+	crop, err := p.CropBox()
+	if err != nil {
+		return err
+	}
+	if edit.MediaBox != (Rect{}) {
+		media = edit.MediaBox
+	}
+	if edit.CropBox != (Rect{}) {
+		crop = edit.CropBox
+	}
+	m := edit.Margins
+	crop.Min.X += m.Left
+	crop.Min.Y += m.Bottom
+	crop.Max.X -= m.Right
+	crop.Max.Y -= m.Top
+	crop = crop.Intersect(media)
+	if crop == (Rect{}) {
+		return errors.New("pdf: page crop box is empty")
+	}
+
+	if edit.NormalizeOrigin && media.Min != (Point{}) {
+		dx, dy := -media.Min.X, -media.Min.Y
+		media = shiftRect(media, dx, dy)
+		crop = shiftRect(crop, dx, dy)
+		for _, key := range []string{"BleedBox", "TrimBox", "ArtBox"} {
+			box, err := p.V.Key(key)
+			if err != nil {
+				return err
+			}
+			if box.Kind() != Array {
+				continue
+			}
+			r, err := rectFromArray(box)
+			if err != nil {
+				return err
+			}
+			entries[key] = rectValue(shiftRect(r, dx, dy))
+		}
+		// Draw the contents moved by the same amount, in a single
+		// stream, since content arrays are not interpreted by Reader.
+		data := []byte(fmt.Sprintf("1 0 0 1 %g %g cm\n", dx, dy))
+		old := entries["Contents"]
+		switch old.Kind() {
+		case Stream:
+			b, err := streamData(old)
+			if err != nil {
+				return err
+			}
+			data = append(data, b...)
+		case Array:
+			for i := 0; i < old.Len(); i++ {
+				c, err := old.Index(i)
+				if err != nil {
+					return err
+				}
+				b, err := streamData(c)
+				if err != nil {
+					return err
+				}
+				data = append(append(data, b...), '\n')
+			}
+		}
+		entries["Contents"] = NewStream(Value{}, data)
+		if entries["Annots"], err = w.shiftAnnots(entries["Annots"], dx, dy); err != nil {
+			return err
+		}
+	}
+
+	entries["MediaBox"] = rectValue(media)
+	entries["CropBox"] = rectValue(crop)
+	if crop == media {
+		delete(entries, "CropBox")
+	}
+	rot = ((rot+edit.Rotate)%360 + 360) % 360
+	entries["Rotate"] = NewInt(int64(rot))
+	return w.Write(p.V, NewDict(entries))
+}
+
+func shiftRect(r Rect, dx, dy float64) Rect {
+	return Rect{Point{r.Min.X + dx, r.Min.Y + dy}, Point{r.Max.X + dx, r.Max.Y + dy}}
+}
+
+// rectValue returns r as a rectangle array.
+func rectValue(r Rect) Value {
+	return NewArray(NewReal(r.Min.X), NewReal(r.Min.Y), NewReal(r.Max.X), NewReal(r.Max.Y))
+}
+
+// shiftAnnots moves the annotations in the array annots by (dx, dy),
+// rewriting those that are indirect objects and returning the new array.
+func (w *Writer) shiftAnnots(annots Value, dx, dy float64) (Value, error) {
+	if annots.Kind() != Array {
+		return annots, nil
+	}
+	var out []Value
+	for i := 0; i < annots.Len(); i++ {
+		a, err := annots.Index(i)
+		if err != nil {
+			return Value{}, err
+		}
+		if a.Kind() != Dict {
+			continue
+		}
+		entries, err := dictEntries(a)
+		if err != nil {
+			return Value{}, err
+		}
+		if r, ok := entries["Rect"]; ok {
+			rect, err := rectFromArray(r)
+			if err != nil {
+				return Value{}, err
+			}
+			entries["Rect"] = rectValue(shiftRect(rect, dx, dy))
+		}
+		if qp, ok := entries["QuadPoints"]; ok {
+			nums, err := numberArray(qp)
+			if err != nil {
+				return Value{}, err
+			}
+			var shifted []Value
+			for j, x := range nums {
+				if j%2 == 0 {
+					x += dx
+				} else {
+					x += dy
+				}
+				shifted = append(shifted, NewReal(x))
+			}
+			entries["QuadPoints"] = NewArray(shifted...)
+		}
+		v := NewDict(entries)
+		if isIndirect(a) {
+			if err := w.Write(a, v); err != nil {
+				return Value{}, err
+			}
+			v = a
+		}
+		out = append(out, v)
+	}
+	return NewArray(out...), nil
+}
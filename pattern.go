@@ -0,0 +1,314 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "errors"
+
+// A Pattern is a pattern used to paint with the Pattern color space:
+// a tiling pattern, which repeats a tile of content, or a shading
+// pattern, which paints a gradient. See PDF 32000-1:2008, §8.7.
+type Pattern struct {
+	V Value // the pattern dictionary or, for a tiling pattern, stream
+}
+
+// A Shading is a smooth gradient of color, painted by the sh operator
+// or by a shading pattern. See PDF 32000-1:2008, §8.7.4.
+type Shading struct {
+	V Value // the shading dictionary or stream
+}
+
+// A Tile is the repeated cell of a tiling pattern.
+type Tile struct {
+	// Colored reports whether the tile's content specifies its own
+	// colors (PaintType 1); otherwise it is a stencil painted in the
+	// color given with the pattern (PaintType 2).
+	Colored bool
+
+	// Spacing is the TilingType: 1 for constant spacing, 2 for no
+	// distortion, or 3 for constant spacing and faster tiling.
+	Spacing int
+
+	BBox         Rect    // the tile's bounding box, in pattern space
+	XStep, YStep float64 // the spacing of tiles, in pattern space
+}
+
+// Patterns returns the patterns in the page's resources, including
+// those in the resources of the form XObjects and tiling patterns
+// the page uses. Each pattern is returned once.
+func (p Page) Patterns() ([]Pattern, error) {
+	patterns, _, err := p.paints()
+	return patterns, err
+}
+
+// Shadings returns the shadings the page's resources provide for the
+// sh operator and for shading patterns, including those of the form
+// XObjects and tiling patterns the page uses. Each shading is returned
+// once.
+func (p Page) Shadings() ([]Shading, error) {
+	_, shadings, err := p.paints()
+	return shadings, err
+}
+
+// paints returns the patterns and shadings in the page's resources.
+func (p Page) paints() ([]Pattern, []Shading, error) {
+	res, err := p.Resources()
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		patterns []Pattern
+		shadings []Shading
+		seen     = make(map[objptr]bool)
+	)
+	// once reports whether v is to be visited: whether it is not an
+	// object already seen. Direct objects are visited each time.
+	once := func(v Value) bool {
+		if v.Kind() != Stream && !isIndirect(v) {
+			return true
+		}
+		if seen[v.ptr] {
+			return false
+		}
+		seen[v.ptr] = true
+		return true
+	}
+	addShading := func(sh Value) {
+		if k := sh.Kind(); (k == Dict || k == Stream) && once(sh) {
+			shadings = append(shadings, Shading{sh})
+		}
+	}
+	var walk func(res Value) error
+	walk = func(res Value) error {
+		pats, err := res.Key("Pattern")
+		if err != nil {
+			return err
+		}
+		for _, name := range pats.Keys() {
+			pat, err := pats.Key(name)
+			if err != nil {
+				return err
+			}
+			if k := pat.Kind(); k != Dict && k != Stream || !once(pat) {
+				continue
+			}
+			patterns = append(patterns, Pattern{pat})
+			if pat.Kind() == Stream {
+				pres, err := pat.Key("Resources")
+				if err != nil {
+					return err
+				}
+				if err := walk(pres); err != nil {
+					return err
+				}
+				continue
+			}
+			sh, err := pat.Key("Shading")
+			if err != nil {
+				return err
+			}
+			addShading(sh)
+		}
+		shs, err := res.Key("Shading")
+		if err != nil {
+			return err
+		}
+		for _, name := range shs.Keys() {
+			sh, err := shs.Key(name)
+			if err != nil {
+				return err
+			}
+			addShading(sh)
+		}
+		xobjs, err := res.Key("XObject")
+		if err != nil {
+			return err
+		}
+		for _, name := range xobjs.Keys() {
+			x, err := xobjs.Key(name)
+			if err != nil {
+				return err
+			}
+			if x.Kind() != Stream || !once(x) {
+				continue
+			}
+			if sub, err := x.Key("Subtype"); err != nil {
+				return err
+			} else if sub.Name() != "Form" {
+				continue
+			}
+			fres, err := x.Key("Resources")
+			if err != nil {
+				return err
+			}
+			if err := walk(fres); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(res); err != nil {
+		return nil, nil, err
+	}
+	return patterns, shadings, nil
+}
+
+// Type returns the PatternType: 1 for a tiling pattern,
+// or 2 for a shading pattern.
+func (pt Pattern) Type() (int, error) {
+	t, err := pt.V.Key("PatternType")
+	if err != nil {
+		return 0, err
+	}
+	return int(t.Int64()), nil
+}
+
+// Matrix returns the pattern matrix, which maps pattern space to the
+// default coordinate space of the page or form that uses the pattern.
+func (pt Pattern) Matrix() (Matrix, error) {
+	m, err := pt.V.Key("Matrix")
+	if err != nil {
+		return Matrix{}, err
+	}
+	nums, err := numberArray(m)
+	if err != nil {
+		return Matrix{}, err
+	}
+	if len(nums) != 6 {
+		return Identity, nil
+	}
+	return Matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}, nil
+}
+
+// Tile returns the tile of a tiling pattern. Its content is the data
+// of the pattern's stream, V, with the resources of its Resources entry.
+func (pt Pattern) Tile() (Tile, error) {
+	if pt.V.Kind() != Stream {
+		return Tile{}, errors.New("pdf: not a tiling pattern")
+	}
+	var t Tile
+	paint, err := pt.V.Key("PaintType")
+	if err != nil {
+		return Tile{}, err
+	}
+	t.Colored = paint.Int64() != 2
+	spacing, err := pt.V.Key("TilingType")
+	if err != nil {
+		return Tile{}, err
+	}
+	t.Spacing = int(spacing.Int64())
+	bbox, err := pt.V.Key("BBox")
+	if err != nil {
+		return Tile{}, err
+	}
+	if t.BBox, err = rectFromArray(bbox); err != nil {
+		return Tile{}, err
+	}
+	xstep, err := pt.V.Key("XStep")
+	if err != nil {
+		return Tile{}, err
+	}
+	ystep, err := pt.V.Key("YStep")
+	if err != nil {
+		return Tile{}, err
+	}
+	t.XStep, t.YStep = xstep.Float64(), ystep.Float64()
+	return t, nil
+}
+
+// Shading returns the shading of a shading pattern,
+// or a Shading with a null V for a tiling pattern.
+func (pt Pattern) Shading() (Shading, error) {
+	sh, err := pt.V.Key("Shading")
+	if err != nil {
+		return Shading{}, err
+	}
+	return Shading{sh}, nil
+}
+
+// Type returns the ShadingType: 1 for a function-based shading,
+// 2 for axial, 3 for radial, 4 and 5 for triangle meshes,
+// 6 for a Coons patch mesh, or 7 for a tensor-product patch mesh.
+func (sh Shading) Type() (int, error) {
+	t, err := sh.V.Key("ShadingType")
+	if err != nil {
+		return 0, err
+	}
+	return int(t.Int64()), nil
+}
+
+// ColorSpace returns the shading's color space: a name, such as
+// DeviceRGB, or a color space array. Pattern is not allowed.
+func (sh Shading) ColorSpace() (Value, error) {
+	return sh.V.Key("ColorSpace")
+}
+
+// Coords returns the geometry of an axial shading, the starting and
+// ending points [x0 y0 x1 y1] of its axis, or of a radial shading, the
+// centers and radii [x0 y0 r0 x1 y1 r1] of its starting and ending
+// circles, in shading space.
+func (sh Shading) Coords() ([]float64, error) {
+	c, err := sh.V.Key("Coords")
+	if err != nil {
+		return nil, err
+	}
+	return numberArray(c)
+}
+
+// Domain returns the interval of the parameter t of an axial or radial
+// shading, from the starting point or circle to the ending one.
+// It is [0, 1] unless the shading gives another.
+func (sh Shading) Domain() (t0, t1 float64, err error) {
+	d, err := sh.V.Key("Domain")
+	if err != nil {
+		return 0, 0, err
+	}
+	nums, err := numberArray(d)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(nums) != 2 {
+		return 0, 1, nil
+	}
+	return nums[0], nums[1], nil
+}
+
+// Extend reports whether an axial or radial shading extends beyond
+// its starting and ending points or circles.
+func (sh Shading) Extend() (start, end bool, err error) {
+	e, err := sh.V.Key("Extend")
+	if err != nil {
+		return false, false, err
+	}
+	s, err := e.Index(0)
+	if err != nil {
+		return false, false, err
+	}
+	x, err := e.Index(1)
+	if err != nil {
+		return false, false, err
+	}
+	return s.Bool(), x.Bool(), nil
+}
+
+// Color evaluates the shading's Function at the inputs in: the point
+// (x, y) in shading space for a function-based shading, the parameter t
+// for an axial or radial shading, or the parametric value of a vertex of
+// a mesh shading. It returns the components of the color, in the
+// shading's color space. Shadings whose mesh vertices give their
+// colors directly have no Function, and Color returns an error.
+func (sh Shading) Color(in ...float64) ([]float64, error) {
+	fv, err := sh.V.Key("Function")
+	if err != nil {
+		return nil, err
+	}
+	if fv.Kind() == Null {
+		return nil, errors.New("pdf: shading has no function")
+	}
+	f, err := loadFunction(fv, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f.eval(in), nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF returns a file holding objs as objects 1, 2, and so on,
+// with a cross-reference table and a trailer whose Root is object 1.
+func buildPDF(objs ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offs := make([]int, len(objs))
+	for i, obj := range objs {
+		offs[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offs {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return buf.Bytes()
+}
+
+// streamObj returns a stream object with the entries hdr, besides
+// its Length, and the given data.
+func streamObj(hdr, data string) string {
+	return fmt.Sprintf("<< /Length %d %s >>\nstream\n%s\nendstream", len(data), hdr, data)
+}
+
+// pageTree returns the objects of a document with n pages, each
+// showing its number as text, for buildPDF: the catalog, the page
+// tree root, and then each page and its content stream.
+func pageTree(n int) []string {
+	var kids []string
+	for i := 0; i < n; i++ {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 3+2*i))
+	}
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Count %d /Kids [%s] >>", n, strings.Join(kids, " ")),
+	}
+	for i := 0; i < n; i++ {
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >> >>", 4+2*i),
+			streamObj("", fmt.Sprintf("BT /F1 12 Tf 72 720 Td (page %d) Tj ET", i+1)))
+	}
+	return objs
+}
+
+// openPDF opens the file data, failing the test if it cannot.
+func openPDF(t testing.TB, data []byte, opts *Options) *Reader {
+	t.Helper()
+	r, err := NewReaderOptions(bytes.NewReader(data), int64(len(data)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// buildObjStmPDF is like buildPDF, but stores the objects that are not
+// streams in an object stream, object len(objs)+1, and writes a
+// cross-reference stream, object len(objs)+2, in place of the table.
+func buildObjStmPDF(objs ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	size := len(objs) + 3
+	stm := len(objs) + 1
+	type entry struct{ typ, x, y int }
+	entries := make([]entry, size)
+	var index, body bytes.Buffer
+	n := 0
+	for i, obj := range objs {
+		if strings.Contains(obj, "stream\n") {
+			entries[i+1] = entry{1, buf.Len(), 0}
+			fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+			continue
+		}
+		entries[i+1] = entry{2, stm, n}
+		fmt.Fprintf(&index, "%d %d ", i+1, body.Len())
+		fmt.Fprintf(&body, "%s\n", obj)
+		n++
+	}
+	entries[stm] = entry{1, buf.Len(), 0}
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", stm,
+		streamObj(fmt.Sprintf("/Type /ObjStm /N %d /First %d", n, index.Len()), index.String()+body.String()))
+	xref := buf.Len()
+	entries[size-1] = entry{1, xref, 0}
+	var data bytes.Buffer
+	for _, e := range entries {
+		data.Write([]byte{byte(e.typ), byte(e.x >> 24), byte(e.x >> 16), byte(e.x >> 8), byte(e.x), byte(e.y >> 8), byte(e.y)})
+	}
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", size-1,
+		streamObj(fmt.Sprintf("/Type /XRef /Size %d /W [1 4 2] /Root 1 0 R", size), data.String()))
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,718 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A PDFAViolation describes a way in which a document
+// fails to conform to PDF/A.
+type PDFAViolation struct {
+	Page int // page number, starting at 1, or 0 for the document as a whole
+
+	// Rule names the requirement violated:
+	//
+	//	encryption      the document is encrypted
+	//	file-id         the trailer has no file identifier (ID)
+	//	metadata        XMP metadata is missing, malformed, or does not identify PDF/A
+	//	info            the document information dictionary disagrees with the XMP metadata
+	//	output-intent   there is no PDF/A output intent with an ICC profile
+	//	font-embedding  a font is not embedded
+	//	javascript      the document contains JavaScript
+	//	action          the document uses a forbidden action or additional actions (AA)
+	//	transparency    the document uses transparency (PDF/A-1 only)
+	//	embedded-file   the document has embedded files (PDF/A-1 only)
+	Rule string
+
+	Msg string
+}
+
+func (v PDFAViolation) String() string {
+	if v.Page == 0 {
+		return v.Rule + ": " + v.Msg
+	}
+	return fmt.Sprintf("page %d: %s: %s", v.Page, v.Rule, v.Msg)
+}
+
+// CheckPDFA checks the document against the main requirements of
+// PDF/A-1b (ISO 19005-1) or PDF/A-2b (ISO 19005-2), according to part,
+// and returns the violations it finds. If part is 0, CheckPDFA uses
+// the part that the document's XMP metadata claims, or 2 if it claims none.
+//
+// CheckPDFA is not a complete validator: it checks encryption,
+// metadata, output intents, font embedding, actions, and, for PDF/A-1,
+// transparency and embedded files, but not, for example, the syntax of
+// the file or the contents of fonts and ICC profiles. A document without
+// violations may still fail to conform.
+func (r *Reader) CheckPDFA(ctx context.Context, part int) ([]PDFAViolation, error) {
+	c := &pdfaChecker{r: r, part: part, seen: make(map[objptr]bool)}
+	if err := c.document(); err != nil {
+		return nil, err
+	}
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		c.page = num
+		return c.checkPage(p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.out, nil
+}
+
+// A pdfaChecker accumulates the violations found by Reader.CheckPDFA.
+type pdfaChecker struct {
+	r    *Reader
+	part int
+	page int // the page being checked, or 0
+	out  []PDFAViolation
+	seen map[objptr]bool // indirect objects already checked
+}
+
+func (c *pdfaChecker) report(rule, format string, args ...interface{}) {
+	c.out = append(c.out, PDFAViolation{Page: c.page, Rule: rule, Msg: fmt.Sprintf(format, args...)})
+}
+
+// once reports whether v is seen for the first time,
+// so that shared objects are checked and reported once.
+// Direct objects are always new; streams are always indirect.
+func (c *pdfaChecker) once(v Value) bool {
+	if v.Kind() != Stream && !isIndirect(v) {
+		return true
+	}
+	if c.seen[v.ptr] {
+		return false
+	}
+	c.seen[v.ptr] = true
+	return true
+}
+
+// document checks the document-wide requirements.
+func (c *pdfaChecker) document() error {
+	trailer := c.r.Trailer()
+	root, err := trailer.Key("Root")
+	if err != nil {
+		return err
+	}
+	if err := c.metadata(root); err != nil {
+		return err
+	}
+
+	if enc, err := trailer.Key("Encrypt"); err != nil {
+		return err
+	} else if !enc.IsNull() {
+		c.report("encryption", "document is encrypted")
+	}
+	if id, err := trailer.Key("ID"); err != nil {
+		return err
+	} else if id.Kind() != Array || id.Len() != 2 {
+		c.report("file-id", "trailer has no file identifier")
+	}
+
+	if err := c.outputIntents(root); err != nil {
+		return err
+	}
+
+	names, err := root.Key("Names")
+	if err != nil {
+		return err
+	}
+	js, err := names.Key("JavaScript")
+	if err != nil {
+		return err
+	}
+	n := 0
+	if err := walkNameTree(js, func(string, Value) error { n++; return nil }); err != nil {
+		return err
+	}
+	if n > 0 {
+		c.report("javascript", "document has %d document-level JavaScript actions", n)
+	}
+	if c.part == 1 {
+		files, err := names.Key("EmbeddedFiles")
+		if err != nil {
+			return err
+		}
+		n := 0
+		if err := walkNameTree(files, func(string, Value) error { n++; return nil }); err != nil {
+			return err
+		}
+		if n > 0 {
+			c.report("embedded-file", "document has %d embedded files", n)
+		}
+	}
+
+	if err := c.additionalActions(root, "document catalog"); err != nil {
+		return err
+	}
+	open, err := root.Key("OpenAction")
+	if err != nil {
+		return err
+	}
+	if open.Kind() == Dict {
+		if err := c.action(open, "open action"); err != nil {
+			return err
+		}
+	}
+	if err := c.outline(root); err != nil {
+		return err
+	}
+	return c.fields(root)
+}
+
+// metadata checks the document's XMP metadata, and sets c.part
+// from it if the caller left it 0.
+func (c *pdfaChecker) metadata(root Value) error {
+	m, err := root.Key("Metadata")
+	if err != nil {
+		return err
+	}
+	if m.Kind() != Stream {
+		if c.part == 0 {
+			c.part = 2
+		}
+		c.report("metadata", "document has no XMP metadata stream")
+		return nil
+	}
+	if filter, err := m.Key("Filter"); err != nil {
+		return err
+	} else if !filter.IsNull() {
+		c.report("metadata", "metadata stream is compressed")
+	}
+	data, err := streamData(m)
+	if err != nil {
+		if c.part == 0 {
+			c.part = 2
+		}
+		c.report("metadata", "cannot decode metadata stream: %v", err)
+		return nil
+	}
+	x, err := ParseXMP(data)
+	if err != nil {
+		if c.part == 0 {
+			c.part = 2
+		}
+		c.report("metadata", "%v", err)
+		return nil
+	}
+
+	if c.part == 0 {
+		c.part = x.PDFAPart
+		if c.part == 0 {
+			c.part = 2
+		}
+	}
+	switch {
+	case x.PDFAPart == 0:
+		c.report("metadata", "metadata does not identify the PDF/A part (pdfaid:part)")
+	case x.PDFAPart != c.part:
+		c.report("metadata", "metadata claims PDF/A-%d, not PDF/A-%d", x.PDFAPart, c.part)
+	}
+	switch conf := strings.ToUpper(x.PDFAConformance); {
+	case conf == "A", conf == "B", conf == "U" && c.part > 1:
+	case conf == "":
+		c.report("metadata", "metadata does not identify the PDF/A conformance level (pdfaid:conformance)")
+	default:
+		c.report("metadata", "invalid PDF/A conformance level %q", x.PDFAConformance)
+	}
+
+	// Each entry of the information dictionary must match its XMP property.
+	info, err := c.r.Info()
+	if err != nil {
+		return err
+	}
+	if info.V.IsNull() {
+		return nil
+	}
+	text := func(key, xmpName, val, xmpVal string) error {
+		v, err := info.V.Key(key)
+		if err != nil {
+			return err
+		}
+		if !v.IsNull() && val != xmpVal {
+			c.report("info", "%s %q does not match %s %q", key, val, xmpName, xmpVal)
+		}
+		return nil
+	}
+	date := func(key, xmpName string, val, xmpVal time.Time) error {
+		v, err := info.V.Key(key)
+		if err != nil {
+			return err
+		}
+		if !v.IsNull() && !val.Equal(xmpVal) {
+			c.report("info", "%s %s does not match %s", key, v.Text(), xmpName)
+		}
+		return nil
+	}
+	for _, check := range []error{
+		text("Title", "dc:title", info.Title, x.Title),
+		text("Author", "dc:creator", info.Author, strings.Join(x.Creators, ", ")),
+		text("Subject", "dc:description", info.Subject, x.Description),
+		text("Keywords", "pdf:Keywords", info.Keywords, x.Keywords),
+		text("Creator", "xmp:CreatorTool", info.Creator, x.CreatorTool),
+		text("Producer", "pdf:Producer", info.Producer, x.Producer),
+		date("CreationDate", "xmp:CreateDate", info.CreationDate, x.CreateDate),
+		date("ModDate", "xmp:ModifyDate", info.ModDate, x.ModifyDate),
+	} {
+		if check != nil {
+			return check
+		}
+	}
+	return nil
+}
+
+// outputIntents checks for a PDF/A output intent with an ICC profile.
+func (c *pdfaChecker) outputIntents(root Value) error {
+	intents, err := root.Key("OutputIntents")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < intents.Len(); i++ {
+		oi, err := intents.Index(i)
+		if err != nil {
+			return err
+		}
+		s, err := oi.Key("S")
+		if err != nil {
+			return err
+		}
+		if s.Name() != "GTS_PDFA1" {
+			continue
+		}
+		profile, err := oi.Key("DestOutputProfile")
+		if err != nil {
+			return err
+		}
+		if profile.Kind() != Stream {
+			c.report("output-intent", "PDF/A output intent has no ICC profile (DestOutputProfile)")
+		}
+		return nil
+	}
+	c.report("output-intent", "document has no PDF/A output intent (GTS_PDFA1)")
+	return nil
+}
+
+// outline checks the actions of the document outline.
+func (c *pdfaChecker) outline(root Value) error {
+	outlines, err := root.Key("Outlines")
+	if err != nil {
+		return err
+	}
+	item, err := outlines.Key("First")
+	if err != nil {
+		return err
+	}
+	var walk func(item Value) error
+	walk = func(item Value) error {
+		for item.Kind() == Dict && c.once(item) {
+			a, err := item.Key("A")
+			if err != nil {
+				return err
+			}
+			if a.Kind() == Dict {
+				if err := c.action(a, "outline item"); err != nil {
+					return err
+				}
+			}
+			first, err := item.Key("First")
+			if err != nil {
+				return err
+			}
+			if err := walk(first); err != nil {
+				return err
+			}
+			if item, err = item.Key("Next"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(item)
+}
+
+// fields checks the additional actions of the interactive form fields,
+// which need not have widgets on any page.
+func (c *pdfaChecker) fields(root Value) error {
+	form, err := root.Key("AcroForm")
+	if err != nil {
+		return err
+	}
+	fields, err := form.Key("Fields")
+	if err != nil {
+		return err
+	}
+	var walk func(fields Value) error
+	walk = func(fields Value) error {
+		for i := 0; i < fields.Len(); i++ {
+			f, err := fields.Index(i)
+			if err != nil {
+				return err
+			}
+			if f.Kind() != Dict || !c.once(f) {
+				continue
+			}
+			t, err := f.Key("T")
+			if err != nil {
+				return err
+			}
+			if err := c.additionalActions(f, fmt.Sprintf("form field %q", t.Text())); err != nil {
+				return err
+			}
+			kids, err := f.Key("Kids")
+			if err != nil {
+				return err
+			}
+			if err := walk(kids); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(fields)
+}
+
+// pdfaActions lists the action types that PDF/A-1 forbids.
+// PDF/A-2 forbids these and those in pdfa2Actions.
+var pdfaActions = map[string]bool{
+	"Launch":     true,
+	"Sound":      true,
+	"Movie":      true,
+	"ResetForm":  true,
+	"ImportData": true,
+	"JavaScript": true,
+}
+
+var pdfa2Actions = map[string]bool{
+	"Hide":        true,
+	"SetOCGState": true,
+	"Rendition":   true,
+	"Trans":       true,
+	"GoTo3DView":  true,
+}
+
+// action checks the action a, found in the place described by where,
+// and the actions that follow it.
+func (c *pdfaChecker) action(a Value, where string) error {
+	for a.Kind() == Dict && c.once(a) {
+		typ, err := Action{a}.Type()
+		if err != nil {
+			return err
+		}
+		switch {
+		case typ == "JavaScript":
+			c.report("javascript", "%s has a JavaScript action", where)
+		case pdfaActions[typ], c.part > 1 && pdfa2Actions[typ]:
+			c.report("action", "%s has a forbidden %s action", where, typ)
+		case typ == "Named":
+			n, err := a.Key("N")
+			if err != nil {
+				return err
+			}
+			switch n.Name() {
+			case "NextPage", "PrevPage", "FirstPage", "LastPage":
+			default:
+				c.report("action", "%s has a forbidden named action %s", where, n.Name())
+			}
+		}
+		next, err := a.Key("Next")
+		if err != nil {
+			return err
+		}
+		if next.Kind() == Array {
+			for i := 0; i < next.Len(); i++ {
+				x, err := next.Index(i)
+				if err != nil {
+					return err
+				}
+				if err := c.action(x, where); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		a = next
+	}
+	return nil
+}
+
+// additionalActions checks the additional actions (AA) of v, found in
+// the place described by where. Documents, pages, form fields,
+// and widget annotations must have none.
+func (c *pdfaChecker) additionalActions(v Value, where string) error {
+	aa, err := v.Key("AA")
+	if err != nil {
+		return err
+	}
+	if aa.Kind() != Dict {
+		return nil
+	}
+	js := false
+	for _, key := range aa.Keys() {
+		a, err := aa.Key(key)
+		if err != nil {
+			return err
+		}
+		if a.Kind() != Dict {
+			continue
+		}
+		typ, err := Action{a}.Type()
+		if err != nil {
+			return err
+		}
+		if typ == "JavaScript" {
+			js = true
+		}
+	}
+	if js {
+		c.report("javascript", "%s has JavaScript additional actions", where)
+	}
+	c.report("action", "%s has additional actions", where)
+	return nil
+}
+
+func (c *pdfaChecker) checkPage(p Page) error {
+	if err := c.additionalActions(p.V, "page"); err != nil {
+		return err
+	}
+	if c.part == 1 {
+		group, err := p.V.Key("Group")
+		if err != nil {
+			return err
+		}
+		if err := c.group(group, "page"); err != nil {
+			return err
+		}
+	}
+	res, err := p.Resources()
+	if err != nil {
+		return err
+	}
+	if err := c.resources(res); err != nil {
+		return err
+	}
+
+	annots, err := p.Annotations()
+	if err != nil {
+		return err
+	}
+	for _, a := range annots {
+		where := a.Subtype + " annotation"
+		act, err := a.V.Key("A")
+		if err != nil {
+			return err
+		}
+		if act.Kind() == Dict {
+			if err := c.action(act, where); err != nil {
+				return err
+			}
+		}
+		// A widget merged with its form field has been checked already.
+		if a.Subtype == "Widget" && c.once(a.V) {
+			if err := c.additionalActions(a.V, where); err != nil {
+				return err
+			}
+		}
+		if a.Subtype == "FileAttachment" && c.part == 1 {
+			c.report("embedded-file", "page has a file attachment annotation")
+		}
+		// Check the fonts and images of the appearance streams.
+		ap, err := a.V.Key("AP")
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{"N", "R", "D"} {
+			app, err := ap.Key(key)
+			if err != nil {
+				return err
+			}
+			states := []Value{app}
+			if app.Kind() == Dict {
+				states = nil
+				for _, state := range app.Keys() {
+					s, err := app.Key(state)
+					if err != nil {
+						return err
+					}
+					states = append(states, s)
+				}
+			}
+			for _, s := range states {
+				if err := c.xobject(s); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resources checks the fonts, graphics states, and XObjects
+// in the resource dictionary res.
+func (c *pdfaChecker) resources(res Value) error {
+	if res.Kind() != Dict || !c.once(res) {
+		return nil
+	}
+	fonts, err := res.Key("Font")
+	if err != nil {
+		return err
+	}
+	for _, name := range fonts.Keys() {
+		f, err := fonts.Key(name)
+		if err != nil {
+			return err
+		}
+		if f.Kind() != Dict || !c.once(f) {
+			continue
+		}
+		if err := c.font(Font{V: f}, name); err != nil {
+			return err
+		}
+	}
+
+	if c.part == 1 {
+		gstates, err := res.Key("ExtGState")
+		if err != nil {
+			return err
+		}
+		for _, name := range gstates.Keys() {
+			gs, err := gstates.Key(name)
+			if err != nil {
+				return err
+			}
+			if gs.Kind() != Dict || !c.once(gs) {
+				continue
+			}
+			if err := c.extGState(gs, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	xobjs, err := res.Key("XObject")
+	if err != nil {
+		return err
+	}
+	for _, name := range xobjs.Keys() {
+		x, err := xobjs.Key(name)
+		if err != nil {
+			return err
+		}
+		if err := c.xobject(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// font checks that the font f, with the resource name name, is embedded.
+func (c *pdfaChecker) font(f Font, name string) error {
+	if f.subtype() == "Type3" {
+		res, err := f.V.Key("Resources")
+		if err != nil {
+			return err
+		}
+		return c.resources(res)
+	}
+	fp, err := f.Program()
+	if err != nil {
+		return err
+	}
+	if fp.V.IsNull() {
+		base, err := f.BaseFont()
+		if err != nil {
+			return err
+		}
+		c.report("font-embedding", "font %s (%s) is not embedded", name, base)
+	}
+	return nil
+}
+
+// extGState checks the graphics state parameters gs,
+// with the resource name name, for transparency.
+func (c *pdfaChecker) extGState(gs Value, name string) error {
+	smask, err := gs.Key("SMask")
+	if err != nil {
+		return err
+	}
+	if !smask.IsNull() && smask.Name() != "None" {
+		c.report("transparency", "graphics state %s has a soft mask", name)
+	}
+	for _, key := range []string{"CA", "ca"} {
+		a, err := gs.Key(key)
+		if err != nil {
+			return err
+		}
+		if !a.IsNull() && a.Float64() != 1 {
+			c.report("transparency", "graphics state %s has %s %g", name, key, a.Float64())
+		}
+	}
+	bm, err := gs.Key("BM")
+	if err != nil {
+		return err
+	}
+	if bm.Kind() == Array {
+		bm, _ = bm.Index(0)
+	}
+	if !bm.IsNull() && bm.Name() != "Normal" && bm.Name() != "Compatible" {
+		c.report("transparency", "graphics state %s has blend mode %s", name, bm.Name())
+	}
+	return nil
+}
+
+// group checks the transparency group attributes of a page or form.
+func (c *pdfaChecker) group(group Value, where string) error {
+	s, err := group.Key("S")
+	if err != nil {
+		return err
+	}
+	if s.Name() == "Transparency" {
+		c.report("transparency", "%s is a transparency group", where)
+	}
+	return nil
+}
+
+// xobject checks the XObject x: the resources of a form,
+// or the soft mask of an image.
+func (c *pdfaChecker) xobject(x Value) error {
+	if x.Kind() != Stream || !c.once(x) {
+		return nil
+	}
+	sub, err := x.Key("Subtype")
+	if err != nil {
+		return err
+	}
+	switch sub.Name() {
+	case "Form":
+		if c.part == 1 {
+			group, err := x.Key("Group")
+			if err != nil {
+				return err
+			}
+			if err := c.group(group, "form XObject"); err != nil {
+				return err
+			}
+		}
+		res, err := x.Key("Resources")
+		if err != nil {
+			return err
+		}
+		return c.resources(res)
+	case "Image":
+		if c.part > 1 {
+			return nil
+		}
+		smask, err := x.Key("SMask")
+		if err != nil {
+			return err
+		}
+		if smask.Kind() == Stream {
+			c.report("transparency", "image has a soft mask")
+		}
+	}
+	return nil
+}
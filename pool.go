@@ -0,0 +1,118 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Reuse of the memory for reading objects and decoding streams.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Pooled memory that has grown larger than this is dropped rather
+// than kept for reuse, so that one large object or stream does not
+// hold on to memory for good.
+const maxPooled = 1 << 20
+
+// bufferPool holds buffers for reuse, with their read buffer and
+// scratch space for tokens. Reading an object allocates a buffer,
+// and resolving the objects of a document reads thousands of them.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &buffer{buf: make([]byte, 0, 4096)}
+	},
+}
+
+// free returns b to the pool for reuse by newBuffer.
+// Neither b nor its input may be used after.
+func (b *buffer) free() {
+	tmp := b.tmp[:0]
+	if cap(tmp) > maxPooled {
+		tmp = nil
+	}
+	clear(b.unread)
+	*b = buffer{buf: b.buf[:0], tmp: tmp, unread: b.unread[:0]}
+	bufferPool.Put(b)
+}
+
+// zlibReaders holds decompressors for FlateDecode, which each have
+// tens of kilobytes of state, for reuse.
+var zlibReaders sync.Pool
+
+// A flateReader decodes FlateDecode data with a pooled decompressor,
+// returned to the pool by Close.
+type flateReader struct {
+	zr io.ReadCloser
+}
+
+func newFlateReader(rd io.Reader) (*flateReader, error) {
+	if zr, ok := zlibReaders.Get().(io.ReadCloser); ok {
+		if err := zr.(zlib.Resetter).Reset(rd, nil); err != nil {
+			zlibReaders.Put(zr)
+			return nil, err
+		}
+		return &flateReader{zr}, nil
+	}
+	zr, err := zlib.NewReader(rd)
+	if err != nil {
+		return nil, err
+	}
+	return &flateReader{zr}, nil
+}
+
+func (f *flateReader) Read(p []byte) (int, error) {
+	if f.zr == nil {
+		return 0, errors.New("pdf: read from closed stream")
+	}
+	return f.zr.Read(p)
+}
+
+func (f *flateReader) Close() error {
+	if f.zr != nil {
+		zlibReaders.Put(f.zr)
+		f.zr = nil
+	}
+	return nil
+}
+
+// A streamReader is the reader of a stream's decoded data returned by
+// Value.Reader. Closing it releases the pooled decoders it uses.
+type streamReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (s *streamReader) Close() error {
+	for _, c := range s.closers {
+		c.Close()
+	}
+	s.closers = nil
+	return nil
+}
+
+// scratchPool holds buffers for reading the decoded data of streams.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// readAll returns all the data read from rd, like io.ReadAll, growing
+// a pooled buffer as it reads rather than allocating a new one, and
+// copying the data out at its final size.
+func readAll(rd io.Reader) ([]byte, error) {
+	buf := scratchPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooled {
+			buf.Reset()
+			scratchPool.Put(buf)
+		}
+	}()
+	_, err := buf.ReadFrom(rd)
+	return append([]byte{}, buf.Bytes()...), err
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// benchPDF returns a document of n pages whose content streams
+// are compressed with FlateDecode, each drawing 50 lines of text.
+func benchPDF(n int) []byte {
+	objs := pageTree(n)
+	for i := 0; i < n; i++ {
+		var content bytes.Buffer
+		for j := 0; j < 50; j++ {
+			fmt.Fprintf(&content, "BT /F1 12 Tf 72 %d Td (page %d, line %d) Tj ET\n", 720-12*j, i+1, j+1)
+		}
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(content.Bytes())
+		zw.Close()
+		objs[3+2*i] = streamObj("/Filter /FlateDecode", buf.String())
+	}
+	return buildPDF(objs...)
+}
+
+func BenchmarkResolveAll(b *testing.B) {
+	r := openPDF(b, benchPDF(500), nil)
+	table, err := r.xrefTable(allObjects)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for id := 1; id < len(table); id++ {
+			v, err := r.resolve(objptr{}, objptr{uint32(id), 0})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if v.Kind() == Stream {
+				if _, err := streamData(v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPageContent(b *testing.B) {
+	ctx := context.Background()
+	r := openPDF(b, benchPDF(20), nil)
+	var pages []Page
+	for p, err := range r.Pages(ctx) {
+		if err != nil {
+			b.Fatal(err)
+		}
+		pages = append(pages, p)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, p := range pages {
+			if _, err := p.Content(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
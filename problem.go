@@ -0,0 +1,98 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"fmt"
+	"io"
+)
+
+// A Problem describes a defect in a PDF file that the Reader worked around.
+// Problems do not prevent reading the file, but the data read may be
+// incomplete or may differ from what another reader would produce.
+type Problem struct {
+	Offset int64  // file offset of the defect, or -1 if unknown
+	Msg    string // description of the defect and how it was handled
+}
+
+func (p Problem) String() string {
+	if p.Offset < 0 {
+		return p.Msg
+	}
+	return fmt.Sprintf("offset %d: %s", p.Offset, p.Msg)
+}
+
+// Problems returns the problems recorded so far while reading the file.
+// Because values are read lazily, reading more of the document
+// can record more problems.
+func (r *Reader) Problems() []Problem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Problem(nil), r.problems...)
+}
+
+// problem records a problem found at the given file offset.
+// Because objects are parsed each time they are used,
+// the same problem can be found repeatedly; it is recorded once.
+func (r *Reader) problem(offset int64, format string, args ...interface{}) {
+	p := Problem{offset, fmt.Sprintf(format, args...)}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.problemsSeen[p] {
+		return
+	}
+	if r.problemsSeen == nil {
+		r.problemsSeen = make(map[Problem]bool)
+	}
+	r.problemsSeen[p] = true
+	r.problems = append(r.problems, p)
+	if DebugOn {
+		fmt.Printf("pdf: %v\n", p)
+	}
+}
+
+// panicError returns the error reporting the panic p, recovered while
+// reading a file. Malformed files are reported with errors, and the
+// code decoding intricate data, such as filters and font programs,
+// recovers from a panic, which is a bug, rather than letting malformed
+// input crash the program.
+func panicError(p interface{}) error {
+	return fmt.Errorf("pdf: internal error reading malformed data: %v", p)
+}
+
+// catchPanic, when deferred, recovers from a panic, setting *err to
+// the error reporting it.
+func catchPanic(err *error) {
+	if p := recover(); p != nil {
+		*err = panicError(p)
+	}
+}
+
+// A safeReader reads from a decoder, returning a panic in the decoder
+// as an error.
+type safeReader struct {
+	r io.Reader
+}
+
+func (s safeReader) Read(b []byte) (n int, err error) {
+	defer catchPanic(&err)
+	return s.r.Read(b)
+}
+
+// truncatedReader reads a stream whose data was cut short by the end of the file.
+// Decoders report the missing data as io.ErrUnexpectedEOF;
+// truncatedReader turns that into an ordinary end of stream
+// so that the data that is present can still be used.
+type truncatedReader struct {
+	r io.Reader
+}
+
+func (t *truncatedReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
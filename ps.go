@@ -45,6 +45,11 @@ func newDict() Value {
 //
 // Interpret handles the operators "dict", "currentdict", "begin", "end", "def", and "pop" itself.
 //
+// So that content streams can be interpreted, the inline image operator "BI"
+// is read with its parameters and data, through "ID" and "EI": Interpret pushes
+// a dictionary of the parameters, as written, and a string of the data, still
+// encoded, before calling do with op "BI".
+//
 // Interpret is not a full-blown PostScript interpreter. Its job is to handle the
 // very limited PostScript found in certain supporting file formats embedded
 // in PDF files, such as cmap files that describe the mapping from font code
@@ -131,6 +136,17 @@ Reading:
 			case "pop":
 				stk.Pop()
 				continue
+			case "BI":
+				op, err := readInlineImage(b)
+				if err != nil {
+					return err
+				}
+				stk.Push(op.Args[0])
+				stk.Push(Value{nil, objptr{}, string(op.Data)})
+				if err := do(&stk, "BI"); err != nil {
+					return err
+				}
+				continue
 			}
 		}
 		b.unreadToken(tok)
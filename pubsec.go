@@ -0,0 +1,321 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// NewReaderCertificate opens a file for reading, using the data in f with
+// the given total size. If the PDF is encrypted with the public-key security
+// handler (Adobe.PubSec), NewReaderCertificate decrypts it using the private
+// key of the recipient identified by cert; key must be an RSA key.
+// Files encrypted with the standard security handler are opened
+// with the empty user password.
+// See PDF 32000-1:2008, §7.6.4.
+func NewReaderCertificate(f io.ReaderAt, size int64, cert *x509.Certificate, key crypto.Decrypter) (*Reader, error) {
+	r, err := openReader(context.Background(), f, size, Options{})
+	if err != nil {
+		return nil, err
+	}
+	if r.trailer["Encrypt"] == nil {
+		return r, nil
+	}
+	encResolved, err := r.resolve(objptr{}, r.trailer["Encrypt"])
+	if err != nil {
+		return nil, err
+	}
+	encrypt, _ := encResolved.data.(dict)
+	if encrypt["Filter"] != name("Adobe.PubSec") {
+		if err := r.initEncrypt(""); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	if err := r.initPubSec(encrypt, cert, key); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ErrNotRecipient is returned when opening a file encrypted for
+// recipients that do not include the given certificate.
+var ErrNotRecipient = errors.New("encrypted PDF: certificate is not a recipient")
+
+// initPubSec sets up decryption for the public-key security handler.
+func (r *Reader) initPubSec(encrypt dict, cert *x509.Certificate, key crypto.Decrypter) error {
+	V, _ := encrypt["V"].(int64)
+	sub, _ := encrypt["SubFilter"].(name)
+
+	// In the s3 and s4 formats, the Encrypt dictionary lists the
+	// recipients; in s5, the default crypt filter does.
+	recipients := encrypt["Recipients"]
+	bits, _ := encrypt["Length"].(int64)
+	if bits == 0 {
+		bits = 40
+	}
+	useAES := false
+	encryptMetadata := true
+	if sub == "adbe.pkcs7.s5" || V >= 4 {
+		cf, _ := encrypt["CF"].(dict)
+		stmf, _ := encrypt["StmF"].(name)
+		strf, _ := encrypt["StrF"].(name)
+		if stmf != strf && strf != "Identity" {
+			return fmt.Errorf("unsupported PDF: different string and stream crypt filters; %v", objfmt(encrypt))
+		}
+		filter, _ := cf[stmf].(dict)
+		recipients = filter["Recipients"]
+		switch filter["CFM"] {
+		case name("AESV2"):
+			bits, useAES = 128, true
+		case name("AESV3"):
+			bits, useAES = 256, true
+		case name("V2"):
+			if n, ok := filter["Length"].(int64); ok {
+				bits = n
+				if n <= 32 {
+					// Some producers give the length in bytes.
+					bits = 8 * n
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported PDF: crypt filter method %v", objfmt(filter["CFM"]))
+		}
+		if b, ok := filter["EncryptMetadata"].(bool); ok {
+			encryptMetadata = b
+		}
+	} else if b, ok := encrypt["EncryptMetadata"].(bool); ok {
+		encryptMetadata = b
+	}
+	if bits%8 != 0 || bits < 40 || bits > 256 {
+		return fmt.Errorf("malformed PDF: %d-bit encryption key", bits)
+	}
+
+	var list []string
+	switch x := recipients.(type) {
+	case string:
+		list = []string{x}
+	case array:
+		for _, v := range x {
+			v, err := r.resolve(objptr{}, v)
+			if err != nil {
+				return err
+			}
+			if s, ok := v.data.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("malformed PDF: missing encryption Recipients")
+	}
+
+	var seed []byte
+	for _, env := range list {
+		data, err := openEnvelope([]byte(env), cert, key)
+		if err == ErrNotRecipient {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(data) < 24 {
+			return fmt.Errorf("malformed PDF: short encryption seed")
+		}
+		seed = data[:24]
+		break
+	}
+	if seed == nil {
+		return ErrNotRecipient
+	}
+
+	// The file key is a hash of the seed and all the recipients.
+	h := sha1.New()
+	if bits == 256 {
+		h = sha256.New()
+	}
+	h.Write(seed[:20])
+	for _, env := range list {
+		h.Write([]byte(env))
+	}
+	if !encryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	r.key = h.Sum(nil)[:bits/8]
+	r.useAES = useAES
+	// The seed is followed by the recipient's permissions.
+	r.security = Security{
+		Encrypted:   true,
+		Filter:      "Adobe.PubSec",
+		V:           int(V),
+		KeyLength:   int(bits),
+		Permissions: Permissions(binary.BigEndian.Uint32(seed[20:24])),
+	}
+	return nil
+}
+
+// CMS (PKCS #7) enveloped data, as described by RFC 5652.
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+type cmsEnvelopedData struct {
+	Version              int
+	OriginatorInfo       asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo cmsEncryptedContentInfo
+}
+
+type cmsEncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm cmsAlgorithm
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type cmsAlgorithm struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type cmsKeyTransRecipientInfo struct {
+	Version                int
+	RID                    asn1.RawValue // IssuerAndSerialNumber or [0] SubjectKeyIdentifier
+	KeyEncryptionAlgorithm cmsAlgorithm
+	EncryptedKey           []byte
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+var (
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAES         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAOAEP       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	oidDESCBC        = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 7}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// openEnvelope decrypts the content of the CMS enveloped data env
+// using the key of the recipient identified by cert.
+// It returns ErrNotRecipient if cert is not one of the recipients.
+func openEnvelope(env []byte, cert *x509.Certificate, key crypto.Decrypter) ([]byte, error) {
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(env, &ci); err != nil {
+		return nil, fmt.Errorf("malformed PDF: invalid encryption recipient: %v", err)
+	}
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, fmt.Errorf("malformed PDF: encryption recipient is not enveloped data")
+	}
+	var ed cmsEnvelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, fmt.Errorf("malformed PDF: invalid encryption recipient: %v", err)
+	}
+
+	var cek []byte
+	for _, raw := range ed.RecipientInfos {
+		var ri cmsKeyTransRecipientInfo
+		if _, err := asn1.Unmarshal(raw.FullBytes, &ri); err != nil {
+			// Not a key transport recipient.
+			continue
+		}
+		if !recipientMatches(ri.RID, cert) {
+			continue
+		}
+		var err error
+		switch {
+		case ri.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSAES):
+			cek, err = key.Decrypt(nil, ri.EncryptedKey, nil)
+		case ri.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSAOAEP):
+			cek, err = key.Decrypt(nil, ri.EncryptedKey, &rsa.OAEPOptions{Hash: crypto.SHA1})
+		default:
+			return nil, fmt.Errorf("unsupported PDF: key encryption algorithm %v", ri.KeyEncryptionAlgorithm.Algorithm)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encrypted PDF: decrypting content key: %v", err)
+		}
+		break
+	}
+	if cek == nil {
+		return nil, ErrNotRecipient
+	}
+
+	eci := ed.EncryptedContentInfo
+	data := eci.EncryptedContent.Bytes
+	if eci.EncryptedContent.IsCompound {
+		// A constructed octet string: concatenate its segments.
+		var buf []byte
+		rest := data
+		for len(rest) > 0 {
+			var seg asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &seg); err != nil {
+				return nil, fmt.Errorf("malformed PDF: invalid encrypted content: %v", err)
+			}
+			buf = append(buf, seg.Bytes...)
+		}
+		data = buf
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("malformed PDF: invalid content encryption parameters: %v", err)
+	}
+	var block cipher.Block
+	var err error
+	alg := eci.ContentEncryptionAlgorithm.Algorithm
+	switch {
+	case alg.Equal(oidAES128CBC), alg.Equal(oidAES192CBC), alg.Equal(oidAES256CBC):
+		block, err = aes.NewCipher(cek)
+	case alg.Equal(oidDESEDE3CBC):
+		block, err = des.NewTripleDESCipher(cek)
+	case alg.Equal(oidDESCBC):
+		block, err = des.NewCipher(cek)
+	default:
+		return nil, fmt.Errorf("unsupported PDF: content encryption algorithm %v", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encrypted PDF: %v", err)
+	}
+	if len(iv) != block.BlockSize() || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("malformed PDF: invalid encrypted content")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	return unpad(out), nil
+}
+
+// recipientMatches reports whether the recipient identifier rid
+// identifies cert.
+func recipientMatches(rid asn1.RawValue, cert *x509.Certificate) bool {
+	if rid.Class == asn1.ClassContextSpecific && rid.Tag == 0 {
+		return bytes.Equal(rid.Bytes, cert.SubjectKeyId)
+	}
+	var ias cmsIssuerAndSerial
+	if _, err := asn1.Unmarshal(rid.FullBytes, &ias); err != nil {
+		return false
+	}
+	return bytes.Equal(ias.Issuer.FullBytes, cert.RawIssuer) && ias.SerialNumber.Cmp(cert.SerialNumber) == 0
+}
@@ -0,0 +1,427 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Scan conversion of paths for Page.Render.
+
+package pdf
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// A polyline is a flattened subpath.
+type polyline struct {
+	pts    []Point
+	closed bool
+}
+
+// flatten converts the path segments, in pixel coordinates,
+// to polylines, replacing curves by line segments.
+func flatten(segs []PathSegment) []polyline {
+	var out []polyline
+	var cur *polyline
+	var start, pt Point
+	for _, s := range segs {
+		switch s.Op {
+		case 'm':
+			out = append(out, polyline{pts: []Point{s.Pts[0]}})
+			cur = &out[len(out)-1]
+			start, pt = s.Pts[0], s.Pts[0]
+			continue
+		case 'h':
+			if cur != nil {
+				cur.closed = true
+				// A segment after a closepath begins at the same point.
+				out = append(out, polyline{pts: []Point{start}})
+				cur = &out[len(out)-1]
+				pt = start
+			}
+			continue
+		}
+		if cur == nil {
+			// A path must begin with a moveto; tolerate its absence.
+			out = append(out, polyline{pts: []Point{pt}})
+			cur = &out[len(out)-1]
+		}
+		switch s.Op {
+		case 'l':
+			cur.pts = append(cur.pts, s.Pts[0])
+		case 'c':
+			if len(s.Pts) != 3 {
+				continue
+			}
+			p1, p2, p3 := s.Pts[0], s.Pts[1], s.Pts[2]
+			l := dist(pt, p1) + dist(p1, p2) + dist(p2, p3)
+			n := int(math.Sqrt(l*2)) + 1
+			if n > 100 {
+				n = 100
+			}
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+				cur.pts = append(cur.pts, Point{
+					a*pt.X + b*p1.X + c*p2.X + d*p3.X,
+					a*pt.Y + b*p1.Y + c*p2.Y + d*p3.Y,
+				})
+			}
+		}
+		pt = cur.pts[len(cur.pts)-1]
+	}
+	// Drop the empty subpaths left by closepath.
+	keep := out[:0]
+	for _, p := range out {
+		if len(p.pts) > 1 || p.closed {
+			keep = append(keep, p)
+		}
+	}
+	return keep
+}
+
+func dist(p, q Point) float64 {
+	return math.Hypot(q.X-p.X, q.Y-p.Y)
+}
+
+// A canvas is an image being painted.
+type canvas struct {
+	img  *image.RGBA
+	clip image.Rectangle // the pixels that may be painted
+}
+
+// fill paints the area enclosed by the polygons in color c,
+// with the nonzero winding rule or, if evenOdd is set, the even-odd rule.
+// The polygons are implicitly closed.
+func (cv *canvas) fill(polys [][]Point, evenOdd bool, c color.Color) {
+	type edge struct {
+		x0, y0, x1, y1 float64
+		dir            int
+	}
+	var edges []edge
+	bounds := Rect{Point{math.Inf(1), math.Inf(1)}, Point{math.Inf(-1), math.Inf(-1)}}
+	for _, poly := range polys {
+		for i, p := range poly {
+			q := poly[(i+1)%len(poly)]
+			bounds.Min.X = math.Min(bounds.Min.X, p.X)
+			bounds.Min.Y = math.Min(bounds.Min.Y, p.Y)
+			bounds.Max.X = math.Max(bounds.Max.X, p.X)
+			bounds.Max.Y = math.Max(bounds.Max.Y, p.Y)
+			switch {
+			case p.Y < q.Y:
+				edges = append(edges, edge{p.X, p.Y, q.X, q.Y, 1})
+			case p.Y > q.Y:
+				edges = append(edges, edge{q.X, q.Y, p.X, p.Y, -1})
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	area := image.Rect(int(math.Floor(bounds.Min.X)), int(math.Floor(bounds.Min.Y)),
+		int(math.Ceil(bounds.Max.X))+1, int(math.Ceil(bounds.Max.Y))+1).Intersect(cv.clip)
+	if area.Empty() {
+		return
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	// Each pixel row is sampled on several scan lines; along a scan
+	// line, coverage is exact.
+	const samples = 4
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var active []edge
+	var xs []crossing
+	cover := make([]float64, area.Dx())
+	next := 0
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for i := range cover {
+			cover[i] = 0
+		}
+		for s := 0; s < samples; s++ {
+			sy := float64(y) + (float64(s)+0.5)/samples
+			for next < len(edges) && edges[next].y0 <= sy {
+				active = append(active, edges[next])
+				next++
+			}
+			xs = xs[:0]
+			keep := active[:0]
+			for _, e := range active {
+				if e.y1 <= sy {
+					continue
+				}
+				keep = append(keep, e)
+				if e.y0 <= sy {
+					xs = append(xs, crossing{e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), e.dir})
+				}
+			}
+			active = keep
+			sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+			wind := 0
+			for i, x := range xs {
+				if evenOdd {
+					wind ^= 1
+				} else {
+					wind += x.dir
+				}
+				if wind == 0 || i+1 == len(xs) {
+					continue
+				}
+				cv.span(cover, area, x.x, xs[i+1].x, 1.0/samples)
+			}
+		}
+		cv.blendRow(cover, area.Min.X, y, c)
+	}
+}
+
+// span adds weight times the coverage of the span from x0 to x1
+// to the coverage of the pixels of the row area.
+func (cv *canvas) span(cover []float64, area image.Rectangle, x0, x1, weight float64) {
+	x0 = math.Max(x0, float64(area.Min.X))
+	x1 = math.Min(x1, float64(area.Max.X))
+	if x0 >= x1 {
+		return
+	}
+	for px := int(x0); float64(px) < x1; px++ {
+		c := math.Min(float64(px+1), x1) - math.Max(float64(px), x0)
+		cover[px-area.Min.X] += c * weight
+	}
+}
+
+// blendRow paints color c over the row of pixels starting at (x0, y),
+// each with the opacity given by its coverage.
+func (cv *canvas) blendRow(cover []float64, x0, y int, c color.Color) {
+	r, g, b, a := c.RGBA()
+	for i, cov := range cover {
+		if cov <= 0 {
+			continue
+		}
+		if cov > 1 {
+			cov = 1
+		}
+		cv.blend(x0+i, y, r, g, b, a, cov)
+	}
+}
+
+// blend paints the premultiplied color (r, g, b, a) over the pixel
+// at (x, y) with the given opacity.
+func (cv *canvas) blend(x, y int, r, g, b, a uint32, opacity float64) {
+	i := cv.img.PixOffset(x, y)
+	pix := cv.img.Pix[i : i+4 : i+4]
+	k := opacity / 0xffff
+	ka := 1 - float64(a)*k
+	pix[0] = uint8(float64(r)*k*0xff + float64(pix[0])*ka + 0.5)
+	pix[1] = uint8(float64(g)*k*0xff + float64(pix[1])*ka + 0.5)
+	pix[2] = uint8(float64(b)*k*0xff + float64(pix[2])*ka + 0.5)
+	pix[3] = uint8(float64(a)*k*0xff + float64(pix[3])*ka + 0.5)
+}
+
+// A strokeStyle describes how lines are stroked, in pixels.
+type strokeStyle struct {
+	width      float64
+	cap, join  int
+	miterLimit float64
+	dash       []float64
+	dashPhase  float64
+}
+
+// stroke returns the polygons covering the stroked lines,
+// to be filled by the nonzero winding rule.
+func stroke(lines []polyline, st strokeStyle) [][]Point {
+	if len(st.dash) > 0 {
+		lines = dashLines(lines, st.dash, st.dashPhase)
+	}
+	hw := st.width / 2
+	var polys [][]Point
+	add := func(poly ...Point) {
+		// All polygons wind the same way, so that they add up.
+		area := 0.0
+		for i, p := range poly {
+			q := poly[(i+1)%len(poly)]
+			area += p.X*q.Y - q.X*p.Y
+		}
+		if area < 0 {
+			for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+				poly[i], poly[j] = poly[j], poly[i]
+			}
+		}
+		polys = append(polys, poly)
+	}
+	disc := func(c Point) {
+		n := int(hw) + 8
+		if n > 64 {
+			n = 64
+		}
+		poly := make([]Point, n)
+		for i := range poly {
+			t := 2 * math.Pi * float64(i) / float64(n)
+			poly[i] = Point{c.X + hw*math.Cos(t), c.Y + hw*math.Sin(t)}
+		}
+		add(poly...)
+	}
+
+	for _, l := range lines {
+		pts := dedup(l.pts)
+		if len(pts) == 1 {
+			// A degenerate subpath is drawn only with round or square caps.
+			c := pts[0]
+			switch st.cap {
+			case 1:
+				disc(c)
+			case 2:
+				add(Point{c.X - hw, c.Y - hw}, Point{c.X + hw, c.Y - hw}, Point{c.X + hw, c.Y + hw}, Point{c.X - hw, c.Y + hw})
+			}
+			continue
+		}
+		closed := l.closed
+		if closed && pts[0] != pts[len(pts)-1] {
+			pts = append(pts, pts[0])
+		}
+		n := len(pts)
+		for i := 0; i+1 < n; i++ {
+			a, b := pts[i], pts[i+1]
+			dx, dy := (b.X-a.X)/dist(a, b), (b.Y-a.Y)/dist(a, b)
+			if !closed && st.cap == 2 {
+				// Square caps extend the ends by half the line width.
+				if i == 0 {
+					a = Point{a.X - dx*hw, a.Y - dy*hw}
+				}
+				if i+2 == n {
+					b = Point{b.X + dx*hw, b.Y + dy*hw}
+				}
+			}
+			nx, ny := -dy*hw, dx*hw
+			add(Point{a.X + nx, a.Y + ny}, Point{b.X + nx, b.Y + ny}, Point{b.X - nx, b.Y - ny}, Point{a.X - nx, a.Y - ny})
+		}
+		// Joins, at the interior points and the start of a closed line.
+		for i := 1; i < n; i++ {
+			if i == n-1 && !closed {
+				break
+			}
+			prev, v := pts[i-1], pts[i]
+			next := pts[(i+1)%n]
+			if i == n-1 {
+				next = pts[1]
+			}
+			join(v, prev, next, hw, st, add, disc)
+		}
+		if !closed && st.cap == 1 {
+			disc(pts[0])
+			disc(pts[n-1])
+		}
+	}
+	return polys
+}
+
+// join adds the polygons joining the segments prev-v and v-next.
+func join(v, prev, next Point, hw float64, st strokeStyle, add func(...Point), disc func(Point)) {
+	if st.join == 1 {
+		disc(v)
+		return
+	}
+	d1x, d1y := (v.X-prev.X)/dist(prev, v), (v.Y-prev.Y)/dist(prev, v)
+	d2x, d2y := (next.X-v.X)/dist(v, next), (next.Y-v.Y)/dist(v, next)
+	cross := d1x*d2y - d1y*d2x
+	if math.Abs(cross) < 1e-9 {
+		return
+	}
+	// The outer side of the turn.
+	side := -1.0
+	if cross < 0 {
+		side = 1
+	}
+	p1 := Point{v.X - d1y*hw*side, v.Y + d1x*hw*side}
+	p2 := Point{v.X - d2y*hw*side, v.Y + d2x*hw*side}
+	add(v, p1, p2)
+	if st.join == 0 {
+		// The miter length, relative to the line width, is 1/sin(θ/2)
+		// for the angle θ between the segments.
+		cosTheta := -(d1x*d2x + d1y*d2y)
+		sinHalf := math.Sqrt((1 - cosTheta) / 2)
+		if sinHalf > 0 && 1/sinHalf <= st.miterLimit {
+			mx, my := (p1.X+p2.X)/2-v.X, (p1.Y+p2.Y)/2-v.Y
+			ml := math.Hypot(mx, my)
+			if ml > 0 {
+				l := hw / sinHalf
+				tip := Point{v.X + mx/ml*l, v.Y + my/ml*l}
+				add(v, p1, tip, p2)
+			}
+		}
+	}
+}
+
+// dedup returns pts without consecutive duplicate points.
+func dedup(pts []Point) []Point {
+	out := pts[:1:1]
+	for _, p := range pts[1:] {
+		if p != out[len(out)-1] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// dashLines divides lines into the dashes of the dash pattern
+// dash, starting phase into the pattern.
+func dashLines(lines []polyline, dash []float64, phase float64) []polyline {
+	total := 0.0
+	for _, d := range dash {
+		if d < 0 {
+			return lines
+		}
+		total += d
+	}
+	if total <= 0 {
+		return lines
+	}
+	var out []polyline
+	for _, l := range lines {
+		pts := l.pts
+		if l.closed {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+		// Find the dash and the distance into it at the start.
+		i, left := 0, math.Mod(phase, total)
+		for left >= dash[i] {
+			left -= dash[i]
+			i = (i + 1) % len(dash)
+		}
+		left = dash[i] - left
+		on := i%2 == 0
+		var cur []Point
+		if on {
+			cur = []Point{pts[0]}
+		}
+		for j := 0; j+1 < len(pts); j++ {
+			a, b := pts[j], pts[j+1]
+			seg := dist(a, b)
+			pos := 0.0
+			for seg-pos > left {
+				pos += left
+				t := pos / seg
+				p := Point{a.X + (b.X-a.X)*t, a.Y + (b.Y-a.Y)*t}
+				if on {
+					out = append(out, polyline{pts: append(cur, p)})
+					cur = nil
+				} else {
+					cur = []Point{p}
+				}
+				on = !on
+				i = (i + 1) % len(dash)
+				left = dash[i]
+			}
+			left -= seg - pos
+			if on {
+				cur = append(cur, b)
+			}
+		}
+		if on && len(cur) > 1 {
+			out = append(out, polyline{pts: cur})
+		}
+	}
+	return out
+}
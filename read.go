@@ -62,18 +62,18 @@ package pdf
 
 import (
 	"bytes"
-	"compress/zlib"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/md5"
 	"crypto/rc4"
-	"encoding/ascii85"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"sort"
 	"strconv"
+	"sync"
 )
 
 // DebugOn is responsible for logging messages into stdout. If problems arise during reading, set it true.
@@ -86,8 +86,62 @@ type Reader struct {
 	xref       []xref
 	trailer    dict
 	trailerptr objptr
+	startxref  int64
 	key        []byte
 	useAES     bool
+	security   Security
+
+	// Limits bounds the work done on behalf of the document.
+	// It is initialized to the Options' Limits or DefaultLimits and
+	// may be changed by the caller.
+	Limits Limits
+
+	// TextOptions controls text extraction from the document's pages.
+	TextOptions TextOptions
+
+	// Strict makes defects that the Reader would otherwise work around,
+	// recording them as Problems, errors instead.
+	// It is set from Options.Strict when the file is opened.
+	Strict bool
+
+	repairs Repair // the repairs allowed; see Options.Repairs
+
+	mu           sync.Mutex
+	problems     []Problem
+	problemsSeen map[Problem]bool
+	objStms      map[objptr]*objStm   // decoded object streams
+	pageNums     map[objptr]int       // page numbers by page object, built on first use
+	pageCounts   map[objptr]int       // checked page counts of page tree nodes
+	functions    map[objptr]*function // loaded function streams
+
+	// For a linearized file, opened with the cross-reference section
+	// for its first page alone, linear holds its parameters, and
+	// xrefMu guards reading the rest of its cross-reference table.
+	linear *linearization
+	xrefMu sync.Mutex
+
+	// For a file whose cross-reference data is damaged, scan holds
+	// the objects found by scanning the file, and repaired records
+	// that the table was rebuilt from it.
+	scanOnce sync.Once
+	scan     *fileScan
+	repaired bool
+
+	// sequential records that the file was read by NewReaderSequential,
+	// and f holds only its object definitions.
+	sequential bool
+}
+
+// A linearization holds the parameters of a linearized file
+// (see Reader.Linearized).
+type linearization struct {
+	firstPage objptr // the first page's page object
+	pages     int
+	size      int64 // the number of objects, from the trailer's Size
+
+	// mainXref is the offset of the main cross-reference section,
+	// describing the objects after the first page, or 0 once it is read.
+	mainXref int64
 }
 
 type xref struct {
@@ -97,8 +151,16 @@ type xref struct {
 	offset   int64
 }
 
-// Open opens a file for reading.
-func Open(file string) (*os.File, *Reader, error) {
+// Open opens a file for reading, with the given options.
+// Nil options are the zero Options.
+func Open(file string, opts *Options) (*os.File, *Reader, error) {
+	return OpenContext(context.Background(), file, opts)
+}
+
+// OpenContext is like Open but stops opening the file, returning
+// ctx's error, if ctx is done first. Opening a damaged file (see
+// RepairXref) can mean reading all of it.
+func OpenContext(ctx context.Context, file string, opts *Options) (*os.File, *Reader, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		f.Close()
@@ -109,90 +171,206 @@ func Open(file string) (*os.File, *Reader, error) {
 		f.Close()
 		return nil, nil, err
 	}
-	reader, err := NewReader(f, fi.Size())
+	reader, err := NewReaderContext(ctx, f, fi.Size(), opts)
 	return f, reader, err
 }
 
 // NewReader opens a file for reading, using the data in f with the given total size.
 func NewReader(f io.ReaderAt, size int64) (*Reader, error) {
-	return NewReaderEncrypted(f, size, nil)
+	return NewReaderOptions(f, size, nil)
 }
 
 // NewReaderEncrypted opens a file for reading, using the data in f with the given total size.
 // If the PDF is encrypted, NewReaderEncrypted calls pw repeatedly to obtain passwords
 // to try. If pw returns the empty string, NewReaderEncrypted stops trying to decrypt
-// the file and returns an error.
+// the file and returns an error. Each password may be either the user password
+// or the owner password; see Reader.Permissions.
 func NewReaderEncrypted(f io.ReaderAt, size int64, pw func() string) (*Reader, error) {
+	return NewReaderOptions(f, size, &Options{Password: pw})
+}
+
+// NewReaderOptions opens a file for reading, using the data in f with the
+// given total size and the given options. Nil options are the zero Options.
+func NewReaderOptions(f io.ReaderAt, size int64, opts *Options) (*Reader, error) {
+	return NewReaderContext(context.Background(), f, size, opts)
+}
+
+// NewReaderContext is like NewReaderOptions but stops opening the file,
+// returning ctx's error, if ctx is done first.
+func NewReaderContext(ctx context.Context, f io.ReaderAt, size int64, opts *Options) (*Reader, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if opts == nil {
+		opts = new(Options)
+	}
+	r, err := openReader(ctx, f, size, *opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.decrypt(ctx, opts.Password); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// decrypt sets up decryption of an encrypted file, trying the empty
+// password and then those pw returns, as described for NewReaderEncrypted.
+func (r *Reader) decrypt(ctx context.Context, pw func() string) error {
+	if r.trailer["Encrypt"] == nil {
+		return nil
+	}
+	err := r.initEncrypt("")
+	if err == nil {
+		return nil
+	}
+	if pw == nil || err != ErrInvalidPassword {
+		return err
+	}
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		next := pw()
+		if next == "" {
+			break
+		}
+		if r.initEncrypt(next) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// openReader reads the header, cross-reference data, and trailer
+// of the file in f, without setting up decryption.
+func openReader(ctx context.Context, f io.ReaderAt, size int64, opts Options) (r *Reader, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			r, err = nil, panicError(p)
+		}
+	}()
+	r = &Reader{
+		f:       f,
+		end:     size,
+		Limits:  DefaultLimits,
+		Strict:  opts.Strict,
+		repairs: opts.Repairs,
+	}
+	if opts.Limits != nil {
+		r.Limits = *opts.Limits
+	}
 	buf := make([]byte, 10)
 	f.ReadAt(buf, 0)
-	if !bytes.HasPrefix(buf, []byte("%PDF-1.")) || buf[7] < '0' || buf[7] > '7' || buf[8] != '\r' && buf[8] != '\n' {
-		return nil, fmt.Errorf("not a PDF file: invalid header")
+	if !validHeader(buf) {
+		if !r.repair(RepairHeader) || !r.repairHeader() {
+			return nil, fmt.Errorf("not a PDF file: invalid header")
+		}
 	}
-	end := size
+	if ok, err := r.openLinearized(); err != nil {
+		return nil, err
+	} else if ok {
+		return r, nil
+	}
+	if err := r.openXref(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Rebuilding the table would only exceed the limit again.
+		var limit *LimitError
+		if errors.As(err, &limit) || !r.repair(RepairXref) {
+			return nil, err
+		}
+		if err := r.rebuildXref(ctx, err); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// openXref reads the cross-reference table and trailer located by
+// the startxref line at the end of the file.
+func (r *Reader) openXref(ctx context.Context) error {
+	f, end := r.f, r.end
 	const endChunk = 100
-	buf = make([]byte, endChunk)
+	buf := make([]byte, endChunk)
 	f.ReadAt(buf, end-endChunk)
 	for len(buf) > 0 && buf[len(buf)-1] == '\n' || buf[len(buf)-1] == '\r' {
 		buf = buf[:len(buf)-1]
 	}
 	buf = bytes.TrimRight(buf, "\r\n\t ")
 	if !bytes.HasSuffix(buf, []byte("%%EOF")) {
-		return nil, fmt.Errorf("not a PDF file: missing %%%%EOF")
+		return fmt.Errorf("not a PDF file: missing %%%%EOF")
 	}
 	i := findLastLine(buf, "startxref")
 	if i < 0 {
-		return nil, fmt.Errorf("malformed PDF file: missing final startxref")
+		return fmt.Errorf("malformed PDF file: missing final startxref")
 	}
 
-	r := &Reader{
-		f:   f,
-		end: end,
-	}
 	pos := end - endChunk + int64(i)
 	b := newBuffer(io.NewSectionReader(f, pos, end-pos), pos)
+	b.owner = r
 	token, err := b.readToken()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if token != keyword("startxref") {
-		return nil, fmt.Errorf("malformed PDF file: missing startxref")
+		return fmt.Errorf("malformed PDF file: missing startxref")
 	}
 	token, err = b.readToken()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	startxref, ok := token.(int64)
 	if !ok {
-		return nil, fmt.Errorf("malformed PDF file: startxref not followed by integer")
+		return fmt.Errorf("malformed PDF file: startxref not followed by integer")
 	}
 	b = newBuffer(io.NewSectionReader(r.f, startxref, r.end-startxref), startxref)
-	xref, trailerptr, trailer, err := readXref(r, b)
+	b.owner = r
+	xref, trailerptr, trailer, err := readXref(ctx, r, b)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	r.xref = xref
 	r.trailer = trailer
 	r.trailerptr = trailerptr
-	if trailer["Encrypt"] == nil {
-		return r, nil
+	r.startxref = startxref
+	return nil
+}
+
+// validHeader reports whether buf begins with a valid %PDF header line.
+func validHeader(buf []byte) bool {
+	if len(buf) < 9 {
+		return false
 	}
-	err = r.initEncrypt("")
-	if err == nil {
-		return r, nil
+	return (bytes.HasPrefix(buf, []byte("%PDF-1.")) || bytes.HasPrefix(buf, []byte("%PDF-2."))) && '0' <= buf[7] && buf[7] <= '7' && (buf[8] == '\r' || buf[8] == '\n')
+}
+
+// RevisionAt returns a Reader for the document as it was when its first
+// n bytes were written, ignoring any incremental updates appended after.
+// This is the view of the document covered by a signature whose
+// ByteRange ends at offset n.
+// The first n bytes must form a complete revision, ending in %%EOF.
+// The returned Reader shares r's decryption key, limits, and options.
+func (r *Reader) RevisionAt(n int64) (*Reader, error) {
+	if r.sequential {
+		return nil, errSequential
 	}
-	if pw == nil || err != ErrInvalidPassword {
-		return nil, err
+	if n <= 0 || n > r.end {
+		return nil, fmt.Errorf("pdf: revision length %d outside file of %d bytes", n, r.end)
 	}
-	for {
-		next := pw()
-		if next == "" {
-			break
-		}
-		if r.initEncrypt(next) == nil {
-			return r, nil
-		}
+	rev, err := openReader(context.Background(), io.NewSectionReader(r.f, 0, n), n, Options{Strict: r.Strict, Repairs: r.repairs, Limits: &r.Limits})
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+	// Every revision of a document shares the encryption dictionary
+	// and the first file identifier, and so the file key.
+	rev.key = r.key
+	rev.useAES = r.useAES
+	rev.security = r.security
+	rev.Limits = r.Limits
+	rev.TextOptions = r.TextOptions
+	return rev, nil
 }
 
 // Trailer returns the file's Trailer value.
@@ -200,22 +378,199 @@ func (r *Reader) Trailer() Value {
 	return Value{r, r.trailerptr, r.trailer}
 }
 
-func readXref(r *Reader, b *buffer) ([]xref, objptr, dict, error) {
+// linearization returns the linearization parameter dictionary
+// at the start of the file and the offset of the cross-reference
+// section for the first page, which follows it, if the file has one.
+// The file may have been updated since it was linearized.
+func (r *Reader) linearization() (d dict, xref int64, ok bool) {
+	b := newBuffer(io.NewSectionReader(r.f, 0, r.end), 0)
+	b.owner = r
+	defer b.free()
+	obj, err := b.readObject()
+	if err != nil {
+		return nil, 0, false
+	}
+	def, ok := obj.(objdef)
+	if !ok {
+		return nil, 0, false
+	}
+	d, ok = def.obj.(dict)
+	if !ok || d["Linearized"] == nil {
+		return nil, 0, false
+	}
+	// The section starts after the white space ending the object.
+	for i := 0; i < 64 && isSpace(b.readByte()); i++ {
+	}
+	b.unreadByte()
+	return d, b.readOffset(), true
+}
+
+// openLinearized opens the file as a linearized file, reading only
+// the linearization parameter dictionary, at the start of the file,
+// and the cross-reference section for the first page, which follows it.
+// It reports whether the file is linearized: whether the dictionary
+// gives the file's length, which it does not once the file has been
+// updated. See PDF 32000-1:2008, Annex F. The error is a *LimitError
+// if the file has more objects than r.Limits.MaxObjects.
+func (r *Reader) openLinearized() (bool, error) {
+	d, startxref, ok := r.linearization()
+	if !ok || d["L"] != r.end {
+		return false, nil
+	}
+	first, ok1 := d["O"].(int64)
+	pages, ok2 := d["N"].(int64)
+	if !ok1 || !ok2 || first <= 0 || pages <= 0 {
+		return false, nil
+	}
+	b := newBuffer(io.NewSectionReader(r.f, startxref, r.end-startxref), startxref)
+	b.owner = r
+	table, trailerptr, trailer, err := readXrefSection(r, b, nil)
+	if err != nil {
+		return false, nil
+	}
+	prev, _ := trailer["Prev"].(int64)
+	size, _ := trailer["Size"].(int64)
+	if prev <= 0 || prev >= r.end || size < int64(len(table)) {
+		return false, nil
+	}
+	if err := r.checkObject(size - 1); err != nil {
+		return false, err
+	}
+	// The objects after the first page are added by xrefTable.
+	r.xref = table
+	r.trailer = trailer
+	r.trailerptr = trailerptr
+	r.startxref = startxref
+	r.linear = &linearization{
+		firstPage: objptr{uint32(first), 0},
+		pages:     int(pages),
+		size:      size,
+		mainXref:  prev,
+	}
+	return true, nil
+}
+
+// Linearized reports whether the file is linearized (web-optimized),
+// organized so that its first page can be shown before the rest of it
+// is read (see Reader.WriteLinearized). The Reader of a linearized file
+// reads only the start of the file when it is opened, and Page(1) and
+// NumPage use the parameters recorded there. The rest of the file's
+// cross-reference table is read when an object it describes is needed,
+// so, for instance, the first page can be extracted or rendered from a
+// file whose data after the first page is not yet available, provided
+// that its size is known.
+func (r *Reader) Linearized() bool {
+	return r.linear != nil
+}
+
+// allObjects is the object id for which xrefTable returns the whole table.
+const allObjects = ^uint32(0)
+
+// xrefTable returns the cross-reference table, for looking up the
+// object id. If the file was opened as linearized and the object is not
+// among those of the first page, xrefTable first reads the rest of the
+// table, if it has not already. Passing allObjects reads all of it.
+func (r *Reader) xrefTable(id uint32) ([]xref, error) {
+	if r.linear == nil {
+		return r.xref, nil
+	}
+	r.xrefMu.Lock()
+	defer r.xrefMu.Unlock()
+	if r.linear.mainXref == 0 || int(id) < len(r.xref) && r.xref[id].ptr != (objptr{}) {
+		return r.xref, nil
+	}
+	off := r.linear.mainXref
+	b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off)
+	b.owner = r
+	table, _, trailer, err := readXrefSection(r, b, append([]xref(nil), r.xref...))
+	if err != nil {
+		return nil, err
+	}
+	if table, err = readPrevXref(context.Background(), r, table, trailer); err != nil {
+		return nil, err
+	}
+	if int64(len(table)) > r.linear.size {
+		table = table[:r.linear.size]
+	}
+	r.xref = table
+	r.linear.mainXref = 0
+	return r.xref, nil
+}
+
+func readXref(ctx context.Context, r *Reader, b *buffer) ([]xref, objptr, dict, error) {
+	table, trailerptr, trailer, err := readXrefSection(r, b, nil)
+	if err != nil {
+		return nil, objptr{}, nil, err
+	}
+	if table, err = readPrevXref(ctx, r, table, trailer); err != nil {
+		return nil, objptr{}, nil, err
+	}
+	size, ok := trailer[name("Size")].(int64)
+	if !ok {
+		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: trailer missing /Size entry")
+	}
+	if size < int64(len(table)) {
+		table = table[:size]
+	}
+	return table, trailerptr, trailer, nil
+}
+
+// readPrevXref follows the Prev chain from the section whose trailer
+// is given to the cross-reference sections of earlier revisions,
+// adding their entries to table. Entries already in the table, from
+// later revisions, take precedence. A chain may mix tables and streams.
+func readPrevXref(ctx context.Context, r *Reader, table []xref, trailer dict) ([]xref, error) {
+	seen := make(map[int64]bool)
+	for prevoff := trailer["Prev"]; prevoff != nil; {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		off, ok := prevoff.(int64)
+		if !ok {
+			return nil, fmt.Errorf("malformed PDF: xref Prev is not integer: %v", prevoff)
+		}
+		if off < 0 || off >= r.end || seen[off] {
+			return nil, fmt.Errorf("malformed PDF: invalid xref Prev offset %d", off)
+		}
+		seen[off] = true
+		if err := r.checkXrefSections(len(seen) + 1); err != nil {
+			return nil, err
+		}
+		b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off)
+		b.owner = r
+		var prev dict
+		var err error
+		table, _, prev, err = readXrefSection(r, b, table)
+		if err != nil {
+			return nil, err
+		}
+		prevoff = prev["Prev"]
+	}
+	return table, nil
+}
+
+// readXrefSection reads the single cross-reference section at b,
+// either a table or a stream, adding its entries to table.
+// It returns the section's trailer dictionary and, for a stream,
+// the stream's object pointer.
+func readXrefSection(r *Reader, b *buffer, table []xref) ([]xref, objptr, dict, error) {
 	tok, err := b.readToken()
 	if err != nil {
 		return nil, objptr{}, nil, err
 	}
 	if tok == keyword("xref") {
-		return readXrefTable(r, b)
+		return readXrefTable(r, b, table)
 	}
 	if _, ok := tok.(int64); ok {
 		b.unreadToken(tok)
-		return readXrefStream(r, b)
+		return readXrefStream(r, b, table)
 	}
 	return nil, objptr{}, nil, fmt.Errorf("malformed PDF: cross-reference table not found: %v", tok)
 }
 
-func readXrefStream(r *Reader, b *buffer) ([]xref, objptr, dict, error) {
+// readXrefStream reads a cross-reference stream (PDF 32000-1:2008, §7.5.8),
+// adding its entries to table.
+func readXrefStream(r *Reader, b *buffer, table []xref) ([]xref, objptr, dict, error) {
 	obj1, err := b.readObject()
 	if err != nil {
 		return nil, objptr{}, nil, err
@@ -224,7 +579,6 @@ func readXrefStream(r *Reader, b *buffer) ([]xref, objptr, dict, error) {
 	if !ok {
 		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: cross-reference table not found: %v", objfmt(obj1))
 	}
-	strmptr := obj.ptr
 	strm, ok := obj.obj.(stream)
 	if !ok {
 		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: cross-reference table not found: %v", objfmt(obj))
@@ -236,57 +590,11 @@ func readXrefStream(r *Reader, b *buffer) ([]xref, objptr, dict, error) {
 	if !ok {
 		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref stream missing Size")
 	}
-	table := make([]xref, size)
-
 	table, err = readXrefStreamData(r, strm, table, size)
 	if err != nil {
-		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: %v", err)
+		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: %w", err)
 	}
-
-	for prevoff := strm.hdr["Prev"]; prevoff != nil; {
-		off, ok := prevoff.(int64)
-		if !ok {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref Prev is not integer: %v", prevoff)
-		}
-		b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off)
-		obj1, err := b.readObject()
-		if err != nil {
-			return nil, objptr{}, nil, err
-		}
-		obj, ok := obj1.(objdef)
-		if !ok {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref prev stream not found: %v", objfmt(obj1))
-		}
-		prevstrm, ok := obj.obj.(stream)
-		if !ok {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref prev stream not found: %v", objfmt(obj))
-		}
-		prevoff = prevstrm.hdr["Prev"]
-		prev := Value{r, objptr{}, prevstrm}
-		if prev.Kind() != Stream {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref prev stream is not stream: %v", prev)
-		}
-		prevType, err := prev.Key("Type")
-		if err != nil {
-			return nil, objptr{}, nil, err
-		}
-		if prevType.Name() != "XRef" {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref prev stream does not have type XRef")
-		}
-		prevSize, err := prev.Key("Size")
-		if err != nil {
-			return nil, objptr{}, nil, err
-		}
-		psize := prevSize.Int64()
-		if psize > size {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref prev stream larger than last stream")
-		}
-		if table, err = readXrefStreamData(r, prev.data.(stream), table, psize); err != nil {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: reading xref prev stream: %v", err)
-		}
-	}
-
-	return table, strmptr, strm.hdr, nil
+	return table, obj.ptr, strm.hdr, nil
 }
 
 func readXrefStreamData(r *Reader, strm stream, table []xref, size int64) ([]xref, error) {
@@ -343,9 +651,15 @@ func readXrefStreamData(r *Reader, strm stream, table []xref, size int64) ([]xre
 			v2 := decodeInt(buf[w[0] : w[0]+w[1]])
 			v3 := decodeInt(buf[w[0]+w[1] : w[0]+w[1]+w[2]])
 			x := int(start) + i
+			if err := r.checkObject(int64(x)); err != nil {
+				return nil, err
+			}
 			for cap(table) <= x {
 				table = append(table[:cap(table)], xref{})
 			}
+			if len(table) <= x {
+				table = table[:x+1]
+			}
 			if table[x].ptr != (objptr{}) {
 				continue
 			}
@@ -374,12 +688,12 @@ func decodeInt(b []byte) int {
 	return x
 }
 
-func readXrefTable(r *Reader, b *buffer) ([]xref, objptr, dict, error) {
-	var table []xref
-
-	table, err := readXrefTableData(b, table)
+// readXrefTable reads a cross-reference table and its trailer
+// (PDF 32000-1:2008, §7.5.4), adding its entries to table.
+func readXrefTable(r *Reader, b *buffer, table []xref) ([]xref, objptr, dict, error) {
+	table, err := readXrefTableData(r, b, table)
 	if err != nil {
-		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: %v", err)
+		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: %w", err)
 	}
 
 	obj, err := b.readObject()
@@ -391,48 +705,26 @@ func readXrefTable(r *Reader, b *buffer) ([]xref, objptr, dict, error) {
 		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref table not followed by trailer dictionary")
 	}
 
-	for prevoff := trailer["Prev"]; prevoff != nil; {
-		off, ok := prevoff.(int64)
-		if !ok {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref Prev is not integer: %v", prevoff)
+	// In a hybrid-reference file, XRefStm locates a cross-reference stream
+	// listing the objects hidden from readers that only understand tables,
+	// typically those in object streams. Its entries follow the table's
+	// own and precede those of earlier sections (§7.5.8.4).
+	if x, ok := trailer["XRefStm"]; ok {
+		off, ok := x.(int64)
+		if !ok || off < 0 || off >= r.end {
+			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: invalid XRefStm %v", objfmt(x))
 		}
 		b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off)
-		tok, err := b.readToken()
+		b.owner = r
+		table, _, _, err = readXrefStream(r, b, table)
 		if err != nil {
 			return nil, objptr{}, nil, err
 		}
-		if tok != keyword("xref") {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref Prev does not point to xref")
-		}
-		table, err = readXrefTableData(b, table)
-		if err != nil {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: %v", err)
-		}
-
-		obj, err := b.readObject()
-		if err != nil {
-			return nil, objptr{}, nil, err
-		}
-		trailer, ok := obj.(dict)
-		if !ok {
-			return nil, objptr{}, nil, fmt.Errorf("malformed PDF: xref Prev table not followed by trailer dictionary")
-		}
-		prevoff = trailer["Prev"]
-	}
-
-	size, ok := trailer[name("Size")].(int64)
-	if !ok {
-		return nil, objptr{}, nil, fmt.Errorf("malformed PDF: trailer missing /Size entry")
-	}
-
-	if size < int64(len(table)) {
-		table = table[:size]
 	}
-
 	return table, objptr{}, trailer, nil
 }
 
-func readXrefTableData(b *buffer, table []xref) ([]xref, error) {
+func readXrefTableData(r *Reader, b *buffer, table []xref) ([]xref, error) {
 	for {
 		tok, err := b.readToken()
 		if err != nil {
@@ -470,13 +762,16 @@ func readXrefTableData(b *buffer, table []xref) ([]xref, error) {
 				return nil, fmt.Errorf("malformed xref table")
 			}
 			x := int(start) + i
+			if err := r.checkObject(int64(x)); err != nil {
+				return nil, err
+			}
 			for cap(table) <= x {
 				table = append(table[:cap(table)], xref{})
 			}
 			if len(table) <= x {
 				table = table[:x+1]
 			}
-			if alloc == "n" && table[x].offset == 0 {
+			if alloc == "n" && table[x].ptr == (objptr{}) {
 				table[x] = xref{ptr: objptr{uint32(x), uint16(gen)}, offset: int64(off)}
 			}
 		}
@@ -614,6 +909,12 @@ func objfmt(x interface{}) string {
 
 	case objdef:
 		return fmt.Sprintf("{%d %d obj}%v", x.ptr.id, x.ptr.gen, objfmt(x.obj))
+
+	case Value:
+		return objfmt(x.data)
+
+	case writerRef:
+		return fmt.Sprintf("%d 0 R", x.id)
 	}
 }
 
@@ -768,94 +1069,69 @@ func (v Value) Len() int {
 
 func (r *Reader) resolve(parent objptr, x interface{}) (Value, error) {
 	if ptr, ok := x.(objptr); ok {
-		if ptr.id >= uint32(len(r.xref)) {
+		table, err := r.xrefTable(ptr.id)
+		if err != nil {
+			return Value{}, err
+		}
+		if ptr.id >= uint32(len(table)) {
 			return Value{}, nil
 		}
-		xref := r.xref[ptr.id]
+		xref := table[ptr.id]
 		if xref.ptr != ptr || !xref.inStream && xref.offset == 0 {
 			return Value{}, nil
 		}
 		if xref.inStream {
+			// Object streams hold only non-stream objects, so an object stream
+			// cannot itself be compressed. Checking here keeps a corrupt xref
+			// from sending resolve into unbounded recursion.
+			if table, err = r.xrefTable(xref.stream.id); err != nil {
+				return Value{}, err
+			}
+			if id := xref.stream.id; id >= uint32(len(table)) || table[id].inStream {
+				return Value{}, fmt.Errorf("loading %v: invalid object stream %v", ptr, xref.stream)
+			}
 			strm, err := r.resolve(parent, xref.stream)
 			if err != nil {
 				return Value{}, err
 			}
-		Search:
+			seen := make(map[objptr]bool)
 			for {
-				if strm.Kind() != Stream {
-					return Value{}, fmt.Errorf("not a stream")
-				}
-				strmType, err := strm.Key("Type")
-				if err != nil {
-					return Value{}, err
-				}
-				if strmType.Name() != "ObjStm" {
-					return Value{}, fmt.Errorf("not an object stream")
-				}
-				strmN, err := strm.Key("N")
-				if err != nil {
-					return Value{}, err
-				}
-				n := int(strmN.Int64())
-				strmFirst, err := strm.Key("First")
-				if err != nil {
-					return Value{}, err
-				}
-				first := strmFirst.Int64()
-				if first == 0 {
-					return Value{}, fmt.Errorf("missing First")
+				if seen[strm.ptr] {
+					return Value{}, fmt.Errorf("loading %v: object stream Extends loop", ptr)
 				}
-				reader, err := strm.Reader()
+				seen[strm.ptr] = true
+				stm, err := r.objStm(strm)
 				if err != nil {
 					return Value{}, err
 				}
-				b := newBuffer(reader, 0)
-				b.allowEOF = true
-				for i := 0; i < n; i++ {
-					tok, err := b.readToken()
-					if err != nil {
-						return Value{}, err
-					}
-					id, _ := tok.(int64)
-					tok, err = b.readToken()
+				if span, ok := stm.spans[ptr.id]; ok {
+					b := newBuffer(bytes.NewReader(stm.data[span[0]:span[1]]), 0)
+					b.allowEOF = true
+					b.owner = r
+					b.relative = true
+					x, err = b.readObject()
+					b.free()
 					if err != nil {
 						return Value{}, err
 					}
-					off, _ := tok.(int64)
-					if uint32(id) == ptr.id {
-						b.seekForward(first + off)
-						x, err = b.readObject()
-						if err != nil {
-							return Value{}, err
-						}
-						break Search
-					}
-				}
-				strmExt, err := strm.Key("Extends")
-				if err != nil {
-					return Value{}, err
+					break
 				}
-				ext := strmExt
-				if ext.Kind() != Stream {
+				if stm.extends.Kind() != Stream {
 					return Value{}, fmt.Errorf("cannot find object in stream")
 				}
-				strm = ext
+				strm = stm.extends
 			}
 		} else {
-			b := newBuffer(io.NewSectionReader(r.f, xref.offset, r.end-xref.offset), xref.offset)
-			b.key = r.key
-			b.useAES = r.useAES
-			obj, err := b.readObject()
+			def, err := r.readObjdef(ptr, xref)
+			if err != nil && r.repair(RepairObjectOffsets) {
+				// The table may be wrong about where the object is.
+				if x, ok := r.repairedXref(ptr, xref); ok {
+					def, err = r.readObjdef(ptr, x)
+				}
+			}
 			if err != nil {
 				return Value{}, err
 			}
-			def, ok := obj.(objdef)
-			if !ok {
-				return Value{}, fmt.Errorf("loading %v: found %T instead of objdef", ptr, obj)
-			}
-			if def.ptr != ptr {
-				return Value{}, fmt.Errorf("loading %v: found %v", ptr, def.ptr)
-			}
 			x = def.obj
 		}
 		parent = ptr
@@ -866,145 +1142,320 @@ func (r *Reader) resolve(parent objptr, x interface{}) (Value, error) {
 		return Value{r, parent, x}, nil
 	case string:
 		return Value{r, parent, x}, nil
+	case Value:
+		// An element of a dictionary or array built by NewDict or NewArray.
+		return x, nil
 	default:
 		return Value{}, fmt.Errorf("unexpected value type %T in resolve", x)
 	}
 }
 
-// Reader returns the data contained in the stream v.
-// If v.Kind() != Stream, Reader returns a ReadCloser that
-// responds to all reads with a ``stream not present'' error.
-func (v Value) Reader() (io.ReadCloser, error) {
-	x, ok := v.data.(stream)
+// readObjdef reads the definition of the object ptr at the offset given
+// by its cross-reference entry x.
+func (r *Reader) readObjdef(ptr objptr, x xref) (objdef, error) {
+	b := newBuffer(io.NewSectionReader(r.f, x.offset, r.end-x.offset), x.offset)
+	b.owner = r
+	b.key = r.key
+	b.useAES = r.useAES
+	obj, err := b.readObject()
+	b.free()
+	if err != nil {
+		return objdef{}, err
+	}
+	def, ok := obj.(objdef)
 	if !ok {
-		return nil, fmt.Errorf("stream not present")
+		return objdef{}, fmt.Errorf("loading %v: found %T instead of objdef", ptr, obj)
 	}
-	var rd io.Reader
-	vLen, err := v.Key("Length")
+	if def.ptr != ptr {
+		return objdef{}, fmt.Errorf("loading %v: found %v", ptr, def.ptr)
+	}
+	return def, nil
+}
+
+// An objStm is a decoded object stream (PDF 32000-1:2008, §7.5.7).
+type objStm struct {
+	data    []byte
+	spans   map[uint32][2]int64 // object number to start and end offset in data
+	extends Value               // the object stream this one extends, if any
+}
+
+// objStm returns the decoded object stream strm. Decoding an object stream
+// means decompressing all of it, so decoded streams are cached for reuse
+// by the other objects stored in them.
+func (r *Reader) objStm(strm Value) (*objStm, error) {
+	r.mu.Lock()
+	stm := r.objStms[strm.ptr]
+	r.mu.Unlock()
+	if stm != nil {
+		return stm, nil
+	}
+
+	if strm.Kind() != Stream {
+		return nil, fmt.Errorf("not a stream")
+	}
+	strmType, err := strm.Key("Type")
 	if err != nil {
 		return nil, err
 	}
-	rd = io.NewSectionReader(v.r.f, x.offset, vLen.Int64())
-	if v.r.key != nil {
-		rd, err = decryptStream(v.r.key, v.r.useAES, x.ptr, rd)
+	if strmType.Name() != "ObjStm" {
+		return nil, fmt.Errorf("not an object stream")
+	}
+	strmN, err := strm.Key("N")
+	if err != nil {
+		return nil, err
+	}
+	n := int(strmN.Int64())
+	strmFirst, err := strm.Key("First")
+	if err != nil {
+		return nil, err
+	}
+	first := strmFirst.Int64()
+	if first == 0 {
+		return nil, fmt.Errorf("missing First")
+	}
+	ext, err := strm.Key("Extends")
+	if err != nil {
+		return nil, err
+	}
+	data, err := streamData(strm)
+	if err != nil {
+		return nil, err
+	}
+	if first < 0 || first > int64(len(data)) {
+		return nil, fmt.Errorf("object stream First %d outside stream of %d bytes", first, len(data))
+	}
+
+	// The header is N pairs of object number and offset relative to First.
+	// Each object ends where the next one in the data begins.
+	b := newBuffer(bytes.NewReader(data[:first]), 0)
+	defer b.free()
+	b.allowEOF = true
+	type entry struct {
+		id  uint32
+		off int64
+	}
+	var entries []entry
+	for i := 0; i < n; i++ {
+		tok, err := b.readToken()
+		if err != nil {
+			return nil, err
+		}
+		id, ok1 := tok.(int64)
+		tok, err = b.readToken()
 		if err != nil {
 			return nil, err
 		}
+		off, ok2 := tok.(int64)
+		if !ok1 || !ok2 {
+			break
+		}
+		if off < 0 || first+off > int64(len(data)) {
+			return nil, fmt.Errorf("object stream offset %d outside stream of %d bytes", off, len(data))
+		}
+		entries = append(entries, entry{uint32(id), first + off})
+	}
+	ends := make([]int64, len(entries))
+	for i := range ends {
+		ends[i] = int64(len(data))
+	}
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return entries[order[i]].off < entries[order[j]].off
+	})
+	for k := 0; k+1 < len(order); k++ {
+		ends[order[k]] = entries[order[k+1]].off
+	}
+	stm = &objStm{data: data, spans: make(map[uint32][2]int64), extends: ext}
+	for i, e := range entries {
+		if _, dup := stm.spans[e.id]; !dup {
+			stm.spans[e.id] = [2]int64{e.off, ends[i]}
+		}
+	}
+
+	r.mu.Lock()
+	if r.objStms == nil {
+		r.objStms = make(map[objptr]*objStm)
+	}
+	r.objStms[strm.ptr] = stm
+	r.mu.Unlock()
+	return stm, nil
+}
+
+// Reader returns the data contained in the stream v.
+// If v.Kind() != Stream, Reader returns a ReadCloser that
+// responds to all reads with a ``stream not present'' error.
+// Closing the ReadCloser, after which it cannot be read, lets the
+// memory used to decode the data be reused.
+// The data of JPEG (DCTDecode), CCITT fax (CCITTFaxDecode), and JBIG2
+// (JBIG2Decode) images is decoded to its samples; Image.Encoded returns
+// the encoded data itself.
+func (v Value) Reader() (io.ReadCloser, error) {
+	rd, _, err := v.reader(nil)
+	return rd, err
+}
+
+// reader returns the data contained in the stream v with its filters applied,
+// except that if keep reports true for the last filter, that filter is
+// not applied and its name is returned instead. This lets callers pass
+// through data in formats such as JPEG that they handle themselves.
+func (v Value) reader(keep func(filter string) bool) (io.ReadCloser, string, error) {
+	rd, truncated, err := v.rawReader()
+	if err != nil {
+		return nil, "", err
 	}
 	filter, err := v.Key("Filter")
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	param, err := v.Key("DecodeParms")
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	var (
+		kept    string
+		closers []io.Closer
+	)
 	switch filter.Kind() {
 	default:
-		return nil, fmt.Errorf("unsupported filter %v", filter)
+		return nil, "", fmt.Errorf("unsupported filter %v", filter)
 	case Null:
 		// ok
 	case Name:
-		rd, err = applyFilter(rd, filter.Name(), param)
+		if keep != nil && keep(filter.Name()) {
+			kept = filter.Name()
+			break
+		}
+		rd, err = applyFilter(rd, filter.Name(), param, &closers)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	case Array:
 		for i := 0; i < filter.Len(); i++ {
 			filterIdx, err := filter.Index(i)
 			if err != nil {
-				return nil, err
+				return nil, "", err
+			}
+			if i == filter.Len()-1 && keep != nil && keep(filterIdx.Name()) {
+				kept = filterIdx.Name()
+				break
 			}
 			paramIdx, err := param.Index(i)
 			if err != nil {
-				return nil, err
+				return nil, "", err
 			}
-			rd, err = applyFilter(rd, filterIdx.Name(), paramIdx)
+			rd, err = applyFilter(rd, filterIdx.Name(), paramIdx, &closers)
 			if err != nil {
-				return nil, err
+				return nil, "", err
 			}
 		}
 	}
 
-	return ioutil.NopCloser(rd), nil
+	if truncated {
+		rd = &truncatedReader{rd}
+	}
+	if v.r != nil && v.r.Limits.MaxStreamSize > 0 {
+		rd = &streamLimitReader{r: rd, max: v.r.Limits.MaxStreamSize}
+	}
+	return &streamReader{rd, closers}, kept, nil
 }
 
-func applyFilter(rd io.Reader, name string, param Value) (io.Reader, error) {
-	switch name {
-	default:
-		return nil, fmt.Errorf("unknown filter " + name)
-	case "FlateDecode":
-		zr, err := zlib.NewReader(rd)
-		if err != nil {
-			return nil, err
-		}
-		pred, err := param.Key("Predictor")
-		if err != nil {
-			return nil, err
+// rawReader returns the data of the stream v as stored in the file,
+// decrypted but not decoded by its filters.
+// It reports whether the data was cut short by the end of the file.
+func (v Value) rawReader() (rd io.Reader, truncated bool, err error) {
+	x, ok := v.data.(stream)
+	if !ok {
+		return nil, false, fmt.Errorf("stream not present")
+	}
+	if x.data != nil {
+		return bytes.NewReader(x.data), false, nil
+	}
+	vLen, err := v.Key("Length")
+	if err != nil {
+		return nil, false, err
+	}
+	length := vLen.Int64()
+	if length < 0 || x.offset+length > v.r.end {
+		if !v.r.repair(RepairTruncatedStreams) {
+			return nil, false, fmt.Errorf("stream %v Length %d extends past end of file", x.ptr, length)
 		}
-		if pred.Kind() == Null {
-			return zr, nil
+		// A truncated download leaves streams running past the end of the file.
+		// Use the data that is there rather than failing.
+		v.r.problem(x.offset, "stream %v Length %d extends past end of file; truncated", x.ptr, length)
+		length = v.r.end - x.offset
+		if length < 0 {
+			length = 0
 		}
-		colParam, err := param.Key("Columns")
+		truncated = true
+	}
+	rd = io.NewSectionReader(v.r.f, x.offset, length)
+	if v.r.key != nil {
+		rd, err = decryptStream(v.r.key, v.r.useAES, x.ptr, rd)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		columns := colParam.Int64()
-		switch pred.Int64() {
-		default:
-			if DebugOn {
-				fmt.Println("unknown predictor", pred)
-			}
-			return nil, fmt.Errorf("unknown predictor")
-		case 12:
-			return &pngUpReader{r: zr, hist: make([]byte, 1+columns), tmp: make([]byte, 1+columns)}, nil
-		}
-	case "ASCII85Decode":
-		cleanASCII85 := newAlphaReader(rd)
-		decoder := ascii85.NewDecoder(cleanASCII85)
+	}
+	return rd, truncated, nil
+}
 
-		switch param.Keys() {
-		default:
-			if DebugOn {
-				fmt.Println("param=", param)
-			}
-			return nil, fmt.Errorf("not expected DecodeParms for ascii85")
-		case nil:
-			return decoder, nil
-		}
+// streamData returns the decoded contents of the stream v.
+func streamData(v Value) ([]byte, error) {
+	rd, err := v.Reader()
+	if err != nil {
+		return nil, err
 	}
+	defer rd.Close()
+	return readAll(rd)
 }
 
-type pngUpReader struct {
-	r    io.Reader
-	hist []byte
-	tmp  []byte
-	pend []byte
+// applyFilter returns a reader decoding the data read from rd with the
+// named filter. The decoders parse untrusted data, so a panic in one,
+// which is a bug, is returned as an error.
+func applyFilter(rd io.Reader, name string, param Value, closers *[]io.Closer) (out io.Reader, err error) {
+	defer catchPanic(&err)
+	out, err = filterReader(rd, name, param, closers)
+	if err != nil {
+		return nil, err
+	}
+	return safeReader{out}, nil
 }
 
-func (r *pngUpReader) Read(b []byte) (int, error) {
-	n := 0
-	for len(b) > 0 {
-		if len(r.pend) > 0 {
-			m := copy(b, r.pend)
-			n += m
-			b = b[m:]
-			r.pend = r.pend[m:]
-			continue
-		}
-		_, err := io.ReadFull(r.r, r.tmp)
+// filterReader returns a reader decoding the data read from rd with
+// the named filter. Decoders to close once the data is read, which
+// releases pooled memory, are added to closers.
+func filterReader(rd io.Reader, name string, param Value, closers *[]io.Closer) (io.Reader, error) {
+	switch name {
+	default:
+		return nil, fmt.Errorf("unknown filter " + name)
+	case "FlateDecode":
+		zr, err := newFlateReader(rd)
 		if err != nil {
-			return n, err
-		}
-		if r.tmp[0] != 2 {
-			return n, fmt.Errorf("malformed PNG-Up encoding")
+			return nil, err
 		}
-		for i, b := range r.tmp {
-			r.hist[i] += b
+		*closers = append(*closers, zr)
+		return applyPredictor(zr, param)
+	case "LZWDecode":
+		early, err := param.Key("EarlyChange")
+		if err != nil {
+			return nil, err
 		}
-		r.pend = r.hist[1:]
+		return applyPredictor(newLZWReader(rd, early.Kind() != Integer || early.Int64() != 0), param)
+	case "DCTDecode":
+		return dctDecode(rd)
+	case "CCITTFaxDecode":
+		return ccittDecode(rd, param)
+	case "JBIG2Decode":
+		return jbig2Decode(rd, param)
+	case "RunLengthDecode":
+		return newRunLengthReader(rd), nil
+	case "ASCII85Decode":
+		return newASCII85Reader(rd), nil
+	case "ASCIIHexDecode":
+		return newASCIIHexReader(rd), nil
 	}
-	return n, nil
 }
 
 var passwordPad = []byte{
@@ -1022,6 +1473,9 @@ func (r *Reader) initEncrypt(password string) error {
 	if encrypt["Filter"] != name("Standard") {
 		return fmt.Errorf("unsupported PDF: encryption filter %v", objfmt(encrypt["Filter"]))
 	}
+	if V, _ := encrypt["V"].(int64); V == 5 {
+		return r.initEncryptV5(encrypt, password)
+	}
 	n, _ := encrypt["Length"].(int64)
 	if n == 0 {
 		n = 40
@@ -1060,64 +1514,34 @@ func (r *Reader) initEncrypt(password string) error {
 	P := uint32(p)
 
 	// TODO: Password should be converted to Latin-1.
-	pw := []byte(password)
-	h := md5.New()
-	if len(pw) >= 32 {
-		h.Write(pw[:32])
-	} else {
-		h.Write(pw)
-		h.Write(passwordPad[:32-len(pw)])
-	}
-	h.Write([]byte(O))
-	h.Write([]byte{byte(P), byte(P >> 8), byte(P >> 16), byte(P >> 24)})
-	h.Write([]byte(ID))
-	key := h.Sum(nil)
-
-	if R >= 3 {
-		for i := 0; i < 50; i++ {
-			h.Reset()
-			h.Write(key[:n/8])
-			key = h.Sum(key[:0])
+	encryptMetadata := true
+	if b, ok := encrypt["EncryptMetadata"].(bool); ok && R >= 4 {
+		encryptMetadata = b
+	}
+	// Try password as the user password, then as the owner password,
+	// which decrypts O to the user password (algorithm 7).
+	owner := false
+	key := legacyFileKey([]byte(password), O, P, ID, R, int(n/8), encryptMetadata)
+	if !bytes.HasPrefix([]byte(U), legacyUserHash(key, ID, R)) {
+		user := legacyUserPassword([]byte(password), O, R, int(n/8))
+		key = legacyFileKey(user, O, P, ID, R, int(n/8), encryptMetadata)
+		if !bytes.HasPrefix([]byte(U), legacyUserHash(key, ID, R)) {
+			return ErrInvalidPassword
 		}
-		key = key[:n/8]
-	} else {
-		key = key[:40/8]
-	}
-
-	c, err := rc4.NewCipher(key)
-	if err != nil {
-		return fmt.Errorf("malformed PDF: invalid RC4 key: %v", err)
-	}
-
-	var u []byte
-	if R == 2 {
-		u = make([]byte, 32)
-		copy(u, passwordPad)
-		c.XORKeyStream(u, u)
-	} else {
-		h.Reset()
-		h.Write(passwordPad)
-		h.Write([]byte(ID))
-		u = h.Sum(nil)
-		c.XORKeyStream(u, u)
-
-		for i := 1; i <= 19; i++ {
-			key1 := make([]byte, len(key))
-			copy(key1, key)
-			for j := range key1 {
-				key1[j] ^= byte(i)
-			}
-			c, _ = rc4.NewCipher(key1)
-			c.XORKeyStream(u, u)
-		}
-	}
-
-	if !bytes.HasPrefix([]byte(U), u) {
-		return ErrInvalidPassword
+		owner = true
 	}
 
 	r.key = key
 	r.useAES = V == 4
+	r.security = Security{
+		Encrypted:   true,
+		Filter:      "Standard",
+		V:           int(V),
+		R:           int(R),
+		KeyLength:   8 * len(key),
+		Permissions: Permissions(P),
+		Owner:       owner,
+	}
 
 	return nil
 }
@@ -1154,6 +1578,10 @@ func okayV4(encrypt dict) bool {
 }
 
 func cryptKey(key []byte, useAES bool, ptr objptr) []byte {
+	if len(key) == 32 {
+		// AES-256 (AESV3) uses the file key for every object.
+		return key
+	}
 	h := md5.New()
 	h.Write(key)
 	h.Write([]byte{byte(ptr.id), byte(ptr.id >> 8), byte(ptr.id >> 16), byte(ptr.gen), byte(ptr.gen >> 8)})
@@ -1175,9 +1603,13 @@ func decryptString(key []byte, useAES bool, ptr objptr, x string) (string, error
 		iv := s[:aes.BlockSize]
 		s = s[aes.BlockSize:]
 
+		if len(s)%aes.BlockSize != 0 {
+			return "", fmt.Errorf("Encrypted text not a multiple of AES block size")
+		}
+
 		stream := cipher.NewCBCDecrypter(block, iv)
 		stream.CryptBlocks(s, s)
-		x = string(s)
+		x = string(unpad(s))
 	} else {
 		c, _ := rc4.NewCipher(key)
 		data := []byte(x)
@@ -1208,20 +1640,54 @@ func decryptStream(key []byte, useAES bool, ptr objptr, rd io.Reader) (io.Reader
 type cbcReader struct {
 	cbc  cipher.BlockMode
 	rd   io.Reader
-	buf  []byte
+	buf  []byte // the next block, read ahead to find the last
+	next []byte
 	pend []byte
+	err  error
 }
 
 func (r *cbcReader) Read(b []byte) (n int, err error) {
-	if len(r.pend) == 0 {
-		_, err = io.ReadFull(r.rd, r.buf)
-		if err != nil {
-			return 0, err
+	for len(r.pend) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.next == nil {
+			r.next = make([]byte, len(r.buf))
+			if _, err := io.ReadFull(r.rd, r.next); err != nil {
+				return 0, err
+			}
 		}
-		r.cbc.CryptBlocks(r.buf, r.buf)
+		r.cbc.CryptBlocks(r.next, r.next)
+		r.buf, r.next = r.next, r.buf
 		r.pend = r.buf
+		if _, err := io.ReadFull(r.rd, r.next); err != nil {
+			// The last block ends with padding.
+			r.pend = unpad(r.buf)
+			r.err = err
+			if err == io.ErrUnexpectedEOF {
+				r.err = io.EOF
+			}
+		}
 	}
 	n = copy(b, r.pend)
 	r.pend = r.pend[n:]
 	return n, nil
 }
+
+// unpad removes the PKCS#5 padding from the decrypted data b,
+// if it is well-formed.
+func unpad(b []byte) []byte {
+	if len(b) == 0 {
+		return b
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return b
+	}
+	for _, c := range b[len(b)-n:] {
+		if int(c) != n {
+			return b
+		}
+	}
+	return b[:len(b)-n]
+}
@@ -0,0 +1,417 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sort"
+)
+
+// An OrderedItem is one element of a document's logical reading order.
+type OrderedItem struct {
+	Kind OrderedKind
+	Page int    // page number, counting from 1; 0 for a structure element with no page
+	Box  Rect   // bounds of the text run or image; zero for structure boundaries
+	Text string // text of an ItemText run, words separated by single spaces
+
+	// For ItemImage, V is the image XObject or inline image (see Image.IsInline).
+	// For ItemStructStart and ItemStructEnd, V is the structure element
+	// and Type and Role are its structure type as written and as mapped
+	// to a standard type through the RoleMap.
+	V    Value
+	Type string
+	Role string
+
+	Err error // for ItemError, the error that ended the sequence
+}
+
+// An OrderedKind is the kind of an OrderedItem.
+type OrderedKind int
+
+const (
+	ItemText        OrderedKind = iota // a line of text
+	ItemImage                          // an image placement
+	ItemStructStart                    // the start of a structure element
+	ItemStructEnd                      // the end of a structure element
+	ItemError                          // an error; always the last item
+)
+
+// ReadingOrder returns the document's content as a single sequence of
+// text runs, image placements, and structure boundaries, in the best
+// logical order the document provides. The first applicable source wins:
+//
+//  1. If the document is tagged (MarkInfo Marked is true and it has a
+//     structure tree), items follow the structure tree in depth-first
+//     order, bracketed by ItemStructStart and ItemStructEnd.
+//  2. Otherwise, if the document has article threads, items follow the
+//     beads of each thread in turn.
+//  3. Otherwise, items follow page order and, within a page, geometry:
+//     top to bottom, and left to right within a line.
+//
+// Content that the structure tree or article threads do not reach is
+// appended after them, page by page, in geometric order. Content marked
+// as an Artifact, such as running headers and page numbers, is omitted.
+// Content inside form XObjects and inline images is not reported.
+// Text runs are lines reconstructed from glyph positions, in the
+// coordinates selected by the Reader's TextOptions.
+//
+// If an error occurs, the sequence ends with an ItemError item.
+func (r *Reader) ReadingOrder(ctx context.Context) iter.Seq[OrderedItem] {
+	return func(yield func(OrderedItem) bool) {
+		o := &orderer{r: r, ctx: ctx, yield: yield}
+		err := o.run()
+		if err != nil && err != errStopOrder {
+			yield(OrderedItem{Kind: ItemError, Err: err})
+		}
+	}
+}
+
+// errStopOrder reports that the consumer of a ReadingOrder sequence stopped early.
+var errStopOrder = errors.New("stop reading order")
+
+type orderer struct {
+	r       *Reader
+	ctx     context.Context
+	yield   func(OrderedItem) bool
+	pages   []Page
+	pageNum map[objptr]int
+	content map[int]*pageContent
+}
+
+// pageContent is the content of one page, with a record of which
+// glyphs and images have already been emitted.
+type pageContent struct {
+	glyphs    []Glyph
+	images    []contentImage
+	glyphUsed []bool
+	imageUsed []bool
+}
+
+func (o *orderer) emit(x OrderedItem) error {
+	if !o.yield(x) {
+		return errStopOrder
+	}
+	return nil
+}
+
+func (o *orderer) run() error {
+	o.pageNum = make(map[objptr]int)
+	o.content = make(map[int]*pageContent)
+	err := o.r.walkPages(o.ctx, func(num int, p Page) error {
+		o.pages = append(o.pages, p)
+		o.pageNum[p.V.ptr] = num
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	root, err := o.r.Trailer().Key("Root")
+	if err != nil {
+		return err
+	}
+	tagged, err := isTagged(root)
+	if err != nil {
+		return err
+	}
+	if tagged {
+		err = o.structTree(root)
+	} else {
+		err = o.threads(root)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Whatever remains, in geometric order.
+	for i := range o.pages {
+		if err := o.take(i+1, func(Rect, int) bool { return true }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isTagged(root Value) (bool, error) {
+	info, err := root.Key("MarkInfo")
+	if err != nil {
+		return false, err
+	}
+	marked, err := info.Key("Marked")
+	if err != nil {
+		return false, err
+	}
+	tree, err := root.Key("StructTreeRoot")
+	if err != nil {
+		return false, err
+	}
+	return marked.Bool() && tree.Kind() == Dict, nil
+}
+
+// pageContent returns the content of page num, interpreting
+// the page's content stream the first time it is needed.
+func (o *orderer) pageContent(num int) (*pageContent, error) {
+	if c := o.content[num]; c != nil {
+		return c, nil
+	}
+	p := o.pages[num-1]
+	c := new(pageContent)
+	_, err := p.walkContent(o.ctx, p.textOptions(), contentHandler{
+		glyph: func(g Glyph) error {
+			if !g.synthetic && !g.artifact {
+				c.glyphs = append(c.glyphs, g)
+			}
+			return nil
+		},
+		image: func(im contentImage) error {
+			if !im.artifact {
+				c.images = append(c.images, im)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.glyphUsed = make([]bool, len(c.glyphs))
+	c.imageUsed = make([]bool, len(c.images))
+	o.content[num] = c
+	return c, nil
+}
+
+// take emits, in geometric order, the not yet emitted content of page num
+// for which keep reports true given the content's bounds and MCID.
+func (o *orderer) take(num int, keep func(box Rect, mcid int) bool) error {
+	c, err := o.pageContent(num)
+	if err != nil {
+		return err
+	}
+	var glyphs []Glyph
+	for i, g := range c.glyphs {
+		if !c.glyphUsed[i] && keep(glyphRect(g), g.mcid) {
+			c.glyphUsed[i] = true
+			glyphs = append(glyphs, g)
+		}
+	}
+	var items []OrderedItem
+	for _, line := range layoutLines(glyphs) {
+		items = append(items, OrderedItem{Kind: ItemText, Page: num, Box: line.Box, Text: line.Text()})
+	}
+	for i, im := range c.images {
+		if !c.imageUsed[i] && keep(im.Box, im.mcid) {
+			c.imageUsed[i] = true
+			items = append(items, OrderedItem{Kind: ItemImage, Page: num, Box: im.Box, V: im.V})
+		}
+	}
+	// Lines are already top to bottom; place images among them by their tops.
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Box.Max.Y > items[j].Box.Max.Y
+	})
+	for _, x := range items {
+		if err := o.emit(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structTree emits the content of a tagged document in structure order.
+func (o *orderer) structTree(root Value) error {
+	tree, err := root.Key("StructTreeRoot")
+	if err != nil {
+		return err
+	}
+	roleMap, err := tree.Key("RoleMap")
+	if err != nil {
+		return err
+	}
+	b := structBuilder{roleMap: roleMap, seen: map[objptr]bool{tree.ptr: true}}
+	return o.structKids(&b, tree, Value{})
+}
+
+// structKids emits the children (K) of the structure element v.
+// pg is the page inherited from v's ancestors.
+func (o *orderer) structKids(b *structBuilder, v, pg Value) error {
+	if p, err := v.Key("Pg"); err != nil {
+		return err
+	} else if p.Kind() == Dict {
+		pg = p
+	}
+	k, err := v.Key("K")
+	if err != nil {
+		return err
+	}
+	kids := []Value{k}
+	if k.Kind() == Array {
+		kids = kids[:0]
+		for i := 0; i < k.Len(); i++ {
+			kid, err := k.Index(i)
+			if err != nil {
+				return err
+			}
+			kids = append(kids, kid)
+		}
+	}
+	for _, kid := range kids {
+		switch kid.Kind() {
+		case Integer:
+			if err := o.markedContent(pg, int(kid.Int64())); err != nil {
+				return err
+			}
+		case Dict:
+			typ, err := kid.Key("Type")
+			if err != nil {
+				return err
+			}
+			switch {
+			case typ.Name() == "MCR":
+				if err := o.markedContentRef(kid, pg); err != nil {
+					return err
+				}
+			case isStructElem(kid):
+				// As in StructTree, visit each element once, and not
+				// inside itself, so that a cyclic tree terminates.
+				if b.seen[kid.ptr] {
+					continue
+				}
+				b.seen[kid.ptr] = true
+				if err := o.structElem(b, kid, pg); err != nil {
+					return err
+				}
+			}
+			// Object references (OBJR) point at annotations and XObjects,
+			// which are not part of the page content reported here.
+		}
+	}
+	return nil
+}
+
+func (o *orderer) structElem(b *structBuilder, v, pg Value) error {
+	s, err := v.Key("S")
+	if err != nil {
+		return err
+	}
+	x := OrderedItem{Kind: ItemStructStart, V: v, Type: s.Name()}
+	x.Role, err = b.role(x.Type)
+	if err != nil {
+		return err
+	}
+	if p, err := v.Key("Pg"); err != nil {
+		return err
+	} else if p.Kind() == Dict {
+		pg = p
+	}
+	x.Page = o.pageNum[pg.ptr]
+	if err := o.emit(x); err != nil {
+		return err
+	}
+	if err := o.structKids(b, v, pg); err != nil {
+		return err
+	}
+	x.Kind = ItemStructEnd
+	return o.emit(x)
+}
+
+// markedContentRef emits the content referenced by a marked-content reference dictionary.
+func (o *orderer) markedContentRef(ref, pg Value) error {
+	stm, err := ref.Key("Stm")
+	if err != nil {
+		return err
+	}
+	if !stm.IsNull() {
+		// Marked content in a form XObject; not reported.
+		return nil
+	}
+	if p, err := ref.Key("Pg"); err != nil {
+		return err
+	} else if p.Kind() == Dict {
+		pg = p
+	}
+	id, err := ref.Key("MCID")
+	if err != nil {
+		return err
+	}
+	if id.Kind() != Integer {
+		return nil
+	}
+	return o.markedContent(pg, int(id.Int64()))
+}
+
+// markedContent emits the content of page pg marked with the given MCID.
+func (o *orderer) markedContent(pg Value, mcid int) error {
+	num := o.pageNum[pg.ptr]
+	if num == 0 {
+		return nil
+	}
+	return o.take(num, func(_ Rect, id int) bool { return id == mcid })
+}
+
+// threads emits the content of the document's article threads, bead by bead.
+// See PDF 32000-1:2008, §12.4.3.
+func (o *orderer) threads(root Value) error {
+	threads, err := root.Key("Threads")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < threads.Len(); i++ {
+		thread, err := threads.Index(i)
+		if err != nil {
+			return err
+		}
+		bead, err := thread.Key("F")
+		if err != nil {
+			return err
+		}
+		// The beads form a circular list; stop on returning to any bead.
+		seen := make(map[objptr]bool)
+		for bead.Kind() == Dict && !seen[bead.ptr] {
+			seen[bead.ptr] = true
+			if err := o.bead(bead); err != nil {
+				return err
+			}
+			bead, err = bead.Key("N")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bead emits the content whose center lies within the bead's rectangle.
+func (o *orderer) bead(bead Value) error {
+	pg, err := bead.Key("P")
+	if err != nil {
+		return err
+	}
+	num := o.pageNum[pg.ptr]
+	if num == 0 {
+		return nil
+	}
+	rv, err := bead.Key("R")
+	if err != nil {
+		return err
+	}
+	r, err := rectFromArray(rv)
+	if err != nil {
+		return err
+	}
+	// Bead rectangles are in default user space, but content
+	// may have been transformed into displayed space.
+	if p := o.pages[num-1]; p.textOptions().Displayed {
+		m, err := p.Transform()
+		if err != nil {
+			return err
+		}
+		r = m.ApplyRect(r)
+	}
+	return o.take(num, func(box Rect, _ int) bool {
+		x := (box.Min.X + box.Max.X) / 2
+		y := (box.Min.Y + box.Max.Y) / 2
+		return r.Min.X <= x && x <= r.Max.X && r.Min.Y <= y && y <= r.Max.Y
+	})
+}
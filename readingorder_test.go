@@ -0,0 +1,38 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadingOrderStructCycle(t *testing.T) {
+	// The paragraph, object 7, lists itself among its kids
+	// before its marked content.
+	objs := pageTree(1)
+	objs[0] = "<< /Type /Catalog /Pages 2 0 R /StructTreeRoot 5 0 R /MarkInfo << /Marked true >> >>"
+	objs[3] = streamObj("", "/P << /MCID 0 >> BDC BT /F1 12 Tf 72 720 Td (page 1) Tj ET EMC")
+	objs = append(objs,
+		"<< /Type /StructTreeRoot /K 6 0 R >>",
+		"<< /Type /StructElem /S /Document /P 5 0 R /K [7 0 R] >>",
+		"<< /Type /StructElem /S /P /P 6 0 R /Pg 3 0 R /K [7 0 R 0] >>")
+	r := openPDF(t, buildPDF(objs...), nil)
+	var starts int
+	var text []string
+	for item := range r.ReadingOrder(context.Background()) {
+		switch item.Kind {
+		case ItemError:
+			t.Fatal(item.Err)
+		case ItemStructStart:
+			starts++
+		case ItemText:
+			text = append(text, item.Text)
+		}
+	}
+	if starts != 2 || len(text) != 1 || text[0] != "page 1" {
+		t.Errorf("ReadingOrder: %d elements and text %q, want 2 elements and [\"page 1\"]", starts, text)
+	}
+}
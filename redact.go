@@ -0,0 +1,632 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// A Redaction is an area of a page whose content Reader.Redact removes.
+type Redaction struct {
+	Page int  // the page number, starting at 1
+	Rect Rect // the area, in default user space
+}
+
+// RedactOptions controls how Reader.Redact marks the redacted areas.
+type RedactOptions struct {
+	// Fill, if not nil, is the color painted over each redacted area:
+	// one, three, or four components for gray, RGB, or CMYK.
+	// Otherwise the areas are left blank.
+	Fill []float64
+}
+
+// Redact writes to w a new PDF file holding the document read by r
+// with the given areas redacted: their content is removed from the
+// file, not just covered up.
+//
+// On each page with redactions, the characters shown in an area are
+// removed from the text-showing operations, leaving the other characters
+// in place, and the replacement text (ActualText, Alt, and E) of marked
+// content enclosing them is dropped. The samples of images in an area
+// are blacked out, in a copy of the image without soft masks; inline
+// images and images that cannot be decoded (see Image.Decode) are
+// removed entirely. Form XObjects are redacted in copies of their own.
+// Annotations overlapping an area, and popups belonging to them, are
+// removed. Paths and shadings are left alone, as is text elsewhere in
+// the document, such as in the outline, the metadata, or form field
+// values whose widgets are not merged with their fields.
+//
+// Like WriteTo, Redact writes only the objects reachable from the
+// trailer, so that the original content streams are dropped.
+func (r *Reader) Redact(ctx context.Context, w io.Writer, areas []Redaction, opts RedactOptions) error {
+	switch len(opts.Fill) {
+	case 0, 1, 3, 4:
+	default:
+		return fmt.Errorf("pdf: redaction fill color has %d components", len(opts.Fill))
+	}
+	byPage := make(map[int][]Rect)
+	for _, a := range areas {
+		byPage[a.Page] = append(byPage[a.Page], a.Rect)
+	}
+	var pages []Page
+	var nums []int
+	count := 0
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		count = num
+		if byPage[num] != nil {
+			pages = append(pages, p)
+			nums = append(nums, num)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for num := range byPage {
+		if num < 1 || num > count {
+			return fmt.Errorf("pdf: page %d out of range [1, %d]", num, count)
+		}
+	}
+
+	// Map the redacted pages to their copies, and the removed
+	// annotations to null, before writing anything, so that no object
+	// refers to the originals.
+	pw := NewWriter(w)
+	refs := make([]Value, len(pages))
+	for i, p := range pages {
+		if !isIndirect(p.V) {
+			return fmt.Errorf("pdf: page %d is not an indirect object", nums[i])
+		}
+		refs[i] = pw.NewRef()
+		pw.alias(p.V, refs[i])
+	}
+	var null Value
+	annots := make([]Value, len(pages))
+	for i, p := range pages {
+		removed, kept, err := redactAnnots(p, byPage[nums[i]])
+		if err != nil {
+			return err
+		}
+		for _, a := range removed {
+			if null.IsNull() {
+				if null, err = pw.Add(Value{}); err != nil {
+					return err
+				}
+			}
+			pw.alias(a, null)
+		}
+		if kept != nil {
+			annots[i] = NewArray(kept...)
+		}
+	}
+
+	for i, p := range pages {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rd := &redactor{ctx: ctx, w: pw, rects: byPage[nums[i]]}
+		if err := rd.page(p, refs[i], annots[i], opts); err != nil {
+			return err
+		}
+	}
+	trailer, err := r.copyTrailer()
+	if err != nil {
+		return err
+	}
+	return pw.Close(trailer)
+}
+
+// redactAnnots returns the annotations of the page p overlapping
+// any of rects, along with the popups belonging to them, and the
+// annotations to keep, as elements of a new Annots array.
+// If no annotations are removed, kept is nil.
+func redactAnnots(p Page, rects []Rect) (removed, kept []Value, err error) {
+	old, err := p.V.Key("Annots")
+	if err != nil {
+		return nil, nil, err
+	}
+	gone := make(map[objptr]bool)
+	hit := make([]bool, old.Len())
+	for i := 0; i < old.Len(); i++ {
+		a, err := old.Index(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		rect, err := a.Key("Rect")
+		if err != nil {
+			return nil, nil, err
+		}
+		box, err := rectFromArray(rect)
+		if err != nil {
+			return nil, nil, err
+		}
+		if overlapsAny(box, rects) {
+			hit[i] = true
+			if isIndirect(a) {
+				gone[a.ptr] = true
+			}
+		}
+	}
+	for i := 0; i < old.Len(); i++ {
+		a, err := old.Index(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		parent, err := a.Key("Parent")
+		if err != nil {
+			return nil, nil, err
+		}
+		sub, err := a.Key("Subtype")
+		if err != nil {
+			return nil, nil, err
+		}
+		if sub.Name() == "Popup" && isIndirect(parent) && gone[parent.ptr] {
+			hit[i] = true
+		}
+		if hit[i] {
+			if isIndirect(a) {
+				removed = append(removed, a)
+			}
+			continue
+		}
+		kept = append(kept, Value{old.r, old.ptr, old.data.(array)[i]})
+	}
+	if len(kept) == old.Len() {
+		return nil, nil, nil
+	}
+	if kept == nil {
+		kept = []Value{}
+	}
+	return removed, kept, nil
+}
+
+// overlapsAny reports whether r overlaps any of rects
+// in an area larger than zero.
+func overlapsAny(r Rect, rects []Rect) bool {
+	for _, s := range rects {
+		if r.Min.X < s.Max.X && s.Min.X < r.Max.X && r.Min.Y < s.Max.Y && s.Min.Y < r.Max.Y {
+			return true
+		}
+	}
+	return false
+}
+
+// A redactor removes the content in rects from a page.
+type redactor struct {
+	ctx   context.Context
+	w     *Writer
+	rects []Rect
+	forms []objptr // the form XObjects being redacted, to stop cycles
+}
+
+// A redactGlyph is a character shown by a text-showing operation.
+type redactGlyph struct {
+	code string  // the character code
+	hit  bool    // whether the character is redacted
+	tx   float64 // the horizontal displacement, in units of the font size and horizontal scaling
+}
+
+// page writes the redacted copy of p as the object ref, with the
+// annotations annots, or p's annotations if annots is null.
+func (rd *redactor) page(p Page, ref, annots Value, opts RedactOptions) error {
+	data, err := p.ContentData()
+	if err != nil {
+		return err
+	}
+	res, err := p.Resources()
+	if err != nil {
+		return err
+	}
+	// Content outside the crop box is redacted too.
+	gs := initialGraphicsState()
+	gs.Clip = Rect{Point{math.Inf(-1), math.Inf(-1)}, Point{math.Inf(1), math.Inf(1)}}
+	data, res, _, err = rd.content(data, res, gs)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if opts.Fill != nil {
+		// Paint the areas after the content, in the initial graphics state.
+		buf.WriteString("q\n")
+		buf.Write(data)
+		fill := []Operator{{Name: "Q"}, {Name: map[int]string{1: "g", 3: "rg", 4: "k"}[len(opts.Fill)]}}
+		for _, c := range opts.Fill {
+			fill[1].Args = append(fill[1].Args, NewReal(c))
+		}
+		for _, r := range rd.rects {
+			fill = append(fill, Operator{Name: "re", Args: []Value{
+				NewReal(r.Min.X), NewReal(r.Min.Y), NewReal(r.Max.X - r.Min.X), NewReal(r.Max.Y - r.Min.Y),
+			}})
+		}
+		buf.WriteByte('\n')
+		formatContent(&buf, append(fill, Operator{Name: "f"}))
+	} else {
+		buf.Write(data)
+	}
+	contents, err := rd.w.Add(deflateStream(nil, buf.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	entries, err := dictEntries(p.V)
+	if err != nil {
+		return err
+	}
+	// The thumbnail would show what was redacted.
+	delete(entries, "Thumb")
+	entries["Contents"] = contents
+	entries["Resources"] = res
+	if !annots.IsNull() {
+		entries["Annots"] = annots
+	}
+	return rd.w.Write(ref, NewDict(entries))
+}
+
+// deflateStream returns a stream Value with the entries hdr and the
+// data compressed with FlateDecode.
+func deflateStream(hdr map[string]Value, data []byte) Value {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	entries := map[string]Value{"Filter": NewName("FlateDecode")}
+	for k, v := range hdr {
+		entries[k] = v
+	}
+	return NewStream(NewDict(entries), buf.Bytes())
+}
+
+// content redacts the content stream data, whose resources are res,
+// interpreted starting in the graphics state gs. It returns the new
+// data and resources, and whether anything was redacted.
+func (rd *redactor) content(data []byte, res Value, gs GraphicsState) ([]byte, Value, bool, error) {
+	var (
+		ops    []Operator
+		glyphs = make(map[int][]redactGlyph) // by operation
+		marked []int                         // the open marked-content operations
+		strip  = make(map[int]bool)          // marked-content operations to strip of replacement text
+		repl   = make(map[int][]Operator)    // replacements for operations
+		xobjs  = make(map[string]Value)      // XObjects painted by replacements
+	)
+	in := &interp{ctx: rd.ctx}
+	in.op = func(op Operator, gs *GraphicsState) error {
+		ops = append(ops, op)
+		switch op.Name {
+		case "BMC", "BDC":
+			marked = append(marked, len(ops)-1)
+		case "EMC":
+			if n := len(marked); n > 0 {
+				marked = marked[:n-1]
+			}
+		case "BI":
+			if overlapsAny(gs.CTM.ApplyRect(unitRect), rd.rects) {
+				repl[len(ops)-1] = []Operator{}
+			}
+		}
+		return nil
+	}
+	in.w.Glyph = func(g Glyph, gs *GraphicsState) error {
+		i := len(ops) - 1
+		hit := overlapsAny(glyphRect(g), rd.rects)
+		if hit {
+			for _, m := range marked {
+				strip[m] = true
+			}
+		}
+		// Recover the displacement in text space from the one in device space.
+		m := gs.TextMatrix.Mul(gs.CTM)
+		var tx float64
+		if d := m[0]*m[0] + m[1]*m[1]; d != 0 {
+			tx = (g.DX*m[0] + g.DY*m[1]) / d
+		}
+		glyphs[i] = append(glyphs[i], redactGlyph{g.code, hit, tx / (gs.FontSize * gs.Scale)})
+		return nil
+	}
+	in.w.Image = func(im Image, gs *GraphicsState) error {
+		// Inline images that are hit are removed, above.
+		if im.IsInline() || !overlapsAny(gs.CTM.ApplyRect(unitRect), rd.rects) {
+			return nil
+		}
+		x, err := rd.image(im, gs.CTM)
+		if err != nil {
+			return err
+		}
+		rd.replaceDo(len(ops)-1, x, xobjs, repl)
+		return nil
+	}
+	in.form = func(x, formRes Value, gs GraphicsState) error {
+		if !overlapsAny(gs.Clip, rd.rects) {
+			return nil
+		}
+		for _, ptr := range rd.forms {
+			if ptr == x.ptr {
+				return nil
+			}
+		}
+		rd.forms = append(rd.forms, x.ptr)
+		defer func() { rd.forms = rd.forms[:len(rd.forms)-1] }()
+		data, err := streamData(x)
+		if err != nil {
+			return err
+		}
+		data, formRes, changed, err := rd.content(data, formRes, gs)
+		if err != nil || !changed {
+			return err
+		}
+		hdr := make(map[string]Value)
+		for _, k := range x.Keys() {
+			switch k {
+			case "Length", "Filter", "DecodeParms":
+				continue
+			}
+			if hdr[k], err = x.Key(k); err != nil {
+				return err
+			}
+		}
+		hdr["Resources"] = formRes
+		ref, err := rd.w.Add(deflateStream(hdr, data))
+		if err != nil {
+			return err
+		}
+		rd.replaceDo(len(ops)-1, ref, xobjs, repl)
+		return nil
+	}
+	if err := in.run(data, res, gs); err != nil {
+		return nil, Value{}, false, err
+	}
+
+	for i, gl := range glyphs {
+		for _, g := range gl {
+			if g.hit {
+				repl[i] = redactText(ops[i], gl)
+				break
+			}
+		}
+	}
+	for i := range strip {
+		if len(ops[i].Args) == 2 && ops[i].Args[1].Kind() == Dict {
+			props := make(dict)
+			for k, v := range ops[i].Args[1].data.(dict) {
+				switch k {
+				case "ActualText", "Alt", "E":
+					continue
+				}
+				props[k] = v
+			}
+			repl[i] = []Operator{{Name: "BDC", Args: []Value{ops[i].Args[0], {nil, objptr{}, props}}}}
+		}
+	}
+
+	// Rewrite the operations, keeping track of the XObjects still
+	// painted under their original names.
+	if len(repl) == 0 {
+		return data, res, false, nil
+	}
+	var out []Operator
+	kept := make(map[string]bool)
+	for i, op := range ops {
+		if r, ok := repl[i]; ok {
+			out = append(out, r...)
+			continue
+		}
+		if op.Name == "Do" && len(op.Args) == 1 {
+			kept[op.Args[0].Name()] = true
+		}
+		out = append(out, op)
+	}
+	var buf bytes.Buffer
+	formatContent(&buf, out)
+	if len(xobjs) == 0 {
+		return buf.Bytes(), res, true, nil
+	}
+
+	// Add the new XObjects to a copy of the resources, dropping those
+	// that were only painted by redacted operations.
+	resEntries, err := dictEntries(res)
+	if err != nil {
+		return nil, Value{}, false, err
+	}
+	old, err := res.Key("XObject")
+	if err != nil {
+		return nil, Value{}, false, err
+	}
+	entries, err := dictEntries(old)
+	if err != nil {
+		return nil, Value{}, false, err
+	}
+	for i, op := range ops {
+		if _, ok := repl[i]; ok && op.Name == "Do" && len(op.Args) == 1 && !kept[op.Args[0].Name()] {
+			delete(entries, op.Args[0].Name())
+		}
+	}
+	for k, v := range xobjs {
+		entries[k] = v
+	}
+	resEntries["XObject"] = NewDict(entries)
+	return buf.Bytes(), NewDict(resEntries), true, nil
+}
+
+// unitRect is the unit square, which images are painted into.
+var unitRect = Rect{Point{0, 0}, Point{1, 1}}
+
+// replaceDo records the replacement of the Do operation numbered i
+// by one painting the XObject x, or by nothing if x is null.
+func (rd *redactor) replaceDo(i int, x Value, xobjs map[string]Value, repl map[int][]Operator) {
+	if x.IsNull() {
+		repl[i] = []Operator{}
+		return
+	}
+	name := fmt.Sprintf("Redacted%d", len(xobjs)+1)
+	xobjs[name] = x
+	repl[i] = []Operator{{Name: "Do", Args: []Value{NewName(name)}}}
+}
+
+// redactText returns the operations replacing the text-showing
+// operation op, which showed the characters gl: a TJ operation showing
+// the characters that are not redacted, with the redacted ones replaced
+// by equivalent displacements.
+func redactText(op Operator, gl []redactGlyph) []Operator {
+	var out []Operator
+	var elems []Value
+	switch op.Name {
+	case "Tj", "'", "\"":
+		if len(op.Args) > 0 {
+			elems = op.Args[len(op.Args)-1:]
+		}
+	case "TJ":
+		if len(op.Args) == 1 {
+			for i := 0; i < op.Args[0].Len(); i++ {
+				elems = append(elems, Value{nil, objptr{}, op.Args[0].data.(array)[i]})
+			}
+		}
+	}
+	switch op.Name {
+	case "\"":
+		if len(op.Args) == 3 {
+			out = append(out,
+				Operator{Name: "Tw", Args: op.Args[:1]},
+				Operator{Name: "Tc", Args: op.Args[1:2]})
+		}
+		fallthrough
+	case "'":
+		out = append(out, Operator{Name: "T*"})
+	}
+
+	var arr []Value
+	var str []byte
+	var adj float64
+	flush := func() {
+		if str != nil {
+			arr = append(arr, NewString(string(str)))
+			str = nil
+		}
+	}
+	k := 0
+	for _, e := range elems {
+		if e.Kind() != String {
+			flush()
+			adj += e.Float64()
+			continue
+		}
+		s := e.RawString()
+		for n := 0; n < len(s) && k < len(gl); k++ {
+			g := gl[k]
+			n += len(g.code)
+			if g.hit {
+				if !math.IsInf(g.tx, 0) && !math.IsNaN(g.tx) {
+					flush()
+					adj -= g.tx * 1000
+				}
+				continue
+			}
+			if adj != 0 {
+				arr = append(arr, NewReal(math.Round(adj*1000)/1000))
+				adj = 0
+			}
+			str = append(str, g.code...)
+		}
+	}
+	flush()
+	if adj != 0 {
+		arr = append(arr, NewReal(math.Round(adj*1000)/1000))
+	}
+	return append(out, Operator{Name: "TJ", Args: []Value{NewArray(arr...)}})
+}
+
+// image returns a copy of the image im, painted with the transformation
+// ctm, with its samples in the redacted areas blacked out, or null if im
+// cannot be decoded, so that it must be removed.
+func (rd *redactor) image(im Image, ctm Matrix) (Value, error) {
+	img, err := im.Decode()
+	if err != nil {
+		return Value{}, nil
+	}
+	mask, err := im.IsMask()
+	if err != nil {
+		return Value{}, err
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	hit := func(x, y int) bool {
+		cell := Rect{
+			Point{float64(x) / float64(w), 1 - float64(y+1)/float64(h)},
+			Point{float64(x+1) / float64(w), 1 - float64(y)/float64(h)},
+		}
+		return overlapsAny(ctm.ApplyRect(cell), rd.rects)
+	}
+
+	hdr := map[string]Value{
+		"Type":             NewName("XObject"),
+		"Subtype":          NewName("Image"),
+		"Width":            NewInt(int64(w)),
+		"Height":           NewInt(int64(h)),
+		"BitsPerComponent": NewInt(8),
+	}
+	for _, k := range []string{"Interpolate", "Intent", "OC"} {
+		if hdr[k], err = im.V.Key(k); err != nil {
+			return Value{}, err
+		}
+	}
+	var data []byte
+	switch {
+	case mask:
+		// Samples of 0 are painted; redacted samples are not.
+		hdr["ImageMask"] = NewBool(true)
+		hdr["BitsPerComponent"] = NewInt(1)
+		stride := (w + 7) / 8
+		data = make([]byte, stride*h)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if g := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray); g.Y >= 0x80 || hit(x, y) {
+					data[y*stride+x/8] |= 0x80 >> uint(x%8)
+				}
+			}
+		}
+	default:
+		var n int
+		var sample func(c color.Color) []byte
+		switch img.(type) {
+		case *image.Gray, *image.Gray16:
+			hdr["ColorSpace"], n = NewName("DeviceGray"), 1
+			sample = func(c color.Color) []byte {
+				return []byte{color.GrayModel.Convert(c).(color.Gray).Y}
+			}
+		case *image.CMYK:
+			hdr["ColorSpace"], n = NewName("DeviceCMYK"), 4
+			sample = func(c color.Color) []byte {
+				k := color.CMYKModel.Convert(c).(color.CMYK)
+				return []byte{k.C, k.M, k.Y, k.K}
+			}
+		default:
+			hdr["ColorSpace"], n = NewName("DeviceRGB"), 3
+			sample = func(c color.Color) []byte {
+				r, g, b, _ := c.RGBA()
+				return []byte{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}
+			}
+		}
+		black := make([]byte, n)
+		if n == 4 {
+			black[3] = 0xff
+		}
+		data = make([]byte, 0, w*h*n)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if hit(x, y) {
+					data = append(data, black...)
+				} else {
+					data = append(data, sample(img.At(b.Min.X+x, b.Min.Y+y))...)
+				}
+			}
+		}
+	}
+	return rd.w.Add(deflateStream(hdr, data))
+}
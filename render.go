@@ -0,0 +1,681 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"unicode/utf8"
+)
+
+// RenderOptions controls how Page.Render rasterizes a page.
+type RenderOptions struct {
+	DPI        float64     // resolution, in pixels per inch; 0 means 72
+	Background color.Color // the color of the page; nil means white
+}
+
+// maxRenderPixels limits the size of the images made by Page.Render.
+const maxRenderPixels = 1 << 28
+
+// Render rasterizes the page, as displayed (see Page.Transform),
+// at the resolution given by opts.
+//
+// Render is a basic renderer, for previews. It fills and strokes paths
+// with anti-aliasing, draws images that Image.Decode supports, with
+// their masks, and draws text with the glyph outlines of embedded
+// TrueType, OpenType, and CFF fonts and the glyph procedures of Type 3
+// fonts. Text in other fonts, such as the standard fonts when they are
+// not embedded and Type 1 fonts, is drawn as bars the size of the
+// text. Patterns, shadings, blend modes, and transfer functions are
+// ignored; clipping paths are approximated by their bounding boxes;
+// and colors are converted to RGB without color management.
+func (p Page) Render(ctx context.Context, opts RenderOptions) (*image.RGBA, error) {
+	w, h, err := p.Size()
+	if err != nil {
+		return nil, err
+	}
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = 72
+	}
+	scale := dpi / 72
+	pw, ph := int(math.Ceil(w*scale)), int(math.Ceil(h*scale))
+	if pw <= 0 || ph <= 0 || float64(pw)*float64(ph) > maxRenderPixels {
+		return nil, errors.New("pdf: invalid size for rendered page")
+	}
+	img := image.NewRGBA(image.Rect(0, 0, pw, ph))
+	bg := opts.Background
+	if bg == nil {
+		bg = color.White
+	}
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	rd := &renderer{
+		ctx:   ctx,
+		cv:    canvas{img: img, clip: img.Bounds()},
+		pixel: Matrix{scale, 0, 0, -scale, 0, h * scale},
+		fonts: make(map[objptr]*renderFont),
+	}
+	err = p.Walk(ctx, ContentWalker{
+		Displayed: true,
+		Path:      rd.path,
+		Glyph:     rd.glyph,
+		Image:     rd.image,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// A renderer paints the marks made by a page's content for Page.Render.
+type renderer struct {
+	ctx   context.Context
+	cv    canvas
+	pixel Matrix // from device (displayed) space to pixels
+	fonts map[objptr]*renderFont
+	type3 []objptr // the Type 3 fonts whose glyphs are being drawn, to stop cycles
+}
+
+// setClip restricts painting to the clipping region of gs.
+func (rd *renderer) setClip(gs *GraphicsState) {
+	c := rd.pixel.ApplyRect(gs.Clip)
+	r := image.Rect(int(math.Floor(c.Min.X)), int(math.Floor(c.Min.Y)), int(math.Ceil(c.Max.X)), int(math.Ceil(c.Max.Y)))
+	rd.cv.clip = r.Intersect(rd.cv.img.Bounds())
+}
+
+// toPixels returns the path segments, in device space, in pixels,
+// after transforming them by m.
+func (rd *renderer) toPixels(segs []PathSegment, m Matrix) []PathSegment {
+	m = m.Mul(rd.pixel)
+	out := make([]PathSegment, len(segs))
+	for i, s := range segs {
+		pts := make([]Point, len(s.Pts))
+		for j, pt := range s.Pts {
+			pts[j] = m.Apply(pt)
+		}
+		out[i] = PathSegment{s.Op, pts}
+	}
+	return out
+}
+
+func (rd *renderer) path(pa Path, gs *GraphicsState) error {
+	rd.setClip(gs)
+	lines := flatten(rd.toPixels(pa.Segments, Identity))
+	if pa.Fill {
+		if c, ok := renderColor(gs.FillSpace, gs.FillColor, gs.FillAlpha); ok {
+			polys := make([][]Point, len(lines))
+			for i, l := range lines {
+				polys[i] = l.pts
+			}
+			rd.cv.fill(polys, pa.EvenOdd, c)
+		}
+	}
+	if pa.Stroke {
+		if c, ok := renderColor(gs.StrokeSpace, gs.StrokeColor, gs.StrokeAlpha); ok {
+			rd.cv.fill(stroke(lines, rd.strokeStyle(gs, Identity)), false, c)
+		}
+	}
+	return nil
+}
+
+// strokeStyle returns the style of lines stroked in the graphics
+// state gs, in pixels, for paths in a space mapped to user space by m.
+func (rd *renderer) strokeStyle(gs *GraphicsState, m Matrix) strokeStyle {
+	t := m.Mul(gs.CTM).Mul(rd.pixel)
+	k := math.Sqrt(math.Abs(t[0]*t[3] - t[1]*t[2]))
+	st := strokeStyle{
+		width:      gs.LineWidth * k,
+		cap:        gs.LineCap,
+		join:       gs.LineJoin,
+		miterLimit: gs.MiterLimit,
+		dashPhase:  gs.DashPhase * k,
+	}
+	// A line width of 0 means the thinnest line that can be drawn.
+	if st.width < 1 {
+		st.width = 1
+	}
+	for _, d := range gs.Dash {
+		st.dash = append(st.dash, d*k)
+	}
+	return st
+}
+
+func (rd *renderer) glyph(g Glyph, gs *GraphicsState) error {
+	mode := gs.RenderMode % 4
+	if mode == 3 {
+		return nil
+	}
+	rd.setClip(gs)
+	trm := Matrix{gs.FontSize * gs.Scale, 0, 0, gs.FontSize, 0, gs.Rise}.Mul(gs.TextMatrix)
+	if gs.Font.subtype() == "Type3" {
+		return rd.type3Glyph(g, gs, trm)
+	}
+	segs, ok := rd.font(gs.Font).outline(gs.Font, g.code, g.S)
+	if !ok {
+		// Without an outline, draw a bar where the text is.
+		if g.S == " " || g.S == "" {
+			return nil
+		}
+		adv := g.W / math.Hypot(trm.Mul(gs.CTM)[0], trm.Mul(gs.CTM)[1])
+		if adv == 0 || math.IsNaN(adv) {
+			adv = 0.5
+		}
+		segs = []PathSegment{
+			{'m', []Point{{0.05 * adv, 0}}},
+			{'l', []Point{{0.95 * adv, 0}}},
+			{'l', []Point{{0.95 * adv, 0.5}}},
+			{'l', []Point{{0.05 * adv, 0.5}}},
+			{Op: 'h'},
+		}
+		mode = 0
+	}
+	lines := flatten(rd.toPixels(segs, trm.Mul(gs.CTM)))
+	if mode == 0 || mode == 2 {
+		if c, ok := renderColor(gs.FillSpace, gs.FillColor, gs.FillAlpha); ok {
+			polys := make([][]Point, len(lines))
+			for i, l := range lines {
+				polys[i] = l.pts
+			}
+			rd.cv.fill(polys, false, c)
+		}
+	}
+	if mode == 1 || mode == 2 {
+		if c, ok := renderColor(gs.StrokeSpace, gs.StrokeColor, gs.StrokeAlpha); ok {
+			rd.cv.fill(stroke(lines, rd.strokeStyle(gs, Identity)), false, c)
+		}
+	}
+	return nil
+}
+
+// type3Glyph draws the glyph g of a Type 3 font by interpreting its
+// glyph procedure, in glyph space mapped by the font's FontMatrix and
+// the text rendering matrix trm. A procedure beginning with d1 describes
+// only a shape, which is painted in the text's fill color.
+func (rd *renderer) type3Glyph(g Glyph, gs *GraphicsState, trm Matrix) error {
+	f := gs.Font
+	for _, ptr := range rd.type3 {
+		if ptr == f.V.ptr {
+			return nil
+		}
+	}
+	if len(g.code) != 1 {
+		return nil
+	}
+	name := rd.font(f).diffs[int(g.code[0])]
+	procs, err := f.V.Key("CharProcs")
+	if err != nil {
+		return err
+	}
+	proc, err := procs.Key(name)
+	if err != nil || proc.Kind() != Stream {
+		return err
+	}
+	data, err := streamData(proc)
+	if err != nil {
+		return err
+	}
+	res, err := f.V.Key("Resources")
+	if err != nil {
+		return err
+	}
+	fm, err := f.fontMatrix()
+	if err != nil {
+		return err
+	}
+	rd.type3 = append(rd.type3, f.V.ptr)
+	defer func() { rd.type3 = rd.type3[:len(rd.type3)-1] }()
+
+	glyphGS := *gs
+	glyphGS.CTM = fm.Mul(trm).Mul(gs.CTM)
+	shape := false
+	paint := func(pa Path, pgs *GraphicsState) error {
+		if shape {
+			x := *pgs
+			x.FillSpace, x.FillColor, x.FillAlpha = gs.FillSpace, gs.FillColor, gs.FillAlpha
+			x.StrokeSpace, x.StrokeColor, x.StrokeAlpha = gs.FillSpace, gs.FillColor, gs.FillAlpha
+			pgs = &x
+		}
+		return rd.path(pa, pgs)
+	}
+	in := &interp{
+		ctx: rd.ctx,
+		w:   ContentWalker{Path: paint, Glyph: rd.glyph, Image: rd.image},
+		op: func(op Operator, _ *GraphicsState) error {
+			if op.Name == "d1" {
+				shape = true
+			}
+			return nil
+		},
+	}
+	return in.run(data, res, glyphGS)
+}
+
+func (rd *renderer) image(im Image, gs *GraphicsState) error {
+	rd.setClip(gs)
+	m := gs.CTM.Mul(rd.pixel)
+	inv, ok := m.inverse()
+	if !ok {
+		return nil
+	}
+	mask, err := im.IsMask()
+	if err != nil {
+		return err
+	}
+	var src image.Image
+	var fill color.Color
+	if mask {
+		var ok bool
+		if fill, ok = renderColor(gs.FillSpace, gs.FillColor, gs.FillAlpha); !ok {
+			return nil
+		}
+		src, err = im.Decode()
+	} else {
+		// The image's mask, if any, is its alpha channel.
+		src, err = im.DecodeAlpha()
+	}
+	if err != nil {
+		// Skip the images that cannot be decoded.
+		return nil
+	}
+
+	// Map each pixel back to the unit square, and so to the image.
+	box := m.ApplyRect(Rect{Point{0, 0}, Point{1, 1}})
+	area := image.Rect(int(math.Floor(box.Min.X)), int(math.Floor(box.Min.Y)),
+		int(math.Ceil(box.Max.X)), int(math.Ceil(box.Max.Y))).Intersect(rd.cv.clip)
+	sb := src.Bounds()
+	sample := func(img image.Image, u, v float64) color.Color {
+		b := img.Bounds()
+		x := b.Min.X + int(u*float64(b.Dx()))
+		y := b.Min.Y + int((1-v)*float64(b.Dy()))
+		return img.At(min(x, b.Max.X-1), min(y, b.Max.Y-1))
+	}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			uv := inv.Apply(Point{float64(x) + 0.5, float64(y) + 0.5})
+			if uv.X < 0 || uv.X >= 1 || uv.Y < 0 || uv.Y >= 1 || sb.Empty() {
+				continue
+			}
+			opacity := gs.FillAlpha
+			c := sample(src, uv.X, uv.Y)
+			if mask {
+				// Samples of 0 are painted, unless Decode inverts them.
+				if g := color.GrayModel.Convert(c).(color.Gray); g.Y >= 0x80 {
+					continue
+				}
+				c = fill
+				opacity = 1 // the fill color carries the alpha
+			}
+			r, g, b, a := c.RGBA()
+			rd.cv.blend(x, y, r, g, b, a, opacity)
+		}
+	}
+	return nil
+}
+
+// renderColor returns the color with components comps in the color
+// space cs, at the given opacity, and reports whether it can be painted.
+// Colors in the Pattern space cannot.
+func renderColor(cs Value, comps []float64, alpha float64) (color.Color, bool) {
+	rgb, ok := toRGB(cs, comps, 0)
+	if !ok {
+		return nil, false
+	}
+	a := to8(alpha)
+	return color.NRGBA{to8(rgb[0]), to8(rgb[1]), to8(rgb[2]), a}, true
+}
+
+// toRGB converts the color comps in the color space cs to RGB, naively.
+// depth guards against color spaces defined in terms of themselves.
+func toRGB(cs Value, comps []float64, depth int) ([3]float64, bool) {
+	c := func(i int) float64 {
+		if i < len(comps) {
+			return comps[i]
+		}
+		return 0
+	}
+	gray := func(g float64) [3]float64 { return [3]float64{g, g, g} }
+	family := cs
+	if cs.Kind() == Array {
+		family, _ = cs.Index(0)
+	}
+	if depth > 8 {
+		return gray(0), true
+	}
+	switch family.Name() {
+	case "DeviceGray", "CalGray", "G":
+		return gray(c(0)), true
+	case "DeviceRGB", "CalRGB", "RGB":
+		return [3]float64{c(0), c(1), c(2)}, true
+	case "DeviceCMYK", "CMYK":
+		k := c(3)
+		return [3]float64{(1 - c(0)) * (1 - k), (1 - c(1)) * (1 - k), (1 - c(2)) * (1 - k)}, true
+	case "Lab":
+		return labToRGB(c(0), c(1), c(2)), true
+	case "ICCBased":
+		strm, _ := cs.Index(1)
+		if alt, _ := strm.Key("Alternate"); !alt.IsNull() {
+			return toRGB(alt, comps, depth+1)
+		}
+		n, _ := strm.Key("N")
+		switch n.Int64() {
+		case 3:
+			return toRGB(NewName("DeviceRGB"), comps, depth+1)
+		case 4:
+			return toRGB(NewName("DeviceCMYK"), comps, depth+1)
+		}
+		return gray(c(0)), true
+	case "Indexed", "I":
+		base, _ := cs.Index(1)
+		hival, _ := cs.Index(2)
+		lookup, _ := cs.Index(3)
+		table := []byte(lookup.RawString())
+		if lookup.Kind() == Stream {
+			table, _ = streamData(lookup)
+		}
+		// The table holds colors in the base space, with their
+		// components scaled from their ranges to bytes.
+		init, _ := initialColor(base)
+		n := len(init)
+		decode := []float64(nil)
+		if fam, _ := base.Index(0); fam.Name() == "Lab" {
+			rng := labRange(base)
+			decode = append([]float64{0, 100}, rng[:]...)
+		}
+		i := int(c(0))
+		if i < 0 || i > int(hival.Int64()) || n < 1 || (i+1)*n > len(table) {
+			return gray(0), true
+		}
+		bc := make([]float64, n)
+		for j := range bc {
+			bc[j] = float64(table[i*n+j]) / 0xff
+			if decode != nil {
+				bc[j] = decode[2*j] + bc[j]*(decode[2*j+1]-decode[2*j])
+			}
+		}
+		return toRGB(base, bc, depth+1)
+	case "Separation", "DeviceN":
+		// Use the tint transform, and if it cannot be evaluated,
+		// darken gray by the largest tint.
+		alt, _ := cs.Index(2)
+		fv, _ := cs.Index(3)
+		if fn, err := loadFunction(fv, 0); err == nil {
+			return toRGB(alt, fn.eval(comps), depth+1)
+		}
+		t := 0.0
+		for _, x := range comps {
+			t = math.Max(t, x)
+		}
+		return gray(1 - t), true
+	}
+	return [3]float64{}, false
+}
+
+// labRange returns the ranges of the a* and b* components
+// of the Lab color space cs.
+func labRange(cs Value) [4]float64 {
+	rng := [4]float64{-100, 100, -100, 100}
+	dict, _ := cs.Index(1)
+	r, _ := dict.Key("Range")
+	if x, _ := numberArray(r); len(x) == 4 {
+		copy(rng[:], x)
+	}
+	return rng
+}
+
+// labToRGB converts the CIE L*a*b* color to sRGB, mapping the color
+// space's white point to sRGB's white. See PDF 32000-1:2008, §8.6.5.4.
+func labToRGB(l, a, b float64) [3]float64 {
+	g := func(x float64) float64 {
+		if x >= 6.0/29 {
+			return x * x * x
+		}
+		return 108.0 / 841 * (x - 4.0/29)
+	}
+	m := (l + 16) / 116
+	// XYZ relative to the white point, scaled to D65.
+	x := g(m+a/500) * 0.9505
+	y := g(m)
+	z := g(m-b/200) * 1.089
+	rgb := [3]float64{
+		3.2406*x - 1.5372*y - 0.4986*z,
+		-0.9689*x + 1.8758*y + 0.0415*z,
+		0.0557*x - 0.2040*y + 1.0570*z,
+	}
+	for i, c := range rgb {
+		c = math.Max(0, math.Min(1, c))
+		if c <= 0.0031308 {
+			c *= 12.92
+		} else {
+			c = 1.055*math.Pow(c, 1/2.4) - 0.055
+		}
+		rgb[i] = c
+	}
+	return rgb
+}
+
+// A renderFont holds the glyph outlines of a font, for Page.Render.
+type renderFont struct {
+	sfnt      *sfnt    // a TrueType or OpenType program
+	cff       *cffFont // a bare CFF program, or that of the OpenType program
+	cidToGID  []byte   // for a CIDFontType2 font, its CIDToGIDMap, or nil for Identity
+	composite bool
+	symbolic  bool
+	diffs     map[int]string // glyph names from the Differences of the encoding
+	byName    map[string]int // glyphs of a name-keyed CFF program, by name
+	byRune    map[rune]int   // glyphs of a name-keyed CFF program, by their names' characters
+}
+
+// font returns the outlines of font f, loading them if needed.
+// For a font without a usable embedded program, it returns
+// a renderFont with no outlines.
+func (rd *renderer) font(f Font) *renderFont {
+	if rf, ok := rd.fonts[f.V.ptr]; ok {
+		return rf
+	}
+	rf, err := loadRenderFont(f)
+	if err != nil {
+		rf = new(renderFont)
+	}
+	rd.fonts[f.V.ptr] = rf
+	return rf
+}
+
+func loadRenderFont(f Font) (*renderFont, error) {
+	rf := new(renderFont)
+	desc := f.V
+	if f.subtype() == "Type0" {
+		rf.composite = true
+		descendants, err := f.V.Key("DescendantFonts")
+		if err != nil {
+			return nil, err
+		}
+		if desc, err = descendants.Index(0); err != nil {
+			return nil, err
+		}
+		m, err := desc.Key("CIDToGIDMap")
+		if err != nil {
+			return nil, err
+		}
+		if m.Kind() == Stream {
+			if rf.cidToGID, err = streamData(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	fd, err := desc.Key("FontDescriptor")
+	if err != nil {
+		return nil, err
+	}
+	flags, err := fd.Key("Flags")
+	if err != nil {
+		return nil, err
+	}
+	rf.symbolic = flags.Int64()&4 != 0
+
+	if file, err := fd.Key("FontFile2"); err != nil {
+		return nil, err
+	} else if file.Kind() == Stream {
+		data, err := streamData(file)
+		if err != nil {
+			return nil, err
+		}
+		if rf.sfnt, err = parseSFNT(data); err != nil {
+			return nil, err
+		}
+		rf.cff = rf.sfnt.cff
+	} else if file, err := fd.Key("FontFile3"); err != nil {
+		return nil, err
+	} else if file.Kind() == Stream {
+		data, err := streamData(file)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := file.Key("Subtype")
+		if err != nil {
+			return nil, err
+		}
+		if sub.Name() == "OpenType" {
+			if rf.sfnt, err = parseSFNT(data); err != nil {
+				return nil, err
+			}
+			rf.cff = rf.sfnt.cff
+		} else if rf.cff, err = parseCFF(data); err != nil {
+			return nil, err
+		}
+	}
+	if rf.cff != nil && !rf.cff.cid {
+		rf.byName = make(map[string]int)
+		rf.byRune = make(map[rune]int)
+		for gid := len(rf.cff.charset) - 1; gid > 0; gid-- {
+			name := rf.cff.glyphName(gid)
+			rf.byName[name] = gid
+			if r := glyphNameToRune(name); r != 0 {
+				rf.byRune[r] = gid
+			}
+		}
+	}
+
+	if enc, err := f.V.Key("Encoding"); err != nil {
+		return nil, err
+	} else if enc.Kind() == Dict {
+		diff, err := enc.Key("Differences")
+		if err != nil {
+			return nil, err
+		}
+		rf.diffs = make(map[int]string)
+		code := 0
+		for i := 0; i < diff.Len(); i++ {
+			x, err := diff.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			switch x.Kind() {
+			case Integer:
+				code = int(x.Int64())
+			case Name:
+				rf.diffs[code] = x.Name()
+				code++
+			}
+		}
+	}
+	return rf, nil
+}
+
+// outline returns the outline, in glyph space (1 unit per em), of the
+// glyph of font f for the character code, whose text is text.
+// It reports whether the font has outlines.
+func (rf *renderFont) outline(f Font, code, text string) ([]PathSegment, bool) {
+	if rf.sfnt == nil && rf.cff == nil {
+		return nil, false
+	}
+	glyph := func(gid int) (segs []PathSegment) {
+		// A glyph whose outline is too malformed to read is not drawn.
+		defer func() {
+			if recover() != nil {
+				segs = nil
+			}
+		}()
+		if rf.sfnt != nil {
+			return rf.sfnt.glyph(gid)
+		}
+		return rf.cff.glyph(gid)
+	}
+	if rf.composite {
+		e, ok := f.enc.(*type0Encoder)
+		if !ok || e.enc == nil {
+			return nil, true
+		}
+		cid := e.enc.cid(code)
+		if cid < 0 {
+			return nil, true
+		}
+		if rf.cff != nil {
+			return glyph(rf.cff.cidGlyph(cid)), true
+		}
+		gid := cid
+		if rf.cidToGID != nil {
+			if 2*cid+2 > len(rf.cidToGID) {
+				return nil, true
+			}
+			gid = int(binary.BigEndian.Uint16(rf.cidToGID[2*cid:]))
+		}
+		return glyph(gid), true
+	}
+
+	if len(code) != 1 {
+		return nil, true
+	}
+	c := int(code[0])
+	r, _ := utf8.DecodeRuneInString(text)
+	name := rf.diffs[c]
+	if name != "" {
+		if x := glyphNameToRune(name); x != 0 {
+			r = x
+		}
+	}
+	if rf.cff != nil {
+		// A name-keyed CFF program: find the glyph by name,
+		// then by its code in the program's own encoding, then by text.
+		if gid, ok := rf.byName[name]; ok && name != "" {
+			return glyph(gid), true
+		}
+		if gid, ok := rf.cff.encoding[c]; ok && (rf.diffs == nil || rf.symbolic) {
+			return glyph(gid), true
+		}
+		if gid, ok := rf.byRune[r]; ok {
+			return glyph(gid), true
+		}
+		if gid, ok := rf.cff.encoding[c]; ok {
+			return glyph(gid), true
+		}
+		return nil, true
+	}
+
+	// A TrueType program: use the Unicode cmap for nonsymbolic fonts,
+	// and otherwise the symbol or Macintosh cmap, by code.
+	if !rf.symbolic {
+		if gid, ok := rf.sfnt.cmapLookup(3, 1, int(r)); ok && gid != 0 {
+			return glyph(gid), true
+		}
+	}
+	for _, try := range []struct{ platform, encoding, code int }{
+		{3, 0, 0xf000 + c},
+		{3, 0, c},
+		{1, 0, c},
+		{3, 1, int(r)},
+	} {
+		if gid, ok := rf.sfnt.cmapLookup(try.platform, try.encoding, try.code); ok && gid != 0 {
+			return glyph(gid), true
+		}
+	}
+	// Without a usable cmap, codes are often glyph indexes.
+	return glyph(c), true
+}
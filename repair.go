@@ -0,0 +1,328 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Repair of files whose cross-reference data is damaged.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A fileScan is the result of scanning the whole file for the
+// definitions of objects, "N G obj", and for trailer keywords,
+// without the help of the cross-reference table.
+type fileScan struct {
+	objs     map[uint32]xref // the last definition of each object
+	trailers []int64         // offsets of trailer keywords, in file order
+	err      error
+}
+
+// scanFile scans the file for object definitions and trailers.
+// The scan is done once, when first needed.
+func (r *Reader) scanFile(ctx context.Context) *fileScan {
+	r.scanOnce.Do(func() {
+		r.scan = scanObjects(ctx, r.f, r.end)
+	})
+	return r.scan
+}
+
+// scanObjects scans the size bytes of f for object definitions and
+// trailer keywords. Each keyword must be a token by itself, and an
+// object definition's numbers must precede its obj keyword.
+// Text in strings and streams that looks like a definition is not
+// told apart from one; as in other readers, a later definition of
+// an object replaces an earlier one. If ctx is done first, the scan
+// stops with its error.
+func scanObjects(ctx context.Context, f io.ReaderAt, size int64) *fileScan {
+	const (
+		chunk = 1 << 16
+		back  = 64 // how far to look back from obj for the object number
+		ahead = 8  // how far to look past a keyword for the byte after it
+	)
+	s := &fileScan{objs: make(map[uint32]xref)}
+	buf := make([]byte, back+chunk+ahead)
+	for off := int64(0); off < size; off += chunk {
+		if err := ctx.Err(); err != nil {
+			s.err = err
+			return s
+		}
+		start := off - back
+		if start < 0 {
+			start = 0
+		}
+		end := off + chunk + ahead
+		if end > size {
+			end = size
+		}
+		data := buf[:end-start]
+		if n, err := f.ReadAt(data, start); n < len(data) {
+			s.err = fmt.Errorf("malformed PDF: reading at offset %d: %v", start+int64(n), err)
+			return s
+		}
+		// Keywords are taken from this chunk alone, not the overlap.
+		lo, hi := int(off-start), int(off-start)+chunk
+		if hi > len(data) {
+			hi = len(data)
+		}
+		for _, i := range keywordsAt(data, "obj", lo, hi) {
+			id, gen, at, ok := objectNumber(data, i)
+			if ok && (at > 0 || start == 0) {
+				ptr := objptr{uint32(id), uint16(gen)}
+				s.objs[ptr.id] = xref{ptr: ptr, offset: start + int64(at)}
+			}
+		}
+		for _, i := range keywordsAt(data, "trailer", lo, hi) {
+			s.trailers = append(s.trailers, start+int64(i))
+		}
+	}
+	return s
+}
+
+// keywordsAt returns the indexes in data, from lo up to hi, at which the
+// keyword kw appears as a token of its own: neither preceded nor followed
+// by a regular character.
+func keywordsAt(data []byte, kw string, lo, hi int) []int {
+	var out []int
+	for i := lo; i < hi; {
+		j := bytes.Index(data[i:], []byte(kw))
+		if j < 0 || i+j >= hi {
+			break
+		}
+		i += j
+		before := i == 0 || isSpace(data[i-1]) || isDelim(data[i-1])
+		after := i+len(kw) == len(data) || isSpace(data[i+len(kw)]) || isDelim(data[i+len(kw)])
+		if before && after {
+			out = append(out, i)
+		}
+		i += len(kw)
+	}
+	return out
+}
+
+// objectNumber parses the object and generation numbers preceding the
+// obj keyword at data[i], returning them and the index of the first.
+// The index is 0 if the numbers start data, in which case they may
+// continue before it.
+func objectNumber(data []byte, i int) (id, gen int64, at int, ok bool) {
+	// number reads the digits, after white space, ending at data[i],
+	// backward.
+	number := func() (int64, bool) {
+		j := i
+		for j > 0 && isSpace(data[j-1]) {
+			j--
+		}
+		if j == i {
+			return 0, false
+		}
+		k := j
+		for k > 0 && j-k < 10 && '0' <= data[k-1] && data[k-1] <= '9' {
+			k--
+		}
+		if k == j {
+			return 0, false
+		}
+		var n int64
+		for _, c := range data[k:j] {
+			n = n*10 + int64(c-'0')
+		}
+		i = k
+		return n, true
+	}
+	gen, ok1 := number()
+	id, ok2 := number()
+	if !ok1 || !ok2 || id <= 0 || id != int64(uint32(id)) || gen != int64(uint16(gen)) {
+		return 0, 0, 0, false
+	}
+	if i > 0 && !isSpace(data[i-1]) && !isDelim(data[i-1]) {
+		return 0, 0, 0, false
+	}
+	return id, gen, i, true
+}
+
+// rebuildXref replaces the cross-reference table, which could not be
+// read because of cause, with one rebuilt from a scan of the file, and
+// finds the trailer: the last trailer dictionary or cross-reference
+// stream whose Root is a dictionary or, failing that, a trailer naming
+// the last document catalog in the file. Objects in object streams are
+// found by reading the object streams the scan finds.
+// What was rebuilt is recorded as Problems.
+func (r *Reader) rebuildXref(ctx context.Context, cause error) error {
+	s := r.scanFile(ctx)
+	if s.err != nil {
+		return s.err
+	}
+	if len(s.objs) == 0 {
+		return fmt.Errorf("%v; no objects found to rebuild it from", cause)
+	}
+	var defs []xref
+	for _, x := range s.objs {
+		defs = append(defs, x)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].offset < defs[j].offset })
+	r.xref = nil
+	for _, x := range defs {
+		if err := r.checkObject(int64(x.ptr.id)); err != nil {
+			return err
+		}
+		r.xref = growXref(r.xref, x.ptr.id)
+		r.xref[x.ptr.id] = x
+	}
+	r.trailer, r.trailerptr, r.startxref = nil, objptr{}, 0
+	r.repaired = true
+
+	var (
+		cands   []trailerCandidate
+		catalog objptr
+		stms    []Value
+	)
+	for _, x := range defs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		v, err := r.resolve(objptr{}, x.ptr)
+		if err != nil {
+			continue
+		}
+		typ, _ := v.Key("Type")
+		switch typ.Name() {
+		case "Catalog":
+			catalog = x.ptr
+		case "XRef":
+			if strm, ok := v.data.(stream); ok {
+				cands = append(cands, trailerCandidate{x.offset, x.ptr, strm.hdr})
+			}
+		case "ObjStm":
+			stms = append(stms, v)
+		}
+	}
+	for _, off := range s.trailers {
+		b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off)
+		b.owner = r
+		if tok, err := b.readToken(); err != nil || tok != keyword("trailer") {
+			continue
+		}
+		if obj, err := b.readObject(); err == nil {
+			if d, ok := obj.(dict); ok {
+				cands = append(cands, trailerCandidate{off, objptr{}, d})
+			}
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool { return cands[i].offset < cands[j].offset })
+
+	ncompressed := 0
+	for _, strm := range stms {
+		stm, err := r.objStm(strm)
+		if err != nil {
+			r.problem(-1, "object stream %v unreadable: %v", strm.ptr, err)
+			continue
+		}
+		for id := range stm.spans {
+			if id == 0 {
+				continue
+			}
+			if err := r.checkObject(int64(id)); err != nil {
+				return err
+			}
+			r.xref = growXref(r.xref, id)
+			if r.xref[id].ptr == (objptr{}) {
+				r.xref[id] = xref{ptr: objptr{id, 0}, inStream: true, stream: strm.ptr}
+				ncompressed++
+			}
+		}
+	}
+	r.problem(-1, "cross-reference table unusable (%v); rebuilt from %d objects found by scanning the file and %d in object streams", cause, len(defs), ncompressed)
+
+	for i := len(cands) - 1; i >= 0; i-- {
+		c := cands[i]
+		root, err := r.resolve(c.ptr, c.trailer["Root"])
+		if err != nil || root.Kind() != Dict {
+			continue
+		}
+		r.setTrailer(c.ptr, c.trailer)
+		r.problem(c.offset, "using trailer found by scanning the file")
+		break
+	}
+	if r.trailer == nil {
+		if catalog == (objptr{}) {
+			return fmt.Errorf("%v; no document catalog found", cause)
+		}
+		r.trailer = dict{"Root": catalog}
+		r.problem(r.xref[catalog.id].offset, "no trailer found; using document catalog %v", catalog)
+	}
+	r.trailer["Size"] = int64(len(r.xref))
+	return nil
+}
+
+// A trailerCandidate is a trailer dictionary or cross-reference stream
+// found without the help of the cross-reference table.
+type trailerCandidate struct {
+	offset  int64
+	ptr     objptr // the stream's object, for a cross-reference stream
+	trailer dict
+}
+
+// setTrailer sets the trailer to the document-wide entries of the
+// trailer dictionary or cross-reference stream header d, found without
+// the help of the cross-reference table: Root, Info, ID, and Encrypt.
+// For a stream, ptr is the stream's object.
+func (r *Reader) setTrailer(ptr objptr, d dict) {
+	r.trailer, r.trailerptr = make(dict), ptr
+	for _, k := range []name{"Root", "Info", "ID", "Encrypt"} {
+		if x, ok := d[k]; ok {
+			r.trailer[k] = x
+		}
+	}
+}
+
+// repairedXref returns the location of the object ptr found by scanning
+// the file, for an object that is not at the offset the cross-reference
+// table gives, or false if the scan finds no other definition of it.
+func (r *Reader) repairedXref(ptr objptr, bad xref) (xref, bool) {
+	s := r.scanFile(context.Background())
+	x, ok := s.objs[ptr.id]
+	if !ok || x.ptr != ptr || x.offset == bad.offset {
+		return xref{}, false
+	}
+	r.problem(bad.offset, "object %v not at offset given by cross-reference table; using definition at offset %d", ptr, x.offset)
+	return x, true
+}
+
+// growXref returns table extended, if needed, to have an entry for id.
+func growXref(table []xref, id uint32) []xref {
+	for cap(table) <= int(id) {
+		table = append(table[:cap(table)], xref{})
+	}
+	if len(table) <= int(id) {
+		table = table[:id+1]
+	}
+	return table
+}
+
+// repairHeader finds the %PDF header of a file whose first bytes are
+// not a valid header, reporting whether there is one. The header may
+// follow junk, such as a mail or HTTP header, in which case the file is
+// taken to start at the header, so that its offsets are counted from it,
+// as other readers do.
+func (r *Reader) repairHeader() bool {
+	const maxJunk = 1024
+	buf := make([]byte, maxJunk+len("%PDF-"))
+	n, _ := r.f.ReadAt(buf, 0)
+	i := bytes.Index(buf[:n], []byte("%PDF-"))
+	if i < 0 {
+		return false
+	}
+	if i > 0 {
+		r.f = io.NewSectionReader(r.f, int64(i), r.end-int64(i))
+		r.end -= int64(i)
+		r.problem(0, "%d bytes before %%PDF header; ignored", i)
+		return true
+	}
+	r.problem(0, "invalid version in %%PDF header; ignored")
+	return true
+}
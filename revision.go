@@ -0,0 +1,185 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A Revision is one revision of a document: the original file
+// or the file as extended by an incremental update.
+type Revision struct {
+	Start   int64 // offset of the revision's cross-reference section
+	End     int64 // length of the file up to the end of the revision, including its %%EOF line
+	Trailer Value // the trailer of the revision's cross-reference section
+
+	// Signature is the signature dictionary of a signature field whose
+	// ByteRange ends with the revision, so that it signs the document
+	// exactly as of this revision. It is null if there is none.
+	Signature Value
+}
+
+// Revisions returns the document's revisions, oldest first.
+// The revisions are found by following the Prev chain of cross-reference
+// sections back from the last one; each ends at the first %%EOF
+// marker after its cross-reference section. In a linearized file, the
+// cross-reference section for the first page, at the start of the file,
+// belongs to the revision of the main section, at the end.
+// The last revision is the document as r presents it; RevisionAt(rev.End)
+// returns a Reader for the document as of an earlier revision.
+func (r *Reader) Revisions() ([]Revision, error) {
+	if r.sequential {
+		return nil, errSequential
+	}
+	var revs []Revision
+	_, firstPage, linear := r.linearization()
+	seen := make(map[int64]bool)
+	for off := r.startxref; ; {
+		if seen[off] {
+			return nil, fmt.Errorf("malformed PDF: xref Prev loop at offset %d", off)
+		}
+		seen[off] = true
+		trailer, err := r.sectionTrailer(off)
+		if err != nil {
+			return nil, err
+		}
+		if !linear || off != firstPage {
+			end, err := r.revisionEnd(off)
+			if err != nil {
+				return nil, err
+			}
+			revs = append(revs, Revision{Start: off, End: end, Trailer: trailer})
+		}
+		prev, err := trailer.Key("Prev")
+		if err != nil {
+			return nil, err
+		}
+		if prev.Kind() != Integer {
+			break
+		}
+		off = prev.Int64()
+		if off < 0 || off >= r.end {
+			return nil, fmt.Errorf("malformed PDF: xref Prev offset %d outside file", off)
+		}
+	}
+	sort.Slice(revs, func(i, j int) bool {
+		return revs[i].End < revs[j].End
+	})
+
+	fields, err := r.Fields()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		typ, err := f.Type()
+		if err != nil {
+			return nil, err
+		}
+		if typ != "Sig" {
+			continue
+		}
+		sig, err := f.V.Key("V")
+		if err != nil {
+			return nil, err
+		}
+		br, err := sig.Key("ByteRange")
+		if err != nil {
+			return nil, err
+		}
+		if br.Len() != 4 {
+			continue
+		}
+		var x [4]int64
+		for i := range x {
+			xi, err := br.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = xi.Int64()
+		}
+		// The signed bytes end at %%EOF or after the end-of-line marker following it.
+		end := x[2] + x[3]
+		for i := range revs {
+			if revs[i].End-2 <= end && end <= revs[i].End && revs[i].Signature.IsNull() {
+				revs[i].Signature = sig
+				break
+			}
+		}
+	}
+	return revs, nil
+}
+
+// sectionTrailer returns the trailer of the single cross-reference
+// section at offset off, without following its Prev entry.
+func (r *Reader) sectionTrailer(off int64) (Value, error) {
+	b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off)
+	b.owner = r
+	tok, err := b.readToken()
+	if err != nil {
+		return Value{}, err
+	}
+	if tok == keyword("xref") {
+		// Skip the table to its trailer.
+		if _, err := readXrefTableData(r, b, nil); err != nil {
+			return Value{}, fmt.Errorf("malformed PDF: %v", err)
+		}
+		obj, err := b.readObject()
+		if err != nil {
+			return Value{}, err
+		}
+		trailer, ok := obj.(dict)
+		if !ok {
+			return Value{}, fmt.Errorf("malformed PDF: xref table at offset %d not followed by trailer dictionary", off)
+		}
+		return Value{r, objptr{}, trailer}, nil
+	}
+	b.unreadToken(tok)
+	obj, err := b.readObject()
+	if err != nil {
+		return Value{}, err
+	}
+	def, ok := obj.(objdef)
+	if !ok {
+		return Value{}, fmt.Errorf("malformed PDF: cross-reference section not found at offset %d: %v", off, objfmt(obj))
+	}
+	strm, ok := def.obj.(stream)
+	if !ok || strm.hdr["Type"] != name("XRef") {
+		return Value{}, fmt.Errorf("malformed PDF: cross-reference section not found at offset %d: %v", off, objfmt(def))
+	}
+	return Value{r, def.ptr, strm.hdr}, nil
+}
+
+// revisionEnd returns the offset just past the first %%EOF line after off.
+func (r *Reader) revisionEnd(off int64) (int64, error) {
+	const chunk = 32 << 10
+	marker := []byte("%%EOF")
+	buf := make([]byte, chunk+len(marker))
+	for pos := off; pos < r.end; pos += chunk {
+		n, err := r.f.ReadAt(buf, pos)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if rest := r.end - pos; int64(n) > rest {
+			n = int(rest)
+		}
+		if i := bytes.Index(buf[:n], marker); i >= 0 {
+			end := pos + int64(i+len(marker))
+			// Include the end-of-line marker, if any.
+			var eol [2]byte
+			m, _ := r.f.ReadAt(eol[:], end)
+			switch {
+			case m == 2 && eol[0] == '\r' && eol[1] == '\n':
+				end += 2
+			case m >= 1 && (eol[0] == '\r' || eol[0] == '\n'):
+				end++
+			}
+			return end, nil
+		}
+	}
+	return 0, fmt.Errorf("malformed PDF: no %%%%EOF after cross-reference section at offset %d", off)
+}
@@ -0,0 +1,93 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// updatePDF returns file with an incremental update appended that
+// replaces the text of page 1, which is object 3 with contents object 4.
+func updatePDF(t *testing.T, file []byte) []byte {
+	t.Helper()
+	r := openPDF(t, file, nil)
+	p, err := r.Page(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := p.V.Key("Contents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := r.Update(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("BT /F1 12 Tf 72 720 Td (updated) Tj ET")
+	if err := w.Write(contents, NewStream(NewDict(nil), data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(Value{}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRevisions(t *testing.T) {
+	ctx := context.Background()
+	plain := buildPDF(pageTree(2)...)
+	linear := linearizedPDF(t, pageTree(2)...)
+	for _, tt := range []struct {
+		name string
+		file []byte
+		ends []int // the ends of the revisions, oldest first
+	}{
+		{"plain", plain, []int{len(plain)}},
+		{"updated", updatePDF(t, plain), []int{len(plain), -1}},
+		{"linearized", linear, []int{len(linear)}},
+		{"updated linearized", updatePDF(t, linear), []int{len(linear), -1}},
+	} {
+		r := openPDF(t, tt.file, nil)
+		revs, err := r.Revisions()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var ends []int
+		for _, rev := range revs {
+			ends = append(ends, int(rev.End))
+		}
+		if n := len(tt.ends); tt.ends[n-1] < 0 {
+			tt.ends[n-1] = len(tt.file)
+		}
+		if len(ends) != len(tt.ends) {
+			t.Errorf("%s: revisions end at %v, want %v", tt.name, ends, tt.ends)
+			continue
+		}
+		for i := range ends {
+			if ends[i] != tt.ends[i] {
+				t.Errorf("%s: revisions end at %v, want %v", tt.name, ends, tt.ends)
+				break
+			}
+		}
+
+		// The oldest revision shows the original text.
+		old, err := r.RevisionAt(revs[0].End)
+		if err != nil {
+			t.Errorf("%s: RevisionAt: %v", tt.name, err)
+			continue
+		}
+		p, err := old.Page(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text, err := p.GetPlainText(ctx, nil); err != nil || text != "page 1" {
+			t.Errorf("%s: oldest revision page 1 text = %q, %v, want \"page 1\"", tt.name, text, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// A SearchMatch is an occurrence of the text searched for by Reader.Search.
+type SearchMatch struct {
+	Page  int    // the page number, starting at 1
+	S     string // the text matched, as shown on the page
+	Quads []Quad // the text matched, one quadrilateral for each run of it along a line, as for Annotation.QuadPoints
+}
+
+// Search returns the occurrences of query in the text of the document's
+// pages, in order, for uses such as drawing highlights over them.
+// Letters match regardless of case, and any run of white space in
+// query matches any run of white space, line breaks, or gaps between
+// glyphs on the page, so that a match may span text-showing operations
+// and lines. The glyphs are taken in the order they are drawn.
+// Coordinates are as selected by the Reader's TextOptions.
+func (r *Reader) Search(ctx context.Context, query string) ([]SearchMatch, error) {
+	var want []rune
+	for _, f := range strings.Fields(query) {
+		if len(want) > 0 {
+			want = append(want, ' ')
+		}
+		for _, c := range f {
+			want = append(want, unicode.ToLower(c))
+		}
+	}
+	if len(want) == 0 {
+		return nil, nil
+	}
+	var out []SearchMatch
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		glyphs, err := p.Glyphs(ctx)
+		if err != nil {
+			return err
+		}
+		text, from := searchText(glyphs)
+		for i := 0; i+len(want) <= len(text); i++ {
+			if !runesEqual(text[i:i+len(want)], want) {
+				continue
+			}
+			m := SearchMatch{Page: num}
+			var run []Glyph
+			last := -1
+			for _, g := range from[i : i+len(want)] {
+				if g < 0 {
+					// A space between glyphs that are apart.
+					m.S += " "
+					continue
+				}
+				if g == last {
+					continue
+				}
+				if len(run) > 0 && !continues(glyphs[last], glyphs[g]) {
+					m.Quads = append(m.Quads, runQuad(run))
+					run = nil
+				}
+				if strings.TrimFunc(glyphs[g].S, unicode.IsSpace) != "" {
+					run = append(run, glyphs[g])
+				}
+				m.S += glyphs[g].S
+				last = g
+			}
+			if len(run) > 0 {
+				m.Quads = append(m.Quads, runQuad(run))
+			}
+			out = append(out, m)
+			i += len(want) - 1
+		}
+		return nil
+	})
+	return out, err
+}
+
+// searchText returns the lower-cased text of glyphs, with runs of white
+// space, line breaks, and gaps between glyphs replaced by single spaces,
+// and the index of the glyph each rune of the text comes from, or -1 for
+// an inserted space.
+func searchText(glyphs []Glyph) (text []rune, from []int) {
+	space := func(g int) {
+		if n := len(text); n > 0 && text[n-1] != ' ' {
+			text = append(text, ' ')
+			from = append(from, g)
+		}
+	}
+	for i, g := range glyphs {
+		if i > 0 && !continues(glyphs[i-1], g) {
+			space(-1)
+		}
+		for _, c := range g.S {
+			if unicode.IsSpace(c) {
+				space(i)
+				continue
+			}
+			text = append(text, unicode.ToLower(c))
+			from = append(from, i)
+		}
+	}
+	return text, from
+}
+
+// continues reports whether the glyph g continues the text of the
+// glyph prev: whether it starts within a quarter of the font size of
+// where prev ends.
+func continues(prev, g Glyph) bool {
+	tol := math.Max(math.Abs(prev.FontSize), math.Abs(g.FontSize)) / 4
+	return math.Hypot(g.X-(prev.X+prev.DX), g.Y-(prev.Y+prev.DY)) <= tol
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runQuad returns the quadrilateral enclosing the run of glyphs, which
+// continue one another along a baseline. Without font metrics the
+// ascent and descent are taken to be 80% and 20% of the font size,
+// as in glyphRect.
+func runQuad(run []Glyph) Quad {
+	first, last := run[0], run[len(run)-1]
+	// The baseline runs in the direction of the glyphs' advance.
+	dx, dy := last.X+last.DX-first.X, last.Y+last.DY-first.Y
+	if d := math.Hypot(dx, dy); d > 0 {
+		dx, dy = dx/d, dy/d
+	} else {
+		dx, dy = 1, 0
+	}
+	size := 0.0
+	for _, g := range run {
+		size = math.Max(size, math.Abs(g.FontSize))
+	}
+	w := last.W
+	if w == 0 {
+		w = math.Hypot(last.DX, last.DY)
+	}
+	if w == 0 {
+		w = math.Abs(last.FontSize) / 2
+	}
+	start := Point{first.X, first.Y}
+	end := Point{last.X + w*dx, last.Y + w*dy}
+	up := func(pt Point, h float64) Point {
+		return Point{pt.X - dy*h, pt.Y + dx*h}
+	}
+	return Quad{up(start, 0.8*size), up(end, 0.8*size), up(start, -0.2*size), up(end, -0.2*size)}
+}
@@ -0,0 +1,519 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Reading of files in a single pass, front to back.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// errSequential is returned by the methods that need the bytes of a file
+// read with NewReaderSequential, which the Reader does not keep.
+var errSequential = errors.New("pdf: file read sequentially; its bytes are not kept")
+
+// NewReaderSequential reads a file from rd in a single pass, front to
+// back, without seeking: for a file arriving over a network connection,
+// for instance, that cannot be stored first. Rather than using the
+// cross-reference table, at the end of the file, it collects the
+// definitions of objects as they appear, keeping them in memory, and
+// takes the trailer from the last trailer dictionary or cross-reference
+// stream. A later definition of an object replaces an earlier one, as in
+// an incremental update. The file's other bytes, such as its
+// cross-reference tables, are dropped. Objects that cannot be parsed are
+// skipped and recorded as Problems, or are errors if opts is Strict.
+// Nil options are the zero Options.
+//
+// Because the Reader does not keep the whole file, it cannot make an
+// incremental update, return earlier revisions, or verify signatures;
+// Update, RevisionAt, Revisions, and Signature.Verify return errors.
+// Offsets in Problems count from the file's %PDF header.
+func NewReaderSequential(ctx context.Context, rd io.Reader, opts *Options) (r *Reader, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			r, err = nil, panicError(p)
+		}
+	}()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if opts == nil {
+		opts = new(Options)
+	}
+	file := new(sparseFile)
+	r = &Reader{
+		f:          file,
+		Limits:     DefaultLimits,
+		Strict:     opts.Strict,
+		repairs:    opts.Repairs,
+		sequential: true,
+	}
+	if opts.Limits != nil {
+		r.Limits = *opts.Limits
+	}
+	in := &seqInput{r: rd}
+	if err := r.seqHeader(in); err != nil {
+		return nil, err
+	}
+	p := &seqParser{r: r, in: in, file: file, b: newBuffer(in, 0)}
+	p.b.allowEOF = true
+	p.b.owner = r
+	if err := p.run(ctx); err != nil {
+		return nil, err
+	}
+
+	for i := len(p.trailers) - 1; i >= 0 && r.trailer == nil; i-- {
+		if c := p.trailers[i]; c.trailer["Root"] != nil {
+			r.setTrailer(c.ptr, c.trailer)
+		}
+	}
+	if r.trailer == nil {
+		if p.catalog == (objptr{}) {
+			return nil, fmt.Errorf("malformed PDF: no trailer or document catalog found")
+		}
+		r.trailer = dict{"Root": p.catalog}
+		r.problem(r.xref[p.catalog.id].offset, "no trailer found; using document catalog %v", p.catalog)
+	}
+	r.trailer["Size"] = int64(len(r.xref))
+	if err := r.decrypt(ctx, opts.Password); err != nil {
+		return nil, err
+	}
+	// Object streams can be read only once the file is decrypted.
+	if err := p.indexObjStms(ctx); err != nil {
+		return nil, err
+	}
+	r.trailer["Size"] = int64(len(r.xref))
+	return r, nil
+}
+
+// seqHeader checks the header at the start of in. Junk before the
+// header is dropped, if RepairHeader allows it, so that offsets count
+// from the header.
+func (r *Reader) seqHeader(in *seqInput) error {
+	const maxJunk = 1024
+	head := in.fill(maxJunk + 10)
+	if validHeader(head) {
+		return nil
+	}
+	i := bytes.Index(head, []byte("%PDF-"))
+	if i < 0 || !r.repair(RepairHeader) {
+		return fmt.Errorf("not a PDF file: invalid header")
+	}
+	if i > 0 {
+		in.buf = in.buf[i:]
+		r.problem(0, "%d bytes before %%PDF header; ignored", i)
+		return nil
+	}
+	r.problem(0, "invalid version in %%PDF header; ignored")
+	return nil
+}
+
+// A seqParser collects the objects of a file read sequentially.
+type seqParser struct {
+	r    *Reader
+	in   *seqInput
+	file *sparseFile
+	b    *buffer
+
+	trailers []trailerCandidate // in file order
+	catalog  objptr             // the last document catalog
+	objStms  []objptr           // object streams, in file order
+}
+
+// run reads the whole input, collecting object definitions and trailers.
+func (p *seqParser) run(ctx context.Context) error {
+	b := p.b
+	// The header line is not part of the first object.
+	for c := b.readByte(); c != '\n' && c != '\r' && !b.eof; c = b.readByte() {
+	}
+
+	// An object definition is found by its obj keyword, and begins with
+	// the two tokens before it. Their offsets are unknown, -1, if they
+	// were read again after a parse looked ahead.
+	type tokenAt struct {
+		tok token
+		off int64
+	}
+	var prev [2]tokenAt
+	for n := 0; ; n++ {
+		if n%256 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if prev[0].off > 0 {
+			p.in.discard(prev[0].off)
+		}
+		off := int64(-1)
+		if len(b.unread) == 0 {
+			off = b.readOffset()
+		}
+		tok, err := b.readToken()
+		if err != nil {
+			if b.err != nil {
+				return b.err
+			}
+			if p.r.Strict {
+				return fmt.Errorf("malformed PDF: offset %d: %v", off, err)
+			}
+			p.r.problem(off, "unreadable data skipped: %v", err)
+			prev = [2]tokenAt{}
+			continue
+		}
+		if tok == io.EOF {
+			return nil
+		}
+		switch tok {
+		case keyword("obj"):
+			id, ok1 := prev[0].tok.(int64)
+			gen, ok2 := prev[1].tok.(int64)
+			if ok1 && ok2 && prev[0].off > 0 && id > 0 && id == int64(uint32(id)) && gen == int64(uint16(gen)) {
+				if err := p.r.checkObject(id); err != nil {
+					return err
+				}
+				if err := p.object(prev[0].off, objptr{uint32(id), uint16(gen)}); err != nil {
+					return err
+				}
+			}
+			prev = [2]tokenAt{}
+			continue
+		case keyword("trailer"):
+			obj, err := b.readObject()
+			if d, ok := obj.(dict); err == nil && ok {
+				p.trailers = append(p.trailers, trailerCandidate{off, objptr{}, d})
+			}
+			prev = [2]tokenAt{}
+			continue
+		}
+		prev[0], prev[1] = prev[1], tokenAt{tok, off}
+	}
+}
+
+// object reads the definition of the object ptr, from its obj keyword
+// on, and adds it to the file. The definition starts at offset start.
+func (p *seqParser) object(start int64, ptr objptr) error {
+	b := p.b
+	b.objptr = ptr
+	obj, err := b.readObject()
+	b.objptr = objptr{}
+	if err != nil {
+		return p.skip(start, ptr, err)
+	}
+	if s, ok := obj.(stream); ok {
+		ended, err := p.streamEnd(s)
+		if err != nil {
+			return p.skip(start, ptr, err)
+		}
+		if !ended {
+			// The input ends within the stream's data.
+			if !p.r.repair(RepairTruncatedStreams) {
+				return p.skip(start, ptr, fmt.Errorf("stream %v not ended by endstream", ptr))
+			}
+			p.add(start, p.in.end(), ptr, obj)
+			return nil
+		}
+	}
+	fromInput := len(b.unread) == 0
+	off := b.readOffset()
+	tok, err := b.readToken()
+	if err != nil {
+		return p.skip(start, ptr, err)
+	}
+	if tok != keyword("endobj") {
+		if _, ok := obj.(stream); ok {
+			// As when reading at random, endobj after a stream is optional.
+			p.in.seek(b, off)
+			p.add(start, off, ptr, obj)
+			return nil
+		}
+		if fromInput {
+			// Let the token be read again, from the input, as the
+			// possible start of the next object.
+			p.in.seek(b, off)
+		}
+		return p.skip(start, ptr, errors.New("missing endobj after indirect object definition"))
+	}
+	p.add(start, b.readOffset(), ptr, obj)
+	return nil
+}
+
+// streamEnd moves past the data of the stream s and its endstream
+// keyword. The data ends after Length bytes or, if that does not put
+// it before endstream, at the first endstream after its start.
+// streamEnd reports false if the input ends first.
+func (p *seqParser) streamEnd(s stream) (bool, error) {
+	b := p.b
+	length := int64(-1)
+	switch l := s.hdr["Length"].(type) {
+	case int64:
+		length = l
+	case objptr:
+		// An indirect Length is often defined after the stream,
+		// in which case the data is found by its end.
+		if v, err := p.r.resolve(objptr{}, l); err == nil && v.Kind() == Integer {
+			length = v.Int64()
+		}
+	}
+	if length >= 0 {
+		if err := b.seekForward(s.offset + length); err != nil {
+			return false, err
+		}
+		tok, err := b.readToken()
+		if err != nil {
+			return false, err
+		}
+		if tok == keyword("endstream") {
+			return true, nil
+		}
+		p.r.problem(s.offset, "stream %v Length %d does not end at endstream", s.ptr, length)
+	}
+	end, ok := p.in.find(s.offset, []byte("endstream"))
+	if !ok {
+		return false, nil
+	}
+	p.in.seek(b, end)
+	_, err := b.readToken()
+	return true, err
+}
+
+// skip handles the definition of the object ptr, at offset start,
+// that cannot be read because of err: it is skipped, as a Problem,
+// or is an error if the Reader is strict.
+func (p *seqParser) skip(start int64, ptr objptr, err error) error {
+	if p.b.err != nil {
+		return p.b.err
+	}
+	if p.r.Strict {
+		return fmt.Errorf("malformed PDF: object %v at offset %d: %v", ptr, start, err)
+	}
+	p.r.problem(start, "object %v unreadable, skipped: %v", ptr, err)
+	return nil
+}
+
+// add adds the definition of the object ptr, obj, at file offsets
+// start up to end, to the file.
+func (p *seqParser) add(start, end int64, ptr objptr, obj object) {
+	r := p.r
+	p.file.add(start, p.in.bytes(start, end))
+	r.end = p.file.size
+	r.xref = growXref(r.xref, ptr.id)
+	r.xref[ptr.id] = xref{ptr: ptr, offset: start}
+
+	var hdr dict
+	switch x := obj.(type) {
+	case dict:
+		hdr = x
+	case stream:
+		hdr = x.hdr
+	}
+	switch hdr["Type"] {
+	case name("Catalog"):
+		p.catalog = ptr
+	case name("ObjStm"):
+		if _, ok := obj.(stream); ok {
+			p.objStms = append(p.objStms, ptr)
+		}
+	case name("XRef"):
+		p.trailers = append(p.trailers, trailerCandidate{start, ptr, hdr})
+	}
+}
+
+// indexObjStms adds the objects in the object streams to the
+// cross-reference table. An object replaces those defined earlier in
+// the file, and is replaced by those defined later.
+func (p *seqParser) indexObjStms(ctx context.Context) error {
+	r := p.r
+	// at returns the offset at which the entry x was defined.
+	at := func(x xref) int64 {
+		if x.inStream {
+			return r.xref[x.stream.id].offset
+		}
+		return x.offset
+	}
+	for _, ptr := range p.objStms {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if r.xref[ptr.id].ptr != ptr {
+			continue // replaced by a later definition
+		}
+		strm, err := r.resolve(objptr{}, ptr)
+		if err != nil {
+			return err
+		}
+		stm, err := r.objStm(strm)
+		if err != nil {
+			if r.Strict {
+				return err
+			}
+			r.problem(r.xref[ptr.id].offset, "object stream %v unreadable: %v", ptr, err)
+			continue
+		}
+		off := r.xref[ptr.id].offset
+		for id := range stm.spans {
+			if id == 0 {
+				continue
+			}
+			if err := r.checkObject(int64(id)); err != nil {
+				return err
+			}
+			r.xref = growXref(r.xref, id)
+			if x := r.xref[id]; x.ptr == (objptr{}) || at(x) < off {
+				r.xref[id] = xref{ptr: objptr{id, 0}, inStream: true, stream: ptr}
+			}
+		}
+	}
+	return nil
+}
+
+// A seqInput reads the input of a sequential Reader. It keeps the
+// bytes read since those of the object being parsed began, to store
+// the object and to reread the data of a stream whose Length is wrong.
+type seqInput struct {
+	r    io.Reader
+	err  error  // the error that ended r
+	base int64  // the file offset of buf[0]
+	buf  []byte // the bytes kept
+	pos  int    // index in buf of the next byte for Read
+}
+
+func (in *seqInput) Read(p []byte) (int, error) {
+	if in.pos == len(in.buf) && !in.more() {
+		return 0, in.err
+	}
+	n := copy(p, in.buf[in.pos:])
+	in.pos += n
+	return n, nil
+}
+
+// more reads more of the input into buf, reporting whether there was any.
+func (in *seqInput) more() bool {
+	if in.err != nil {
+		return false
+	}
+	var tmp [32 << 10]byte
+	n, err := io.ReadAtLeast(in.r, tmp[:], 1)
+	in.buf = append(in.buf, tmp[:n]...)
+	if err != nil {
+		in.err = err
+	}
+	return n > 0
+}
+
+// fill reads until at least n bytes are kept, or the input ends,
+// and returns the bytes kept.
+func (in *seqInput) fill(n int) []byte {
+	for len(in.buf) < n && in.more() {
+	}
+	return in.buf
+}
+
+// end returns the offset of the end of the input read so far.
+func (in *seqInput) end() int64 {
+	return in.base + int64(len(in.buf))
+}
+
+// seek moves b, reading from in, back or forward to offset off,
+// which must be kept.
+func (in *seqInput) seek(b *buffer, off int64) {
+	in.pos = int(off - in.base)
+	b.seek(off)
+}
+
+// find returns the offset of the first s at or after offset from,
+// reading as much of the input as needed. It reports false if the
+// input ends first.
+func (in *seqInput) find(from int64, s []byte) (int64, bool) {
+	i := int(from - in.base)
+	for {
+		if j := bytes.Index(in.buf[i:], s); j >= 0 {
+			return in.base + int64(i+j), true
+		}
+		// s may straddle the bytes read so far and the next ones.
+		if k := len(in.buf) - len(s) + 1; k > i {
+			i = k
+		}
+		if !in.more() {
+			return 0, false
+		}
+	}
+}
+
+// bytes returns a copy of the bytes from offset from up to offset to.
+func (in *seqInput) bytes(from, to int64) []byte {
+	return append([]byte(nil), in.buf[from-in.base:to-in.base]...)
+}
+
+// discard drops the bytes before offset to, which have been read.
+func (in *seqInput) discard(to int64) {
+	k := int(to - in.base)
+	if k <= 0 {
+		return
+	}
+	n := copy(in.buf, in.buf[k:])
+	in.buf = in.buf[:n]
+	in.pos -= k
+	in.base = to
+}
+
+// A sparseFile holds the object definitions of a file read sequentially,
+// at their offsets in the file. Reads between them return spaces.
+type sparseFile struct {
+	segs []segment // in order of offset, not overlapping
+	size int64
+}
+
+// A segment is data at an offset in a sparseFile.
+type segment struct {
+	off  int64
+	data []byte
+}
+
+// add adds data at offset off, which is after the data already added.
+func (f *sparseFile) add(off int64, data []byte) {
+	f.segs = append(f.segs, segment{off, data})
+	if end := off + int64(len(data)); end > f.size {
+		f.size = end
+	}
+}
+
+func (f *sparseFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("pdf: negative offset")
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if int64(n) > f.size-off {
+		n = int(f.size - off)
+	}
+	// i is the first segment ending after off.
+	i := sort.Search(len(f.segs), func(i int) bool {
+		return f.segs[i].off+int64(len(f.segs[i].data)) > off
+	})
+	for k := 0; k < n; {
+		pos := off + int64(k)
+		if i < len(f.segs) && f.segs[i].off <= pos {
+			k += copy(p[k:n], f.segs[i].data[pos-f.segs[i].off:])
+			i++
+			continue
+		}
+		gap := n
+		if i < len(f.segs) && f.segs[i].off-off < int64(n) {
+			gap = int(f.segs[i].off - off)
+		}
+		for ; k < gap; k++ {
+			p[k] = ' '
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,340 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Glyph outlines of TrueType and OpenType font programs, for Page.Render.
+
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// An sfnt is a TrueType or OpenType font program.
+type sfnt struct {
+	tables map[string][]byte
+	upem   float64 // units per em
+	loca   []int   // offsets of the glyphs in the glyf table
+	cff    *cffFont
+}
+
+// parseSFNT parses the TrueType or OpenType font program data.
+func parseSFNT(data []byte) (_ *sfnt, err error) {
+	defer catchPanic(&err)
+	if len(data) < 12 {
+		return nil, errors.New("malformed font: too short")
+	}
+	f := &sfnt{tables: make(map[string][]byte), upem: 1000}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			break
+		}
+		off := int(binary.BigEndian.Uint32(data[rec+8:]))
+		size := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if off < 0 || size < 0 || off > len(data) || size > len(data)-off {
+			continue
+		}
+		f.tables[string(data[rec:rec+4])] = data[off : off+size]
+	}
+	if head := f.tables["head"]; len(head) >= 54 {
+		if u := binary.BigEndian.Uint16(head[18:]); u != 0 {
+			f.upem = float64(u)
+		}
+		long := binary.BigEndian.Uint16(head[50:]) != 0
+		if loca := f.tables["loca"]; f.tables["glyf"] != nil {
+			if long {
+				for i := 0; i+4 <= len(loca); i += 4 {
+					f.loca = append(f.loca, int(binary.BigEndian.Uint32(loca[i:])))
+				}
+			} else {
+				for i := 0; i+2 <= len(loca); i += 2 {
+					f.loca = append(f.loca, 2*int(binary.BigEndian.Uint16(loca[i:])))
+				}
+			}
+		}
+	}
+	if data := f.tables["CFF "]; data != nil {
+		cff, err := parseCFF(data)
+		if err != nil {
+			return nil, err
+		}
+		f.cff = cff
+	}
+	if f.loca == nil && f.cff == nil {
+		return nil, errors.New("malformed font: no glyph outlines")
+	}
+	return f, nil
+}
+
+// glyph returns the outline of glyph gid, in glyph space units,
+// where the em square is 1 unit high.
+func (f *sfnt) glyph(gid int) []PathSegment {
+	if f.cff != nil {
+		return f.cff.glyph(gid)
+	}
+	var segs []PathSegment
+	f.glyf(gid, Identity, 0, &segs)
+	return segs
+}
+
+// glyf appends the outline of the TrueType glyph gid, transformed by m,
+// to segs. depth counts the enclosing composite glyphs.
+func (f *sfnt) glyf(gid int, m Matrix, depth int, segs *[]PathSegment) {
+	if gid < 0 || gid+1 >= len(f.loca) || depth > 8 {
+		return
+	}
+	glyf := f.tables["glyf"]
+	start, end := f.loca[gid], f.loca[gid+1]
+	if start >= end || end > len(glyf) || end-start < 10 {
+		return
+	}
+	g := glyf[start:end]
+	u16 := func(off int) int {
+		if off < 0 || off+2 > len(g) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(g[off:]))
+	}
+	scale := 1 / f.upem
+	contours := int(int16(u16(0)))
+	if contours < 0 {
+		// A composite glyph: transformed copies of other glyphs.
+		const (
+			argsAreWords  = 0x0001
+			argsAreXY     = 0x0002
+			haveScale     = 0x0008
+			moreComps     = 0x0020
+			haveXYScale   = 0x0040
+			haveTwoByTwo  = 0x0080
+			f2dot14Factor = 1.0 / 16384
+		)
+		off := 10
+		for {
+			flags, comp := u16(off), u16(off+2)
+			off += 4
+			var dx, dy float64
+			if flags&argsAreWords != 0 {
+				dx, dy = float64(int16(u16(off))), float64(int16(u16(off+2)))
+				off += 4
+			} else {
+				dx, dy = float64(int8(u16(off)>>8)), float64(int8(u16(off)))
+				off += 2
+			}
+			if flags&argsAreXY == 0 {
+				// Components positioned by matching points are not supported.
+				dx, dy = 0, 0
+			}
+			cm := Identity
+			switch {
+			case flags&haveScale != 0:
+				s := float64(int16(u16(off))) * f2dot14Factor
+				cm[0], cm[3] = s, s
+				off += 2
+			case flags&haveXYScale != 0:
+				cm[0] = float64(int16(u16(off))) * f2dot14Factor
+				cm[3] = float64(int16(u16(off+2))) * f2dot14Factor
+				off += 4
+			case flags&haveTwoByTwo != 0:
+				cm[0] = float64(int16(u16(off))) * f2dot14Factor
+				cm[1] = float64(int16(u16(off+2))) * f2dot14Factor
+				cm[2] = float64(int16(u16(off+4))) * f2dot14Factor
+				cm[3] = float64(int16(u16(off+6))) * f2dot14Factor
+				off += 8
+			}
+			// The offset is in font units; the outline is already scaled.
+			cm[4], cm[5] = dx*scale, dy*scale
+			f.glyf(comp, cm.Mul(m), depth+1, segs)
+			if flags&moreComps == 0 || off >= len(g) {
+				return
+			}
+		}
+	}
+
+	// A simple glyph: contours of on- and off-curve points.
+	ends := make([]int, contours)
+	for i := range ends {
+		ends[i] = u16(10 + 2*i)
+	}
+	if contours == 0 {
+		return
+	}
+	npts := ends[contours-1] + 1
+	off := 10 + 2*contours
+	off += 2 + u16(off) // instructions
+	flags := make([]byte, 0, npts)
+	for len(flags) < npts && off < len(g) {
+		fl := g[off]
+		off++
+		flags = append(flags, fl)
+		if fl&8 != 0 && off < len(g) {
+			for n := int(g[off]); n > 0 && len(flags) < npts; n-- {
+				flags = append(flags, fl)
+			}
+			off++
+		}
+	}
+	if len(flags) < npts {
+		return
+	}
+	coords := func(short, same byte) []float64 {
+		v := make([]float64, npts)
+		x := 0
+		for i, fl := range flags {
+			switch {
+			case fl&short != 0:
+				if off >= len(g) {
+					return nil
+				}
+				d := int(g[off])
+				off++
+				if fl&same == 0 {
+					d = -d
+				}
+				x += d
+			case fl&same == 0:
+				x += int(int16(u16(off)))
+				off += 2
+			}
+			v[i] = float64(x) * scale
+		}
+		return v
+	}
+	xs := coords(2, 16)
+	ys := coords(4, 32)
+	if xs == nil || ys == nil {
+		return
+	}
+
+	pt := func(i int) Point { return m.Apply(Point{xs[i], ys[i]}) }
+	mid := func(p, q Point) Point { return Point{(p.X + q.X) / 2, (p.Y + q.Y) / 2} }
+	first := 0
+	for _, last := range ends {
+		if last < first || last >= npts {
+			break
+		}
+		n := last - first + 1
+		on := func(i int) bool { return flags[first+(i%n)]&1 != 0 }
+		at := func(i int) Point { return pt(first + (i % n)) }
+		// Start at an on-curve point, or between two off-curve points.
+		var start Point
+		k := 0
+		switch {
+		case on(0):
+			start = at(0)
+		case on(n - 1):
+			start = at(n - 1)
+			k = -1
+		default:
+			start = mid(at(n-1), at(0))
+			k = -1
+		}
+		*segs = append(*segs, PathSegment{'m', []Point{start}})
+		cur := start
+		for i := k + 1; i <= k+n; i++ {
+			p := at(i + n)
+			if on(i + n) {
+				*segs = append(*segs, PathSegment{'l', []Point{p}})
+				cur = p
+				continue
+			}
+			next := at(i + n + 1)
+			if !on(i + n + 1) {
+				next = mid(p, next)
+			} else {
+				i++
+			}
+			*segs = append(*segs, quadTo(cur, p, next))
+			cur = next
+		}
+		*segs = append(*segs, PathSegment{Op: 'h'})
+		first = last + 1
+	}
+}
+
+// quadTo returns the cubic Bézier segment equivalent to the quadratic
+// one from p0 with control point c to p.
+func quadTo(p0, c, p Point) PathSegment {
+	return PathSegment{'c', []Point{
+		{p0.X + 2*(c.X-p0.X)/3, p0.Y + 2*(c.Y-p0.Y)/3},
+		{p.X + 2*(c.X-p.X)/3, p.Y + 2*(c.Y-p.Y)/3},
+		p,
+	}}
+}
+
+// cmapLookup returns the glyph for the character code c in the font's
+// cmap subtable for the given platform and encoding, and reports
+// whether the font has that subtable.
+func (f *sfnt) cmapLookup(platform, encoding int, c int) (gid int, ok bool) {
+	cmap := f.tables["cmap"]
+	u16 := func(off int) int {
+		if off < 0 || off+2 > len(cmap) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(cmap[off:]))
+	}
+	u32 := func(off int) int {
+		if off < 0 || off+4 > len(cmap) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint32(cmap[off:]))
+	}
+	sub := -1
+	for i, n := 0, u16(2); i < n; i++ {
+		rec := 4 + 8*i
+		if u16(rec) == platform && u16(rec+2) == encoding {
+			sub = u32(rec + 4)
+			break
+		}
+	}
+	if sub < 0 || sub >= len(cmap) {
+		return 0, false
+	}
+	switch u16(sub) {
+	case 0:
+		if c < 256 && sub+6+c < len(cmap) {
+			return int(cmap[sub+6+c]), true
+		}
+	case 4:
+		segs := u16(sub+6) / 2
+		ends := sub + 14
+		starts := ends + 2*segs + 2
+		deltas := starts + 2*segs
+		rangeOffs := deltas + 2*segs
+		for i := 0; i < segs; i++ {
+			if c > u16(ends+2*i) {
+				continue
+			}
+			start := u16(starts + 2*i)
+			if c < start {
+				break
+			}
+			delta, ro := u16(deltas+2*i), u16(rangeOffs+2*i)
+			if ro == 0 {
+				return (c + delta) & 0xffff, true
+			}
+			if g := u16(rangeOffs + 2*i + ro + 2*(c-start)); g != 0 {
+				return (g + delta) & 0xffff, true
+			}
+			break
+		}
+	case 6:
+		first, count := u16(sub+6), u16(sub+8)
+		if c >= first && c < first+count {
+			return u16(sub + 10 + 2*(c-first)), true
+		}
+	case 12:
+		for i, n := 0, u32(sub+12); i < n; i++ {
+			g := sub + 16 + 12*i
+			if g+12 > len(cmap) {
+				break
+			}
+			if start, end := u32(g), u32(g+4); start <= c && c <= end {
+				return u32(g+8) + c - start, true
+			}
+		}
+	}
+	return 0, true
+}
@@ -0,0 +1,426 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// SignOptions describes a signature for Reader.Sign to add.
+type SignOptions struct {
+	Signer      crypto.Signer       // the signer's private key
+	Certificate *x509.Certificate   // the signer's certificate, for Signer's public key
+	Chain       []*x509.Certificate // intermediate certificates to include, if any
+	Hash        crypto.Hash         // the digest algorithm; 0 means SHA-256
+
+	// PAdES selects a PAdES baseline B-B signature (ETSI.CAdES.detached)
+	// instead of a plain CMS signature (adbe.pkcs7.detached).
+	PAdES bool
+
+	// Field is the name of the signature field to sign. If the document
+	// has an unsigned signature field of that name, it is signed;
+	// otherwise a new, invisible field is added to the page numbered Page
+	// (or 1). If Field is empty, the new field is called Signature1,
+	// or Signature2 if that name is taken, and so on.
+	Field string
+	Page  int
+
+	Name        string    // the name of the signer
+	Reason      string    // the reason for signing
+	Location    string    // the place of signing
+	ContactInfo string    // how to reach the signer
+	Time        time.Time // the time of signing; zero means now
+
+	// Size is the number of bytes to reserve for the signature.
+	// Zero means enough for the certificates and a few kilobytes more.
+	Size int
+}
+
+// Sign writes to w the document read by r followed by an incremental
+// update (see Reader.Update) that signs it with a detached CMS signature
+// made by opts.Signer.
+//
+// The update is assembled in memory, so that the signature can be
+// computed over the finished file and filled in.
+func (r *Reader) Sign(ctx context.Context, w io.Writer, opts SignOptions) error {
+	if opts.Signer == nil || opts.Certificate == nil {
+		return errors.New("pdf: Sign requires a Signer and its Certificate")
+	}
+	if pub, ok := opts.Signer.Public().(interface{ Equal(crypto.PublicKey) bool }); ok && !pub.Equal(opts.Certificate.PublicKey) {
+		return errors.New("pdf: signing certificate does not match the Signer's key")
+	}
+	if opts.Hash == 0 {
+		opts.Hash = crypto.SHA256
+	}
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = len(opts.Certificate.Raw) + 4096
+		for _, c := range opts.Chain {
+			size += len(c.Raw)
+		}
+	}
+
+	var buf bytes.Buffer
+	pw, err := r.Update(&buf)
+	if err != nil {
+		return err
+	}
+	if pw.encrypting() {
+		// The signature itself must not be encrypted.
+		return errors.New("pdf: cannot sign an encrypted document")
+	}
+	subFilter := "adbe.pkcs7.detached"
+	if opts.PAdES {
+		subFilter = "ETSI.CAdES.detached"
+	}
+	byteRange := &mark{v: array{int64(0), int64(signPlaceholder), int64(signPlaceholder), int64(signPlaceholder)}}
+	contents := &mark{v: string(make([]byte, size))}
+	sig := map[string]Value{
+		"Type":      NewName("Sig"),
+		"Filter":    NewName("Adobe.PPKLite"),
+		"SubFilter": NewName(subFilter),
+		"M":         NewString(formatDate(opts.Time)),
+		// Placeholders, filled in once the file is complete.
+		"ByteRange": {data: byteRange},
+		"Contents":  {data: contents},
+	}
+	for k, s := range map[string]string{
+		"Name":        opts.Name,
+		"Reason":      opts.Reason,
+		"Location":    opts.Location,
+		"ContactInfo": opts.ContactInfo,
+	} {
+		if s != "" {
+			sig[k] = NewText(s)
+		}
+	}
+	sigRef, err := pw.Add(NewDict(sig))
+	if err != nil {
+		return err
+	}
+	if err := pw.addSignatureField(ctx, opts, sigRef); err != nil {
+		return err
+	}
+	if err := pw.Close(Value{}); err != nil {
+		return err
+	}
+
+	// Fill in the placeholders, at the offsets they were written at.
+	data := buf.Bytes()
+	brStart, brEnd := int(byteRange.start), int(byteRange.end)
+	hole, holeEnd := int(contents.start), int(contents.end)
+
+	br := fmt.Sprintf("[0 %d %d %d", hole, holeEnd, len(data)-holeEnd)
+	if len(br)+1 > brEnd-brStart {
+		return errors.New("pdf: signature byte range does not fit its placeholder")
+	}
+	copy(data[brStart:], br)
+	for i := brStart + len(br); i < brEnd-1; i++ {
+		data[i] = ' '
+	}
+
+	h := opts.Hash.New()
+	h.Write(data[:hole])
+	h.Write(data[holeEnd:])
+	cms, err := signCMS(opts, h.Sum(nil))
+	if err != nil {
+		return err
+	}
+	if len(cms) > size {
+		return fmt.Errorf("pdf: signature of %d bytes does not fit in the %d reserved", len(cms), size)
+	}
+	const hexDigits = "0123456789abcdef"
+	for i, b := range cms {
+		data[hole+1+2*i] = hexDigits[b>>4]
+		data[hole+2+2*i] = hexDigits[b&15]
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// signPlaceholder is written for each computed number of a ByteRange,
+// to reserve room for it.
+const signPlaceholder = 9999999999
+
+// addSignatureField sets the value of the signature field named by
+// opts.Field to sig, adding the field if the document has no unsigned
+// signature field of that name.
+func (w *Writer) addSignatureField(ctx context.Context, opts SignOptions, sig Value) error {
+	r := w.update
+	fields, err := r.Fields()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, f := range fields {
+		name, err := f.Name()
+		if err != nil {
+			return err
+		}
+		names[name] = true
+		if name != opts.Field || opts.Field == "" {
+			continue
+		}
+		typ, err := f.Type()
+		if err != nil {
+			return err
+		}
+		v, err := f.V.Key("V")
+		if err != nil {
+			return err
+		}
+		if typ != "Sig" || !v.IsNull() {
+			return fmt.Errorf("pdf: form field %q is not an unsigned signature field", name)
+		}
+		if !isIndirect(f.V) {
+			return fmt.Errorf("pdf: form field %q is not an indirect object", name)
+		}
+		entries, err := dictEntries(f.V)
+		if err != nil {
+			return err
+		}
+		entries["V"] = sig
+		if err := w.Write(f.V, NewDict(entries)); err != nil {
+			return err
+		}
+		return w.setAcroForm(Value{})
+	}
+
+	name := opts.Field
+	for i := 1; name == ""; i++ {
+		if n := fmt.Sprintf("Signature%d", i); !names[n] {
+			name = n
+		}
+	}
+	num := opts.Page
+	if num == 0 {
+		num = 1
+	}
+	p, err := r.Page(ctx, num)
+	if err != nil {
+		return err
+	}
+	if !isIndirect(p.V) {
+		return fmt.Errorf("pdf: page %d is not an indirect object", num)
+	}
+	// An invisible widget merged with its field.
+	field, err := w.Add(NewDict(map[string]Value{
+		"FT":      NewName("Sig"),
+		"T":       NewText(name),
+		"V":       sig,
+		"Type":    NewName("Annot"),
+		"Subtype": NewName("Widget"),
+		"Rect":    NewArray(NewInt(0), NewInt(0), NewInt(0), NewInt(0)),
+		"F":       NewInt(int64(AnnotPrint | AnnotLocked)),
+		"P":       p.V,
+	}))
+	if err != nil {
+		return err
+	}
+	old, err := p.V.Key("Annots")
+	if err != nil {
+		return err
+	}
+	var annots []Value
+	for i := 0; i < old.Len(); i++ {
+		annots = append(annots, Value{old.r, old.ptr, old.data.(array)[i]})
+	}
+	entries, err := dictEntries(p.V)
+	if err != nil {
+		return err
+	}
+	entries["Annots"] = NewArray(append(annots, field)...)
+	if err := w.Write(p.V, NewDict(entries)); err != nil {
+		return err
+	}
+	return w.setAcroForm(field)
+}
+
+// setAcroForm writes the interactive form dictionary of the document
+// being updated, marking it as signed and adding field, if not null,
+// to its fields.
+func (w *Writer) setAcroForm(field Value) error {
+	catalog, err := w.update.Trailer().Key("Root")
+	if err != nil {
+		return err
+	}
+	form, err := catalog.Key("AcroForm")
+	if err != nil {
+		return err
+	}
+	entries, err := dictEntries(form)
+	if err != nil {
+		return err
+	}
+	// SignaturesExist and AppendOnly.
+	entries["SigFlags"] = NewInt(3)
+	if !field.IsNull() {
+		old := entries["Fields"]
+		var fields []Value
+		for i := 0; i < old.Len(); i++ {
+			fields = append(fields, Value{old.r, old.ptr, old.data.(array)[i]})
+		}
+		entries["Fields"] = NewArray(append(fields, field)...)
+	}
+	if isIndirect(form) {
+		return w.Write(form, NewDict(entries))
+	}
+	cat, err := dictEntries(catalog)
+	if err != nil {
+		return err
+	}
+	cat["AcroForm"] = NewDict(entries)
+	return w.Write(catalog, NewDict(cat))
+}
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidRSA                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidEd25519              = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// signCMS returns a detached CMS signature, as ContentInfo,
+// for content with the digest d.
+func signCMS(opts SignOptions, d []byte) ([]byte, error) {
+	var digestAlg cmsAlgorithm
+	for _, h := range cmsHashes {
+		if h.hash == opts.Hash {
+			digestAlg.Algorithm = h.oid
+		}
+	}
+	if digestAlg.Algorithm == nil {
+		return nil, fmt.Errorf("pdf: unsupported signature digest algorithm %v", opts.Hash)
+	}
+	var sigAlg cmsAlgorithm
+	switch opts.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = cmsAlgorithm{oidRSA, asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		oid, ok := map[crypto.Hash]asn1.ObjectIdentifier{
+			crypto.SHA1:   {1, 2, 840, 10045, 4, 1},
+			crypto.SHA224: {1, 2, 840, 10045, 4, 3, 1},
+			crypto.SHA256: {1, 2, 840, 10045, 4, 3, 2},
+			crypto.SHA384: {1, 2, 840, 10045, 4, 3, 3},
+			crypto.SHA512: {1, 2, 840, 10045, 4, 3, 4},
+		}[opts.Hash]
+		if !ok {
+			return nil, fmt.Errorf("pdf: unsupported signature digest algorithm %v", opts.Hash)
+		}
+		sigAlg.Algorithm = oid
+	case ed25519.PublicKey:
+		sigAlg.Algorithm = oidEd25519
+	default:
+		return nil, fmt.Errorf("pdf: unsupported signing key type %T", opts.Signer.Public())
+	}
+
+	// The signed attributes, a DER SET OF, so sorted by encoding.
+	attr := func(typ asn1.ObjectIdentifier, val interface{}) ([]byte, error) {
+		b, err := asn1.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(cmsAttribute{typ, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: b}})
+	}
+	type attrValue struct {
+		typ asn1.ObjectIdentifier
+		val interface{}
+	}
+	vals := []attrValue{
+		{oidContentType, oidData},
+		{oidMessageDigest, d},
+	}
+	if opts.PAdES {
+		// PAdES identifies the signing certificate by its hash,
+		// and takes the time of signing from the signature dictionary.
+		type essCertIDv2 struct {
+			CertHash []byte // SHA-256, the default hash algorithm
+		}
+		certHash := sha256.Sum256(opts.Certificate.Raw)
+		vals = append(vals, attrValue{oidSigningCertificateV2, struct {
+			Certs []essCertIDv2
+		}{[]essCertIDv2{{certHash[:]}}}})
+	} else {
+		vals = append(vals, attrValue{oidSigningTime, opts.Time.UTC()})
+	}
+	var attrs [][]byte
+	for _, v := range vals {
+		b, err := attr(v.typ, v.val)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, b)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return bytes.Compare(attrs[i], attrs[j]) < 0 })
+	signedAttrs := bytes.Join(attrs, nil)
+
+	// The signature is over the attributes tagged as a SET OF.
+	msg, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: signedAttrs})
+	if err != nil {
+		return nil, err
+	}
+	var sig []byte
+	if sigAlg.Algorithm.Equal(oidEd25519) {
+		sig, err = opts.Signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	} else {
+		h := opts.Hash.New()
+		h.Write(msg)
+		sig, err = opts.Signer.Sign(rand.Reader, h.Sum(nil), opts.Hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := asn1.Marshal(cmsIssuerAndSerial{
+		Issuer:       asn1.RawValue{FullBytes: opts.Certificate.RawIssuer},
+		SerialNumber: opts.Certificate.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	certs := opts.Certificate.Raw
+	for _, c := range opts.Chain {
+		certs = append(certs[:len(certs):len(certs)], c.Raw...)
+	}
+	sd, err := asn1.Marshal(cmsSignedData{
+		Version:          1,
+		DigestAlgorithms: []cmsAlgorithm{digestAlg},
+		EncapContentInfo: cmsEncapContentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []cmsSignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    digestAlg,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs},
+			SignatureAlgorithm: sigAlg,
+			Signature:          sig,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(cmsContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
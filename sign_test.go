@@ -0,0 +1,73 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSignPlaceholders(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The text entries imitate the placeholders, and ContactInfo
+	// is written before Contents.
+	const fake = "x /Contents <00> /ByteRange [0 1] y"
+	r := openPDF(t, buildPDF(pageTree(1)...), nil)
+	var buf bytes.Buffer
+	err = r.Sign(context.Background(), &buf, SignOptions{
+		Signer:      key,
+		Certificate: cert,
+		Name:        fake,
+		Reason:      fake,
+		ContactInfo: fake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigs, err := openPDF(t, buf.Bytes(), nil).Signatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 1 {
+		t.Fatalf("got %d signatures, want 1", len(sigs))
+	}
+	if sigs[0].ContactInfo != fake {
+		t.Errorf("ContactInfo = %q, want %q", sigs[0].ContactInfo, fake)
+	}
+	v, err := sigs[0].Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Valid() || v.Modified {
+		t.Errorf("Verify = %+v, want valid and unmodified", v)
+	}
+}
@@ -0,0 +1,487 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// A Signature is a digital signature of the document,
+// the value of a signature field. See PDF 32000-1:2008, §12.8.
+type Signature struct {
+	Field Field // the signature field
+	V     Value // the signature dictionary
+
+	// SubFilter is the encoding of the signature: adbe.pkcs7.detached,
+	// adbe.pkcs7.sha1, adbe.x509.rsa_sha1, ETSI.CAdES.detached,
+	// or, for a document timestamp, ETSI.RFC3161.
+	SubFilter string
+
+	Name        string    // name of the person or authority signing, as recorded in the dictionary
+	Reason      string    // reason for signing
+	Location    string    // place where the document was signed
+	ContactInfo string    // how to reach the signer
+	Time        time.Time // time of signing claimed by the dictionary (M), or zero
+
+	// ByteRange lists the offset and length of each range of bytes
+	// of the file that are signed: all of the file as of signing
+	// except the signature itself.
+	ByteRange []int64
+}
+
+// Signatures returns the signatures of the document's signed signature
+// fields, in the order of the fields. Unsigned signature fields are skipped.
+func (r *Reader) Signatures() ([]Signature, error) {
+	fields, err := r.Fields()
+	if err != nil {
+		return nil, err
+	}
+	var out []Signature
+	for _, f := range fields {
+		typ, err := f.Type()
+		if err != nil {
+			return nil, err
+		}
+		if typ != "Sig" {
+			continue
+		}
+		v, err := f.findInherited("V")
+		if err != nil {
+			return nil, err
+		}
+		if v.Kind() != Dict {
+			continue
+		}
+		s := Signature{Field: f, V: v}
+		for _, x := range []struct {
+			key string
+			dst *string
+		}{
+			{"Name", &s.Name},
+			{"Reason", &s.Reason},
+			{"Location", &s.Location},
+			{"ContactInfo", &s.ContactInfo},
+		} {
+			t, err := v.Key(x.key)
+			if err != nil {
+				return nil, err
+			}
+			*x.dst = t.Text()
+		}
+		sub, err := v.Key("SubFilter")
+		if err != nil {
+			return nil, err
+		}
+		s.SubFilter = sub.Name()
+		m, err := v.Key("M")
+		if err != nil {
+			return nil, err
+		}
+		s.Time, _ = ParseDate(m.Text())
+		br, err := v.Key("ByteRange")
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < br.Len(); i++ {
+			x, err := br.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			s.ByteRange = append(s.ByteRange, x.Int64())
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// A SignatureVerification is the result of verifying a Signature.
+type SignatureVerification struct {
+	Signer       *x509.Certificate   // certificate of the signer, or nil if the signature does not include it
+	Certificates []*x509.Certificate // all certificates included in the signature
+
+	// SigningTime is the time of signing: for a document timestamp, the
+	// time certified by the timestamp authority; otherwise the time the
+	// signer claims in the signature (the signingTime attribute), or,
+	// failing that, in the signature dictionary.
+	SigningTime time.Time
+
+	// DigestValid reports whether the digest of the signed bytes
+	// matches the digest recorded in the signature.
+	DigestValid bool
+
+	// SignatureValid reports whether the signature verifies
+	// with the public key of the signer's certificate.
+	SignatureValid bool
+
+	// Modified reports whether the file continues after the signed bytes,
+	// so that it has been updated since it was signed. The updates may
+	// be benign, such as filling in form fields or adding signatures.
+	Modified bool
+}
+
+// Valid reports whether the signature is intact:
+// both DigestValid and SignatureValid.
+func (v SignatureVerification) Valid() bool {
+	return v.DigestValid && v.SignatureValid
+}
+
+// Verify checks the signature against the bytes of the file it signs.
+//
+// Verify checks only that the signed bytes are unchanged and that the
+// signature was made with the key of the included signer certificate.
+// It does not check that the certificate is valid or trusted; to do that,
+// call the certificate's Verify method, using the other certificates
+// as intermediates.
+//
+// Verify returns an error if the signature cannot be checked at all:
+// for example, if the byte range does not cover the whole file except
+// the signature, or the signature is malformed.
+func (s Signature) Verify() (SignatureVerification, error) {
+	r := s.V.r
+	if r == nil {
+		return SignatureVerification{}, errors.New("pdf: signature not read from a file")
+	}
+	if r.sequential {
+		return SignatureVerification{}, errSequential
+	}
+	br := s.ByteRange
+	if len(br) != 4 || br[0] != 0 || br[1] < 0 || br[2] < br[1] || br[3] < 0 || br[2]+br[3] > r.end {
+		return SignatureVerification{}, fmt.Errorf("pdf: invalid signature byte range %v", br)
+	}
+
+	// The gap between the two ranges must hold the signature,
+	// and nothing else, as a hexadecimal string.
+	gap := make([]byte, br[2]-br[1])
+	if _, err := r.f.ReadAt(gap, br[1]); err != nil {
+		return SignatureVerification{}, err
+	}
+	if len(gap) < 2 || gap[0] != '<' || gap[len(gap)-1] != '>' {
+		return SignatureVerification{}, errors.New("pdf: signature byte range does not exclude exactly the signature")
+	}
+	// The signature is usually followed by zero padding,
+	// which the ASN.1 parser ignores.
+	contents, err := hex.DecodeString(string(bytes.Join(bytes.Fields(gap[1:len(gap)-1]), nil)))
+	if err != nil {
+		return SignatureVerification{}, fmt.Errorf("malformed PDF: invalid signature contents: %v", err)
+	}
+	signed := io.MultiReader(io.NewSectionReader(r.f, br[0], br[1]), io.NewSectionReader(r.f, br[2], br[3]))
+
+	v := SignatureVerification{SigningTime: s.Time, Modified: br[2]+br[3] < r.end}
+	if s.SubFilter == "adbe.x509.rsa_sha1" {
+		err = s.verifyRSASHA1(&v, contents, signed)
+	} else {
+		err = verifyCMS(&v, contents, signed, s.SubFilter)
+	}
+	if err != nil {
+		return SignatureVerification{}, err
+	}
+	return v, nil
+}
+
+// verifyRSASHA1 verifies a bare PKCS #1 signature, whose certificates
+// are in the Cert entry of the signature dictionary.
+func (s Signature) verifyRSASHA1(v *SignatureVerification, contents []byte, signed io.Reader) error {
+	var sig []byte
+	if _, err := asn1.Unmarshal(contents, &sig); err != nil {
+		return fmt.Errorf("malformed PDF: invalid signature: %v", err)
+	}
+	certs, err := s.V.Key("Cert")
+	if err != nil {
+		return err
+	}
+	list := []Value{certs}
+	if certs.Kind() == Array {
+		list = nil
+		for i := 0; i < certs.Len(); i++ {
+			c, err := certs.Index(i)
+			if err != nil {
+				return err
+			}
+			list = append(list, c)
+		}
+	}
+	for _, c := range list {
+		cert, err := x509.ParseCertificate([]byte(c.RawString()))
+		if err != nil {
+			return fmt.Errorf("malformed PDF: invalid signature certificate: %v", err)
+		}
+		v.Certificates = append(v.Certificates, cert)
+	}
+	if len(v.Certificates) == 0 {
+		return errors.New("malformed PDF: signature has no certificate")
+	}
+	// The first certificate is the signer's.
+	v.Signer = v.Certificates[0]
+	h := crypto.SHA1.New()
+	if _, err := io.Copy(h, signed); err != nil {
+		return err
+	}
+	v.DigestValid = true
+	v.SignatureValid = verifySigned(v.Signer.PublicKey, nil, crypto.SHA1, nil, h.Sum(nil), sig)
+	return nil
+}
+
+// CMS (PKCS #7) signed data, as described by RFC 5652.
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []cmsAlgorithm `asn1:"set"`
+	EncapContentInfo cmsEncapContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue // IssuerAndSerialNumber or [0] SubjectKeyIdentifier
+	DigestAlgorithm    cmsAlgorithm
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm cmsAlgorithm
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// tstInfo is the content of an RFC 3161 timestamp token.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint struct {
+		HashAlgorithm cmsAlgorithm
+		HashedMessage []byte
+	}
+	SerialNumber *big.Int
+	GenTime      time.Time
+}
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidRSAPSS        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+)
+
+// cmsHashes maps the object identifiers of digest algorithms to hashes.
+var cmsHashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, crypto.SHA1},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, crypto.SHA256},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}, crypto.SHA384},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}, crypto.SHA512},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 4}, crypto.SHA224},
+}
+
+func cmsHash(alg cmsAlgorithm) (crypto.Hash, error) {
+	for _, h := range cmsHashes {
+		if h.oid.Equal(alg.Algorithm) {
+			return h.hash, nil
+		}
+	}
+	return 0, fmt.Errorf("pdf: unsupported signature digest algorithm %v", alg.Algorithm)
+}
+
+// verifyCMS verifies the CMS signed data sig over the bytes signed.
+// For the adbe.pkcs7.sha1 encoding, the signed content is the SHA-1
+// digest of the bytes; for a document timestamp (ETSI.RFC3161),
+// it is a TSTInfo holding their digest. Otherwise, the signature
+// is detached: the signed content is the bytes themselves.
+func verifyCMS(v *SignatureVerification, sig []byte, signed io.Reader, subFilter string) error {
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(sig, &ci); err != nil {
+		return fmt.Errorf("malformed PDF: invalid signature: %v", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return errors.New("malformed PDF: signature is not CMS signed data")
+	}
+	var sd cmsSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return fmt.Errorf("malformed PDF: invalid signature: %v", err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return errors.New("malformed PDF: signature has no signer")
+	}
+	si := sd.SignerInfos[0]
+	hash, err := cmsHash(si.DigestAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	// The certificates are a SET OF choices, of which only
+	// X.509 certificates are of interest.
+	for rest := sd.Certificates.Bytes; len(rest) > 0; {
+		var raw asn1.RawValue
+		if rest, err = asn1.Unmarshal(rest, &raw); err != nil {
+			return fmt.Errorf("malformed PDF: invalid signature certificates: %v", err)
+		}
+		if cert, err := x509.ParseCertificate(raw.FullBytes); err == nil {
+			v.Certificates = append(v.Certificates, cert)
+		}
+	}
+	for _, cert := range v.Certificates {
+		if recipientMatches(si.SID, cert) {
+			v.Signer = cert
+			break
+		}
+	}
+
+	// Check that the signed content matches the bytes signed.
+	content := sd.EncapContentInfo.Content.Bytes
+	switch {
+	case sd.EncapContentInfo.ContentType.Equal(oidTSTInfo):
+		var tst tstInfo
+		if _, err := asn1.Unmarshal(content, &tst); err != nil {
+			return fmt.Errorf("malformed PDF: invalid timestamp: %v", err)
+		}
+		h, err := cmsHash(tst.MessageImprint.HashAlgorithm)
+		if err != nil {
+			return err
+		}
+		d, err := digest(h, signed)
+		if err != nil {
+			return err
+		}
+		v.DigestValid = bytes.Equal(d, tst.MessageImprint.HashedMessage)
+		v.SigningTime = tst.GenTime
+	case subFilter == "adbe.pkcs7.sha1":
+		var inner []byte
+		if _, err := asn1.Unmarshal(content, &inner); err != nil {
+			inner = content
+		}
+		d, err := digest(crypto.SHA1, signed)
+		if err != nil {
+			return err
+		}
+		v.DigestValid = bytes.Equal(d, inner)
+		content = inner
+	case len(content) == 0:
+		// Detached: the content is the signed bytes.
+		d, err := digest(hash, signed)
+		if err != nil {
+			return err
+		}
+		v.DigestValid = true
+		if si.SignedAttrs.FullBytes == nil {
+			if v.Signer != nil {
+				v.SignatureValid = verifySigned(v.Signer.PublicKey, si.SignatureAlgorithm.Algorithm, hash, nil, d, si.Signature)
+			}
+			return nil
+		}
+		return verifySignedAttrs(v, si, hash, d)
+	default:
+		return errors.New("malformed PDF: detached signature has content")
+	}
+
+	d, err := digest(hash, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if si.SignedAttrs.FullBytes == nil {
+		if v.Signer != nil {
+			v.SignatureValid = verifySigned(v.Signer.PublicKey, si.SignatureAlgorithm.Algorithm, hash, content, d, si.Signature)
+		}
+		return nil
+	}
+	valid := v.DigestValid
+	err = verifySignedAttrs(v, si, hash, d)
+	v.DigestValid = v.DigestValid && valid
+	return err
+}
+
+// verifySignedAttrs checks the signed attributes of si, which must
+// record the content digest d, and the signature over them.
+func verifySignedAttrs(v *SignatureVerification, si cmsSignerInfo, hash crypto.Hash, d []byte) error {
+	v.DigestValid = false
+	for rest := si.SignedAttrs.Bytes; len(rest) > 0; {
+		var attr cmsAttribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return fmt.Errorf("malformed PDF: invalid signed attributes: %v", err)
+		}
+		switch {
+		case attr.Type.Equal(oidMessageDigest):
+			var md []byte
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &md); err != nil {
+				return fmt.Errorf("malformed PDF: invalid message digest: %v", err)
+			}
+			v.DigestValid = bytes.Equal(md, d)
+		case attr.Type.Equal(oidSigningTime):
+			var t time.Time
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &t); err == nil {
+				v.SigningTime = t
+			}
+		}
+	}
+	if v.Signer == nil {
+		return nil
+	}
+	// The signature is over the DER encoding of the attributes
+	// as a SET OF, not with the implicit [0] tag they carry.
+	msg := append([]byte(nil), si.SignedAttrs.FullBytes...)
+	msg[0] = 0x31
+	md, err := digest(hash, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	v.SignatureValid = verifySigned(v.Signer.PublicKey, si.SignatureAlgorithm.Algorithm, hash, msg, md, si.Signature)
+	return nil
+}
+
+// digest returns the hash of the data read from r.
+func digest(hash crypto.Hash, r io.Reader) ([]byte, error) {
+	if !hash.Available() {
+		return nil, fmt.Errorf("pdf: unsupported signature digest algorithm %v", hash)
+	}
+	h := hash.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// verifySigned reports whether sig is a valid signature by key of the
+// message msg, whose hash is d. Only Ed25519 signs msg itself.
+// The signature algorithm alg distinguishes RSA-PSS from PKCS #1 v1.5.
+func verifySigned(key crypto.PublicKey, alg asn1.ObjectIdentifier, hash crypto.Hash, msg, d, sig []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg.Equal(oidRSAPSS) {
+			return rsa.VerifyPSS(key, hash, d, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+		}
+		return rsa.VerifyPKCS1v15(key, hash, d, sig) == nil
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, d, sig)
+	case ed25519.PublicKey:
+		return msg != nil && ed25519.Verify(key, msg, sig)
+	}
+	return false
+}
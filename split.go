@@ -0,0 +1,175 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// ExtractPages writes to w a new PDF file containing the pages of r
+// with the given page numbers, in the given order, along with the
+// resources, annotations, and other objects they use.
+// Page numbers are indexed starting at 1.
+//
+// The pages keep the attributes they inherit from the page tree.
+// References from the copied objects to pages that are not extracted,
+// such as link destinations, are replaced by null.
+// Document-level structures such as the outline, forms, and
+// structure tree are not copied.
+func (r *Reader) ExtractPages(ctx context.Context, w io.Writer, pages []int) error {
+	pw := NewWriter(w)
+	tree := pw.NewRef()
+	kids, err := pw.copyPages(ctx, r, pages, tree)
+	if err != nil {
+		return err
+	}
+	root, err := pw.writePageTree(tree, kids)
+	if err != nil {
+		return err
+	}
+	return pw.Close(NewDict(map[string]Value{"Root": root}))
+}
+
+// writePageTree writes the page tree node tree with the given kids
+// and a catalog referring to it, and returns a reference to the catalog.
+func (w *Writer) writePageTree(tree Value, kids []Value) (Value, error) {
+	err := w.Write(tree, NewDict(map[string]Value{
+		"Type":  NewName("Pages"),
+		"Kids":  NewArray(kids...),
+		"Count": NewInt(int64(len(kids))),
+	}))
+	if err != nil {
+		return Value{}, err
+	}
+	return w.Add(NewDict(map[string]Value{
+		"Type":  NewName("Catalog"),
+		"Pages": tree,
+	}))
+}
+
+// copyPages writes copies of the pages of r with the given numbers,
+// as children of parent, and returns references to them.
+// Other pages of r are written as null wherever the copied objects
+// refer to them.
+func (w *Writer) copyPages(ctx context.Context, r *Reader, nums []int, parent Value) ([]Value, error) {
+	var all []Page
+	err := r.walkPages(ctx, func(num int, p Page) error {
+		all = append(all, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[int]bool)
+	for _, num := range nums {
+		if num < 1 || num > len(all) {
+			return nil, fmt.Errorf("pdf: page %d out of range [1, %d]", num, len(all))
+		}
+		selected[num] = true
+	}
+
+	// Map every page to its copy or to null before writing anything,
+	// so that no object refers to a page's original.
+	var null Value
+	for i, p := range all {
+		if selected[i+1] {
+			continue
+		}
+		if null.IsNull() {
+			if null, err = w.Add(Value{}); err != nil {
+				return nil, err
+			}
+		}
+		w.alias(p.V, null)
+	}
+	refs := make([]Value, len(nums))
+	for i, num := range nums {
+		refs[i] = w.NewRef()
+		w.alias(all[num-1].V, refs[i])
+	}
+
+	for i, num := range nums {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		p := all[num-1]
+		entries := make(map[string]Value)
+		for _, k := range p.V.Keys() {
+			switch k {
+			case "Parent", "B":
+				// The page tree is replaced, and article threads are not copied.
+				continue
+			}
+			v, err := p.V.Key(k)
+			if err != nil {
+				return nil, err
+			}
+			entries[k] = v
+		}
+		for _, k := range []string{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			if _, ok := entries[k]; ok {
+				continue
+			}
+			v, err := p.findInherited(k)
+			if err != nil {
+				return nil, err
+			}
+			entries[k] = v
+		}
+		entries["Parent"] = parent
+		if err := w.Write(refs[i], NewDict(entries)); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// alias arranges for references to the indirect object v of a Reader
+// to be written as references to ref.
+func (w *Writer) alias(v, ref Value) {
+	if v.r == nil || v.ptr.id == 0 {
+		return
+	}
+	k := copyKey{v.r, v.ptr}
+	if _, ok := w.copied[k]; !ok {
+		w.copied[k] = ref.data.(writerRef).id
+	}
+}
+
+// Merge writes to w a new PDF file containing all the pages of the
+// given documents, in order, along with the resources, annotations,
+// and other objects they use. Identical streams, such as embedded font
+// programs and images shared by the documents, and other identical
+// objects that refer to no others, are written once.
+// As with ExtractPages, document-level structures are not copied.
+func Merge(ctx context.Context, w io.Writer, readers ...*Reader) error {
+	pw := NewWriter(w)
+	pw.dedup = make(map[[sha256.Size]byte]uint32)
+	tree := pw.NewRef()
+	var kids []Value
+	for _, r := range readers {
+		n, err := r.NumPage()
+		if err != nil {
+			return err
+		}
+		nums := make([]int, n)
+		for i := range nums {
+			nums[i] = i + 1
+		}
+		refs, err := pw.copyPages(ctx, r, nums, tree)
+		if err != nil {
+			return err
+		}
+		kids = append(kids, refs...)
+	}
+	root, err := pw.writePageTree(tree, kids)
+	if err != nil {
+		return err
+	}
+	return pw.Close(NewDict(map[string]Value{"Root": root}))
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"sort"
+)
+
+// A SpotColorUse reports where a spot colorant is used on a page.
+type SpotColorUse struct {
+	Name   string // colorant name, such as "PANTONE 185 C"
+	Bounds Rect   // union of Boxes
+	Boxes  []Rect // bounds of each glyph, path, or image painted with the colorant
+}
+
+// SpotColors returns the spot colorants used to paint the page:
+// the colorants of Separation and DeviceN color spaces, including those
+// underlying Indexed color spaces, used by fill and stroke operations,
+// text, and images. Each colorant is reported once, with the bounds of
+// everything painted with it, and colorants are sorted by name.
+// The process colorants Cyan, Magenta, Yellow, and Black, and the
+// special colorants All and None, are not spot colors and are omitted.
+// Coordinates are as selected by the Reader's TextOptions.
+// Shadings and content in form XObjects are not considered.
+func (p Page) SpotColors(ctx context.Context) ([]SpotColorUse, error) {
+	uses := make(map[string]*SpotColorUse)
+	record := func(cs Value, box Rect) error {
+		names, err := colorants(cs, 0)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			switch name {
+			case "Cyan", "Magenta", "Yellow", "Black", "All", "None":
+				continue
+			}
+			u := uses[name]
+			if u == nil {
+				u = &SpotColorUse{Name: name}
+				uses[name] = u
+			}
+			u.Bounds = unionRect(u.Bounds, box)
+			u.Boxes = append(u.Boxes, box)
+		}
+		return nil
+	}
+	_, err := p.walkContent(ctx, p.textOptions(), contentHandler{
+		paint: func(box Rect, fill, stroke Value) error {
+			if err := record(fill, box); err != nil {
+				return err
+			}
+			return record(stroke, box)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []SpotColorUse
+	for _, u := range uses {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// colorants returns the colorant names of a Separation or DeviceN
+// color space, or of one underlying an Indexed color space.
+// depth guards against color spaces defined in terms of themselves.
+func colorants(cs Value, depth int) ([]string, error) {
+	if cs.Kind() != Array || depth > 8 {
+		return nil, nil
+	}
+	family, err := cs.Index(0)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := cs.Index(1)
+	if err != nil {
+		return nil, err
+	}
+	switch family.Name() {
+	case "Separation":
+		return []string{arg.Name()}, nil
+	case "DeviceN":
+		var names []string
+		for i := 0; i < arg.Len(); i++ {
+			x, err := arg.Index(i)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, x.Name())
+		}
+		return names, nil
+	case "Indexed", "I":
+		return colorants(arg, depth+1)
+	}
+	return nil, nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// A Stamp is text, such as a watermark, or the content of a page,
+// such as a logo, to draw on pages with Writer.Stamp.
+// The stamp is centered on each page's crop box, as displayed.
+type Stamp struct {
+	Text     string    // the text to draw
+	Font     string    // the standard 14 font for Text, such as Helvetica-Bold; "" means Helvetica
+	FontSize float64   // the size of Text, in points; 0 means 12
+	Color    []float64 // the color of Text: one, three, or four components for gray, RGB, or CMYK; nil means black
+
+	// Page is the page to draw if Text is empty,
+	// typically read from another Reader.
+	Page Page
+
+	// Scale scales the stamp from its natural size: that of Page's
+	// crop box, or of Text at FontSize. Zero instead scales the stamp
+	// to fit within each page.
+	Scale float64
+
+	Rotate  float64 // the rotation, in degrees counterclockwise
+	Opacity float64 // the opacity, from 0 to 1; 0 means 1, opaque
+	Under   bool    // draw the stamp under the page's content instead of over it
+}
+
+// Stamp draws s on the pages with the given numbers, or on every page
+// if no numbers are given, of the Reader being updated by w (see
+// Reader.Update), and writes the updated pages. The stamp is written
+// once, as a form XObject painted before or after each page's content.
+// A stamp drawn under a page's content may be hidden by an opaque
+// background that the content paints.
+// Since each page is written once, a page can be stamped by one call.
+func (w *Writer) Stamp(ctx context.Context, s Stamp, pages ...int) error {
+	r := w.update
+	if r == nil {
+		return errors.New("pdf: Stamp requires a Writer updating a Reader")
+	}
+	if s.Opacity < 0 || s.Opacity > 1 {
+		return fmt.Errorf("pdf: stamp opacity %g out of range [0, 1]", s.Opacity)
+	}
+	form, box, err := w.stampForm(s)
+	if err != nil {
+		return err
+	}
+	var gs Value
+	if s.Opacity != 0 && s.Opacity != 1 {
+		gs, err = w.Add(NewDict(map[string]Value{
+			"Type": NewName("ExtGState"),
+			"CA":   NewReal(s.Opacity),
+			"ca":   NewReal(s.Opacity),
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	selected := make(map[int]bool)
+	for _, num := range pages {
+		selected[num] = true
+	}
+	count := 0
+	err = r.walkPages(ctx, func(num int, p Page) error {
+		count = num
+		if len(pages) > 0 && !selected[num] {
+			return nil
+		}
+		if !isIndirect(p.V) {
+			return fmt.Errorf("pdf: page %d is not an indirect object", num)
+		}
+		return w.stampPage(p, s, form, box, gs)
+	})
+	if err != nil {
+		return err
+	}
+	for _, num := range pages {
+		if num < 1 || num > count {
+			return fmt.Errorf("pdf: page %d out of range [1, %d]", num, count)
+		}
+	}
+	return nil
+}
+
+// stampForm writes the form XObject drawing the stamp s and returns
+// a reference to it, with the bounds of the stamp in the form's
+// coordinates, as transformed by its Matrix.
+func (w *Writer) stampForm(s Stamp) (Value, Rect, error) {
+	hdr := map[string]Value{
+		"Type":    NewName("XObject"),
+		"Subtype": NewName("Form"),
+	}
+	var data []byte
+	var box Rect
+	if s.Text == "" {
+		p := s.Page
+		if p.V.IsNull() {
+			return Value{}, Rect{}, errors.New("pdf: stamp has neither Text nor Page")
+		}
+		crop, err := p.CropBox()
+		if err != nil {
+			return Value{}, Rect{}, err
+		}
+		t, err := p.Transform()
+		if err != nil {
+			return Value{}, Rect{}, err
+		}
+		res, err := p.Resources()
+		if err != nil {
+			return Value{}, Rect{}, err
+		}
+		if data, err = p.ContentData(); err != nil {
+			return Value{}, Rect{}, err
+		}
+		// Draw the page as it is displayed, rotated by its Rotate.
+		hdr["BBox"] = rectValue(crop)
+		hdr["Matrix"] = NewArray(NewReal(t[0]), NewReal(t[1]), NewReal(t[2]), NewReal(t[3]), NewReal(t[4]), NewReal(t[5]))
+		hdr["Resources"] = res
+		box = t.ApplyRect(crop)
+	} else {
+		fontName := s.Font
+		if fontName == "" {
+			fontName = "Helvetica"
+		}
+		fd := map[string]Value{
+			"Type":     NewName("Font"),
+			"Subtype":  NewName("Type1"),
+			"BaseFont": NewName(fontName),
+		}
+		if fontName != "Symbol" && fontName != "ZapfDingbats" {
+			fd["Encoding"] = NewName("WinAnsiEncoding")
+		}
+		font := Font{V: NewDict(fd)}
+		codes, ok, err := encodeSimple(font, s.Text)
+		if err != nil {
+			return Value{}, Rect{}, err
+		}
+		if !ok {
+			return Value{}, Rect{}, fmt.Errorf("pdf: stamp text %q cannot be shown in %s", s.Text, fontName)
+		}
+		size := s.FontSize
+		if size == 0 {
+			size = 12
+		}
+		width := 0.0
+		for i := 0; i < len(codes); i++ {
+			cw, err := font.Width(int(codes[i]))
+			if err != nil {
+				return Value{}, Rect{}, err
+			}
+			width += cw / 1000 * size
+		}
+		if width == 0 {
+			return Value{}, Rect{}, fmt.Errorf("pdf: stamp font %s has no metrics", fontName)
+		}
+		color := []Value{}
+		for _, c := range s.Color {
+			color = append(color, NewReal(c))
+		}
+		colorOp := map[int]string{0: "g", 1: "g", 3: "rg", 4: "k"}[len(s.Color)]
+		if colorOp == "" {
+			return Value{}, Rect{}, fmt.Errorf("pdf: stamp color has %d components", len(s.Color))
+		}
+		if len(s.Color) == 0 {
+			color = []Value{NewInt(0)}
+		}
+		var buf bytes.Buffer
+		formatContent(&buf, []Operator{
+			{Name: colorOp, Args: color},
+			{Name: "BT"},
+			{Name: "Tf", Args: []Value{NewName("F1"), NewReal(size)}},
+			{Name: "Tj", Args: []Value{NewString(codes)}},
+			{Name: "ET"},
+		})
+		data = buf.Bytes()
+		// Without font metrics the ascent and descent are taken to be
+		// 80% and 20% of the font size, as in glyphRect.
+		box = Rect{Point{0, -0.2 * size}, Point{width, 0.8 * size}}
+		hdr["BBox"] = rectValue(box)
+		hdr["Resources"] = NewDict(map[string]Value{
+			"Font": NewDict(map[string]Value{"F1": font.V}),
+		})
+	}
+	ref, err := w.Add(deflateStream(hdr, data))
+	return ref, box, err
+}
+
+// stampPage draws the stamp s, written as form, whose bounds are box,
+// on the page p and writes the updated page. The graphics state
+// parameters gs, if not null, set the opacity.
+func (w *Writer) stampPage(p Page, s Stamp, form Value, box Rect, gs Value) error {
+	crop, err := p.CropBox()
+	if err != nil {
+		return err
+	}
+	t, err := p.Transform()
+	if err != nil {
+		return err
+	}
+	inv, ok := t.inverse()
+	if !ok {
+		return errors.New("pdf: page transformation is not invertible")
+	}
+	page := t.ApplyRect(crop)
+
+	// Center the stamp on the displayed page, rotated and scaled.
+	sin, cos := math.Sincos(s.Rotate * math.Pi / 180)
+	bw, bh := box.Max.X-box.Min.X, box.Max.Y-box.Min.Y
+	scale := s.Scale
+	if scale == 0 {
+		rw := math.Abs(bw*cos) + math.Abs(bh*sin)
+		rh := math.Abs(bw*sin) + math.Abs(bh*cos)
+		scale = math.Min((page.Max.X-page.Min.X)/rw, (page.Max.Y-page.Min.Y)/rh)
+	}
+	m := Matrix{1, 0, 0, 1, -(box.Min.X + box.Max.X) / 2, -(box.Min.Y + box.Max.Y) / 2}.
+		Mul(Matrix{scale * cos, scale * sin, -scale * sin, scale * cos,
+			(page.Min.X + page.Max.X) / 2, (page.Min.Y + page.Max.Y) / 2}).
+		Mul(inv)
+
+	res, err := p.Resources()
+	if err != nil {
+		return err
+	}
+	resEntries, err := dictEntries(res)
+	if err != nil {
+		return err
+	}
+	ops := []Operator{{Name: "q"}}
+	if !gs.IsNull() {
+		name, err := addResource(resEntries, "ExtGState", "StampGS", gs)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, Operator{Name: "gs", Args: []Value{NewName(name)}})
+	}
+	name, err := addResource(resEntries, "XObject", "Stamp", form)
+	if err != nil {
+		return err
+	}
+	ops = append(ops,
+		Operator{Name: "cm", Args: []Value{NewReal(m[0]), NewReal(m[1]), NewReal(m[2]), NewReal(m[3]), NewReal(m[4]), NewReal(m[5])}},
+		Operator{Name: "Do", Args: []Value{NewName(name)}},
+		Operator{Name: "Q"})
+	// Draw the content and the stamp in a single stream, as EditPage
+	// does, since content arrays are not interpreted by GetPlainText.
+	data, err := p.ContentData()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if s.Under {
+		formatContent(&buf, ops)
+		buf.Write(data)
+	} else {
+		// Keep the content's changes to the graphics state from the stamp.
+		buf.WriteString("q\n")
+		buf.Write(data)
+		buf.WriteString("\nQ\n")
+		formatContent(&buf, ops)
+	}
+
+	entries, err := dictEntries(p.V)
+	if err != nil {
+		return err
+	}
+	entries["Contents"] = deflateStream(nil, buf.Bytes())
+	entries["Resources"] = NewDict(resEntries)
+	return w.Write(p.V, NewDict(entries))
+}
+
+// addResource adds v to the category of the resource dictionary
+// entries, under an unused name starting with base, and returns the name.
+func addResource(entries map[string]Value, category, base string, v Value) (string, error) {
+	all, err := dictEntries(entries[category])
+	if err != nil {
+		return "", err
+	}
+	name := base
+	for i := 1; all[name].data != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	all[name] = v
+	entries[category] = NewDict(all)
+	return name, nil
+}
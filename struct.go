@@ -0,0 +1,380 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"strings"
+)
+
+// A StructElem is an element of the logical structure tree of a tagged PDF.
+// See PDF 32000-1:2008, §14.7.
+type StructElem struct {
+	V    Value
+	Type string // structure type as written in the document (S)
+	Role string // standard structure type that Type maps to through the RoleMap
+
+	ID         string // element identifier
+	Title      string // title of the element (T)
+	Lang       string // natural language of the element's content, such as en-US
+	Alt        string // alternate description, as for a figure
+	ActualText string // replacement text for the element's content
+	Expansion  string // expansion of an abbreviation (E)
+
+	// Page is the page on which the element's content is drawn (Pg),
+	// inherited from the element's ancestors. Page.V is null if unknown.
+	Page Page
+
+	Attrs   []StructAttr    // attribute objects, from the element's classes (C) and then its own (A)
+	Kids    []StructElem    // child structure elements
+	Content []StructContent // marked content and objects that are children of the element
+}
+
+// A StructAttr is an attribute object of a structure element,
+// holding the attributes defined by one owner.
+// See PDF 32000-1:2008, §14.7.5.
+type StructAttr struct {
+	Owner string // O: Layout, List, PrintField, Table, UserProperties, or another owner
+	V     Value  // the attribute object
+}
+
+// Attr returns the attribute name defined by owner, or a null Value if
+// the element has no such attribute. The element's own attributes take
+// precedence over those of its classes.
+func (e StructElem) Attr(owner, name string) (Value, error) {
+	for i := len(e.Attrs) - 1; i >= 0; i-- {
+		a := e.Attrs[i]
+		if a.Owner != owner {
+			continue
+		}
+		v, err := a.V.Key(name)
+		if err != nil || !v.IsNull() {
+			return v, err
+		}
+	}
+	return Value{}, nil
+}
+
+// A StructContent is a piece of content belonging to a structure element:
+// either a marked-content sequence, identified by its marked-content
+// identifier (MCID) in the BDC operator that begins it, or a whole object,
+// such as an annotation or an XObject.
+type StructContent struct {
+	Page Page  // page on which the content is drawn; Page.V is null if unknown
+	MCID int   // marked-content identifier, or -1 for an object reference
+	Stm  Value // the content stream holding the marked content, if not the page's, such as a form XObject
+	Obj  Value // for an object reference, the referenced object
+}
+
+// Text returns the text drawn by marked content in the page's
+// content streams, as lines separated by newlines.
+// It returns the empty string for object references and
+// for marked content in other streams, such as form XObjects.
+func (c StructContent) Text(ctx context.Context) (string, error) {
+	if c.MCID < 0 || !c.Stm.IsNull() || c.Page.V.IsNull() {
+		return "", nil
+	}
+	var glyphs []Glyph
+	_, err := c.Page.walkContent(ctx, c.Page.textOptions(), contentHandler{
+		glyph: func(g Glyph) error {
+			if !g.synthetic && g.mcid == c.MCID {
+				glyphs = append(glyphs, g)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, line := range layoutLines(glyphs) {
+		lines = append(lines, line.Text())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// StructTree returns the document's logical structure tree.
+// The StructElem returned is the root of the tree and has no Type itself;
+// its children are the top-level structure elements.
+// If the document is not tagged, the root has no children.
+func (r *Reader) StructTree() (StructElem, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return StructElem{}, err
+	}
+	tree, err := root.Key("StructTreeRoot")
+	if err != nil {
+		return StructElem{}, err
+	}
+	if tree.Kind() != Dict {
+		return StructElem{}, nil
+	}
+	roleMap, err := tree.Key("RoleMap")
+	if err != nil {
+		return StructElem{}, err
+	}
+	classMap, err := tree.Key("ClassMap")
+	if err != nil {
+		return StructElem{}, err
+	}
+	b := structBuilder{roleMap: roleMap, classMap: classMap, seen: map[objptr]bool{tree.ptr: true}}
+	x := StructElem{V: tree}
+	if err := b.kids(&x, tree); err != nil {
+		return StructElem{}, err
+	}
+	return x, nil
+}
+
+type structBuilder struct {
+	roleMap  Value
+	classMap Value
+	seen     map[objptr]bool
+}
+
+// elem returns the structure element v, whose parent is drawn on page pg.
+func (b *structBuilder) elem(v Value, pg Page) (StructElem, error) {
+	s, err := v.Key("S")
+	if err != nil {
+		return StructElem{}, err
+	}
+	x := StructElem{V: v, Type: s.Name(), Page: pg}
+	x.Role, err = b.role(x.Type)
+	if err != nil {
+		return StructElem{}, err
+	}
+	for _, f := range []struct {
+		key string
+		dst *string
+	}{
+		{"ID", &x.ID},
+		{"T", &x.Title},
+		{"Lang", &x.Lang},
+		{"Alt", &x.Alt},
+		{"ActualText", &x.ActualText},
+		{"E", &x.Expansion},
+	} {
+		s, err := v.Key(f.key)
+		if err != nil {
+			return StructElem{}, err
+		}
+		*f.dst = s.Text()
+	}
+	if p, err := v.Key("Pg"); err != nil {
+		return StructElem{}, err
+	} else if p.Kind() == Dict {
+		x.Page = Page{p}
+	}
+
+	// Attributes of the element's classes come first,
+	// so that its own attributes override them.
+	c, err := v.Key("C")
+	if err != nil {
+		return StructElem{}, err
+	}
+	for _, class := range structAttrList(c) {
+		attrs, err := b.classMap.Key(class.Name())
+		if err != nil {
+			return StructElem{}, err
+		}
+		if x.Attrs, err = appendStructAttrs(x.Attrs, attrs); err != nil {
+			return StructElem{}, err
+		}
+	}
+	a, err := v.Key("A")
+	if err != nil {
+		return StructElem{}, err
+	}
+	if x.Attrs, err = appendStructAttrs(x.Attrs, a); err != nil {
+		return StructElem{}, err
+	}
+
+	if err := b.kids(&x, v); err != nil {
+		return StructElem{}, err
+	}
+	return x, nil
+}
+
+// structAttrList returns the entries of an attribute or class entry
+// (A or C) of a structure element: a single value, or an array
+// in which each value may be followed by a revision number.
+func structAttrList(v Value) []Value {
+	if v.Kind() != Array {
+		if v.IsNull() {
+			return nil
+		}
+		return []Value{v}
+	}
+	var out []Value
+	for i := 0; i < v.Len(); i++ {
+		x, err := v.Index(i)
+		if err != nil || x.Kind() == Integer {
+			continue
+		}
+		out = append(out, x)
+	}
+	return out
+}
+
+// appendStructAttrs appends to attrs the attribute objects in v,
+// a single attribute object or an array of them.
+func appendStructAttrs(attrs []StructAttr, v Value) ([]StructAttr, error) {
+	for _, a := range structAttrList(v) {
+		if a.Kind() != Dict && a.Kind() != Stream {
+			continue
+		}
+		o, err := a.Key("O")
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, StructAttr{Owner: o.Name(), V: a})
+	}
+	return attrs, nil
+}
+
+// kids sets the child structure elements and content of x
+// from the children (K) of v, the dictionary for x.
+func (b *structBuilder) kids(x *StructElem, v Value) error {
+	k, err := v.Key("K")
+	if err != nil {
+		return err
+	}
+	kids := []Value{k}
+	if k.Kind() == Array {
+		kids = kids[:0]
+		for i := 0; i < k.Len(); i++ {
+			kid, err := k.Index(i)
+			if err != nil {
+				return err
+			}
+			kids = append(kids, kid)
+		}
+	}
+	for _, kid := range kids {
+		switch kid.Kind() {
+		case Integer:
+			x.Content = append(x.Content, StructContent{Page: x.Page, MCID: int(kid.Int64())})
+			continue
+		case Dict:
+		default:
+			continue
+		}
+		typ, err := kid.Key("Type")
+		if err != nil {
+			return err
+		}
+		if typ.Name() == "MCR" || typ.Name() == "OBJR" {
+			c, err := structContent(kid, x.Page)
+			if err != nil {
+				return err
+			}
+			x.Content = append(x.Content, c)
+			continue
+		}
+		if !isStructElem(kid) {
+			continue
+		}
+		// Structure elements are indirect objects, each with its own
+		// object pointer; refuse to visit one twice, or an element
+		// inside itself, so that a cyclic tree terminates.
+		if b.seen[kid.ptr] {
+			continue
+		}
+		b.seen[kid.ptr] = true
+		elem, err := b.elem(kid, x.Page)
+		if err != nil {
+			return err
+		}
+		x.Kids = append(x.Kids, elem)
+	}
+	return nil
+}
+
+// structContent returns the content referred to by a marked-content
+// reference (MCR) or object reference (OBJR), whose parent element
+// is drawn on page pg.
+func structContent(ref Value, pg Page) (StructContent, error) {
+	c := StructContent{Page: pg, MCID: -1}
+	if p, err := ref.Key("Pg"); err != nil {
+		return StructContent{}, err
+	} else if p.Kind() == Dict {
+		c.Page = Page{p}
+	}
+	typ, err := ref.Key("Type")
+	if err != nil {
+		return StructContent{}, err
+	}
+	if typ.Name() == "OBJR" {
+		if c.Obj, err = ref.Key("Obj"); err != nil {
+			return StructContent{}, err
+		}
+		return c, nil
+	}
+	if c.Stm, err = ref.Key("Stm"); err != nil {
+		return StructContent{}, err
+	}
+	id, err := ref.Key("MCID")
+	if err != nil {
+		return StructContent{}, err
+	}
+	if id.Kind() == Integer {
+		c.MCID = int(id.Int64())
+	}
+	return c, nil
+}
+
+func isStructElem(v Value) bool {
+	typ, err := v.Key("Type")
+	if err != nil {
+		return false
+	}
+	switch typ.Name() {
+	case "StructElem":
+		return true
+	case "":
+		// Type is optional; structure elements are the dictionaries with S.
+		s, err := v.Key("S")
+		return err == nil && s.Kind() == Name
+	}
+	return false
+}
+
+// role follows the RoleMap from typ until it reaches a standard
+// structure type or a type with no mapping.
+func (b *structBuilder) role(typ string) (string, error) {
+	visited := make(map[string]bool)
+	for !standardStructTypes[typ] && !visited[typ] {
+		visited[typ] = true
+		next, err := b.roleMap.Key(typ)
+		if err != nil {
+			return "", err
+		}
+		if next.Kind() != Name {
+			break
+		}
+		typ = next.Name()
+	}
+	return typ, nil
+}
+
+// standardStructTypes are the standard structure types
+// of PDF 32000-1:2008, §14.8.4.
+var standardStructTypes = map[string]bool{
+	// Grouping elements.
+	"Document": true, "Part": true, "Art": true, "Sect": true, "Div": true,
+	"BlockQuote": true, "Caption": true, "TOC": true, "TOCI": true,
+	"Index": true, "NonStruct": true, "Private": true,
+	// Block-level structure elements.
+	"P": true, "H": true, "H1": true, "H2": true, "H3": true, "H4": true,
+	"H5": true, "H6": true, "L": true, "LI": true, "Lbl": true, "LBody": true,
+	"Table": true, "TR": true, "TH": true, "TD": true, "THead": true,
+	"TBody": true, "TFoot": true,
+	// Inline-level structure elements.
+	"Span": true, "Quote": true, "Note": true, "Reference": true,
+	"BibEntry": true, "Code": true, "Link": true, "Annot": true,
+	"Ruby": true, "RB": true, "RT": true, "RP": true,
+	"Warichu": true, "WT": true, "WP": true,
+	// Illustration elements.
+	"Figure": true, "Formula": true, "Form": true,
+}
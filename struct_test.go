@@ -0,0 +1,30 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "testing"
+
+func TestStructTreeCycle(t *testing.T) {
+	// The paragraph, object 7, lists itself among its kids
+	// before its marked content.
+	objs := pageTree(1)
+	objs[0] = "<< /Type /Catalog /Pages 2 0 R /StructTreeRoot 5 0 R >>"
+	objs = append(objs,
+		"<< /Type /StructTreeRoot /K 6 0 R >>",
+		"<< /Type /StructElem /S /Document /P 5 0 R /K [7 0 R] >>",
+		"<< /Type /StructElem /S /P /P 6 0 R /Pg 3 0 R /K [7 0 R 0] >>")
+	r := openPDF(t, buildPDF(objs...), nil)
+	tree, err := r.StructTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Kids) != 1 || len(tree.Kids[0].Kids) != 1 {
+		t.Fatalf("StructTree has %d kids, want a Document with one P", len(tree.Kids))
+	}
+	p := tree.Kids[0].Kids[0]
+	if p.Type != "P" || len(p.Kids) != 0 || len(p.Content) != 1 || p.Content[0].MCID != 0 {
+		t.Errorf("P element: Type %q, %d kids, content %v; want P, no kids, MCID 0", p.Type, len(p.Kids), p.Content)
+	}
+}
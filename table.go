@@ -0,0 +1,386 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A Table is a table found on a page by Page.Tables.
+type Table struct {
+	Box   Rect          // bounds of the table
+	Cells [][]TableCell // the cells, by row top to bottom, then by column left to right
+	Ruled bool          // whether the table was found by its ruling lines rather than by text alignment
+}
+
+// A TableCell is a cell of a Table.
+// Every row of a table has the same number of cells.
+type TableCell struct {
+	S   string // the text of the cell, with lines separated by newlines
+	Box Rect   // bounds of the cell
+}
+
+// Rows returns the text of the table's cells, by row and then by column.
+func (t Table) Rows() [][]string {
+	rows := make([][]string, len(t.Cells))
+	for i, row := range t.Cells {
+		rows[i] = make([]string, len(row))
+		for j, c := range row {
+			rows[i][j] = c.S
+		}
+	}
+	return rows
+}
+
+// TableOptions controls how Tables finds tables.
+type TableOptions struct {
+	// MaxRuleWidth is the thickness, in points, below which a path
+	// is treated as a ruling line, as for DrawingOptions. The edges
+	// of thicker rectangles, such as shaded cells, are also ruling lines.
+	MaxRuleWidth float64
+
+	// Tolerance is the distance, in points, within which ruling lines
+	// are taken to be aligned or to meet.
+	Tolerance float64
+
+	// MinRows and MinColumns are the least numbers of rows and columns
+	// of a table found by text alignment alone. Rows are lines of text
+	// divided by gaps at least as wide as the line is high.
+	MinRows, MinColumns int
+}
+
+// DefaultTableOptions are reasonable TableOptions for typical documents.
+var DefaultTableOptions = TableOptions{
+	MaxRuleWidth: 2,
+	Tolerance:    2,
+	MinRows:      3,
+	MinColumns:   3,
+}
+
+// Tables returns the tables on the page, top to bottom, then left to right.
+// A grid of ruling lines drawn by the page's content, with at least two
+// horizontal and two vertical lines meeting, is a table whose cells are
+// the spaces between the lines; words belong to the cell containing their
+// centers. Elsewhere, runs of consecutive lines of text divided into the
+// same columns are tables whose rows are the lines, as directed by opts.
+// Coordinates are as selected by the Reader's TextOptions.
+// Paths in form XObjects are not considered.
+func (p Page) Tables(ctx context.Context, opts TableOptions) ([]Table, error) {
+	var horiz, vert []tableRule
+	_, err := p.walkContent(ctx, p.textOptions(), contentHandler{
+		path: func(pa contentPath) error {
+			if pa.Segments > 4 {
+				return nil
+			}
+			b := pa.Box
+			w, h := b.Max.X-b.Min.X, b.Max.Y-b.Min.Y
+			switch {
+			case h <= opts.MaxRuleWidth && w > h:
+				horiz = append(horiz, tableRule{(b.Min.Y + b.Max.Y) / 2, b.Min.X, b.Max.X})
+			case w <= opts.MaxRuleWidth && h > w:
+				vert = append(vert, tableRule{(b.Min.X + b.Max.X) / 2, b.Min.Y, b.Max.Y})
+			case pa.Segments == 4:
+				horiz = append(horiz, tableRule{b.Min.Y, b.Min.X, b.Max.X}, tableRule{b.Max.Y, b.Min.X, b.Max.X})
+				vert = append(vert, tableRule{b.Min.X, b.Min.Y, b.Max.Y}, tableRule{b.Max.X, b.Min.Y, b.Max.Y})
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	glyphs, err := p.Glyphs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := ruledTables(mergeRules(horiz, opts.Tolerance), mergeRules(vert, opts.Tolerance), opts.Tolerance)
+	inTable := func(r Rect) bool {
+		c := Point{(r.Min.X + r.Max.X) / 2, (r.Min.Y + r.Max.Y) / 2}
+		for _, t := range tables {
+			if inRect(c, t.Box) {
+				return true
+			}
+		}
+		return false
+	}
+	var rest []Glyph
+	for _, g := range glyphs {
+		if !inTable(glyphRect(g)) {
+			rest = append(rest, g)
+		}
+	}
+	lines := layoutLines(glyphs)
+	var out []Table
+	for _, t := range tables {
+		// Grids without text, as in charts, are not tables.
+		if fillCells(t, lines) {
+			out = append(out, *t)
+		}
+	}
+	for _, t := range alignedTables(layoutLines(rest), opts) {
+		out = append(out, *t)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Box.Max.Y != out[j].Box.Max.Y {
+			return out[i].Box.Max.Y > out[j].Box.Max.Y
+		}
+		return out[i].Box.Min.X < out[j].Box.Min.X
+	})
+	return out, nil
+}
+
+// A tableRule is a horizontal or vertical ruling line: at Y = pos
+// from X = lo to hi, or at X = pos from Y = lo to hi.
+type tableRule struct {
+	pos, lo, hi float64
+}
+
+// mergeRules joins the rules that continue one another within tol.
+func mergeRules(rules []tableRule, tol float64) []tableRule {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].pos != rules[j].pos {
+			return rules[i].pos < rules[j].pos
+		}
+		return rules[i].lo < rules[j].lo
+	})
+	var out []tableRule
+	for _, r := range rules {
+		merged := false
+		for i := len(out) - 1; i >= 0 && r.pos-out[i].pos <= tol; i-- {
+			if r.lo <= out[i].hi+tol && out[i].lo <= r.hi+tol {
+				out[i].lo = math.Min(out[i].lo, r.lo)
+				out[i].hi = math.Max(out[i].hi, r.hi)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// ruledTables returns the tables formed by the grids of horizontal
+// and vertical rules that meet within tol, with empty cells.
+func ruledTables(horiz, vert []tableRule, tol float64) []*Table {
+	// Union the rules that meet.
+	parent := make([]int, len(horiz)+len(vert))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, h := range horiz {
+		for j, v := range vert {
+			if v.pos >= h.lo-tol && v.pos <= h.hi+tol && h.pos >= v.lo-tol && h.pos <= v.hi+tol {
+				parent[find(len(horiz)+j)] = find(i)
+			}
+		}
+	}
+	groups := make(map[int][]int)
+	var roots []int
+	for i := range parent {
+		root := find(i)
+		if groups[root] == nil {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	var tables []*Table
+	for _, root := range roots {
+		var xs, ys []float64
+		box := Rect{Point{math.Inf(1), math.Inf(1)}, Point{math.Inf(-1), math.Inf(-1)}}
+		for _, i := range groups[root] {
+			if i < len(horiz) {
+				h := horiz[i]
+				ys = append(ys, h.pos)
+				box.Min.X, box.Max.X = math.Min(box.Min.X, h.lo), math.Max(box.Max.X, h.hi)
+			} else {
+				v := vert[i-len(horiz)]
+				xs = append(xs, v.pos)
+				box.Min.Y, box.Max.Y = math.Min(box.Min.Y, v.lo), math.Max(box.Max.Y, v.hi)
+			}
+		}
+		if len(xs) < 2 || len(ys) < 2 {
+			continue
+		}
+		// Tables without outer borders end where their rules do.
+		xs = snap(append(xs, box.Min.X, box.Max.X), tol)
+		ys = snap(append(ys, box.Min.Y, box.Max.Y), tol)
+		if (len(xs)-1)*(len(ys)-1) < 2 {
+			// A single box is not a table.
+			continue
+		}
+		t := &Table{Box: Rect{Point{xs[0], ys[0]}, Point{xs[len(xs)-1], ys[len(ys)-1]}}, Ruled: true}
+		for i := len(ys) - 1; i > 0; i-- {
+			row := make([]TableCell, len(xs)-1)
+			for j := range row {
+				row[j].Box = Rect{Point{xs[j], ys[i-1]}, Point{xs[j+1], ys[i]}}
+			}
+			t.Cells = append(t.Cells, row)
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// snap returns the distinct values of xs, in increasing order,
+// replacing values within tol of one another by their mean.
+func snap(xs []float64, tol float64) []float64 {
+	sort.Float64s(xs)
+	var out []float64
+	start, sum := 0, 0.0
+	for i, x := range xs {
+		if i > start && x-xs[i-1] > tol {
+			out = append(out, sum/float64(i-start))
+			start, sum = i, 0
+		}
+		sum += x
+	}
+	if len(xs) > 0 {
+		out = append(out, sum/float64(len(xs)-start))
+	}
+	return out
+}
+
+// inRect reports whether pt lies within r.
+func inRect(pt Point, r Rect) bool {
+	return r.Min.X <= pt.X && pt.X <= r.Max.X && r.Min.Y <= pt.Y && pt.Y <= r.Max.Y
+}
+
+// fillCells sets the text of the cells of t to the words of lines
+// whose centers lie within them. It reports whether there were any.
+func fillCells(t *Table, lines []Line) bool {
+	found := false
+	last := make(map[*TableCell]int) // the line of each cell's last word
+	for i, line := range lines {
+		for _, w := range line.Words {
+			c := Point{(w.Box.Min.X + w.Box.Max.X) / 2, (w.Box.Min.Y + w.Box.Max.Y) / 2}
+			cell := t.cellAt(c)
+			if cell == nil {
+				continue
+			}
+			if cell.S != "" {
+				if last[cell] == i {
+					cell.S += " "
+				} else {
+					cell.S += "\n"
+				}
+			}
+			cell.S += w.S
+			last[cell] = i
+			found = true
+		}
+	}
+	return found
+}
+
+// cellAt returns the cell of t containing pt, or nil if there is none.
+func (t *Table) cellAt(pt Point) *TableCell {
+	if !inRect(pt, t.Box) {
+		return nil
+	}
+	for i := range t.Cells {
+		for j := range t.Cells[i] {
+			if inRect(pt, t.Cells[i][j].Box) {
+				return &t.Cells[i][j]
+			}
+		}
+	}
+	return nil
+}
+
+// A tableChunk is a run of words in a line of text, separated from the
+// other words of the line by a gap as wide as the line is high.
+type tableChunk struct {
+	S   string
+	Box Rect
+}
+
+// alignedTables returns the tables formed by runs of lines divided into
+// the same columns, as directed by opts.
+func alignedTables(lines []Line, opts TableOptions) []*Table {
+	var tables []*Table
+	var run [][]tableChunk
+	var runBoxes []Rect
+	flush := func() {
+		if t := alignedTable(run, runBoxes, opts); t != nil {
+			tables = append(tables, t)
+		}
+		run, runBoxes = nil, nil
+	}
+	for _, line := range lines {
+		height := line.Box.Max.Y - line.Box.Min.Y
+		var chunks []tableChunk
+		for i, w := range line.Words {
+			if n := len(chunks); n > 0 && w.Box.Min.X-line.Words[i-1].Box.Max.X < height {
+				chunks[n-1].S += " " + w.S
+				chunks[n-1].Box = unionRect(chunks[n-1].Box, w.Box)
+				continue
+			}
+			chunks = append(chunks, tableChunk{w.S, w.Box})
+		}
+		// Rows are lines of at least two chunks, no more than
+		// a line apart.
+		if n := len(runBoxes); n > 0 && (len(chunks) < 2 || runBoxes[n-1].Min.Y-line.Box.Max.Y > 2*height) {
+			flush()
+		}
+		if len(chunks) >= 2 {
+			run = append(run, chunks)
+			runBoxes = append(runBoxes, line.Box)
+		}
+	}
+	flush()
+	return tables
+}
+
+// alignedTable returns the table formed by rows of chunks, whose lines
+// have the bounds boxes, or nil if they do not form a table.
+func alignedTable(rows [][]tableChunk, boxes []Rect, opts TableOptions) *Table {
+	if len(rows) < opts.MinRows || len(rows) == 0 {
+		return nil
+	}
+	// The columns are the unions of the chunks' overlapping spans.
+	var spans []tableRule
+	for _, row := range rows {
+		for _, c := range row {
+			spans = append(spans, tableRule{0, c.Box.Min.X, c.Box.Max.X})
+		}
+	}
+	cols := mergeRules(spans, 0)
+	if len(cols) < opts.MinColumns || len(cols) < 2 {
+		return nil
+	}
+	t := &Table{}
+	for i, row := range rows {
+		cells := make([]TableCell, len(cols))
+		for j, col := range cols {
+			cells[j].Box = Rect{Point{col.lo, boxes[i].Min.Y}, Point{col.hi, boxes[i].Max.Y}}
+		}
+		for _, c := range row {
+			for j, col := range cols {
+				if c.Box.Min.X >= col.lo && c.Box.Max.X <= col.hi {
+					cells[j].S = strings.TrimPrefix(cells[j].S+" "+c.S, " ")
+					break
+				}
+			}
+		}
+		t.Cells = append(t.Cells, cells)
+		t.Box = unionRect(t.Box, boxes[i])
+	}
+	t.Box.Min.X, t.Box.Max.X = cols[0].lo, cols[len(cols)-1].hi
+	return t
+}
@@ -5,8 +5,13 @@
 package pdf
 
 import (
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf16"
+
+	"golang.org/x/text/encoding"
 )
 
 const noRune = unicode.ReplacementChar
@@ -45,12 +50,165 @@ func isUTF16(s string) bool {
 
 func utf16Decode(s string) string {
 	var u []uint16
-	for i := 0; i < len(s); i += 2 {
+	for i := 0; i+1 < len(s); i += 2 {
 		u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
 	}
 	return string(utf16.Decode(u))
 }
 
+// utf16Encode returns s encoded in UTF-16BE, without a byte order mark.
+func utf16Encode(s string) string {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 0, 2*len(u))
+	for _, c := range u {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return string(b)
+}
+
+// glyphNameToRune returns the Unicode character for the glyph name,
+// following the Adobe Glyph List conventions: names from the list itself,
+// and names of the form uniXXXX and uXXXX[XX] giving hexadecimal code points.
+// Suffixes introduced by a period, as in "a.sc", are ignored.
+// If the name cannot be resolved, glyphNameToRune returns 0.
+func glyphNameToRune(name string) rune {
+	if r := nameToRune[name]; r != 0 {
+		return r
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+		if r := nameToRune[name]; r != 0 {
+			return r
+		}
+	}
+	var hex string
+	switch {
+	case strings.HasPrefix(name, "uni") && len(name) == 7:
+		hex = name[3:]
+	case strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7:
+		hex = name[1:]
+	default:
+		return 0
+	}
+	x, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || x > unicode.MaxRune {
+		return 0
+	}
+	return rune(x)
+}
+
+// Bidirectional character classes used by bidiReorder.
+const (
+	bidiNeutral = iota
+	bidiLTR
+	bidiRTL
+)
+
+// rtlScripts are the scripts written right to left.
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Arabic,
+	unicode.Hebrew,
+	unicode.Syriac,
+	unicode.Thaana,
+	unicode.Nko,
+}
+
+func bidiClass(r rune) int {
+	switch {
+	case unicode.In(r, rtlScripts...):
+		return bidiRTL
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		// Digits are laid out left to right even inside right-to-left text.
+		return bidiLTR
+	}
+	return bidiNeutral
+}
+
+// bidiReorderLines applies bidiReorder to each line of s.
+func bidiReorderLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = bidiReorder(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bidiReorder converts a single line of text from visual order,
+// as it is typically stored in PDF content streams, to logical order.
+//
+// The heuristic is much simpler than the Unicode Bidirectional Algorithm:
+// a line whose strong characters are mostly right-to-left is taken to be
+// a right-to-left paragraph and reversed as a whole, after which its
+// left-to-right runs (Latin words, numbers) are reversed back.
+// In a left-to-right paragraph only the right-to-left runs are reversed.
+// Neutral characters between two runs of the same direction join them;
+// otherwise they take the paragraph direction.
+func bidiReorder(s string) string {
+	r := []rune(s)
+	class := make([]int, len(r))
+	nltr, nrtl := 0, 0
+	for i, c := range r {
+		class[i] = bidiClass(c)
+		switch class[i] {
+		case bidiLTR:
+			nltr++
+		case bidiRTL:
+			nrtl++
+		}
+	}
+	if nrtl == 0 {
+		return s
+	}
+	base := bidiLTR
+	if nrtl > nltr {
+		base = bidiRTL
+	}
+
+	// Resolve neutrals from their strong neighbors.
+	for i := 0; i < len(r); {
+		if class[i] != bidiNeutral {
+			i++
+			continue
+		}
+		j := i
+		for j < len(r) && class[j] == bidiNeutral {
+			j++
+		}
+		dir := base
+		if i > 0 && j < len(r) && class[i-1] == class[j] {
+			dir = class[j]
+		}
+		for k := i; k < j; k++ {
+			class[k] = dir
+		}
+		i = j
+	}
+
+	if base == bidiRTL {
+		reverseRunes(r, class, 0, len(r))
+	}
+	for i := 0; i < len(r); {
+		if class[i] == base {
+			i++
+			continue
+		}
+		j := i
+		for j < len(r) && class[j] != base {
+			j++
+		}
+		reverseRunes(r, class, i, j)
+		i = j
+	}
+	return string(r)
+}
+
+func reverseRunes(r []rune, class []int, i, j int) {
+	for j--; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+		class[i], class[j] = class[j], class[i]
+	}
+}
+
 // See PDF 32000-1:2008, Table D.2
 var pdfDocEncoding = [256]rune{
 	noRune, noRune, noRune, noRune, noRune, noRune, noRune, noRune,
@@ -156,3 +314,29 @@ var macRomanEncoding = [256]rune{
 	0xf8ff, 0x00d2, 0x00da, 0x00db, 0x00d9, 0x0131, 0x02c6, 0x02dc,
 	0x00af, 0x02d8, 0x02d9, 0x02da, 0x00b8, 0x02dd, 0x02db, 0x02c7,
 }
+
+// encode converts the UTF-8 text s to o.TargetEncoding, if set,
+// counting unmappable characters in o.Unmappable.
+func (o TextOptions) encode(s string) string {
+	if o.TargetEncoding == nil || s == "" {
+		return s
+	}
+	// Encode the whole string with one encoder, so that a stateful
+	// encoding such as ISO-2022-JP switches modes only where needed,
+	// replacing only the characters it cannot represent.
+	out, _ := encoding.ReplaceUnsupported(o.TargetEncoding.NewEncoder()).String(s)
+	if o.Unmappable == nil {
+		return out
+	}
+	enc := o.TargetEncoding.NewEncoder()
+	var bad int64
+	for _, r := range s {
+		if _, err := enc.String(string(r)); err != nil {
+			bad++
+		}
+	}
+	if bad > 0 {
+		atomic.AddInt64(o.Unmappable, bad)
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestEncodeStateful(t *testing.T) {
+	var bad int64
+	opts := TextOptions{TargetEncoding: japanese.ISO2022JP, Unmappable: &bad}
+
+	// The Japanese characters are written in one run, between one escape sequence
+	// into JIS X 0208 and one back to ASCII.
+	got := opts.encode("ab日本語cd")
+	want, err := japanese.ISO2022JP.NewEncoder().String("ab日本語cd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("encode = %q, want %q", got, want)
+	}
+	if n := strings.Count(got, "\x1b"); n != 2 {
+		t.Errorf("encode = %q, with %d escape sequences, want 2", got, n)
+	}
+	if bad != 0 {
+		t.Errorf("Unmappable = %d, want 0", bad)
+	}
+
+	// Unmappable characters are replaced and counted.
+	opts.TargetEncoding = charmap.Windows1252
+	if got := opts.encode("aΔb一"); got != "a\x1ab\x1a" {
+		t.Errorf("encode = %q, want %q", got, "a\x1ab\x1a")
+	}
+	if bad != 2 {
+		t.Errorf("Unmappable = %d, want 2", bad)
+	}
+}
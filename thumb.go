@@ -0,0 +1,59 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+)
+
+// Thumb returns the page's thumbnail image, its Thumb entry,
+// which is stored in some documents for viewers to show.
+// Most pages have no thumbnail, in which case Thumb returns
+// ok == false. See PDF 32000-1:2008, §12.3.4.
+func (p Page) Thumb() (im Image, ok bool, err error) {
+	t, err := p.V.Key("Thumb")
+	if err != nil {
+		return Image{}, false, err
+	}
+	if t.Kind() != Stream {
+		return Image{}, false, nil
+	}
+	return Image{t}, true, nil
+}
+
+// Thumbnail returns a preview of the page, as displayed, no wider or
+// taller than maxSize pixels. It returns the page's thumbnail image
+// (see Page.Thumb) if it is present, fits, and can be decoded, and
+// otherwise renders the page (see Page.Render) at the largest size
+// that fits.
+func (p Page) Thumbnail(ctx context.Context, maxSize int) (image.Image, error) {
+	if maxSize <= 0 {
+		return nil, errors.New("pdf: invalid thumbnail size")
+	}
+	if t, ok, err := p.Thumb(); err != nil {
+		return nil, err
+	} else if ok {
+		if img, err := t.Decode(); err == nil {
+			b := img.Bounds()
+			if b.Dx() <= maxSize && b.Dy() <= maxSize {
+				return img, nil
+			}
+		}
+	}
+	w, h, err := p.Size()
+	if err != nil {
+		return nil, err
+	}
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("pdf: invalid size for rendered page")
+	}
+	// Render rounds the size in pixels up, so keep the resolution just
+	// below that at which the longer side is exactly maxSize.
+	dpi := 72 * float64(maxSize) / math.Max(w, h) * (1 - 1e-9)
+	return p.Render(ctx, RenderOptions{DPI: dpi})
+}
@@ -0,0 +1,114 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// truncatedPDF returns a three-page file whose last content stream,
+// the last object, is cut short after keep bytes of its data, as by
+// an interrupted download, along with the whole of the stream's data.
+func truncatedPDF(filter string, keep int) (file, data []byte) {
+	var content bytes.Buffer
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&content, "BT /F1 12 Tf 72 %d Td (line %d) Tj ET\n", 720-3*i, i)
+	}
+	data = content.Bytes()
+	stored := data
+	if filter != "" {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		stored = buf.Bytes()
+	}
+	objs := pageTree(3)
+	objs[7] = streamObj(filter, string(stored))
+	file = buildPDF(objs...)
+	obj := bytes.Index(file, []byte("8 0 obj"))
+	start := obj + bytes.Index(file[obj:], []byte("stream\n")) + len("stream\n")
+	return file[:start+keep], data
+}
+
+func TestTruncatedStream(t *testing.T) {
+	ctx := context.Background()
+	for _, filter := range []string{"", "/Filter /FlateDecode"} {
+		file, data := truncatedPDF(filter, 500)
+		r := openPDF(t, file, nil)
+
+		// The pages before the truncated one are read in full.
+		rd, err := r.GetPlainText(ctx)
+		if err != nil {
+			t.Fatalf("%q: Reader.GetPlainText: %v", filter, err)
+		}
+		all, err := io.ReadAll(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"page 1", "page 2", "line 0"} {
+			if !strings.Contains(string(all), want) {
+				t.Errorf("%q: document text lacks %q:\n%s", filter, want, all)
+			}
+		}
+		for num := 1; num <= 2; num++ {
+			p, err := r.Page(ctx, num)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, err := p.GetPlainText(ctx, nil)
+			if want := fmt.Sprintf("page %d", num); err != nil || text != want {
+				t.Errorf("%q: page %d text = %q, %v, want %q", filter, num, text, err, want)
+			}
+		}
+
+		p, err := r.Page(ctx, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.ContentData()
+		if err != nil {
+			t.Fatalf("%q: ContentData: %v", filter, err)
+		}
+		if len(got) == 0 || !bytes.HasPrefix(data, got) {
+			t.Errorf("%q: recovered %d bytes, want a prefix of the %d bytes of content", filter, len(got), len(data))
+		}
+		if filter == "" && len(got) != 500 {
+			t.Errorf("%q: recovered %d bytes, want the 500 present", filter, len(got))
+		}
+		found := false
+		for _, prob := range r.Problems() {
+			if strings.Contains(prob.Msg, "extends past end of file; truncated") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%q: no Problem recorded for the truncated stream; have %v", filter, r.Problems())
+		}
+		text, err := p.GetPlainText(ctx, nil)
+		if err != nil {
+			t.Fatalf("%q: GetPlainText: %v", filter, err)
+		}
+		if !strings.Contains(text, "line 0") {
+			t.Errorf("%q: text of truncated page lacks its first line:\n%s", filter, text)
+		}
+
+		// Without the repair, the stream cannot be read.
+		r = openPDF(t, file, &Options{Repairs: AllRepairs &^ RepairTruncatedStreams})
+		if p, err = r.Page(ctx, 3); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.ContentData(); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("%q: ContentData without repair: got error %v, want Length error", filter, err)
+		}
+	}
+}
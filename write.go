@@ -0,0 +1,701 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// A Writer writes a PDF file.
+//
+// Objects are written with Add, or with NewRef and Write when an object
+// must be referred to before it is written. The values written may be
+// built with NewDict, NewArray, and the other New functions, or may be
+// Values read from a Reader; the objects such a Value refers to are
+// copied along with it, each once, and renumbered. Close writes the
+// cross-reference table and trailer.
+//
+// Streams are copied without being decoded and re-encoded. Strings
+// and streams are written unencrypted unless Encrypt is called first.
+//
+// A Writer returned by Reader.Update instead appends an incremental
+// update to the Reader's file.
+type Writer struct {
+	w       io.Writer
+	n       int64   // bytes written
+	offsets []int64 // offset of each object, indexed by object number; 0 if not yet written
+	copied  map[copyKey]uint32
+	pending []copyKey // objects referred to but not yet copied
+	err     error
+
+	// dedup, if not nil, maps the contents of copied objects that
+	// refer to no others to their object numbers, so that identical
+	// objects from different Readers are written once.
+	dedup map[[sha256.Size]byte]uint32
+
+	crypt *writerCrypt // encryption, if any
+	cur   uint32       // object being written, for encryption
+	marks []*mark      // marks placed in the object being written
+
+	// For an incremental update:
+	update *Reader
+	base   uint32            // first new object number
+	gens   map[uint32]uint16 // generation numbers of replaced objects
+	prev   int64             // offset of the file's last cross-reference section
+}
+
+// A copyKey identifies an indirect object of a Reader.
+type copyKey struct {
+	r   *Reader
+	ptr objptr
+}
+
+// A writerRef is a reference to an object written by a Writer.
+type writerRef struct {
+	w  *Writer
+	id uint32
+}
+
+// NewWriter returns a Writer writing a PDF file to w.
+// It writes the file header immediately.
+func NewWriter(w io.Writer) *Writer {
+	pw := &Writer{
+		w:       w,
+		offsets: []int64{0}, // object 0 is always free
+		copied:  make(map[copyKey]uint32),
+		base:    1,
+	}
+	pw.write([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"))
+	return pw
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+}
+
+// NewRef allocates a new object and returns a reference to it,
+// for use in other objects. The object itself must be written
+// with Write before the Writer is closed.
+func (w *Writer) NewRef() Value {
+	w.offsets = append(w.offsets, 0)
+	return Value{data: writerRef{w, uint32(len(w.offsets) - 1)}}
+}
+
+// Write writes v as the object ref, which must have been returned by NewRef
+// and not yet written.
+//
+// In an incremental update, ref may instead be an indirect object of the
+// Reader being updated, such as a Page's V, to replace that object with v.
+// Values nested directly inside an indirect object share its object
+// number, so writing one of those replaces the whole containing object.
+func (w *Writer) Write(ref, v Value) error {
+	var id uint32
+	if x, ok := ref.data.(writerRef); ok && x.w == w {
+		id = x.id
+	} else if w.update != nil && ref.r == w.update && ref.ptr.id != 0 && ref.ptr.id < w.base {
+		id = ref.ptr.id
+		w.gens[id] = ref.ptr.gen
+	} else {
+		return fmt.Errorf("pdf: Write of %v: not a reference allocated by this Writer", ref)
+	}
+	if w.offsets[id] != 0 {
+		return fmt.Errorf("pdf: object %d written twice", id)
+	}
+	if err := w.writeIndirect(id, v.r, v.data); err != nil {
+		return err
+	}
+	return w.flush()
+}
+
+// Add writes v as a new object and returns a reference to it.
+func (w *Writer) Add(v Value) (Value, error) {
+	ref := w.NewRef()
+	if err := w.Write(ref, v); err != nil {
+		return Value{}, err
+	}
+	return ref, nil
+}
+
+// flush copies the objects that have been referred to but not yet written.
+func (w *Writer) flush() error {
+	for len(w.pending) > 0 {
+		k := w.pending[0]
+		w.pending = w.pending[1:]
+		v, err := k.r.resolve(objptr{}, k.ptr)
+		if err != nil {
+			return err
+		}
+		if err := w.writeIndirect(w.copied[k], v.r, v.data); err != nil {
+			return err
+		}
+	}
+	return w.err
+}
+
+// Close writes the cross-reference table and the trailer, which is built
+// from the dictionary trailer: it should set Root and may set Info and ID.
+// In an incremental update, a null trailer keeps the Reader's trailer,
+// and the trailer written refers back to it with Prev.
+// Close does not close the underlying writer.
+func (w *Writer) Close(trailer Value) error {
+	if w.update != nil && trailer.IsNull() {
+		trailer = w.update.Trailer()
+	}
+	root, err := trailer.Key("Root")
+	if err != nil {
+		return err
+	}
+	if root.IsNull() {
+		return errors.New("pdf: trailer has no Root")
+	}
+	hdr := make(dict)
+	for _, k := range trailer.Keys() {
+		switch k {
+		case "Size", "Prev", "XRefStm", "Encrypt":
+			// Describe the file being read, not the one being written.
+			continue
+		}
+		v, err := trailer.Key(k)
+		if err != nil {
+			return err
+		}
+		hdr[name(k)] = v
+	}
+	if w.crypt != nil {
+		hdr["Encrypt"] = writerRef{w, w.crypt.dict}
+		hdr["ID"] = array{w.crypt.id, w.crypt.id}
+	}
+	// Format the trailer first: it schedules the objects it refers to.
+	var tbuf bytes.Buffer
+	tbuf.WriteString("trailer\n")
+	if err := w.format(&tbuf, nil, hdr); err != nil {
+		return err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	for id := w.base; id < uint32(len(w.offsets)); id++ {
+		if w.offsets[id] == 0 {
+			return fmt.Errorf("pdf: object %d allocated but not written", id)
+		}
+	}
+
+	xref := w.n
+	var buf bytes.Buffer
+	buf.WriteString("xref\n")
+	// Write a subsection for each run of written objects.
+	// Object 0 heads the list of free objects.
+	for id := 0; id < len(w.offsets); {
+		if id > 0 && w.offsets[id] == 0 {
+			id++
+			continue
+		}
+		end := id + 1
+		for end < len(w.offsets) && w.offsets[end] != 0 {
+			end++
+		}
+		fmt.Fprintf(&buf, "%d %d\n", id, end-id)
+		for ; id < end; id++ {
+			if id == 0 {
+				buf.WriteString("0000000000 65535 f \n")
+				continue
+			}
+			fmt.Fprintf(&buf, "%010d %05d n \n", w.offsets[id], w.gens[uint32(id)])
+		}
+	}
+	// Size is known only now.
+	tr := tbuf.Bytes()
+	buf.Write(tr[:len(tr)-len(">>")])
+	if w.update != nil {
+		fmt.Fprintf(&buf, "/Prev %d", w.prev)
+	}
+	fmt.Fprintf(&buf, "/Size %d>>", len(w.offsets))
+	fmt.Fprintf(&buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	w.write(buf.Bytes())
+	return w.err
+}
+
+// writeIndirect writes the object x, read from r, as object number id.
+func (w *Writer) writeIndirect(id uint32, r *Reader, x object) error {
+	if v, ok := x.(Value); ok {
+		r, x = v.r, v.data
+	}
+	defer func(cur uint32, marks []*mark) { w.cur, w.marks = cur, marks }(w.cur, w.marks)
+	w.cur, w.marks = id, nil
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d %d obj\n", id, w.gens[id])
+	if strm, ok := x.(stream); ok {
+		data, err := rawStreamData(Value{r, strm.ptr, strm})
+		if err != nil {
+			return fmt.Errorf("pdf: copying stream %v: %v", strm.ptr, err)
+		}
+		hdr := make(dict, len(strm.hdr)+1)
+		for k, v := range strm.hdr {
+			hdr[k] = v
+		}
+		if w.encrypting() {
+			data = w.crypt.encrypt(id, w.gens[id], data)
+		}
+		hdr["Length"] = int64(len(data))
+		if err := w.format(&buf, r, hdr); err != nil {
+			return err
+		}
+		buf.WriteString("\nstream\n")
+		buf.Write(data)
+		buf.WriteString("\nendstream")
+	} else if err := w.format(&buf, r, x); err != nil {
+		return err
+	}
+	buf.WriteString("\nendobj\n")
+	w.offsets[id] = w.n
+	for _, m := range w.marks {
+		m.start += w.n
+		m.end += w.n
+	}
+	w.write(buf.Bytes())
+	return w.err
+}
+
+// encrypting reports whether the strings and streams of the object
+// being written are to be encrypted.
+func (w *Writer) encrypting() bool {
+	return w.crypt != nil && w.cur != 0 && w.cur != w.crypt.dict
+}
+
+// rawStreamData returns the data of the stream v as stored, but decrypted.
+func rawStreamData(v Value) ([]byte, error) {
+	rd, _, err := v.rawReader()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(rd)
+	return buf.Bytes(), err
+}
+
+// ref returns the object number to use for the object ptr of r,
+// scheduling the object to be copied if it has not been already.
+// The objects of a Reader being updated keep their numbers.
+func (w *Writer) ref(r *Reader, ptr objptr) objptr {
+	if r == w.update {
+		return ptr
+	}
+	k := copyKey{r, ptr}
+	if id, ok := w.copied[k]; ok {
+		return objptr{id, 0}
+	}
+	var sum [sha256.Size]byte
+	dedup := false
+	if w.dedup != nil {
+		sum, dedup = contentSum(r, ptr)
+		if id, ok := w.dedup[sum]; ok && dedup {
+			w.copied[k] = id
+			return objptr{id, 0}
+		}
+	}
+	w.offsets = append(w.offsets, 0)
+	id := uint32(len(w.offsets) - 1)
+	w.copied[k] = id
+	if dedup {
+		w.dedup[sum] = id
+	}
+	w.pending = append(w.pending, k)
+	return objptr{id, 0}
+}
+
+// contentSum returns a hash of the contents of the object ptr of r,
+// if it refers to no other objects.
+func contentSum(r *Reader, ptr objptr) (sum [sha256.Size]byte, ok bool) {
+	v, err := r.resolve(objptr{}, ptr)
+	if err != nil || hasRefs(v.data) {
+		return sum, false
+	}
+	var buf bytes.Buffer
+	var w Writer
+	if strm, isStream := v.data.(stream); isStream {
+		data, err := rawStreamData(v)
+		if err != nil {
+			return sum, false
+		}
+		hdr := make(dict, len(strm.hdr))
+		for k, x := range strm.hdr {
+			if k != "Length" {
+				hdr[k] = x
+			}
+		}
+		if err := w.format(&buf, r, hdr); err != nil {
+			return sum, false
+		}
+		buf.WriteString("stream")
+		buf.Write(data)
+	} else if err := w.format(&buf, r, v.data); err != nil {
+		return sum, false
+	}
+	return sha256.Sum256(buf.Bytes()), true
+}
+
+// hasRefs reports whether x refers to an indirect object.
+// The Length of a stream does not count.
+func hasRefs(x object) bool {
+	switch x := x.(type) {
+	case objptr:
+		return true
+	case dict:
+		for _, v := range x {
+			if hasRefs(v) {
+				return true
+			}
+		}
+	case array:
+		for _, v := range x {
+			if hasRefs(v) {
+				return true
+			}
+		}
+	case stream:
+		for k, v := range x.hdr {
+			if k != "Length" && hasRefs(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// format writes the direct object x, read from r, to buf.
+func (w *Writer) format(buf *bytes.Buffer, r *Reader, x object) error {
+	switch x := x.(type) {
+	default:
+		return fmt.Errorf("pdf: cannot write value of type %T", x)
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(x))
+	case int64:
+		buf.WriteString(strconv.FormatInt(x, 10))
+	case float64:
+		// PDF has no exponential notation.
+		buf.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+	case string:
+		if w.encrypting() {
+			x = string(w.crypt.encrypt(w.cur, w.gens[w.cur], []byte(x)))
+		}
+		formatString(buf, x)
+	case name:
+		formatName(buf, string(x))
+	case dict:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			formatName(buf, k)
+			buf.WriteByte(' ')
+			if err := w.format(buf, r, x[name(k)]); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(">>")
+	case array:
+		buf.WriteByte('[')
+		for i, elem := range x {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			if err := w.format(buf, r, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case objptr:
+		if r == nil {
+			return fmt.Errorf("pdf: reference %v without a Reader", x)
+		}
+		ptr := w.ref(r, x)
+		fmt.Fprintf(buf, "%d %d R", ptr.id, ptr.gen)
+	case writerRef:
+		if x.w != w {
+			return errors.New("pdf: reference allocated by a different Writer")
+		}
+		fmt.Fprintf(buf, "%d 0 R", x.id)
+	case stream:
+		// Streams are always indirect objects.
+		// Refer to a stream read from a file by its own object number,
+		// and give a new stream an object number of its own.
+		if x.data == nil {
+			return w.format(buf, r, x.ptr)
+		}
+		ref := w.NewRef()
+		if err := w.writeIndirect(ref.data.(writerRef).id, nil, x); err != nil {
+			return err
+		}
+		return w.format(buf, nil, ref.data)
+	case *mark:
+		x.start = int64(buf.Len())
+		if err := w.format(buf, r, x.v); err != nil {
+			return err
+		}
+		x.end = int64(buf.Len())
+		w.marks = append(w.marks, x)
+	case Value:
+		if isIndirect(x) {
+			// Keep the reference rather than inlining the object.
+			return w.format(buf, x.r, x.ptr)
+		}
+		return w.format(buf, x.r, x.data)
+	}
+	return nil
+}
+
+// A mark wraps a value to be written, recording where in the file
+// it is written, so that it can be filled in afterwards.
+type mark struct {
+	v          object
+	start, end int64 // offsets of the value written
+}
+
+// isIndirect reports whether the dictionary or array v is an indirect
+// object of its Reader, as returned by Key or Index for a reference,
+// rather than an object nested directly inside one.
+// Values carry only the object number of the indirect object containing
+// them, so the object itself is compared with v.
+func isIndirect(v Value) bool {
+	if v.r == nil || v.ptr.id == 0 {
+		return false
+	}
+	switch v.data.(type) {
+	case dict, array:
+	default:
+		return false
+	}
+	obj, err := v.r.resolve(objptr{}, v.ptr)
+	return err == nil && reflect.DeepEqual(obj.data, v.data)
+}
+
+// formatString writes s as a literal string, or as a hexadecimal string
+// if it is mostly unprintable.
+func formatString(buf *bytes.Buffer, s string) {
+	binary := 0
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 && c != '\n' && c != '\r' && c != '\t' || c >= 0x7f {
+			binary++
+		}
+	}
+	if binary > len(s)/4 {
+		fmt.Fprintf(buf, "<%x>", s)
+		return
+	}
+	buf.WriteByte('(')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', ')', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if c < 0x20 && c != '\n' && c != '\t' || c >= 0x7f {
+				fmt.Fprintf(buf, "\\%03o", c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte(')')
+}
+
+// formatName writes /n, escaping delimiters, white space,
+// and other bytes outside the printable ASCII range.
+func formatName(buf *bytes.Buffer, n string) {
+	buf.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c <= ' ' || c >= 0x7f || bytes.IndexByte([]byte("#()<>[]{}/%"), c) >= 0 {
+			fmt.Fprintf(buf, "#%02x", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// Update returns a Writer that appends an incremental update to the
+// file read by r, after first copying the file's bytes to w unchanged.
+// Objects written with the Writer are added to the document, or replace
+// objects of r (see Writer.Write), and references to r's objects keep
+// their object numbers, so only new and changed objects are written.
+// The original bytes are preserved, as needed to keep existing
+// signatures valid.
+//
+// Updating an encrypted file is not supported, nor is updating a file
+// whose cross-reference table was rebuilt because it was damaged (see
+// Problems), or one read by NewReaderSequential; WriteTo writes such a
+// file anew.
+func (r *Reader) Update(w io.Writer) (*Writer, error) {
+	if r.key != nil {
+		return nil, errors.New("pdf: incremental update of encrypted file not supported")
+	}
+	if r.repaired {
+		return nil, errors.New("pdf: incremental update of file with rebuilt cross-reference table not supported")
+	}
+	if r.sequential {
+		return nil, errSequential
+	}
+	table, err := r.xrefTable(allObjects)
+	if err != nil {
+		return nil, err
+	}
+	pw := &Writer{
+		w:       w,
+		offsets: make([]int64, len(table)),
+		copied:  make(map[copyKey]uint32),
+		update:  r,
+		base:    uint32(len(table)),
+		gens:    make(map[uint32]uint16),
+		prev:    r.startxref,
+	}
+	if pw.base == 0 {
+		pw.offsets, pw.base = []int64{0}, 1
+	}
+	n, err := io.Copy(w, io.NewSectionReader(r.f, 0, r.end))
+	pw.n, pw.err = n, err
+	// The update must start on a new line.
+	var last [1]byte
+	if r.end > 0 {
+		r.f.ReadAt(last[:], r.end-1)
+	}
+	if last[0] != '\n' && last[0] != '\r' {
+		pw.write([]byte("\n"))
+	}
+	return pw, pw.err
+}
+
+// WriteTo writes the document read by r to w as a new PDF file,
+// copying the objects reachable from the trailer's Root and Info.
+// Objects that are no longer used, such as those replaced by incremental
+// updates, are dropped, and the result is unencrypted.
+// It implements io.WriterTo.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	pw := NewWriter(w)
+	trailer, err := r.copyTrailer()
+	if err != nil {
+		return pw.n, err
+	}
+	err = pw.Close(trailer)
+	return pw.n, err
+}
+
+// copyTrailer returns a trailer for a new file holding the document
+// read by r, with r's Root, Info, and ID.
+func (r *Reader) copyTrailer() (Value, error) {
+	trailer := make(map[string]Value)
+	for _, k := range []string{"Root", "Info", "ID"} {
+		v, err := r.Trailer().Key(k)
+		if err != nil {
+			return Value{}, err
+		}
+		if !v.IsNull() {
+			trailer[k] = Value{r, r.trailerptr, r.trailer[name(k)]}
+		}
+	}
+	return NewDict(trailer), nil
+}
+
+// NewDict returns a dictionary Value with the given entries,
+// for writing with a Writer. The entries may be Values read from a
+// Reader, Values built with the New functions, or references
+// returned by a Writer. Null entries are omitted.
+func NewDict(entries map[string]Value) Value {
+	d := make(dict, len(entries))
+	for k, v := range entries {
+		if v.data != nil {
+			d[name(k)] = v
+		}
+	}
+	return Value{data: d}
+}
+
+// NewArray returns an array Value with the given elements,
+// for writing with a Writer.
+func NewArray(elems ...Value) Value {
+	a := make(array, len(elems))
+	for i, v := range elems {
+		a[i] = v
+	}
+	return Value{data: a}
+}
+
+// NewStream returns a stream Value with the dictionary hdr, which may be
+// null, and the given data, for writing with a Writer. The data must
+// already be encoded as described by hdr's Filter entry.
+// Length is set by the Writer.
+func NewStream(hdr Value, data []byte) Value {
+	d := make(dict)
+	for _, k := range hdr.Keys() {
+		v, err := hdr.Key(k)
+		if err == nil && !v.IsNull() {
+			d[name(k)] = v
+		}
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return Value{data: stream{hdr: d, data: data}}
+}
+
+// NewName returns a name Value.
+func NewName(s string) Value {
+	return Value{data: name(s)}
+}
+
+// NewInt returns an integer Value.
+func NewInt(i int64) Value {
+	return Value{data: i}
+}
+
+// NewReal returns a real number Value.
+func NewReal(f float64) Value {
+	return Value{data: f}
+}
+
+// NewBool returns a boolean Value.
+func NewBool(b bool) Value {
+	return Value{data: b}
+}
+
+// NewString returns a string Value holding the bytes s as they are.
+func NewString(s string) Value {
+	return Value{data: s}
+}
+
+// NewText returns a string Value holding the text s as a PDF text string:
+// as is if s is printable ASCII, and in UTF-16 otherwise.
+// Value.Text returns s.
+func NewText(s string) Value {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 && s[i] != '\n' && s[i] != '\r' && s[i] != '\t' || s[i] >= 0x7f {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return Value{data: s}
+	}
+	return Value{data: "\xfe\xff" + utf16Encode(s)}
+}
@@ -0,0 +1,151 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// An XFAPacket is one part of the XML Forms Architecture (XFA) form
+// of a document, such as its template, datasets, or config.
+type XFAPacket struct {
+	Name string // the packet name, such as "template", "datasets", or "config"
+	Data []byte // the packet's XML
+}
+
+// XFA holds the XFA form of a document: an XML Data Package (XDP)
+// divided into packets. The first and last packets are usually
+// "preamble" and "postamble", holding the start and end tags of
+// the enclosing xdp:xdp element.
+// See PDF 32000-1:2008, §12.7.8.
+type XFA struct {
+	Packets []XFAPacket
+}
+
+// XFA returns the XFA form of the document (the XFA entry of its
+// interactive form dictionary). If the document has no XFA form,
+// XFA returns an XFA with no packets.
+//
+// The XFA entry is either an array of packet names and streams or a
+// single stream holding the whole XDP document; in the second case,
+// XFA divides the document into packets at the children of its
+// root element.
+func (r *Reader) XFA() (XFA, error) {
+	root, err := r.Trailer().Key("Root")
+	if err != nil {
+		return XFA{}, err
+	}
+	form, err := root.Key("AcroForm")
+	if err != nil {
+		return XFA{}, err
+	}
+	xfa, err := form.Key("XFA")
+	if err != nil {
+		return XFA{}, err
+	}
+	switch xfa.Kind() {
+	case Stream:
+		data, err := streamData(xfa)
+		if err != nil {
+			return XFA{}, err
+		}
+		return XFA{splitXDP(data)}, nil
+	case Array:
+		var x XFA
+		for i := 0; i+1 < xfa.Len(); i += 2 {
+			name, err := xfa.Index(i)
+			if err != nil {
+				return XFA{}, err
+			}
+			s, err := xfa.Index(i + 1)
+			if err != nil {
+				return XFA{}, err
+			}
+			if s.Kind() != Stream {
+				continue
+			}
+			data, err := streamData(s)
+			if err != nil {
+				return XFA{}, err
+			}
+			x.Packets = append(x.Packets, XFAPacket{Name: name.Text(), Data: data})
+		}
+		return x, nil
+	}
+	return XFA{}, nil
+}
+
+// Packet returns the XML of the named packet, concatenating the parts
+// if the packet is split across several streams.
+// It returns nil if there is no such packet.
+func (x XFA) Packet(name string) []byte {
+	var out []byte
+	for _, p := range x.Packets {
+		if p.Name == name {
+			out = append(out, p.Data...)
+		}
+	}
+	return out
+}
+
+// XDP returns the whole XDP document: all the packets, concatenated.
+func (x XFA) XDP() []byte {
+	var out []byte
+	for _, p := range x.Packets {
+		out = append(out, p.Data...)
+	}
+	return out
+}
+
+// splitXDP divides the XDP document data into packets, one for each
+// child of the root element, with the text before the first child as
+// the preamble and the text after the last as the postamble.
+// If data cannot be parsed, splitXDP returns it as a single packet
+// named "xdp".
+func splitXDP(data []byte) []XFAPacket {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	var packets []XFAPacket
+	depth := 0
+	start := int64(0) // start of the current packet
+	for {
+		off := d.InputOffset()
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return []XFAPacket{{Name: "xdp", Data: data}}
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				if len(packets) == 0 {
+					packets = append(packets, XFAPacket{Name: "preamble", Data: data[:off]})
+				} else {
+					// White space between packets stays with the one before.
+					last := &packets[len(packets)-1]
+					last.Data = data[start-int64(len(last.Data)) : off]
+				}
+				packets = append(packets, XFAPacket{Name: tok.Name.Local})
+				start = off
+			}
+		case xml.EndElement:
+			depth--
+			if depth == 1 {
+				end := d.InputOffset()
+				packets[len(packets)-1].Data = data[start:end]
+				start = end
+			}
+		}
+	}
+	if depth != 0 || len(packets) == 0 {
+		return []XFAPacket{{Name: "xdp", Data: data}}
+	}
+	return append(packets, XFAPacket{Name: "postamble", Data: data[start:]})
+}
@@ -0,0 +1,254 @@
+// Copyright 2014 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XMP holds the document properties recorded in XMP metadata,
+// from the Dublin Core, XMP Basic, Adobe PDF, and PDF/A
+// identification schemas. Text that XMP gives in several languages
+// is returned in the default language.
+type XMP struct {
+	Title       string   // dc:title
+	Creators    []string // dc:creator: the authors
+	Description string   // dc:description
+	Subjects    []string // dc:subject: keywords
+	Rights      string   // dc:rights
+	Format      string   // dc:format: the MIME type, usually application/pdf
+
+	CreatorTool  string    // xmp:CreatorTool: application that created the original document
+	CreateDate   time.Time // xmp:CreateDate
+	ModifyDate   time.Time // xmp:ModifyDate
+	MetadataDate time.Time // xmp:MetadataDate
+
+	Keywords   string // pdf:Keywords
+	Producer   string // pdf:Producer: application that converted the document to PDF
+	PDFVersion string // pdf:PDFVersion
+
+	PDFAPart        int    // pdfaid:part: the PDF/A part the document claims to conform to, or 0
+	PDFAConformance string // pdfaid:conformance: the PDF/A conformance level, such as "B"
+}
+
+const (
+	nsRDF    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC     = "http://purl.org/dc/elements/1.1/"
+	nsXMP    = "http://ns.adobe.com/xap/1.0/"
+	nsPDF    = "http://ns.adobe.com/pdf/1.3/"
+	nsPDFAID = "http://www.aiim.org/pdfa/ns/id/"
+	nsXML    = "http://www.w3.org/XML/1998/namespace"
+)
+
+// XMP returns the properties in the document's XMP metadata
+// (see Reader.Metadata). If the document has no metadata stream,
+// XMP returns the corresponding entries of the document information
+// dictionary instead (see Reader.Info).
+func (r *Reader) XMP() (XMP, error) {
+	data, ok := r.Metadata()
+	if ok {
+		return ParseXMP(data)
+	}
+	info, err := r.Info()
+	if err != nil {
+		return XMP{}, err
+	}
+	x := XMP{
+		Title:       info.Title,
+		Description: info.Subject,
+		Keywords:    info.Keywords,
+		CreatorTool: info.Creator,
+		Producer:    info.Producer,
+		CreateDate:  info.CreationDate,
+		ModifyDate:  info.ModDate,
+	}
+	if info.Author != "" {
+		x.Creators = []string{info.Author}
+	}
+	return x, nil
+}
+
+// ParseXMP parses the XMP metadata packet data.
+// Properties that are missing or cannot be parsed are left zero.
+func ParseXMP(data []byte) (XMP, error) {
+	props, err := xmpProperties(data)
+	if err != nil {
+		return XMP{}, err
+	}
+	first := func(ns, local string) string {
+		if v := props[xml.Name{Space: ns, Local: local}]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	date := func(local string) time.Time {
+		t, _ := parseXMPDate(first(nsXMP, local))
+		return t
+	}
+	x := XMP{
+		Title:        first(nsDC, "title"),
+		Creators:     props[xml.Name{Space: nsDC, Local: "creator"}],
+		Description:  first(nsDC, "description"),
+		Subjects:     props[xml.Name{Space: nsDC, Local: "subject"}],
+		Rights:       first(nsDC, "rights"),
+		Format:       first(nsDC, "format"),
+		CreatorTool:  first(nsXMP, "CreatorTool"),
+		CreateDate:   date("CreateDate"),
+		ModifyDate:   date("ModifyDate"),
+		MetadataDate: date("MetadataDate"),
+		Keywords:     first(nsPDF, "Keywords"),
+		Producer:     first(nsPDF, "Producer"),
+		PDFVersion:   first(nsPDF, "PDFVersion"),
+	}
+	x.PDFAPart, _ = strconv.Atoi(first(nsPDFAID, "part"))
+	x.PDFAConformance = first(nsPDFAID, "conformance")
+	return x, nil
+}
+
+// xmpProperties returns the values of the properties of the
+// rdf:Description elements in data. A property given as an rdf:Alt,
+// rdf:Bag, or rdf:Seq has a value for each item, with any item in the
+// default language (x-default) first.
+func xmpProperties(data []byte) (map[xml.Name][]string, error) {
+	props := make(map[xml.Name][]string)
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return props, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed XMP: %v", err)
+		}
+		desc, ok := tok.(xml.StartElement)
+		if !ok || desc.Name != (xml.Name{Space: nsRDF, Local: "Description"}) {
+			continue
+		}
+		// Simple properties may be written as attributes.
+		for _, a := range desc.Attr {
+			if a.Name.Space != nsRDF && a.Name.Space != "xmlns" && a.Name.Space != "" {
+				props[a.Name] = append(props[a.Name], a.Value)
+			}
+		}
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return nil, fmt.Errorf("malformed XMP: %v", err)
+			}
+			if _, ok := tok.(xml.EndElement); ok {
+				break
+			}
+			if prop, ok := tok.(xml.StartElement); ok {
+				vals, err := xmpValues(d)
+				if err != nil {
+					return nil, err
+				}
+				props[prop.Name] = append(props[prop.Name], vals...)
+			}
+		}
+	}
+}
+
+// xmpValues reads the content of a property element, through its end,
+// and returns its values.
+func xmpValues(d *xml.Decoder) ([]string, error) {
+	var text strings.Builder
+	var vals []string
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, fmt.Errorf("malformed XMP: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if tok.Name != (xml.Name{Space: nsRDF, Local: "li"}) {
+				continue
+			}
+			var item strings.Builder
+			if err := xmlText(d, &item); err != nil {
+				return nil, err
+			}
+			depth--
+			v := strings.TrimSpace(item.String())
+			isDefault := false
+			for _, a := range tok.Attr {
+				if a.Name == (xml.Name{Space: nsXML, Local: "lang"}) && a.Value == "x-default" {
+					isDefault = true
+				}
+			}
+			if isDefault {
+				vals = append([]string{v}, vals...)
+			} else {
+				vals = append(vals, v)
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				if vals == nil {
+					if v := strings.TrimSpace(text.String()); v != "" {
+						vals = []string{v}
+					}
+				}
+				return vals, nil
+			}
+			depth--
+		case xml.CharData:
+			if depth == 0 {
+				text.Write(tok)
+			}
+		}
+	}
+}
+
+// xmlText appends the text in the element whose start was just read
+// to b, through the element's end.
+func xmlText(d *xml.Decoder, b *strings.Builder) error {
+	for depth := 0; ; {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("malformed XMP: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		case xml.CharData:
+			b.Write(tok)
+		}
+	}
+}
+
+// parseXMPDate parses an XMP date: an ISO 8601 date and time,
+// given to any precision from the year to fractions of a second.
+// Times without a time zone are taken to be in UTC.
+func parseXMPDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid XMP date %q", s)
+}